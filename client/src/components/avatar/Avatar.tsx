@@ -1,6 +1,7 @@
 import React from "react";
 import type { AvatarProps } from "./types";
 import imgAvatar from "../../assets/img.jpg"
+import { useImage } from "../../hooks/api/useImage";
 
 const sizeClasses: Record<"sm" | "md" | "lg", string> = {
   sm: "w-8 h-8 text-sm",
@@ -15,10 +16,11 @@ const Avatar: React.FC<AvatarProps> = ({
   rounded = true,
   online = false,
 }) => {
+  const { src: resolvedSrc } = useImage(src);
   return (
     <div className="relative">
       <img
-        src={src || imgAvatar}
+        src={resolvedSrc || imgAvatar}
         alt={alt}
         className={`${sizeClasses[size]} ${
           rounded ? "rounded-full" : "rounded-md"
@@ -3,8 +3,8 @@ import { useEffect, useState } from "react";
 import { Search, UserPlus, Check, Loader2, X, Clock, Users, UserMinus, MessageCircle } from "lucide-react";
 import type { UserResponse } from "../../types/UserResponse";
 import { findUserByEmail } from "../../services/user/userService";
-import { getAuthToken } from "../../utils/Auth";
 import { fetchAddFriend, cancelFriendRequest, acceptFriendRequest, rejectFriendRequest } from "../../api/friends";
+import { useImage } from "../../hooks/api/useImage";
 import { useListInvite } from "../../hooks/api/useListInvite";
 import { useListFriend } from "../../hooks/api/useListFriend";
 import { useSocket } from "../../hooks/socket/useSocket";
@@ -36,11 +36,25 @@ interface PopupFriendsManagerProps {
         email: string;
         avatar?: string;
         status?: FriendStatus;
-        token?: string;
         receiverType?: 'incoming' | 'outgoing'; // Whether user sent or received the friend request
     }[];
 }
 
+// UserAvatar hiển thị avatar qua signed URL, chưa có avatar thì dùng ảnh tạo từ tên
+const UserAvatar = ({ avatar, name }: { avatar?: string; name?: string }) => {
+    const { src } = useImage(avatar);
+    const avatarSrc = src || `https://ui-avatars.com/api/?name=${encodeURIComponent(
+        String(name || "")
+    )}&background=1e293b&color=fff`;
+    return (
+        <img
+            src={avatarSrc}
+            alt="avatar"
+            className="w-10 h-10 rounded-full object-cover"
+        />
+    );
+};
+
 export const PopupFriendsManager = ({
     show,
     onClose,
@@ -148,7 +162,6 @@ export const PopupFriendsManager = ({
                     name: userFound.name || "Unknown",
                     email: userFound.email,
                     avatar: userFound.avatar,
-                    token: getAuthToken() || undefined,
                     status: initialStatus
                 }]);
             }
@@ -258,7 +271,6 @@ export const PopupFriendsManager = ({
         email: invite.email || "",
         avatar: invite.avatar,
         status: 'pending' as FriendStatus,
-        token: getAuthToken() || undefined,
         receiverType: 'incoming' as const
     });
 
@@ -269,7 +281,6 @@ export const PopupFriendsManager = ({
         email: friend.email || "",
         avatar: friend.avatar,
         status: 'friend' as FriendStatus, // Use 'friend' status for accepted friends
-        token: getAuthToken() || undefined,
         receiverType: undefined
     });
 
@@ -385,23 +396,7 @@ export const PopupFriendsManager = ({
             className="flex items-center justify-between bg-gray-800/50 p-3 rounded-xl hover:bg-gray-800 transition"
         >
             <div className="flex items-center gap-3">
-                {(() => {
-                    const hasAvatar = !!user.avatar;
-                    const avatarSrc = hasAvatar
-                        ? `${import.meta.env.VITE_API_URL}/protected/?filename=${encodeURIComponent(
-                            String(user.avatar)
-                        )}&token=${encodeURIComponent(String(user.token ?? ""))}`
-                        : `https://ui-avatars.com/api/?name=${encodeURIComponent(
-                            String(user.name || "")
-                        )}&background=1e293b&color=fff`;
-                    return (
-                        <img
-                            src={avatarSrc}
-                            alt="avatar"
-                            className="w-10 h-10 rounded-full object-cover"
-                        />
-                    );
-                })()}
+                <UserAvatar avatar={user.avatar} name={user.name} />
                 <div>
                     <h4 className="font-semibold text-[15px]">{user.name}</h4>
                     <p className="text-sm text-gray-400">{user.email}</p>
@@ -1,4 +1,5 @@
 import { useState, useEffect } from "react";
+import { signImageUrl } from "../../utils/image";
 
 export function useImage(url?: string) {
   const [src, setSrc] = useState<string | null>(null);
@@ -8,9 +9,8 @@ export function useImage(url?: string) {
   useEffect(() => {
     if (!url) return;
 
+    let cancelled = false;
     const img = new Image();
-    img.src = `${import.meta.env.VITE_API_URL}/protected?filename=${encodeURIComponent(url)}&token=${localStorage.getItem("access_token") || ""}`;
-    console.log("Loading image from URL:", img.src);
     img.onload = () => {
       setSrc(img.src);
       setLoadingImage(false);
@@ -21,8 +21,19 @@ export function useImage(url?: string) {
       setLoadingImage(false);
     };
 
+    signImageUrl(url)
+      .then((signed) => {
+        if (!cancelled) img.src = signed;
+      })
+      .catch(() => {
+        if (cancelled) return;
+        setError(true);
+        setLoadingImage(false);
+      });
+
     return () => {
       // cleanup khi component unmount
+      cancelled = true;
       img.onload = null;
       img.onerror = null;
     };
@@ -17,7 +17,6 @@ import { PopupFriendsManager } from "../components/modals/FriendsManager";
 import NotificationBell from "../components/notify/NotificationBell";
 import { fetchAddFriend } from "../api/friends";
 import { convertUtcToDatePart, TypeDate } from "../utils/date";
-import { defaultProxyImageUrl } from "../utils/image";
 
 import { useConversation } from "../hooks/chat/useConversation";
 import { useNavigate } from "react-router-dom";
@@ -295,7 +294,7 @@ export default function HomeChat() {
 
                     <Avatar src={
                       chat.id === "conversation_admin_default" ? avatarDefault :
-                        defaultProxyImageUrl(chat.participants.find(p => p.user_id !== user?.id)?.user.avatar || "")
+                        chat.participants.find(p => p.user_id !== user?.id)?.user.avatar || ""
                     } size="lg" online={chat.participants.find(p => p.user_id === user?.id)?.user.status === "true"} />
                   </div>
                   <span className={`text-xs truncate w-16 text-center ${selected?.id === chat.id ? 'font-semibold text-blue-500' : 'text-gray-600 dark:text-gray-400'}`}>
@@ -310,7 +309,7 @@ export default function HomeChat() {
               <ChatView onUpdateLastMessage={handleUpdateLastMessage}
                 id={selected.id.toString()} chats={selected?.messages} name={selected.name} img={
                   selected.id === "conversation_admin_default" ? avatarDefault :
-                    defaultProxyImageUrl(selected.participants.find(p => p.user_id !== user?.id)?.user.avatar || "")
+                    selected.participants.find(p => p.user_id !== user?.id)?.user.avatar || ""
                 } is_mobile={isMobile} />
             ) : (
               <div className="flex flex-col items-center justify-center h-full text-center px-6">
@@ -390,7 +389,7 @@ export default function HomeChat() {
                 >
                   <Avatar src={
                     chat.id === "conversation_admin_default" ? avatarDefault :
-                      defaultProxyImageUrl(chat.participants.find(p => p.user_id !== user?.id)?.user.avatar || "")
+                      chat.participants.find(p => p.user_id !== user?.id)?.user.avatar || ""
                   } size="lg" online={chat.participants.find(p => p.user_id === user?.id)?.user.status === "true"} />
                   <div className="flex-1 min-w-0">
                     <div className="flex justify-between items-center">
@@ -415,7 +414,7 @@ export default function HomeChat() {
               <ChatView id={selected.id.toString()} userInfor={selected.participants.find(p => p.user_id !== user?.id)?.user} name={selected.participants.find(p => p.user_id !== user?.id)?.user.name || "Unknown User"}
                 img={
                   selected.id === "conversation_admin_default" ? avatarDefault :
-                    defaultProxyImageUrl(selected.participants.find(p => p.user_id !== user?.id)?.user.avatar || "")
+                    selected.participants.find(p => p.user_id !== user?.id)?.user.avatar || ""
                 } onUpdateLastMessage={handleUpdateLastMessage}
               />
             ) : (
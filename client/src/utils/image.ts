@@ -1,5 +1,22 @@
+import api from "../api/api";
 
+// Avatar trong storage của server cần signed URL, ảnh ngoài (Google, asset) dùng trực tiếp
+const AVATAR_PATH_PREFIX = "/api/v1/avatars/";
+// Lấy URL mới trước khi URL cũ hết hạn
+const EXPIRY_MARGIN_MS = 30_000;
 
-export const defaultProxyImageUrl = (url: string) => {
-  return `${import.meta.env.VITE_API_URL}/protected?filename=${encodeURIComponent(url)}&token=${localStorage.getItem("access_token") || ""}`;
-}
\ No newline at end of file
+const signedUrls = new Map<string, { url: string; expiresAt: number }>();
+
+export const signImageUrl = async (src: string): Promise<string> => {
+  if (!src.startsWith(AVATAR_PATH_PREFIX)) return src;
+
+  const cached = signedUrls.get(src);
+  if (cached && cached.expiresAt - EXPIRY_MARGIN_MS > Date.now()) return cached.url;
+
+  const { data } = await api.get<{ url: string; expires_at?: string }>("/users/avatar-url", { params: { src } });
+  const url = new URL(data.url, import.meta.env.VITE_API_URL).toString();
+  if (data.expires_at) {
+    signedUrls.set(src, { url, expiresAt: new Date(data.expires_at).getTime() });
+  }
+  return url;
+}
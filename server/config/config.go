@@ -0,0 +1,622 @@
+// Package config đọc cấu hình của server vào struct có kiểu.
+// Thứ tự ưu tiên (sau ghi đè trước): giá trị mặc định theo profile < file YAML < biến môi trường.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile là môi trường chạy, chọn bằng biến ENV
+const (
+	Development = "development"
+	Test        = "test"
+	Production  = "production"
+)
+
+// minPartSize là kích thước part tối thiểu của S3 multipart upload (storage.MinPartSize)
+const minPartSize = 5 << 20
+
+// devJWTSecret chỉ dùng khi chạy local, production bắt buộc cấu hình JWT_SECRET_KEY
+const devJWTSecret = "my-super-secret-key-for-dev"
+
+type Config struct {
+	Env          string             `yaml:"env" env:"ENV"`
+	Server       ServerConfig       `yaml:"server"`
+	Database     DatabaseConfig     `yaml:"database"`
+	Redis        RedisConfig        `yaml:"redis"`
+	Auth         AuthConfig         `yaml:"auth"`
+	Google       GoogleConfig       `yaml:"google"`
+	Metrics      MetricsConfig      `yaml:"metrics"`
+	Log          LogConfig          `yaml:"log"`
+	Tracing      TracingConfig      `yaml:"tracing"`
+	FileURL      FileURLConfig      `yaml:"file_url"`
+	Storage      StorageConfig      `yaml:"storage"`
+	Upload       UploadConfig       `yaml:"upload"`
+	Image        ImageConfig        `yaml:"image"`
+	Scan         ScanConfig         `yaml:"scan"`
+	Mail         MailConfig         `yaml:"mail"`
+	OIDC         OIDCConfig         `yaml:"oidc"`
+	Login        LoginConfig        `yaml:"login"`
+	MagicLink    MagicLinkConfig    `yaml:"magic_link"`
+	Verification VerificationConfig `yaml:"verification"`
+}
+
+type ServerConfig struct {
+	Port        string `yaml:"port" env:"PORT"`
+	BaseURL     string `yaml:"base_url" env:"BASE_URL,DEFAULT_URL_SERVER"`
+	FrontendURL string `yaml:"frontend_url" env:"FRONTEND_URL,DEFAULT_URL"`
+	// ShutdownTimeout: thời gian tối đa chờ request đang xử lý khi nhận SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// HealthCheckTimeout: timeout của mỗi check trong /readyz (database, redis, migrations, storage)
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host" env:"DB_HOST"`
+	Port     string `yaml:"port" env:"DB_PORT"`
+	User     string `yaml:"user" env:"DB_USER"`
+	Password string `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name     string `yaml:"name" env:"DB_NAME"`
+	SSLMode  string `yaml:"sslmode" env:"DB_SSLMODE"`
+	// AutoMigrate chạy migration khi khởi động, tắt nếu deploy chạy "migrate up" riêng
+	AutoMigrate bool `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+	// QueryTimeout: thời gian tối đa của mỗi câu lệnh SQL, deadline của request (nếu sớm hơn) vẫn được giữ
+	QueryTimeout time.Duration `yaml:"query_timeout" env:"DB_QUERY_TIMEOUT"`
+}
+
+// DSN là connection string tới database name
+func (d DatabaseConfig) DSN() string {
+	return d.dsn(d.Name)
+}
+
+// AdminDSN kết nối tới database mặc định "postgres", dùng để tạo database nếu chưa có
+func (d DatabaseConfig) AdminDSN() string {
+	return d.dsn("postgres")
+}
+
+func (d DatabaseConfig) dsn(name string) string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		d.Host, d.Port, d.User, d.Password, name, d.SSLMode)
+}
+
+type RedisConfig struct {
+	Host     string `yaml:"host" env:"REDIS_HOST"`
+	Port     string `yaml:"port" env:"REDIS_PORT"`
+	Password string `yaml:"password" env:"REDIS_PASSWORD" secret:"true"`
+	DB       int    `yaml:"db" env:"REDIS_DB"`
+	// CommandTimeout: thời gian tối đa của mỗi lệnh Redis
+	CommandTimeout time.Duration `yaml:"command_timeout" env:"REDIS_COMMAND_TIMEOUT"`
+}
+
+func (r RedisConfig) Addr() string {
+	return net.JoinHostPort(r.Host, r.Port)
+}
+
+type AuthConfig struct {
+	JWTSecret string `yaml:"jwt_secret" env:"JWT_SECRET_KEY" secret:"true"`
+}
+
+type GoogleConfig struct {
+	ClientID     string `yaml:"client_id" env:"GOOGLE_CLIENT_ID"`
+	ClientSecret string `yaml:"client_secret" env:"GOOGLE_CLIENT_SECRET" secret:"true"`
+	RedirectURL  string `yaml:"redirect_url" env:"GOOGLE_REDIRECT_URL"` // mặc định BaseURL + /api/auth/google/callback
+}
+
+// MetricsConfig: /metrics chạy trên listener riêng (Addr, chỉ mở trong mạng nội bộ)
+// hoặc trên port chính và bắt buộc Bearer token
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled" env:"METRICS_ENABLED"`
+	Addr    string `yaml:"addr" env:"METRICS_ADDR"` // vd ":9090", rỗng = dùng port chính
+	Token   string `yaml:"token" env:"METRICS_TOKEN" secret:"true"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL"`   // debug | info | warn | error
+	Format string `yaml:"format" env:"LOG_FORMAT"` // text | json
+}
+
+// TracingConfig: span được gửi qua OTLP/HTTP tới collector (Jaeger, Tempo...)
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled" env:"TRACING_ENABLED"`
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"` // vd "http://otel-collector:4318", rỗng = mặc định của SDK
+	ServiceName string  `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"` // 0..1, áp dụng cho trace mới (không có parent)
+}
+
+// FileURLConfig: signed URL tải attachment và avatar (dùng được trong <img>/<video> không cần header Authorization)
+type FileURLConfig struct {
+	Secret string        `yaml:"secret" env:"FILE_URL_SECRET" secret:"true"` // mặc định dùng auth.jwt_secret
+	TTL    time.Duration `yaml:"ttl" env:"FILE_URL_TTL"`
+}
+
+// StorageConfig: driver "local" lưu ra disk, "s3" dùng S3/Spaces/MinIO. Rỗng = s3 khi có access key.
+type StorageConfig struct {
+	Driver     string          `yaml:"driver" env:"STORAGE_DRIVER"`
+	LocalDir   string          `yaml:"local_dir" env:"STORAGE_LOCAL_DIR"`
+	PublicURL  string          `yaml:"public_url" env:"STORAGE_PUBLIC_URL"`                 // mặc định BaseURL + /api/v1/storage
+	SigningKey string          `yaml:"signing_key" env:"STORAGE_SIGNING_KEY" secret:"true"` // mặc định dùng auth.jwt_secret
+	S3         S3Config        `yaml:"s3"`
+	GC         StorageGCConfig `yaml:"gc"`
+}
+
+// S3Config đọc S3_* (ưu tiên) hoặc SPACES_* cho DigitalOcean Spaces
+type S3Config struct {
+	AccessKey    string `yaml:"access_key" env:"S3_ACCESS_KEY,SPACES_KEY" secret:"true"`
+	SecretKey    string `yaml:"secret_key" env:"S3_SECRET_KEY,SPACES_SECRET" secret:"true"`
+	Region       string `yaml:"region" env:"S3_REGION,SPACES_REGION"`
+	Endpoint     string `yaml:"endpoint" env:"S3_ENDPOINT,SPACES_ENDPOINT"` // để trống với AWS S3
+	Bucket       string `yaml:"bucket" env:"S3_BUCKET,SPACES_BUCKET"`
+	UsePathStyle bool   `yaml:"use_path_style" env:"S3_USE_PATH_STYLE"` // MinIO cần path-style URL
+}
+
+// StorageGCConfig: dọn attachment/blob không còn được dùng
+type StorageGCConfig struct {
+	Interval  time.Duration `yaml:"interval" env:"STORAGE_GC_INTERVAL"`
+	Grace     time.Duration `yaml:"grace" env:"STORAGE_GC_GRACE"` // chỉ dọn object cũ hơn khoảng này
+	BatchSize int           `yaml:"batch_size" env:"STORAGE_GC_BATCH"`
+}
+
+// UploadConfig: kích thước tính bằng bytes, 0 = không giới hạn
+type UploadConfig struct {
+	URLTTL             time.Duration `yaml:"url_ttl" env:"UPLOAD_URL_TTL"`
+	MultipartThreshold int64         `yaml:"multipart_threshold" env:"UPLOAD_MULTIPART_THRESHOLD"`
+	PartSize           int64         `yaml:"part_size" env:"UPLOAD_PART_SIZE"`
+	// AllowedTypes hỗ trợ wildcard "video/*", rỗng = cho phép mọi type
+	AllowedTypes []string `yaml:"allowed_types" env:"UPLOAD_ALLOWED_TYPES"`
+	MaxImageSize int64    `yaml:"max_image_size" env:"UPLOAD_MAX_IMAGE_SIZE"`
+	MaxVideoSize int64    `yaml:"max_video_size" env:"UPLOAD_MAX_VIDEO_SIZE"`
+	MaxAudioSize int64    `yaml:"max_audio_size" env:"UPLOAD_MAX_AUDIO_SIZE"`
+	MaxFileSize  int64    `yaml:"max_file_size" env:"UPLOAD_MAX_FILE_SIZE"`
+	UserQuota    int64    `yaml:"user_quota" env:"USER_STORAGE_QUOTA"`
+}
+
+type ImageConfig struct {
+	Workers   int `yaml:"workers" env:"IMAGE_WORKERS"`
+	QueueSize int `yaml:"queue_size" env:"IMAGE_QUEUE_SIZE"`
+	MaxPixels int `yaml:"max_pixels" env:"IMAGE_MAX_PIXELS"`
+}
+
+// ScanConfig: quét malware file upload, driver "noop" coi mọi file là sạch
+type ScanConfig struct {
+	Driver          string        `yaml:"driver" env:"SCANNER_DRIVER"`
+	ClamAVAddr      string        `yaml:"clamav_addr" env:"CLAMAV_ADDR"`
+	ClamAVTimeout   time.Duration `yaml:"clamav_timeout" env:"CLAMAV_TIMEOUT"`
+	Workers         int           `yaml:"workers" env:"SCAN_WORKERS"`
+	QueueSize       int           `yaml:"queue_size" env:"SCAN_QUEUE_SIZE"`
+	Timeout         time.Duration `yaml:"timeout" env:"SCAN_TIMEOUT"`
+	MaxRetries      int           `yaml:"max_retries" env:"SCAN_MAX_RETRIES"`
+	RequeueInterval time.Duration `yaml:"requeue_interval" env:"SCAN_REQUEUE_INTERVAL"`
+}
+
+// MailConfig: driver "smtp", "file" (ghi ra FileDir để test offline) hoặc "memory". Rỗng = smtp khi có smtp.email.
+type MailConfig struct {
+	Driver       string     `yaml:"driver" env:"MAIL_DRIVER"`
+	From         string     `yaml:"from" env:"MAIL_FROM"` // mặc định smtp.email
+	FileDir      string     `yaml:"file_dir" env:"MAIL_FILE_DIR"`
+	QueueWorkers int        `yaml:"queue_workers" env:"MAIL_QUEUE_WORKERS"`
+	QueueSize    int        `yaml:"queue_size" env:"MAIL_QUEUE_SIZE"`
+	MaxRetries   int        `yaml:"max_retries" env:"MAIL_MAX_RETRIES"`
+	SMTP         SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" env:"SMTP_HOST"`
+	Port     string `yaml:"port" env:"SMTP_PORT"`
+	Email    string `yaml:"email" env:"SMTP_EMAIL"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+// OIDCConfig: Providers là các provider được bật, cấu hình từng provider trong Clients
+// hoặc biến OIDC_<NAME>_* (vd OIDC_GITHUB_CLIENT_ID)
+type OIDCConfig struct {
+	Providers []string                      `yaml:"providers" env:"OIDC_PROVIDERS"`
+	Clients   map[string]OIDCProviderConfig `yaml:"clients"`
+}
+
+// OIDCProviderConfig: provider có sẵn preset (github, gitlab, google, microsoft, keycloak) chỉ cần client id/secret.
+// Tag env là phần sau tiền tố OIDC_<NAME>_.
+type OIDCProviderConfig struct {
+	ClientID     string   `yaml:"client_id" env:"CLIENT_ID"`
+	ClientSecret string   `yaml:"client_secret" env:"CLIENT_SECRET" secret:"true"`
+	Issuer       string   `yaml:"issuer" env:"ISSUER"`
+	AuthURL      string   `yaml:"auth_url" env:"AUTH_URL"`
+	TokenURL     string   `yaml:"token_url" env:"TOKEN_URL"`
+	UserInfoURL  string   `yaml:"userinfo_url" env:"USERINFO_URL"`
+	Scopes       []string `yaml:"scopes" env:"SCOPES"`
+	RedirectURL  string   `yaml:"redirect_url" env:"REDIRECT_URL"` // mặc định BaseURL + /api/v1/auth/oidc/<name>/callback
+	DisplayName  string   `yaml:"display_name" env:"DISPLAY_NAME"`
+	DisablePKCE  bool     `yaml:"disable_pkce" env:"DISABLE_PKCE"`
+}
+
+// LoginConfig: chống brute-force đăng nhập bằng mật khẩu
+type LoginConfig struct {
+	MaxAttempts      int           `yaml:"max_attempts" env:"LOGIN_MAX_ATTEMPTS"`
+	MaxAttemptsPerIP int           `yaml:"max_attempts_per_ip" env:"LOGIN_MAX_ATTEMPTS_PER_IP"`
+	LockoutDuration  time.Duration `yaml:"lockout_duration" env:"LOGIN_LOCKOUT_DURATION"`
+	AttemptWindow    time.Duration `yaml:"attempt_window" env:"LOGIN_ATTEMPT_WINDOW"`
+}
+
+type MagicLinkConfig struct {
+	TTL            time.Duration `yaml:"ttl" env:"MAGIC_LINK_TTL"`
+	ResendInterval time.Duration `yaml:"resend_interval" env:"MAGIC_LINK_RESEND_INTERVAL"`
+}
+
+// VerificationConfig: RequiredFor là các tính năng cần email đã xác thực (friend_invite, send_message, upload), "none" = không giới hạn
+type VerificationConfig struct {
+	RequiredFor    []string      `yaml:"required_for" env:"EMAIL_VERIFICATION_REQUIRED_FOR"`
+	ResendInterval time.Duration `yaml:"resend_interval" env:"EMAIL_VERIFICATION_RESEND_INTERVAL"`
+}
+
+// defaultAllowedTypes không có text/html, xml/svg... để file upload không chạy được script khi mở trên browser
+var defaultAllowedTypes = []string{
+	"image/jpeg", "image/png", "image/gif", "image/webp", "image/bmp", "video/*", "audio/*", "application/ogg",
+	"application/pdf", "application/zip", "application/x-gzip", "application/x-rar-compressed", "application/x-7z-compressed",
+	"text/plain", "application/octet-stream",
+}
+
+// IsProduction: cookie chỉ gửi qua HTTPS, không có secret mặc định
+func (c *Config) IsProduction() bool {
+	return c.Env == Production
+}
+
+// Defaults trả về cấu hình mặc định của profile
+func Defaults(profile string) *Config {
+	cfg := &Config{
+		Env: profile,
+		Server: ServerConfig{
+			Port:               "8080",
+			BaseURL:            "http://localhost:8080",
+			FrontendURL:        "http://localhost:3000",
+			ShutdownTimeout:    30 * time.Second,
+			HealthCheckTimeout: 2 * time.Second,
+		},
+		Database: DatabaseConfig{Host: "localhost", Port: "5432", SSLMode: "disable", AutoMigrate: true, QueryTimeout: 5 * time.Second},
+		Redis:    RedisConfig{Host: "localhost", Port: "6379", CommandTimeout: 2 * time.Second},
+		Log:      LogConfig{Level: "info", Format: "text"},
+		Tracing:  TracingConfig{ServiceName: "chat-server", SampleRatio: 1},
+		FileURL:  FileURLConfig{TTL: 15 * time.Minute},
+		Storage: StorageConfig{
+			LocalDir: "uploads",
+			GC:       StorageGCConfig{Interval: time.Hour, Grace: 24 * time.Hour, BatchSize: 200},
+		},
+		Upload: UploadConfig{
+			URLTTL:             time.Hour,
+			MultipartThreshold: 64 << 20,
+			PartSize:           16 << 20,
+			AllowedTypes:       slices.Clone(defaultAllowedTypes),
+			MaxImageSize:       20 << 20,
+			MaxVideoSize:       500 << 20,
+			MaxAudioSize:       50 << 20,
+			MaxFileSize:        100 << 20,
+			UserQuota:          5 << 30,
+		},
+		Image: ImageConfig{Workers: 2, QueueSize: 100, MaxPixels: 50_000_000},
+		Scan: ScanConfig{
+			Driver:          "noop",
+			Workers:         2,
+			QueueSize:       100,
+			Timeout:         5 * time.Minute,
+			MaxRetries:      3,
+			RequeueInterval: time.Minute,
+		},
+		Mail: MailConfig{
+			FileDir:      "tmp/mails",
+			QueueWorkers: 2,
+			QueueSize:    100,
+			MaxRetries:   3,
+			SMTP:         SMTPConfig{Host: "smtp.gmail.com", Port: "587"},
+		},
+		Login:        LoginConfig{MaxAttempts: 10, MaxAttemptsPerIP: 50, LockoutDuration: 15 * time.Minute, AttemptWindow: 15 * time.Minute},
+		MagicLink:    MagicLinkConfig{TTL: 15 * time.Minute, ResendInterval: time.Minute},
+		Verification: VerificationConfig{RequiredFor: []string{"friend_invite"}, ResendInterval: time.Minute},
+	}
+	switch profile {
+	case Development:
+		cfg.Auth.JWTSecret = devJWTSecret
+		cfg.Log.Level = "debug"
+	case Test:
+		cfg.Auth.JWTSecret = devJWTSecret
+		cfg.Database.Name = "chat_test"
+	case Production:
+		// Production không có URL localhost mặc định, phải cấu hình tường minh
+		cfg.Server.BaseURL = ""
+		cfg.Server.FrontendURL = ""
+		cfg.Log.Format = "json"
+	}
+	return cfg
+}
+
+// Load đọc cấu hình theo profile trong ENV (mặc định development).
+// File: CONFIG_FILE nếu có (bắt buộc tồn tại), nếu không thì config.yaml rồi config.<profile>.yaml trong thư mục hiện tại nếu có.
+func Load() (*Config, error) {
+	return load(os.Getenv)
+}
+
+func load(getenv func(string) string) (*Config, error) {
+	profile := getenv("ENV")
+	if profile == "" {
+		profile = Development
+	}
+	cfg := Defaults(profile)
+
+	if path := getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path, false); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, path := range []string{"config.yaml", "config." + profile + ".yaml"} {
+			if err := cfg.loadFile(path, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := applyEnv(cfg, getenv); err != nil {
+		return nil, err
+	}
+	if err := applyOIDCEnv(cfg, getenv); err != nil {
+		return nil, err
+	}
+	if cfg.Env != profile {
+		return nil, fmt.Errorf("config: env %q in config file does not match ENV profile %q", cfg.Env, profile)
+	}
+	if cfg.Google.RedirectURL == "" && cfg.Server.BaseURL != "" {
+		cfg.Google.RedirectURL = strings.TrimSuffix(cfg.Server.BaseURL, "/") + "/api/auth/google/callback"
+	}
+	for _, name := range cfg.OIDC.Providers {
+		if client := cfg.OIDC.Clients[name]; client.RedirectURL == "" && cfg.Server.BaseURL != "" {
+			client.RedirectURL = strings.TrimSuffix(cfg.Server.BaseURL, "/") + "/api/v1/auth/oidc/" + name + "/callback"
+			cfg.OIDC.Clients[name] = client
+		}
+	}
+	if cfg.FileURL.Secret == "" {
+		cfg.FileURL.Secret = cfg.Auth.JWTSecret
+	}
+	if cfg.Storage.SigningKey == "" {
+		cfg.Storage.SigningKey = cfg.Auth.JWTSecret
+	}
+	if cfg.Storage.PublicURL == "" && cfg.Server.BaseURL != "" {
+		cfg.Storage.PublicURL = strings.TrimSuffix(cfg.Server.BaseURL, "/") + "/api/v1/storage"
+	}
+	if cfg.Storage.Driver == "" {
+		cfg.Storage.Driver = "local"
+		if cfg.Storage.S3.AccessKey != "" {
+			cfg.Storage.Driver = "s3"
+		}
+	}
+	if cfg.Mail.From == "" {
+		cfg.Mail.From = cfg.Mail.SMTP.Email
+	}
+	if cfg.Mail.From == "" {
+		cfg.Mail.From = "DevMess <no-reply@localhost>"
+	}
+	if cfg.Mail.Driver == "" {
+		cfg.Mail.Driver = "file"
+		if cfg.Mail.SMTP.Email != "" {
+			cfg.Mail.Driver = "smtp"
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile ghi đè cfg bằng các key có trong file YAML, key không biết là lỗi (tránh gõ sai tên mà không biết)
+func (c *Config) loadFile(path string, optional bool) error {
+	f, err := os.Open(path)
+	if optional && errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// ValidationError liệt kê mọi giá trị cấu hình sai để sửa 1 lần
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "config: invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate kiểm tra giá trị bắt buộc và định dạng, production kiểm tra chặt hơn
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	required := func(name, value string) {
+		if strings.TrimSpace(value) == "" {
+			add("%s is required", name)
+		}
+	}
+
+	switch c.Env {
+	case Development, Test, Production:
+	default:
+		add("env must be one of %s, %s, %s (got %q)", Development, Test, Production, c.Env)
+	}
+	validPort := func(name, value string) {
+		if p, err := strconv.Atoi(value); err != nil || p < 1 || p > 65535 {
+			add("%s must be a port number (got %q)", name, value)
+		}
+	}
+	validURL := func(name, value string) {
+		if value == "" {
+			return
+		}
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("%s must be an absolute http(s) URL (got %q)", name, value)
+		}
+	}
+
+	validPort("server.port (PORT)", c.Server.Port)
+	validURL("server.base_url (BASE_URL)", c.Server.BaseURL)
+	validURL("server.frontend_url (FRONTEND_URL)", c.Server.FrontendURL)
+	validURL("google.redirect_url (GOOGLE_REDIRECT_URL)", c.Google.RedirectURL)
+	if c.Server.ShutdownTimeout <= 0 {
+		add("server.shutdown_timeout (SHUTDOWN_TIMEOUT) must be positive (got %s)", c.Server.ShutdownTimeout)
+	}
+	if c.Server.HealthCheckTimeout <= 0 {
+		add("server.health_check_timeout (HEALTH_CHECK_TIMEOUT) must be positive (got %s)", c.Server.HealthCheckTimeout)
+	}
+
+	required("database.host (DB_HOST)", c.Database.Host)
+	required("database.user (DB_USER)", c.Database.User)
+	required("database.name (DB_NAME)", c.Database.Name)
+	validPort("database.port (DB_PORT)", c.Database.Port)
+	if c.Database.QueryTimeout <= 0 {
+		add("database.query_timeout (DB_QUERY_TIMEOUT) must be positive (got %s)", c.Database.QueryTimeout)
+	}
+	required("redis.host (REDIS_HOST)", c.Redis.Host)
+	validPort("redis.port (REDIS_PORT)", c.Redis.Port)
+	if c.Redis.CommandTimeout <= 0 {
+		add("redis.command_timeout (REDIS_COMMAND_TIMEOUT) must be positive (got %s)", c.Redis.CommandTimeout)
+	}
+	required("auth.jwt_secret (JWT_SECRET_KEY)", c.Auth.JWTSecret)
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		add("log.level (LOG_LEVEL) must be one of debug, info, warn, error (got %q)", c.Log.Level)
+	}
+	switch c.Log.Format {
+	case "text", "json":
+	default:
+		add("log.format (LOG_FORMAT) must be text or json (got %q)", c.Log.Format)
+	}
+	if c.FileURL.TTL <= 0 {
+		add("file_url.ttl (FILE_URL_TTL) must be positive (got %s)", c.FileURL.TTL)
+	}
+	positive := func(name string, value int64) {
+		if value <= 0 {
+			add("%s must be positive (got %d)", name, value)
+		}
+	}
+	positiveDuration := func(name string, value time.Duration) {
+		if value <= 0 {
+			add("%s must be positive (got %s)", name, value)
+		}
+	}
+	oneOf := func(name, value string, allowed ...string) {
+		if !slices.Contains(allowed, value) {
+			add("%s must be one of %s (got %q)", name, strings.Join(allowed, ", "), value)
+		}
+	}
+
+	oneOf("storage.driver (STORAGE_DRIVER)", c.Storage.Driver, "local", "s3")
+	validURL("storage.public_url (STORAGE_PUBLIC_URL)", c.Storage.PublicURL)
+	if c.Storage.Driver == "s3" {
+		required("storage.s3.bucket (S3_BUCKET)", c.Storage.S3.Bucket)
+	}
+	positiveDuration("storage.gc.interval (STORAGE_GC_INTERVAL)", c.Storage.GC.Interval)
+	positiveDuration("storage.gc.grace (STORAGE_GC_GRACE)", c.Storage.GC.Grace)
+	positive("storage.gc.batch_size (STORAGE_GC_BATCH)", int64(c.Storage.GC.BatchSize))
+
+	positiveDuration("upload.url_ttl (UPLOAD_URL_TTL)", c.Upload.URLTTL)
+	positive("upload.multipart_threshold (UPLOAD_MULTIPART_THRESHOLD)", c.Upload.MultipartThreshold)
+	if c.Upload.PartSize < minPartSize {
+		add("upload.part_size (UPLOAD_PART_SIZE) must be at least %d bytes (got %d)", minPartSize, c.Upload.PartSize)
+	}
+	for name, size := range map[string]int64{
+		"upload.max_image_size (UPLOAD_MAX_IMAGE_SIZE)": c.Upload.MaxImageSize,
+		"upload.max_video_size (UPLOAD_MAX_VIDEO_SIZE)": c.Upload.MaxVideoSize,
+		"upload.max_audio_size (UPLOAD_MAX_AUDIO_SIZE)": c.Upload.MaxAudioSize,
+		"upload.max_file_size (UPLOAD_MAX_FILE_SIZE)":   c.Upload.MaxFileSize,
+		"upload.user_quota (USER_STORAGE_QUOTA)":        c.Upload.UserQuota,
+	} {
+		if size < 0 {
+			add("%s must not be negative (got %d)", name, size)
+		}
+	}
+
+	positive("image.workers (IMAGE_WORKERS)", int64(c.Image.Workers))
+	positive("image.queue_size (IMAGE_QUEUE_SIZE)", int64(c.Image.QueueSize))
+	positive("image.max_pixels (IMAGE_MAX_PIXELS)", int64(c.Image.MaxPixels))
+
+	oneOf("scan.driver (SCANNER_DRIVER)", c.Scan.Driver, "noop", "clamav")
+	positive("scan.workers (SCAN_WORKERS)", int64(c.Scan.Workers))
+	positive("scan.queue_size (SCAN_QUEUE_SIZE)", int64(c.Scan.QueueSize))
+	positiveDuration("scan.timeout (SCAN_TIMEOUT)", c.Scan.Timeout)
+	positiveDuration("scan.requeue_interval (SCAN_REQUEUE_INTERVAL)", c.Scan.RequeueInterval)
+	if c.Scan.MaxRetries < 0 {
+		add("scan.max_retries (SCAN_MAX_RETRIES) must not be negative (got %d)", c.Scan.MaxRetries)
+	}
+
+	oneOf("mail.driver (MAIL_DRIVER)", c.Mail.Driver, "smtp", "file", "memory")
+	if c.Mail.Driver == "smtp" {
+		required("mail.smtp.email (SMTP_EMAIL)", c.Mail.SMTP.Email)
+		required("mail.smtp.password (SMTP_PASSWORD)", c.Mail.SMTP.Password)
+	}
+	positive("mail.queue_workers (MAIL_QUEUE_WORKERS)", int64(c.Mail.QueueWorkers))
+	positive("mail.queue_size (MAIL_QUEUE_SIZE)", int64(c.Mail.QueueSize))
+	if c.Mail.MaxRetries < 0 {
+		add("mail.max_retries (MAIL_MAX_RETRIES) must not be negative (got %d)", c.Mail.MaxRetries)
+	}
+
+	for _, name := range c.OIDC.Providers {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		required(fmt.Sprintf("oidc.clients.%s.client_id (%sCLIENT_ID)", name, prefix), c.OIDC.Clients[name].ClientID)
+		validURL(fmt.Sprintf("oidc.clients.%s.redirect_url (%sREDIRECT_URL)", name, prefix), c.OIDC.Clients[name].RedirectURL)
+	}
+
+	positive("login.max_attempts (LOGIN_MAX_ATTEMPTS)", int64(c.Login.MaxAttempts))
+	positive("login.max_attempts_per_ip (LOGIN_MAX_ATTEMPTS_PER_IP)", int64(c.Login.MaxAttemptsPerIP))
+	positiveDuration("login.lockout_duration (LOGIN_LOCKOUT_DURATION)", c.Login.LockoutDuration)
+	positiveDuration("login.attempt_window (LOGIN_ATTEMPT_WINDOW)", c.Login.AttemptWindow)
+	positiveDuration("magic_link.ttl (MAGIC_LINK_TTL)", c.MagicLink.TTL)
+	positiveDuration("magic_link.resend_interval (MAGIC_LINK_RESEND_INTERVAL)", c.MagicLink.ResendInterval)
+	positiveDuration("verification.resend_interval (EMAIL_VERIFICATION_RESEND_INTERVAL)", c.Verification.ResendInterval)
+	if c.Metrics.Enabled {
+		if c.Metrics.Addr == "" {
+			required("metrics.token (METRICS_TOKEN) when metrics.addr is empty", c.Metrics.Token)
+		} else if _, port, err := net.SplitHostPort(c.Metrics.Addr); err != nil {
+			add("metrics.addr (METRICS_ADDR) must be host:port (got %q)", c.Metrics.Addr)
+		} else if port == c.Server.Port {
+			add("metrics.addr (METRICS_ADDR) must not use the server port %s", port)
+		}
+	}
+
+	if c.Tracing.Enabled {
+		required("tracing.service_name (OTEL_SERVICE_NAME)", c.Tracing.ServiceName)
+		validURL("tracing.endpoint (OTEL_EXPORTER_OTLP_ENDPOINT)", c.Tracing.Endpoint)
+		if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+			add("tracing.sample_ratio (TRACING_SAMPLE_RATIO) must be between 0 and 1 (got %v)", c.Tracing.SampleRatio)
+		}
+	}
+
+	if c.IsProduction() {
+		required("server.base_url (BASE_URL)", c.Server.BaseURL)
+		required("server.frontend_url (FRONTEND_URL)", c.Server.FrontendURL)
+		required("database.password (DB_PASSWORD)", c.Database.Password)
+		required("google.client_id (GOOGLE_CLIENT_ID)", c.Google.ClientID)
+		required("google.client_secret (GOOGLE_CLIENT_SECRET)", c.Google.ClientSecret)
+		if c.Auth.JWTSecret == devJWTSecret || (c.Auth.JWTSecret != "" && len(c.Auth.JWTSecret) < 32) {
+			add("auth.jwt_secret (JWT_SECRET_KEY) must be at least 32 characters and not the development default")
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
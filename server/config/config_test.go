@@ -0,0 +1,179 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mapEnv(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadLayersFileAndEnv(t *testing.T) {
+	path := writeFile(t, `
+server:
+  port: "9000"
+  frontend_url: https://chat.example.com
+  shutdown_timeout: 45s
+database:
+  host: db.internal
+  user: chat
+  name: chat
+redis:
+  db: 2
+`)
+	cfg, err := load(mapEnv(map[string]string{
+		"CONFIG_FILE":     path,
+		"DB_HOST":         "db.override",
+		"DEFAULT_URL":     "http://ignored.example.com", // tên cũ chỉ dùng khi FRONTEND_URL không có
+		"DB_AUTO_MIGRATE": "false",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, Development, cfg.Env)
+	assert.Equal(t, "9000", cfg.Server.Port)
+	assert.Equal(t, 45*time.Second, cfg.Server.ShutdownTimeout)
+	assert.Equal(t, "http://ignored.example.com", cfg.Server.FrontendURL)
+	assert.Equal(t, "db.override", cfg.Database.Host)
+	assert.Equal(t, "5432", cfg.Database.Port)
+	assert.False(t, cfg.Database.AutoMigrate)
+	assert.Equal(t, 2, cfg.Redis.DB)
+	assert.Equal(t, "localhost:6379", cfg.Redis.Addr())
+	assert.Equal(t, "http://localhost:8080/api/auth/google/callback", cfg.Google.RedirectURL)
+	assert.Contains(t, cfg.Database.DSN(), "dbname=chat sslmode=disable")
+}
+
+func TestLoadRejectsInvalidConfig(t *testing.T) {
+	_, err := load(mapEnv(map[string]string{"CONFIG_FILE": writeFile(t, "database:\n  hots: typo\n")}))
+	assert.ErrorContains(t, err, "hots")
+
+	_, err = load(mapEnv(map[string]string{"CONFIG_FILE": filepath.Join(t.TempDir(), "missing.yaml")}))
+	assert.Error(t, err)
+
+	_, err = load(mapEnv(map[string]string{"ENV": "staging", "DB_USER": "chat", "DB_NAME": "chat"}))
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Problems, 2) // env không hợp lệ, jwt_secret không có mặc định
+
+	_, err = load(mapEnv(map[string]string{"DB_USER": "chat", "DB_NAME": "chat", "PORT": "http"}))
+	require.ErrorAs(t, err, &validationErr)
+	assert.Contains(t, validationErr.Problems[0], "PORT")
+
+	_, err = load(mapEnv(map[string]string{"DB_USER": "chat", "DB_NAME": "chat", "SHUTDOWN_TIMEOUT": "30"}))
+	assert.ErrorContains(t, err, "SHUTDOWN_TIMEOUT")
+
+	// Metrics trên port chính phải có token
+	_, err = load(mapEnv(map[string]string{"DB_USER": "chat", "DB_NAME": "chat", "METRICS_ENABLED": "true"}))
+	assert.ErrorContains(t, err, "METRICS_TOKEN")
+	_, err = load(mapEnv(map[string]string{"DB_USER": "chat", "DB_NAME": "chat", "METRICS_ENABLED": "true", "METRICS_ADDR": ":9090"}))
+	assert.NoError(t, err)
+
+	_, err = load(mapEnv(map[string]string{"DB_USER": "chat", "DB_NAME": "chat", "TRACING_ENABLED": "true", "TRACING_SAMPLE_RATIO": "1.5"}))
+	assert.ErrorContains(t, err, "TRACING_SAMPLE_RATIO")
+	cfg, err := load(mapEnv(map[string]string{"DB_USER": "chat", "DB_NAME": "chat", "TRACING_ENABLED": "true", "TRACING_SAMPLE_RATIO": "0.25"}))
+	require.NoError(t, err)
+	assert.Equal(t, 0.25, cfg.Tracing.SampleRatio)
+
+	// Production không dùng secret mặc định và không có URL localhost
+	_, err = load(mapEnv(map[string]string{"ENV": Production, "DB_USER": "chat", "DB_NAME": "chat", "JWT_SECRET_KEY": "short"}))
+	require.ErrorAs(t, err, &validationErr)
+	problems := strings.Join(validationErr.Problems, "\n")
+	for _, key := range []string{"BASE_URL", "FRONTEND_URL", "DB_PASSWORD", "GOOGLE_CLIENT_ID", "GOOGLE_CLIENT_SECRET", "JWT_SECRET_KEY"} {
+		assert.Contains(t, problems, key)
+	}
+}
+
+func TestLoadServiceSections(t *testing.T) {
+	path := writeFile(t, `
+oidc:
+  providers: [keycloak]
+  clients:
+    keycloak:
+      client_id: kc-id
+      issuer: https://sso.example.com/realms/devmess
+upload:
+  allowed_types: [image/png]
+`)
+	cfg, err := load(mapEnv(map[string]string{
+		"CONFIG_FILE":                     path,
+		"DB_USER":                         "chat",
+		"DB_NAME":                         "chat",
+		"BASE_URL":                        "https://api.example.com",
+		"OIDC_PROVIDERS":                  "GitHub, keycloak",
+		"OIDC_GITHUB_CLIENT_ID":           "gh-id",
+		"OIDC_KEYCLOAK_SCOPES":            "openid, email",
+		"UPLOAD_MAX_VIDEO_SIZE":           "1073741824",
+		"EMAIL_VERIFICATION_REQUIRED_FOR": "friend_invite,upload",
+		"SPACES_KEY":                      "spaces-key",
+		"SPACES_BUCKET":                   "media",
+		"SMTP_EMAIL":                      "bot@example.com",
+		"SMTP_PASSWORD":                   "smtp-pass",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"github", "keycloak"}, cfg.OIDC.Providers)
+	assert.Equal(t, "gh-id", cfg.OIDC.Clients["github"].ClientID)
+	assert.Equal(t, "https://api.example.com/api/v1/auth/oidc/github/callback", cfg.OIDC.Clients["github"].RedirectURL)
+	assert.Equal(t, "kc-id", cfg.OIDC.Clients["keycloak"].ClientID)
+	assert.Equal(t, "https://sso.example.com/realms/devmess", cfg.OIDC.Clients["keycloak"].Issuer)
+	assert.Equal(t, []string{"openid", "email"}, cfg.OIDC.Clients["keycloak"].Scopes)
+
+	assert.Equal(t, []string{"image/png"}, cfg.Upload.AllowedTypes)
+	assert.Equal(t, int64(1<<30), cfg.Upload.MaxVideoSize)
+	assert.Equal(t, int64(20<<20), cfg.Upload.MaxImageSize)
+	assert.Equal(t, []string{"friend_invite", "upload"}, cfg.Verification.RequiredFor)
+
+	// Driver được chọn theo credentials có sẵn
+	assert.Equal(t, "s3", cfg.Storage.Driver)
+	assert.Equal(t, "spaces-key", cfg.Storage.S3.AccessKey)
+	assert.Equal(t, "https://api.example.com/api/v1/storage", cfg.Storage.PublicURL)
+	assert.Equal(t, cfg.Auth.JWTSecret, cfg.Storage.SigningKey)
+	assert.Equal(t, "smtp", cfg.Mail.Driver)
+	assert.Equal(t, "bot@example.com", cfg.Mail.From)
+
+	// Provider được bật phải có client id, SMTP phải có mật khẩu
+	_, err = load(mapEnv(map[string]string{"DB_USER": "chat", "DB_NAME": "chat", "OIDC_PROVIDERS": "gitlab", "SMTP_EMAIL": "bot@example.com"}))
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	problems := strings.Join(validationErr.Problems, "\n")
+	assert.Contains(t, problems, "OIDC_GITLAB_CLIENT_ID")
+	assert.Contains(t, problems, "SMTP_PASSWORD")
+
+	_, err = load(mapEnv(map[string]string{"DB_USER": "chat", "DB_NAME": "chat", "UPLOAD_PART_SIZE": "1024", "SCANNER_DRIVER": "virustotal"}))
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Problems, 2)
+}
+
+func TestExampleConfigIsValid(t *testing.T) {
+	_, err := load(mapEnv(map[string]string{"CONFIG_FILE": "../config.example.yaml", "JWT_SECRET_KEY": devJWTSecret}))
+	require.NoError(t, err)
+}
+
+func TestStringRedactsSecrets(t *testing.T) {
+	cfg := Defaults(Development)
+	cfg.Database.Password = "db-pass"
+	cfg.Google.ClientSecret = "google-secret"
+	cfg.OIDC.Clients = map[string]OIDCProviderConfig{"github": {ClientID: "gh-id", ClientSecret: "gh-secret"}}
+	s := cfg.String()
+	assert.NotContains(t, s, "gh-secret")
+	assert.Contains(t, s, "oidc.clients.github.client_id=gh-id")
+	assert.Contains(t, s, "oidc.clients.github.client_secret=***")
+	assert.NotContains(t, s, "db-pass")
+	assert.NotContains(t, s, "google-secret")
+	assert.NotContains(t, s, devJWTSecret)
+	assert.Contains(t, s, "database.password=***")
+	assert.Contains(t, s, `redis.password=""`)
+	assert.Contains(t, s, "server.port=8080")
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv ghi đè các field có tag env, tag có nhiều tên thì tên đầu tiên có giá trị được dùng
+// (tên sau là tên cũ còn giữ để tương thích)
+func applyEnv(cfg *Config, getenv func(string) string) error {
+	return applyEnvTo(reflect.ValueOf(cfg).Elem(), getenv)
+}
+
+// applyOIDCEnv đọc OIDC_<NAME>_* cho từng provider trong oidc.providers, ghi đè cấu hình trong file.
+// Tên provider được chuẩn hóa về chữ thường.
+func applyOIDCEnv(cfg *Config, getenv func(string) string) error {
+	providers := cfg.OIDC.Providers
+	cfg.OIDC.Providers = nil
+	for _, name := range providers {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || slices.Contains(cfg.OIDC.Providers, name) {
+			continue
+		}
+		cfg.OIDC.Providers = append(cfg.OIDC.Providers, name)
+		if cfg.OIDC.Clients == nil {
+			cfg.OIDC.Clients = make(map[string]OIDCProviderConfig)
+		}
+		client := cfg.OIDC.Clients[name]
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		if err := applyEnvTo(reflect.ValueOf(&client).Elem(), func(key string) string {
+			return getenv(prefix + key)
+		}); err != nil {
+			return err
+		}
+		cfg.OIDC.Clients[name] = client
+	}
+	return nil
+}
+
+func applyEnvTo(target reflect.Value, getenv func(string) string) error {
+	return walk(target, "", func(field reflect.StructField, v reflect.Value, _ string) error {
+		tag := field.Tag.Get("env")
+		if tag == "" {
+			return nil
+		}
+		for _, name := range strings.Split(tag, ",") {
+			value := getenv(name)
+			if value == "" {
+				continue
+			}
+			if err := setValue(v, value); err != nil {
+				return fmt.Errorf("config: %s: %w", name, err)
+			}
+			return nil
+		}
+		return nil
+	})
+}
+
+func setValue(v reflect.Value, value string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || v.OverflowInt(n) {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		v.SetBool(b)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported field type %s", v.Type())
+		}
+		// Danh sách phân cách bởi dấu phẩy
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// walk gọi fn cho mọi field không phải struct, path là tên theo key YAML (database.host)
+func walk(v reflect.Value, prefix string, fn func(field reflect.StructField, v reflect.Value, path string) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		path := prefix + name
+		if field.Type.Kind() == reflect.Struct {
+			if err := walk(v.Field(i), path+".", fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(field, v.Field(i), path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String in cấu hình dạng key=value để log lúc khởi động, field có tag secret được che
+func (c *Config) String() string {
+	var parts []string
+	var visit func(field reflect.StructField, v reflect.Value, path string) error
+	visit = func(field reflect.StructField, v reflect.Value, path string) error {
+		// Map của struct (oidc.clients) in từng field để secret bên trong vẫn được che
+		if v.Kind() == reflect.Map && v.Type().Elem().Kind() == reflect.Struct {
+			keys := v.MapKeys()
+			slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+			for _, key := range keys {
+				if err := walk(v.MapIndex(key), path+"."+key.String()+".", visit); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		value := fmt.Sprint(v.Interface())
+		if field.Tag.Get("secret") == "true" {
+			value = redact(value)
+		}
+		parts = append(parts, path+"="+value)
+		return nil
+	}
+	_ = walk(reflect.ValueOf(c).Elem(), "", visit)
+	return strings.Join(parts, " ")
+}
+
+// redact chỉ cho biết secret đã được cấu hình hay chưa
+func redact(value string) string {
+	if value == "" {
+		return `""`
+	}
+	return "***"
+}
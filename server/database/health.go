@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// PingDB trả về check kiểm tra connection pool của PostgreSQL còn kết nối được
+func PingDB(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// PingRedis trả về check kiểm tra Redis trả lời PING
+func PingRedis(rdb *redis.Client) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	}
+}
+
+// CheckMigrations trả về check báo lỗi khi còn migration chưa chạy (replica mới hơn schema thì chưa nhận request)
+func CheckMigrations(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		m, err := NewMigrator(sqlDB)
+		if err != nil {
+			return err
+		}
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migration(s), first: %s", len(pending), pending[0])
+		}
+		return nil
+	}
+}
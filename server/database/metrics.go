@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"project/pkg/metrics"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const metricsStartKey = "metrics:start"
+
+type callbackRegistrar interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+// instrumentGorm đo latency mọi câu lệnh GORM của repository, label operation dạng "query:users"
+func instrumentGorm(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		op            string
+		before, after callbackRegistrar
+	}{
+		{"create", cb.Create().Before("gorm:create"), cb.Create().After("gorm:create")},
+		{"query", cb.Query().Before("gorm:query"), cb.Query().After("gorm:query")},
+		{"update", cb.Update().Before("gorm:update"), cb.Update().After("gorm:update")},
+		{"delete", cb.Delete().Before("gorm:delete"), cb.Delete().After("gorm:delete")},
+		{"row", cb.Row().Before("gorm:row"), cb.Row().After("gorm:row")},
+		{"raw", cb.Raw().Before("gorm:raw"), cb.Raw().After("gorm:raw")},
+	}
+	for _, h := range hooks {
+		op := h.op
+		if err := h.before.Register("metrics:before_"+op, func(tx *gorm.DB) {
+			tx.InstanceSet(metricsStartKey, time.Now())
+		}); err != nil {
+			return err
+		}
+		if err := h.after.Register("metrics:after_"+op, func(tx *gorm.DB) {
+			v, ok := tx.InstanceGet(metricsStartKey)
+			if !ok {
+				return
+			}
+			operation := op
+			if tx.Statement.Table != "" {
+				operation += ":" + tx.Statement.Table
+			}
+			metrics.DBDuration.WithLabelValues("postgres", operation).Observe(time.Since(v.(time.Time)).Seconds())
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redisMetricsHook đo latency theo tên lệnh Redis (get, hset...)
+type redisMetricsHook struct{}
+
+func (redisMetricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (redisMetricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		metrics.DBDuration.WithLabelValues("redis", cmd.Name()).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+func (redisMetricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		metrics.DBDuration.WithLabelValues("redis", "pipeline").Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+var _ redis.Hook = redisMetricsHook{}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+
+	"project/pkg/migrate"
+
+	"gorm.io/gorm"
+)
+
+// Migration SQL được nhúng vào binary, thêm file mới bằng "web_chat migrate create <name>"
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// MigrationsDir là thư mục chứa file migration trong source, dùng cho lệnh create
+const MigrationsDir = "database/migrations"
+
+func migrationsFS() fs.FS {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// NewMigrator tạo migrator với các migration được nhúng trong binary
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	return migrate.New(db, migrationsFS())
+}
+
+// MigrateUp chạy các migration chưa chạy trên connection của GORM
+func MigrateUp(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("get connection pool: %w", err)
+	}
+	m, err := NewMigrator(sqlDB)
+	if err != nil {
+		return err
+	}
+	applied, err := m.Up(ctx)
+	for _, mig := range applied {
+		slog.InfoContext(ctx, "migration applied", "migration", mig.String())
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		slog.InfoContext(ctx, "schema is up to date")
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+-- 0001_init: xóa toàn bộ bảng, chỉ dùng khi dev
+DROP TABLE IF EXISTS friendships;
+DROP TABLE IF EXISTS participants;
+DROP TABLE IF EXISTS attachment_renditions;
+DROP TABLE IF EXISTS attachments;
+DROP TABLE IF EXISTS blobs;
+DROP TABLE IF EXISTS conversations;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS tokens;
+DROP TABLE IF EXISTS devices;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS users;
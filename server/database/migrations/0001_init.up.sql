@@ -0,0 +1,187 @@
+-- 0001_init: schema ban đầu, tương đương kết quả GORM AutoMigrate trước đây.
+-- Bảng đã có sẵn được giữ nguyên, cột còn thiếu ở database cũ được bổ sung trong 0002.
+
+CREATE TABLE IF NOT EXISTS users (
+    id                UUID PRIMARY KEY,
+    name              VARCHAR(100) NOT NULL,
+    email             VARCHAR(100) NOT NULL,
+    avatar            VARCHAR(255),
+    bio               VARCHAR(500),
+    status_text       VARCHAR(140),
+    password          VARCHAR(255) NOT NULL,
+    provider          VARCHAR(50) NOT NULL DEFAULT 'local',
+    email_verified_at TIMESTAMPTZ,
+    storage_used      BIGINT NOT NULL DEFAULT 0,
+    storage_quota     BIGINT NOT NULL DEFAULT 0,
+    created_at        TIMESTAMPTZ,
+    status            VARCHAR(10) DEFAULT 'offline',
+    last_seen         TIMESTAMPTZ,
+    updated_at        TIMESTAMPTZ,
+    deleted_at        TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_email_verified_at ON users (email_verified_at);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL,
+    provider   VARCHAR(50) NOT NULL,
+    subject    VARCHAR(255) NOT NULL,
+    email      VARCHAR(100),
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    CONSTRAINT fk_user_identities_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identity_provider_subject ON user_identities (provider, subject);
+
+CREATE TABLE IF NOT EXISTS devices (
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL,
+    type       TEXT,
+    name       TEXT,
+    ip         TEXT,
+    user_agent TEXT,
+    CONSTRAINT fk_users_devices FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_devices_user_id ON devices (user_id);
+
+CREATE TABLE IF NOT EXISTS tokens (
+    id            UUID PRIMARY KEY,
+    device_id     UUID,
+    access_token  TEXT NOT NULL,
+    refresh_token TEXT NOT NULL,
+    expires_at    BIGINT NOT NULL,
+    token_type    VARCHAR(50) NOT NULL DEFAULT 'Bearer',
+    created_at    TIMESTAMPTZ,
+    updated_at    TIMESTAMPTZ,
+    CONSTRAINT fk_devices_token FOREIGN KEY (device_id) REFERENCES devices (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tokens_device_id ON tokens (device_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tokens_access_token ON tokens (access_token);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tokens_refresh_token ON tokens (refresh_token);
+
+-- messages tạo trước conversations vì conversations.last_message_id tham chiếu messages
+CREATE TABLE IF NOT EXISTS messages (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id UUID NOT NULL,
+    sender_id       UUID,
+    content         TEXT,
+    type            VARCHAR(20) DEFAULT 'text',
+    status          VARCHAR(20) DEFAULT 'sent',
+    is_edited       BOOLEAN DEFAULT false,
+    reply_to_id     UUID,
+    deleted         BOOLEAN DEFAULT false,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ,
+    deleted_at      TIMESTAMPTZ,
+    CONSTRAINT chk_messages_type CHECK (type IN ('text', 'image', 'file', 'video', 'voice', 'system')),
+    CONSTRAINT chk_messages_status CHECK (status IN ('sent', 'delivered', 'read')),
+    CONSTRAINT fk_messages_sender FOREIGN KEY (sender_id) REFERENCES users (id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages (conversation_id);
+CREATE INDEX IF NOT EXISTS idx_messages_sender_id ON messages (sender_id);
+CREATE INDEX IF NOT EXISTS idx_messages_reply_to_id ON messages (reply_to_id);
+CREATE INDEX IF NOT EXISTS idx_messages_deleted_at ON messages (deleted_at);
+
+CREATE TABLE IF NOT EXISTS conversations (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    type            VARCHAR(20) NOT NULL DEFAULT 'direct',
+    name            VARCHAR(255),
+    description     TEXT,
+    avatar          VARCHAR(500),
+    last_message_id UUID,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ,
+    deleted_at      TIMESTAMPTZ,
+    CONSTRAINT fk_conversations_last_message FOREIGN KEY (last_message_id) REFERENCES messages (id)
+);
+CREATE INDEX IF NOT EXISTS idx_conversations_deleted_at ON conversations (deleted_at);
+
+CREATE TABLE IF NOT EXISTS blobs (
+    checksum     VARCHAR(64) PRIMARY KEY,
+    storage_key  VARCHAR(255) NOT NULL,
+    size         BIGINT NOT NULL,
+    mime_type    VARCHAR(100) NOT NULL,
+    created_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_blobs_storage_key ON blobs (storage_key);
+CREATE INDEX IF NOT EXISTS idx_blobs_last_used_at ON blobs (last_used_at);
+
+CREATE TABLE IF NOT EXISTS attachments (
+    id                  UUID PRIMARY KEY,
+    uploader_id         UUID NOT NULL,
+    conversation_id     UUID,
+    message_id          UUID,
+    storage_key         VARCHAR(255) NOT NULL,
+    file_name           VARCHAR(255),
+    mime_type           VARCHAR(100) NOT NULL,
+    size                BIGINT NOT NULL,
+    width               BIGINT,
+    height              BIGINT,
+    checksum            VARCHAR(64) NOT NULL,
+    status              VARCHAR(20) DEFAULT 'ready',
+    scan_signature      VARCHAR(255),
+    multipart_upload_id VARCHAR(255),
+    dominant_color      VARCHAR(7),
+    duration_ms         BIGINT,
+    waveform            BYTEA,
+    created_at          TIMESTAMPTZ,
+    CONSTRAINT chk_attachments_status CHECK (status IN ('pending', 'pending_scan', 'ready', 'quarantined')),
+    CONSTRAINT fk_attachments_uploader FOREIGN KEY (uploader_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_messages_attachments FOREIGN KEY (message_id) REFERENCES messages (id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_attachments_uploader_id ON attachments (uploader_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_conversation_id ON attachments (conversation_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_message_id ON attachments (message_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_blob_key ON attachments (storage_key);
+
+CREATE TABLE IF NOT EXISTS attachment_renditions (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    attachment_id UUID NOT NULL,
+    name          VARCHAR(20) NOT NULL,
+    storage_key   VARCHAR(255) NOT NULL,
+    mime_type     VARCHAR(100) NOT NULL,
+    width         BIGINT,
+    height        BIGINT,
+    size          BIGINT,
+    CONSTRAINT fk_attachments_renditions FOREIGN KEY (attachment_id) REFERENCES attachments (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rendition_attachment_name ON attachment_renditions (attachment_id, name);
+CREATE INDEX IF NOT EXISTS idx_renditions_blob_key ON attachment_renditions (storage_key);
+
+CREATE TABLE IF NOT EXISTS participants (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id         UUID NOT NULL,
+    conversation_id UUID NOT NULL,
+    role            VARCHAR(20) DEFAULT 'member',
+    last_read_at    TIMESTAMPTZ,
+    joined_at       TIMESTAMPTZ,
+    deleted_at      TIMESTAMPTZ,
+    CONSTRAINT fk_users_participants FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_conversations_participants FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_participants_user_id ON participants (user_id);
+CREATE INDEX IF NOT EXISTS idx_participants_conversation_id ON participants (conversation_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_conversation_user ON participants (user_id, conversation_id);
+CREATE INDEX IF NOT EXISTS idx_participants_deleted_at ON participants (deleted_at);
+
+CREATE TABLE IF NOT EXISTS friendships (
+    id           UUID PRIMARY KEY,
+    user_id      UUID NOT NULL,
+    friend_id    UUID NOT NULL,
+    status       VARCHAR(20) DEFAULT 'pending',
+    requested_by UUID NOT NULL,
+    created_at   TIMESTAMPTZ,
+    updated_at   TIMESTAMPTZ,
+    deleted_at   TIMESTAMPTZ,
+    CONSTRAINT chk_friendships_status CHECK (status IN ('no_friend', 'pending', 'friend', 'blocked')),
+    CONSTRAINT fk_friendships_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_friendships_friend FOREIGN KEY (friend_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_friendships_user_id ON friendships (user_id);
+CREATE INDEX IF NOT EXISTS idx_friendships_friend_id ON friendships (friend_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_friend ON friendships (user_id, friend_id);
+CREATE INDEX IF NOT EXISTS idx_friendships_deleted_at ON friendships (deleted_at);
@@ -0,0 +1,2 @@
+-- 0002_backfill_automigrate_columns: các cột và constraint thuộc schema của 0001, rollback 0001 sẽ xóa chúng
+SELECT 1;
@@ -0,0 +1,13 @@
+-- 0002_backfill_automigrate_columns: database tạo bằng AutoMigrate từ phiên bản cũ đã có bảng users/messages
+-- nên 0001 bỏ qua CREATE TABLE, các cột và constraint thêm sau đó phải bổ sung ở đây.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bio VARCHAR(500);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_text VARCHAR(140);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS storage_used BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS storage_quota BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_users_email_verified_at ON users (email_verified_at);
+
+-- Check constraint cũ chưa có 'voice'
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS chk_messages_type;
+ALTER TABLE messages ADD CONSTRAINT chk_messages_type CHECK (type IN ('text', 'image', 'file', 'video', 'voice', 'system'));
@@ -0,0 +1,2 @@
+-- 0003_backfill_google_email_verified: không phân biệt được giá trị backfill với giá trị do user xác thực, giữ nguyên
+SELECT 1;
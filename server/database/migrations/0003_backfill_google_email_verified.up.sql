@@ -0,0 +1,9 @@
+-- 0003_backfill_google_email_verified: tài khoản Google tạo trước khi có email_verified_at chưa có giá trị,
+-- trước đây IsEmailVerified coi mọi tài khoản provider google là đã xác thực.
+-- Chỉ backfill user tạo trước khi database chuyển sang migration (0001), user Google tạo sau đó
+-- đã có email_verified_at theo verified_email mà Google trả về.
+UPDATE users
+SET email_verified_at = created_at
+WHERE provider = 'google'
+  AND email_verified_at IS NULL
+  AND created_at < COALESCE((SELECT applied_at FROM schema_migrations WHERE version = 1), now());
@@ -2,35 +2,39 @@ package database
 
 import (
 	"context"
-	"fmt"
-	"os"
+	"log/slog"
+	"project/config"
 
 	"github.com/redis/go-redis/v9"
 )
 
-var (
-	RDB *redis.Client
-	Ctx = context.Background()
-)
-
-func InitRedis() {
-	RDB = redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%v:%v", os.Getenv("REDIS_HOST"), os.Getenv("REDIS_PORT")),
-		Password: "",
-		DB:       0,
+// InitRedis tạo Redis client kèm hook metrics/tracing. Lỗi kết nối lúc khởi động chỉ được log,
+// /readyz báo fail cho tới khi Redis trả lời.
+func InitRedis(cfg config.RedisConfig) *redis.Client {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+		// Mỗi lệnh dừng theo deadline của ctx (request bị hủy) hoặc CommandTimeout, lấy cái sớm hơn
+		ReadTimeout:           cfg.CommandTimeout,
+		WriteTimeout:          cfg.CommandTimeout,
+		ContextTimeoutEnabled: true,
 	})
+	rdb.AddHook(redisMetricsHook{})
+	rdb.AddHook(redisTracingHook{})
 
 	// 🔍 Kiểm tra kết nối
-	if err := RDB.Ping(Ctx).Err(); err != nil {
-		fmt.Println("❌ Lỗi kết nối Redis:", err)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.CommandTimeout)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		slog.Error("connect redis failed", "addr", cfg.Addr(), "err", err)
 	} else {
-		fmt.Println("✅ Database 'redis' sẵn sàng!")
+		slog.Info("redis ready", "addr", cfg.Addr())
 	}
+	return rdb
 }
 
-func CloseRedis() {
-	if RDB != nil {
-		RDB.Close()
-		fmt.Println("✅ Database 'redis' đã đóng!")
-	}
+func CloseRedis(rdb *redis.Client) {
+	rdb.Close()
+	slog.Info("redis client closed")
 }
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const queryTimeoutKey = "timeout:ctx"
+
+type queryTimeout struct {
+	parent context.Context
+	cancel context.CancelFunc
+}
+
+// applyQueryTimeout giới hạn thời gian mỗi câu lệnh GORM theo cấu hình chung. ctx của request vẫn là cha
+// nên client ngắt kết nối cũng hủy query. Row/Rows không áp dụng vì caller còn đọc rows sau callback.
+func applyQueryTimeout(db *gorm.DB, timeout time.Duration) error {
+	cb := db.Callback()
+	hooks := []struct {
+		op            string
+		before, after callbackRegistrar
+	}{
+		{"create", cb.Create().Before("gorm:create"), cb.Create().After("gorm:create")},
+		{"query", cb.Query().Before("gorm:query"), cb.Query().After("gorm:query")},
+		{"update", cb.Update().Before("gorm:update"), cb.Update().After("gorm:update")},
+		{"delete", cb.Delete().Before("gorm:delete"), cb.Delete().After("gorm:delete")},
+		{"raw", cb.Raw().Before("gorm:raw"), cb.Raw().After("gorm:raw")},
+	}
+	for _, h := range hooks {
+		if err := h.before.Register("timeout:before_"+h.op, func(tx *gorm.DB) {
+			parent := tx.Statement.Context
+			if parent == nil {
+				parent = context.Background()
+			}
+			ctx, cancel := context.WithTimeout(parent, timeout)
+			tx.Statement.Context = ctx
+			tx.InstanceSet(queryTimeoutKey, queryTimeout{parent: parent, cancel: cancel})
+		}); err != nil {
+			return err
+		}
+		if err := h.after.Register("timeout:after_"+h.op, func(tx *gorm.DB) {
+			v, ok := tx.InstanceGet(queryTimeoutKey)
+			if !ok {
+				return
+			}
+			qt := v.(queryTimeout)
+			qt.cancel()
+			// Statement có thể được dùng lại (query.Count rồi query.Find), trả lại ctx chưa bị hủy
+			tx.Statement.Context = qt.parent
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type ctxKey struct{}
+
+func TestQueryTimeoutWrapsRequestContext(t *testing.T) {
+	// DryRun: callback vẫn chạy nhưng không gửi SQL tới server
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1 sslmode=disable"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+	require.NoError(t, applyQueryTimeout(db, time.Second))
+
+	var seen []context.Context
+	require.NoError(t, db.Callback().Query().Before("gorm:query").After("timeout:before_query").
+		Register("test:capture", func(tx *gorm.DB) { seen = append(seen, tx.Statement.Context) }))
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+	query := db.WithContext(ctx).Table("users").Where("id = ?", 1)
+	var rows []map[string]interface{}
+	require.NoError(t, query.Find(&rows).Error)
+	require.NoError(t, query.Find(&rows).Error) // Statement dùng lại không mang ctx đã hủy
+
+	require.Len(t, seen, 2)
+	deadline, ok := seen[0].Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, time.Second)
+	assert.Equal(t, "request", seen[0].Value(ctxKey{}))
+	assert.ErrorIs(t, seen[0].Err(), context.Canceled) // hủy ngay khi câu lệnh xong
+	assert.NoError(t, ctx.Err())
+
+	// Deadline sớm hơn của request được giữ
+	short, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.NoError(t, db.WithContext(short).Table("users").Find(&rows).Error)
+	got, _ := seen[2].Deadline()
+	want, _ := short.Deadline()
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"project/pkg/tracing"
+
+	"github.com/redis/go-redis/v9"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const tracingSpanKey = "tracing:span"
+
+// traceGorm tạo span con cho mỗi câu lệnh GORM từ ctx của repository (db.WithContext(ctx)).
+// SQL được ghi dạng có placeholder ($1, $2), không kèm giá trị tham số.
+func traceGorm(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		op            string
+		before, after callbackRegistrar
+	}{
+		{"create", cb.Create().Before("gorm:create"), cb.Create().After("gorm:create")},
+		{"query", cb.Query().Before("gorm:query"), cb.Query().After("gorm:query")},
+		{"update", cb.Update().Before("gorm:update"), cb.Update().After("gorm:update")},
+		{"delete", cb.Delete().Before("gorm:delete"), cb.Delete().After("gorm:delete")},
+		{"row", cb.Row().Before("gorm:row"), cb.Row().After("gorm:row")},
+		{"raw", cb.Raw().Before("gorm:raw"), cb.Raw().After("gorm:raw")},
+	}
+	for _, h := range hooks {
+		op := h.op
+		if err := h.before.Register("tracing:before_"+op, func(tx *gorm.DB) {
+			ctx := tx.Statement.Context
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			name := "gorm." + op
+			if tx.Statement.Table != "" {
+				name += " " + tx.Statement.Table
+			}
+			_, span := tracing.Start(ctx, "database", name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(semconv.DBSystemNamePostgreSQL, semconv.DBOperationName(op)),
+			)
+			tx.InstanceSet(tracingSpanKey, span)
+		}); err != nil {
+			return err
+		}
+		if err := h.after.Register("tracing:after_"+op, func(tx *gorm.DB) {
+			v, ok := tx.InstanceGet(tracingSpanKey)
+			if !ok {
+				return
+			}
+			span := v.(trace.Span)
+			defer span.End()
+			if tx.Statement.Table != "" {
+				span.SetAttributes(semconv.DBCollectionName(tx.Statement.Table))
+			}
+			if sql := tx.Statement.SQL.String(); sql != "" {
+				span.SetAttributes(semconv.DBQueryText(sql))
+			}
+			if tx.Error != nil && tx.Error != gorm.ErrRecordNotFound {
+				tracing.RecordError(span, tx.Error)
+			}
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redisTracingHook tạo span cho mỗi lệnh Redis, chỉ ghi tên lệnh (key có thể chứa token)
+type redisTracingHook struct{}
+
+func (redisTracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (redisTracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := tracing.Start(ctx, "database", "redis."+cmd.Name(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemNameRedis, semconv.DBOperationName(cmd.Name())),
+		)
+		defer span.End()
+		err := next(ctx, cmd)
+		if err != nil && err != redis.Nil {
+			tracing.RecordError(span, err)
+		}
+		return err
+	}
+}
+
+func (redisTracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		names := make([]string, len(cmds))
+		for i, cmd := range cmds {
+			names[i] = cmd.Name()
+		}
+		ctx, span := tracing.Start(ctx, "database", "redis.pipeline",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemNameRedis, semconv.DBOperationName(strings.Join(names, " "))),
+		)
+		defer span.End()
+		err := next(ctx, cmds)
+		if err != nil && err != redis.Nil {
+			tracing.RecordError(span, err)
+		}
+		return err
+	}
+}
+
+var _ redis.Hook = redisTracingHook{}
@@ -1,16 +1,21 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
-	"os"
 	"project/models"
+	"project/pkg/mailer"
 	"project/repository"
 	"project/service"
 	"project/utils"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
 )
 
 type EmailRequest struct {
@@ -33,17 +38,39 @@ type ResetPasswordRequest struct {
 	Token    string `json:"token" binding:"required"`
 	Password string `json:"password" binding:"required,min=6"`
 }
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ConsumeMagicLinkRequest struct {
+	Token string `json:"token" binding:"required"`
+}
 type AuthHandler struct {
-	userService   *service.UserService
-	authService   *service.AuthService
-	googleService *service.GoogleService
+	userService          *service.UserService
+	authService          *service.AuthService
+	googleService        *service.GoogleService
+	verificationService  *service.VerificationService
+	loginGuard           *service.LoginGuard
+	magicLinkService     *service.MagicLinkService
+	passwordResetService *service.PasswordResetService
 }
 
-func NewAuthHandler(userService *service.UserService, authService *service.AuthService, googleService *service.GoogleService) *AuthHandler {
-	return &AuthHandler{userService: userService, authService: authService, googleService: googleService}
+func NewAuthHandler(userService *service.UserService, authService *service.AuthService, googleService *service.GoogleService, verificationService *service.VerificationService, loginGuard *service.LoginGuard, magicLinkService *service.MagicLinkService, passwordResetService *service.PasswordResetService) *AuthHandler {
+	return &AuthHandler{userService: userService, authService: authService, googleService: googleService, verificationService: verificationService, loginGuard: loginGuard, magicLinkService: magicLinkService, passwordResetService: passwordResetService}
+}
+
+// requestLocale chọn ngôn ngữ email theo header Accept-Language của client (en/vi)
+func requestLocale(c *gin.Context) string {
+	return mailer.ParseLocale(c.GetHeader("Accept-Language"))
 }
 
 func (a *AuthHandler) AuthHandle(c *gin.Context) {
+	ctx := c.Request.Context()
 
 	userValue, exists := c.Get("user")
 	if !exists {
@@ -56,17 +83,24 @@ func (a *AuthHandler) AuthHandle(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user type"})
 		return
 	}
+	verified, err := a.verificationService.IsVerified(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email verification"})
+		return
+	}
 	// ✅ Trả JSON gọn gàng
 	c.JSON(http.StatusOK, gin.H{
-		"id":        user.ID,
-		"email":     user.Email,
-		"name":      user.Name,
-		"avatar":    user.Avatar,
-		"createdAt": user.CreatedAt,
-		"updatedAt": user.UpdatedAt,
+		"id":             user.ID,
+		"email":          user.Email,
+		"name":           user.Name,
+		"avatar":         user.Avatar,
+		"email_verified": verified,
+		"createdAt":      user.CreatedAt,
+		"updatedAt":      user.UpdatedAt,
 	})
 }
 func (a *AuthHandler) AuthRefreshToken(c *gin.Context) {
+	ctx := c.Request.Context()
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header missing"})
@@ -83,7 +117,7 @@ func (a *AuthHandler) AuthRefreshToken(c *gin.Context) {
 	accessToken := strings.TrimPrefix(authHeader, bearerPrefix)
 
 	// 2️⃣ Verify access token và lấy refresh token
-	user, refreshToken, err := a.authService.VerifyAccessToken(accessToken)
+	user, refreshToken, err := a.authService.VerifyAccessToken(ctx, accessToken)
 	if err == nil && user != nil {
 		// Access token còn valid → không cần refresh
 		c.JSON(http.StatusOK, gin.H{
@@ -96,8 +130,11 @@ func (a *AuthHandler) AuthRefreshToken(c *gin.Context) {
 	// 3️⃣ Nếu access token expired nhưng có refresh token
 	if err == service.ErrInvalidOrExpired && refreshToken != "" {
 		// Verify refresh token
-		newToken, err := a.authService.RefreshToken(refreshToken, accessToken, a.googleService.OAuthConfig)
-		fmt.Println(newToken, err)
+		var googleConfig *oauth2.Config
+		if a.googleService != nil {
+			googleConfig = a.googleService.OAuthConfig
+		}
+		newToken, err := a.authService.RefreshToken(ctx, refreshToken, accessToken, googleConfig)
 		if err != nil {
 			// Refresh token invalid → yêu cầu login lại
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -124,53 +161,50 @@ func (a *AuthHandler) AuthRefreshToken(c *gin.Context) {
 }
 
 func (a *AuthHandler) Register(c *gin.Context) {
+	ctx := c.Request.Context()
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// --- Khởi tạo repository và service ---
-	userRepo := repository.NewUserRepository()
-	userService := service.NewUserService(userRepo)
-
-	// --- Kiểm tra email đã tồn tại chưa ---
-	exists, err := userService.CheckEmail(req.Email)
-	println("ERROR:", err)
+	// --- Email đã có tài khoản: trả về giống hệt đăng ký thành công để không lộ email nào đã đăng ký ---
+	exists, err := a.userService.CheckEmail(ctx, req.Email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check email existence"})
 		return
 	}
 	if exists {
-		c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+		// Băm mật khẩu như khi tạo user để thời gian phản hồi không khác nhau
+		_, _ = utils.HashPassword(req.Password)
+		respondRegistered(c)
 		return
 	}
 
-	user, err := userService.RegisterUser(req.Name, req.Email, req.Password)
+	user, err := a.userService.RegisterUser(ctx, req.Name, req.Email, req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user: " + err.Error()})
 		return
 	}
 
-	// --- Tự động đăng nhập và tạo session sau khi đăng ký ---
-
-	ip := c.ClientIP()
-	userAgent := c.Request.UserAgent()
-
-	token, _, err := a.authService.CreateSession(user, ip, userAgent, "", "", 0, "local")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session after registration: " + err.Error()})
-		return
+	// --- Gửi email xác thực (không chặn đăng ký nếu gửi lỗi, user có thể gửi lại) ---
+	if err := a.verificationService.SendVerificationEmail(ctx, user, requestLocale(c)); err != nil {
+		slog.ErrorContext(ctx, "send verification email failed", "user_id", user.ID, "err", err)
 	}
 
-	// --- Trả về token ---
+	// Không tự đăng nhập: response có token sẽ khác với trường hợp email đã tồn tại
+	respondRegistered(c)
+}
+
+// respondRegistered là response chung của Register, user đăng nhập sau khi đăng ký
+func respondRegistered(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "User registered successfully",
-		"token":   token,
+		"message": "Registration received. Please check your email to verify your account, then log in.",
 	})
 }
 
 func (a *AuthHandler) LoginPassword(c *gin.Context) {
+	ctx := c.Request.Context()
 	var req LoginRequest
 	if err := c.BindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
@@ -181,126 +215,187 @@ func (a *AuthHandler) LoginPassword(c *gin.Context) {
 		return
 	}
 
-	// --- Xác thực người dùng ---
-	userRepo := repository.NewUserRepository()
-	userService := service.NewUserService(userRepo)
-	user, err := userService.LoginPassword(req.Email, req.Password)
-	if err != nil || user == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		return
-	}
-
 	ip := c.ClientIP()
 	userAgent := c.Request.UserAgent()
 
-	// Gọi service để tạo session.
-	// Truyền chuỗi rỗng và 0 vì đây là đăng nhập bằng mật khẩu, không có token/expiresIn từ bên ngoài.
-	token, _, err := a.authService.CreateSession(user, ip, userAgent, "", "", 0, "local")
+	// --- Chặn nếu tài khoản hoặc IP đang bị khóa do sai nhiều lần ---
+	// Lượt thử được tính trước khi kiểm tra mật khẩu để request song song không vượt qua backoff
+	attempt, retryAfter, err := a.loginGuard.Reserve(ctx, req.Email, ip)
+	if errors.Is(err, service.ErrLoginLocked) {
+		respondTooManyRequests(c, err, retryAfter)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session: " + err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
 		return
 	}
-	c.JSON(http.StatusAccepted, gin.H{"access_token": token.AccessToken})
-}
 
-func (a *AuthHandler) CheckEmailExist(c *gin.Context) {
-	var req EmailRequest
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+	// --- Xác thực người dùng ---
+	user, err := a.userService.LoginPassword(ctx, req.Email, req.Password)
+	if err != nil || user == nil {
+		if err := a.loginGuard.RecordFailure(ctx, attempt, requestLocale(c)); err != nil {
+			slog.ErrorContext(ctx, "record failed login attempt failed", "client_ip", ip, "err", err)
+		}
+		if !errors.Is(err, repository.ErrInvalidCredentials) {
+			slog.ErrorContext(ctx, "password login failed", "err", err)
+		}
+		// Luôn trả cùng một thông báo để không lộ email có tồn tại hay không
+		c.JSON(http.StatusUnauthorized, gin.H{"error": repository.ErrInvalidCredentials.Error()})
 		return
 	}
+	if err := a.loginGuard.RecordSuccess(ctx, req.Email); err != nil {
+		slog.ErrorContext(ctx, "reset failed login attempts failed", "user_id", user.ID, "err", err)
+	}
 
-	repo := repository.NewUserRepository()
-	userService := service.NewUserService(repo)
-
-	exists, err := userService.CheckEmail(req.Email)
+	// Gọi service để tạo session.
+	// Truyền chuỗi rỗng và 0 vì đây là đăng nhập bằng mật khẩu, không có token/expiresIn từ bên ngoài.
+	token, _, err := a.authService.CreateSession(ctx, user, ip, userAgent, "", "", 0, "local")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session: " + err.Error()})
 		return
 	}
+	c.JSON(http.StatusAccepted, gin.H{"access_token": token.AccessToken})
+}
 
-	if exists {
-		c.JSON(http.StatusOK, gin.H{"message": "user exists"})
-		return
-	} else {
-		c.JSON(http.StatusNotFound, gin.H{"message": "email not exist"})
-		return
-	}
+// respondTooManyRequests trả 429 kèm header Retry-After (giây)
+func respondTooManyRequests(c *gin.Context, err error, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", fmt.Sprint(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       err.Error(),
+		"retry_after": seconds,
+	})
 }
 
 // ForgotPassword: tạo reset token và gửi link (không tiết lộ email tồn tại)
 func (a *AuthHandler) ForgotPassword(c *gin.Context) {
+	ctx := c.Request.Context()
 	var req ForgotPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	userRepo := repository.NewUserRepository()
-	user, err := userRepo.GetUserByEmail(req.Email)
-	if user != nil && user.Provider != "local" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "This email is registered via " + user.Provider})
-		return
+	// Luôn trả cùng một thông báo, lỗi gửi mail chỉ được ghi log
+	if err := a.passwordResetService.RequestPasswordReset(ctx, req.Email, requestLocale(c)); err != nil {
+		slog.ErrorContext(ctx, "request password reset failed", "err", err)
 	}
-	if err != nil || user == nil {
-		c.JSON(http.StatusOK, gin.H{"message": "If the email exists, a reset link was sent"})
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email exists, a reset link was sent"})
+}
+
+// ResetPassword: verify token và cập nhật mật khẩu
+func (a *AuthHandler) ResetPassword(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Tạo reset token
-	token, err := utils.GenerateResetToken(user.ID)
+	claims, err := utils.ValidateResetToken(req.Token)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset token"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Build reset link -> frontend sẽ có route nhận token
-	frontendURL := os.Getenv("DEFAULT_URL")
-	if frontendURL == "" {
-		frontendURL = "http://localhost:5173"
+	if err := a.passwordResetService.ResetPassword(ctx, claims.UserID, req.Password); err != nil {
+		if errors.Is(err, service.ErrResetUserNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
 	}
-	resetLink := fmt.Sprintf("%s/reset-password?token=%s", frontendURL, token)
 
-	// Gửi email (placeholder)
-	_ = utils.SendResetEmail(user.Email, resetLink)
-
-	c.JSON(http.StatusOK, gin.H{"message": "If the email exists, a reset link was sent"})
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
 }
 
-// ResetPassword: verify token và cập nhật mật khẩu
-func (a *AuthHandler) ResetPassword(c *gin.Context) {
-	var req ResetPasswordRequest
+// VerifyEmail: xác thực email bằng token trong link đã gửi
+func (a *AuthHandler) VerifyEmail(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req VerifyEmailRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	claims, err := utils.ValidateResetToken(req.Token)
+	user, err := a.verificationService.VerifyEmail(ctx, req.Token)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	userRepo := repository.NewUserRepository()
-	user, err := userRepo.GetUserByID(claims.UserID)
-	if err != nil || user == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Email has been verified",
+		"email_verified_at": user.EmailVerifiedAt,
+	})
+}
+
+// ResendVerificationEmail: gửi lại email xác thực cho user đang đăng nhập
+func (a *AuthHandler) ResendVerificationEmail(c *gin.Context) {
+	ctx := c.Request.Context()
+	userValue, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	user, ok := userValue.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user type"})
 		return
 	}
 
-	// Hash password
-	hashed, err := utils.HashPassword(req.Password)
+	retryAfter, err := a.verificationService.ResendVerificationEmail(ctx, user.ID, requestLocale(c))
+	if errors.Is(err, service.ErrEmailAlreadyVerified) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrVerificationThrottled) {
+		respondTooManyRequests(c, err, retryAfter)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email: " + err.Error()})
 		return
 	}
 
-	// Cập nhật mật khẩu. Giả sử repo cung cấp UpdateUser hoặc UpdatePassword
-	user.Password = hashed
-	if err := userRepo.UpdateUser(user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// RequestMagicLink: gửi link đăng nhập không cần mật khẩu (không tiết lộ email tồn tại)
+func (a *AuthHandler) RequestMagicLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+	if err := a.magicLinkService.RequestMagicLink(ctx, req.Email, requestLocale(c)); err != nil {
+		slog.ErrorContext(ctx, "send magic link failed", "err", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email exists, a login link was sent"})
+}
+
+// ConsumeMagicLink: đổi token trong link lấy session đăng nhập
+func (a *AuthHandler) ConsumeMagicLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req ConsumeMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, token, err := a.magicLinkService.ConsumeMagicLink(ctx, req.Token, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":   token.AccessToken,
+		"email_verified": service.IsEmailVerified(user),
+	})
 }
@@ -1,18 +1,25 @@
 package handler
 
 import (
-	// 	"bytes"
-	// 	"encoding/json"
-	// 	"net/http"
-	// 	"net/http/httptest"
-	// 	"testing"
-	// 	"time"
-
-	// 	"project/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"project/models"
+	"project/pkg/mailer"
+	"project/repository"
+	"project/service"
+	"project/utils"
 
 	"github.com/gin-gonic/gin"
-	// 	"github.com/google/uuid"
-	// 	"github.com/stretchr/testify/assert"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
 )
 
 func init() {
@@ -108,3 +115,102 @@ func init() {
 // 	ResetPassword(c)
 // 	assert.Equal(t, http.StatusBadRequest, w.Code)
 // }
+
+// Register trả về cùng một response cho email mới và email đã có tài khoản
+func TestRegisterDoesNotRevealExistingEmail(t *testing.T) {
+	var created []string
+	userRepo := &repository.MockUserRepository{
+		MockGetUserByEmail: func(ctx context.Context, email string) (*models.User, error) {
+			if email == "taken@example.com" {
+				return &models.User{ID: uuid.New(), Email: email}, nil
+			}
+			return nil, nil
+		},
+		MockCreateUser: func(ctx context.Context, user *models.User) (*models.User, error) {
+			user.ID = uuid.New()
+			created = append(created, user.Email)
+			return user, nil
+		},
+	}
+	templates, err := mailer.LoadTemplates()
+	require.NoError(t, err)
+	mail := mailer.NewMemoryMailer()
+	verification := service.NewVerificationService(userRepo, nil, mailer.NewTemplateMailer(mail, templates), service.ParseVerificationPolicy(nil, 0), "http://localhost:5173")
+	h := NewAuthHandler(service.NewUserService(userRepo), nil, nil, verification, nil, nil, nil)
+
+	register := func(email string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		body, _ := json.Marshal(map[string]string{"name": "Alice", "email": email, "password": "Secret123"})
+		c.Request = httptest.NewRequest(http.MethodPost, "/auth/register", bytes.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		h.Register(c)
+		return w
+	}
+
+	fresh := register("new@example.com")
+	taken := register("taken@example.com")
+	assert.Equal(t, http.StatusCreated, fresh.Code)
+	assert.Equal(t, fresh.Code, taken.Code)
+	assert.JSONEq(t, fresh.Body.String(), taken.Body.String())
+	assert.NotContains(t, fresh.Body.String(), "token")
+	assert.Equal(t, []string{"new@example.com"}, created)
+	require.Len(t, mail.Messages(), 1)
+	assert.Equal(t, "new@example.com", mail.Messages()[0].To)
+}
+
+// AuthRefreshToken làm mới access token hết hạn cho cả token local và token Google
+func TestAuthRefreshToken(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Email: "alice@example.com"}
+	localRefresh, err := utils.GenerateRefreshToken(user.ID)
+	require.NoError(t, err)
+
+	origTokenSource := service.TokenSourceFunc
+	t.Cleanup(func() { service.TokenSourceFunc = origTokenSource })
+	service.TokenSourceFunc = func(cfg *oauth2.Config, ctx context.Context, t *oauth2.Token) oauth2.TokenSource {
+		return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "google-access-2", RefreshToken: t.RefreshToken, Expiry: time.Now().Add(time.Hour)})
+	}
+
+	newHandler := func(tokenType, refresh string, google *service.GoogleService) *AuthHandler {
+		tokenRepo := &repository.MockTokenRepository{
+			MockGetUserForAccessToken: func(ctx context.Context, accessToken string) (*models.User, string, error) {
+				return nil, refresh, nil
+			},
+			MockGetTokenByRefresh: func(ctx context.Context, r string) (*models.Token, error) {
+				return &models.Token{ID: uuid.New(), AccessToken: "expired", RefreshToken: r, TokenType: tokenType}, nil
+			},
+		}
+		userRepo := &repository.MockUserRepository{
+			MockGetUserByAccesToken: func(ctx context.Context, accessToken string) (*models.User, error) { return user, nil },
+		}
+		authService := service.NewAuthService(userRepo, nil, tokenRepo, repository.NewMemoryRedisRepository(), nil, nil)
+		return NewAuthHandler(service.NewUserService(userRepo), authService, google, nil, nil, nil, nil)
+	}
+	refresh := func(h *AuthHandler) (*httptest.ResponseRecorder, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/auth/refresh", nil)
+		c.Request.Header.Set("Authorization", "Bearer expired")
+		h.AuthRefreshToken(c)
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w, body
+	}
+
+	t.Run("local token without google service", func(t *testing.T) {
+		w, body := refresh(newHandler("local", localRefresh, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, body["access_token"])
+		assert.NotEqual(t, "expired", body["access_token"])
+	})
+	t.Run("google token", func(t *testing.T) {
+		w, body := refresh(newHandler("google", "google-refresh", service.NewGoogleService(&oauth2.Config{ClientID: "id"})))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "google-access-2", body["access_token"])
+	})
+	t.Run("google token without google config", func(t *testing.T) {
+		w, body := refresh(newHandler("google", "google-refresh", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, true, body["require_login"])
+	})
+}
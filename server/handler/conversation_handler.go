@@ -24,6 +24,7 @@ func NewConversationHandler(conversationService *service.ConversationService) *C
 	}
 }
 func (h *ConversationHandler) GetUserConversationsByUserID(c *gin.Context) {
+	ctx := c.Request.Context()
 	// 1️⃣ Lấy user từ context
 	userValue, exists := c.Get("user")
 	if !exists {
@@ -58,7 +59,7 @@ func (h *ConversationHandler) GetUserConversationsByUserID(c *gin.Context) {
 	}
 
 	// 4️⃣ Gọi service
-	conversations, err := h.conversationService.GetUserConversations(user.ID.String(), limitInt, beforePtr)
+	conversations, err := h.conversationService.GetUserConversations(ctx, user.ID.String(), limitInt, beforePtr)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -71,6 +72,7 @@ func (h *ConversationHandler) GetUserConversationsByUserID(c *gin.Context) {
 }
 
 func (h *ConversationHandler) FindConversationByUser(c *gin.Context) {
+	ctx := c.Request.Context()
 	var req FindUserConversationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -87,7 +89,7 @@ func (h *ConversationHandler) FindConversationByUser(c *gin.Context) {
 		return
 	}
 
-	conversation, err := h.conversationService.FindConversationBytwoUserIDs(user.ID.String(), req.UserID)
+	conversation, err := h.conversationService.FindConversationBytwoUserIDs(ctx, user.ID.String(), req.UserID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -98,6 +100,7 @@ func (h *ConversationHandler) FindConversationByUser(c *gin.Context) {
 }
 
 func (h *ConversationHandler) GetMessageByConversationID(c *gin.Context) {
+	ctx := c.Request.Context()
 	userValue, exists := c.Get("user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
@@ -125,7 +128,7 @@ func (h *ConversationHandler) GetMessageByConversationID(c *gin.Context) {
 		}
 		beforePtr = beforeTime
 	}
-	messages, err := h.conversationService.GetMessageByConversationID(conversationID, limitInt, beforePtr)
+	messages, err := h.conversationService.GetMessageByConversationID(ctx, conversationID, limitInt, beforePtr)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -136,6 +139,7 @@ func (h *ConversationHandler) GetMessageByConversationID(c *gin.Context) {
 }
 
 func (h *ConversationHandler) SendMessage(c *gin.Context) {
+	ctx := c.Request.Context()
 	userValue, exists := c.Get("user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
@@ -159,7 +163,7 @@ func (h *ConversationHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	message, err := h.conversationService.SendMessage(req.ConversationID, user.ID.String(), req.Content)
+	message, err := h.conversationService.SendMessage(ctx, req.ConversationID, user.ID.String(), req.Content)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1,21 +1,38 @@
 package handler
 
 import (
+	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"project/models"
 	"project/pkg/storage"
+	"project/service"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// rangeRedirectTTL là thời hạn presigned URL khi redirect request Range sang storage
+const rangeRedirectTTL = 5 * time.Minute
+
 type FileHandler struct {
-	Spaces *storage.SpacesClient
+	store             storage.Store
+	attachmentService *service.AttachmentService
 }
 
-func NewFileHandler(spaces *storage.SpacesClient) *FileHandler {
-	return &FileHandler{Spaces: spaces}
+func NewFileHandler(store storage.Store, attachmentService *service.AttachmentService) *FileHandler {
+	return &FileHandler{store: store, attachmentService: attachmentService}
 }
 
+// Upload lưu file thành attachment, client gửi attachment.id kèm message để chia sẻ
 func (h *FileHandler) Upload(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
 	file, err := c.FormFile("file")
 
 	if err != nil {
@@ -30,24 +47,335 @@ func (h *FileHandler) Upload(c *gin.Context) {
 	}
 	defer src.Close()
 
-	url, err := h.Spaces.UploadFile(src, file.Filename, file.Header.Get("Content-Type"))
+	attachment, err := h.attachmentService.Upload(c.Request.Context(), user.ID, file.Filename, src, file.Size)
+	if rejectUpload(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"url": url,
+		"attachment": attachment,
 	})
 }
 
-func (h *FileHandler) GetFile(c *gin.Context) {
-	filename := c.Param("filename")
-	data, contentType, err := h.Spaces.GetFile(filename)
+// DownloadAttachment chỉ cho participant của conversation (hoặc uploader khi chưa gửi) tải file
+func (h *FileHandler) DownloadAttachment(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	attachment, err := h.attachmentService.GetForDownload(ctx, id, user.ID)
+	switch {
+	case errors.Is(err, service.ErrAttachmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	case errors.Is(err, service.ErrAttachmentForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrAttachmentScanning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrAttachmentInfected):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// ?rendition=thumb|small|large trả về bản thu nhỏ của ảnh
+	if name := c.Query("rendition"); name != "" {
+		for _, r := range attachment.Renditions {
+			if r.Name == name {
+				serveObject(c, h.store, r.StorageKey)
+				return
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rendition not found"})
+		return
+	}
+
+	if attachment.FileName != "" {
+		c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.FileName}))
+	}
+	serveObject(c, h.store, attachment.StorageKey)
+}
+
+// SignURL cấp URL tải attachment có thời hạn, dùng trực tiếp trong <img>/<video> mà không cần access token
+func (h *FileHandler) SignURL(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	url, expiresAt, err := h.attachmentService.SignedURL(ctx, id, user.ID, c.Query("rendition"))
+	switch {
+	case errors.Is(err, service.ErrAttachmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	case errors.Is(err, service.ErrAttachmentForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrAttachmentScanning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrAttachmentInfected):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"url": url, "expires_at": expiresAt})
+}
+
+// ServeSignedFile phục vụ /files/:id bằng signed URL (không qua auth middleware), hỗ trợ Range để tua video
+func (h *FileHandler) ServeSignedFile(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	attachment, key, expiresAt, err := h.attachmentService.OpenSignedURL(ctx, id, c.Request.URL.Query())
+	switch {
+	case errors.Is(err, service.ErrInvalidFileURL), errors.Is(err, service.ErrAttachmentForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrAttachmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	case errors.Is(err, service.ErrAttachmentScanning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrAttachmentInfected):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Browser chỉ cache tới khi URL hết hạn, không cho proxy dùng chung
+	maxAge := int(time.Until(expiresAt).Seconds())
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(max(maxAge, 0)))
+	if key == attachment.StorageKey {
+		if attachment.Checksum != "" {
+			c.Header("ETag", `"`+attachment.Checksum+`"`)
+		}
+		if attachment.FileName != "" {
+			c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.FileName}))
+		}
+	}
+	serveObject(c, h.store, key)
+}
+
+// currentUser lấy user đã xác thực từ context, tự trả lỗi nếu không có
+func currentUser(c *gin.Context) (*models.User, bool) {
+	userValue, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return nil, false
+	}
+	user, ok := userValue.(*models.User)
+	if !ok || user == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user type"})
+		return nil, false
+	}
+	return user, true
+}
+
+// serveObject trả object từ store về client. Reader seek được (local store) dùng
+// http.ServeContent để hỗ trợ Range/If-Modified-Since/If-None-Match.
+// Backend không seek được (S3) thì request có Range được redirect sang presigned URL của store.
+func serveObject(c *gin.Context, store storage.Store, key string) {
+	if c.GetHeader("Range") != "" {
+		if _, local := store.(*storage.LocalStore); !local {
+			url, err := store.PresignGet(c.Request.Context(), key, rangeRedirectTTL)
+			if err == nil {
+				c.Redirect(http.StatusFound, url)
+				return
+			}
+		}
+	}
+	rc, info, err := store.Get(c.Request.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	defer rc.Close()
 
-	c.Data(http.StatusOK, contentType, data)
+	c.Header("Content-Type", info.ContentType)
+	// Không cho browser đoán lại type (vd. file text chứa HTML)
+	c.Header("X-Content-Type-Options", "nosniff")
+	if rs, ok := rc.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, info.Key, info.ModTime, rs)
+		return
+	}
+	c.DataFromReader(http.StatusOK, info.Size, info.ContentType, rc, nil)
+}
+
+// CreateUpload trả về presigned URL để client upload thẳng lên storage, không đi qua server
+func (h *FileHandler) CreateUpload(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		FileName    string `json:"file_name" binding:"required"`
+		ContentType string `json:"content_type" binding:"required"`
+		Size        int64  `json:"size" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	session, err := h.attachmentService.CreateUpload(c.Request.Context(), user.ID, req.FileName, req.ContentType, req.Size)
+	if rejectUpload(c, err) {
+		return
+	}
+	if errors.Is(err, service.ErrInvalidUpload) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// CompleteUpload xác nhận client đã upload xong, attachment chỉ dùng được sau bước này
+func (h *FileHandler) CompleteUpload(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+	var req struct {
+		Parts []storage.CompletedPart `json:"parts"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	attachment, err := h.attachmentService.CompleteUpload(c.Request.Context(), user.ID, uploadID, req.Parts)
+	if rejectUpload(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrAttachmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	case errors.Is(err, service.ErrInvalidUpload), errors.Is(err, service.ErrAttachmentNotReady):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrUploadAlreadyCompleted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrUploadMismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"attachment": attachment,
+	})
+}
+
+// AbortUpload hủy upload đang dở
+func (h *FileHandler) AbortUpload(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+	err = h.attachmentService.AbortUpload(c.Request.Context(), user.ID, uploadID)
+	switch {
+	case errors.Is(err, service.ErrAttachmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	case errors.Is(err, service.ErrUploadAlreadyCompleted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// rejectUpload trả lỗi có cấu trúc khi upload vi phạm policy: 415 cho type không cho phép,
+// 413 cho file quá lớn hoặc hết quota. Trả về false nếu err không phải lỗi policy.
+func rejectUpload(c *gin.Context, err error) bool {
+	var policyErr *service.UploadPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+	status := http.StatusRequestEntityTooLarge
+	switch policyErr.Code {
+	case service.UploadErrTypeNotAllowed:
+		status = http.StatusUnsupportedMediaType
+	case service.UploadErrInvalidMedia:
+		status = http.StatusUnprocessableEntity
+	}
+	body := gin.H{"error": policyErr.Message, "code": policyErr.Code}
+	if policyErr.Limit > 0 {
+		body["limit"] = policyErr.Limit
+	}
+	if policyErr.MimeType != "" {
+		body["mime_type"] = policyErr.MimeType
+	}
+	c.JSON(status, body)
+	return true
+}
+
+// StorageUsage trả về dung lượng user đã dùng và quota
+func (h *FileHandler) StorageUsage(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	usage, err := h.attachmentService.StorageUsage(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
 }
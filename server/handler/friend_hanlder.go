@@ -30,8 +30,8 @@ func NewFriendHandler(friendService *service.FriendService, hub *websocket.Hub,c
 }
 
 func (r *FriendHandler) SendInviteFriend(c *gin.Context) {
+	ctx := c.Request.Context()
 	user, _ := c.Get("user")
-	println("user", user)
 	if user == nil {
 		c.JSON(400, gin.H{"error": "user not found in context"})
 		return
@@ -46,7 +46,7 @@ func (r *FriendHandler) SendInviteFriend(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "Invalid UUID format"})
 		return
 	}
-	err := r.friendService.SendInviteFriend(req.UserID, req.FriendID)
+	err := r.friendService.SendInviteFriend(ctx, req.UserID, req.FriendID)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -65,6 +65,7 @@ func (r *FriendHandler) SendInviteFriend(c *gin.Context) {
 }
 
 func (r *FriendHandler) CancelInviteFriend(c *gin.Context) {
+	ctx := c.Request.Context()
 	var req InviteFriendRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
@@ -74,7 +75,7 @@ func (r *FriendHandler) CancelInviteFriend(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "Invalid UUID format"})
 		return
 	}
-	err := r.friendService.CancelInviteFriend(req.UserID, req.FriendID)
+	err := r.friendService.CancelInviteFriend(ctx, req.UserID, req.FriendID)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -82,6 +83,7 @@ func (r *FriendHandler) CancelInviteFriend(c *gin.Context) {
 	c.JSON(200, gin.H{"message": "Invite canceled successfully"})
 }
 func (r *FriendHandler) GetListInviteFriend(c *gin.Context) {
+	ctx := c.Request.Context()
 	user, exits := c.Get("user")
 	if !exits {
 		c.JSON(400, gin.H{"error": "user_id query parameter is required"})
@@ -93,7 +95,7 @@ func (r *FriendHandler) GetListInviteFriend(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "Invalid UUID format"})
 		return
 	}
-	list, err := r.friendService.GetListsFriendInvite(userID)
+	list, err := r.friendService.GetListsFriendInvite(ctx, userID)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -102,6 +104,7 @@ func (r *FriendHandler) GetListInviteFriend(c *gin.Context) {
 }
 
 func (r *FriendHandler) AcceptInviteFriend(c *gin.Context) {
+	ctx := c.Request.Context()
 	var req InviteFriendRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
@@ -111,7 +114,7 @@ func (r *FriendHandler) AcceptInviteFriend(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "Invalid UUID format"})
 		return
 	}
-	err := r.friendService.AcceptInviteFriend(req.UserID, req.FriendID)
+	err := r.friendService.AcceptInviteFriend(ctx, req.UserID, req.FriendID)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -134,7 +137,7 @@ func (r *FriendHandler) AcceptInviteFriend(c *gin.Context) {
 	}
 	r.hub.SendToUsers([]string{req.UserID, req.FriendID}, json.RawMessage(payload))
 	// create conversation
-	_, err = r.conversationService.CreateDirectConversation(req.UserID, req.FriendID)
+	_, err = r.conversationService.CreateDirectConversation(ctx, req.UserID, req.FriendID)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -144,6 +147,7 @@ func (r *FriendHandler) AcceptInviteFriend(c *gin.Context) {
 }
 
 func (r *FriendHandler) RejectInviteFriend(c *gin.Context) {
+	ctx := c.Request.Context()
 	var req InviteFriendRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
@@ -153,7 +157,7 @@ func (r *FriendHandler) RejectInviteFriend(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "Invalid UUID format"})
 		return
 	}
-	err := r.friendService.RejectInviteFriend(req.UserID, req.FriendID)
+	err := r.friendService.RejectInviteFriend(ctx, req.UserID, req.FriendID)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -161,6 +165,7 @@ func (r *FriendHandler) RejectInviteFriend(c *gin.Context) {
 	c.JSON(200, gin.H{"message": "Invite rejected successfully"})
 }
 func (r *FriendHandler) RemoveFriend(c *gin.Context) {
+	ctx := c.Request.Context()
 	var req InviteFriendRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
@@ -170,7 +175,7 @@ func (r *FriendHandler) RemoveFriend(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "Invalid UUID format"})
 		return
 	}
-	err := r.friendService.RemoveFriend(req.UserID, req.FriendID)
+	err := r.friendService.RemoveFriend(ctx, req.UserID, req.FriendID)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -179,6 +184,7 @@ func (r *FriendHandler) RemoveFriend(c *gin.Context) {
 }
 
 func (r *FriendHandler) GetListFriends(c *gin.Context) {
+	ctx := c.Request.Context()
 	user, exits := c.Get("user")
 	if !exits {
 		c.JSON(400, gin.H{"error": "user_id query parameter is required"})
@@ -190,7 +196,7 @@ func (r *FriendHandler) GetListFriends(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "Invalid UUID format"})
 		return
 	}
-	list, err := r.friendService.GetListFriends(userID)
+	list, err := r.friendService.GetListFriends(ctx, userID)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
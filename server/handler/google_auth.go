@@ -1,11 +1,10 @@
 package handler
 
 import (
-	"fmt"
-	"log"
+	"crypto/subtle"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"project/service"
 
 	"github.com/gin-gonic/gin"
@@ -16,25 +15,24 @@ import (
 type AuthGoogleHandler struct {
 	authService       *service.AuthService
 	GoogleOAuthConfig *oauth2.Config
+	redirect          OAuthRedirectConfig
 }
 
-func NewAuthGoogleHandler(authService *service.AuthService, GoogleOAuthConfig *oauth2.Config) *AuthGoogleHandler {
+func NewAuthGoogleHandler(authService *service.AuthService, GoogleOAuthConfig *oauth2.Config, redirect OAuthRedirectConfig) *AuthGoogleHandler {
 	handler := &AuthGoogleHandler{
 		authService:       authService,
 		GoogleOAuthConfig: GoogleOAuthConfig,
+		redirect:          redirect,
 	}
 
 	// Log OAuth configuration on startup
-	log.Println("✅ [GoogleAuth] Handler initialized")
-	log.Printf("   Client ID: %s", GoogleOAuthConfig.ClientID)
-	log.Printf("   Redirect URL: %s", GoogleOAuthConfig.RedirectURL)
+	slog.Info("google auth handler initialized", "client_id", GoogleOAuthConfig.ClientID, "redirect_url", GoogleOAuthConfig.RedirectURL)
 
 	return handler
 }
 
 func (h *AuthGoogleHandler) InitGoogleOAuth(clientID, clientSecret, redirectURL string) {
-	fmt.Println("Initializing Google OAuth with Client ID:", clientID)
-	fmt.Println("Client Secret:", clientSecret)
+	slog.Info("initializing Google OAuth", "client_id", clientID)
 	h.GoogleOAuthConfig = &oauth2.Config{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
@@ -48,43 +46,59 @@ func (h *AuthGoogleHandler) InitGoogleOAuth(clientID, clientSecret, redirectURL
 }
 
 func (h *AuthGoogleHandler) GoogleLoginHandler(c *gin.Context) {
+	ctx := c.Request.Context()
 	// Store return URL if provided
 	returnURL := c.Query("return_url")
 	if returnURL != "" {
 		// Secure cookie for HTTPS
-		isSecure := os.Getenv("ENV") == "production"
-		c.SetCookie("return_url", returnURL, 300, "/", "", isSecure, true)
-		log.Printf("🔖 [GoogleAuth] Stored return_url: %s", returnURL)
+		c.SetCookie("return_url", returnURL, 300, "/", "", h.redirect.SecureCookies, true)
+		slog.DebugContext(ctx, "google login stored return url", "return_url", returnURL)
 	}
 
+	// State ngẫu nhiên lưu trong cookie, callback phải trả về đúng state này (chống CSRF)
+	state, err := randomOAuthState()
+	if err != nil {
+		h.redirect.redirectToFrontendError(c, "Không thể tạo phiên đăng nhập")
+		return
+	}
+	c.SetCookie("oauth_state", state, 300, "/", "", h.redirect.SecureCookies, true)
+
 	// Generate OAuth URL
 	authURL := h.GoogleOAuthConfig.AuthCodeURL(
-		"state-token",
+		state,
 		oauth2.AccessTypeOffline,
 		oauth2.SetAuthURLParam("prompt", "consent"),
 	)
 
-	log.Printf("🔗 [GoogleAuth] Redirecting to Google OAuth: %s", authURL)
+	slog.InfoContext(ctx, "google login redirect")
 	http.Redirect(c.Writer, c.Request, authURL, http.StatusTemporaryRedirect)
 }
 
 func (h *AuthGoogleHandler) GoogleCallBackHandler(c *gin.Context) {
+	ctx := c.Request.Context()
 	code := c.Query("code")
 	state := c.Query("state")
 
-	log.Printf("📥 [GoogleAuth] Callback received - Code: %s, State: %s",
-		truncateString(code, 20), state)
+	slog.DebugContext(ctx, "google callback received", "code", truncateString(code, 20))
+
+	// Validate state
+	expectedState, err := c.Cookie("oauth_state")
+	c.SetCookie("oauth_state", "", -1, "/", "", h.redirect.SecureCookies, true)
+	if err != nil || expectedState == "" || subtle.ConstantTimeCompare([]byte(expectedState), []byte(state)) != 1 {
+		slog.WarnContext(ctx, "google callback with invalid oauth state")
+		h.redirect.redirectToFrontendError(c, "Phiên đăng nhập không hợp lệ hoặc đã hết hạn")
+		return
+	}
 
 	// Validate authorization code
 	if code == "" {
-		log.Println("❌ [GoogleAuth] Missing authorization code")
-		h.redirectToFrontendError(c, "Thiếu mã xác thực từ Google")
+		slog.WarnContext(ctx, "google callback without authorization code")
+		h.redirect.redirectToFrontendError(c, "Thiếu mã xác thực từ Google")
 		return
 	}
 
 	// Process OAuth callback
-	log.Println("🔄 [GoogleAuth] Processing OAuth callback...")
-	user, tokenModel, device, err := h.authService.HandleGoogleCallback(
+	user, tokenModel, device, err := h.authService.HandleGoogleCallback(ctx,
 		code,
 		c.ClientIP(),
 		c.GetHeader("User-Agent"),
@@ -92,13 +106,12 @@ func (h *AuthGoogleHandler) GoogleCallBackHandler(c *gin.Context) {
 	)
 
 	if err != nil {
-		log.Printf("❌ [GoogleAuth] Callback error: %v", err)
-		h.redirectToFrontendError(c, "Đăng nhập thất bại: "+err.Error())
+		slog.ErrorContext(ctx, "google callback failed", "err", err)
+		h.redirect.redirectToFrontendError(c, "Đăng nhập thất bại: "+oidcErrorMessage(err))
 		return
 	}
 
-	log.Printf("✅ [GoogleAuth] Login successful - User: %s, Device: %s",
-		user.Email, device.ID)
+	slog.InfoContext(ctx, "google login succeeded", "user_id", user.ID, "device_id", device.ID)
 
 	// Get return URL from cookie
 	returnURL, err := c.Cookie("return_url")
@@ -107,44 +120,9 @@ func (h *AuthGoogleHandler) GoogleCallBackHandler(c *gin.Context) {
 	}
 
 	// Clear return URL cookie
-	isSecure := os.Getenv("ENV") == "production"
-	c.SetCookie("return_url", "", -1, "/", "", isSecure, true)
-
-	// Get frontend URL from environment
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		log.Println("⚠️ [GoogleAuth] FRONTEND_URL not set, using DEFAULT_URL")
-		frontendURL = os.Getenv("DEFAULT_URL")
-	}
-
-	// Construct redirect URL with token and return path
-	redirectURL := fmt.Sprintf(
-		"%s/auth/success?token=%s&refresh_token=%s&return_url=%s",
-		frontendURL,
-		tokenModel.AccessToken,
-		tokenModel.RefreshToken,
-		url.QueryEscape(returnURL),
-	)
-
-	log.Printf("🔗 [GoogleAuth] Redirecting to: %s", maskToken(redirectURL))
-	c.Redirect(http.StatusTemporaryRedirect, redirectURL)
-}
-
-// redirectToFrontendError redirects to frontend error page with message
-func (h *AuthGoogleHandler) redirectToFrontendError(c *gin.Context, errMsg string) {
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = os.Getenv("DEFAULT_URL")
-	}
-
-	redirectURL := fmt.Sprintf(
-		"%s/auth/error?msg=%s",
-		frontendURL,
-		url.QueryEscape(errMsg),
-	)
+	c.SetCookie("return_url", "", -1, "/", "", h.redirect.SecureCookies, true)
 
-	log.Printf("🔗 [GoogleAuth] Redirecting to error page: %s", redirectURL)
-	c.Redirect(http.StatusTemporaryRedirect, redirectURL)
+	h.redirect.redirectToFrontendSuccess(c, tokenModel, returnURL)
 }
 
 // Helper functions for logging
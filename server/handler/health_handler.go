@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+
+	"project/pkg/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// Healthz (liveness) chỉ cho biết process còn phục vụ HTTP. Không kiểm tra phụ thuộc
+// để DB/Redis down không làm orchestrator restart mọi replica cùng lúc.
+func (h *HealthHandler) Healthz(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Readyz (readiness) kiểm tra database, Redis, migration và storage, trả 503 kèm chi tiết nếu có check lỗi
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	report := h.checker.Run(c.Request.Context())
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
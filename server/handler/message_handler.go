@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"project/models"
 	"project/service"
@@ -26,6 +27,7 @@ func NewMessageHandler(messageService service.MessageService, hub *websocket.Hub
 }
 
 func (h *MessageHanlder) SendMessageToConversation(c *gin.Context) {
+	ctx := c.Request.Context()
 	userValue, exists := c.Get("user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
@@ -37,19 +39,36 @@ func (h *MessageHanlder) SendMessageToConversation(c *gin.Context) {
 		return
 	}
 	var req struct {
-		ConversationID string `json:"conversation_id" binding:"required"`
-		Content        string `json:"content" binding:"required"`
+		ConversationID string   `json:"conversation_id" binding:"required"`
+		Content        string   `json:"content"`
+		AttachmentIDs  []string `json:"attachment_ids"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
-	message, err := h.messageService.SendMessageToConversation(user.ID.String(), req.ConversationID, req.Content)
-	if err != nil {
+	message, err := h.messageService.SendMessageToConversation(ctx, user.ID.String(), req.ConversationID, req.Content, req.AttachmentIDs)
+	switch {
+	case errors.Is(err, service.ErrEmptyMessage), errors.Is(err, service.ErrTooManyAttachments):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrAttachmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrAttachmentNotOwned):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrAttachmentUsed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrAttachmentInfected):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	participants, err := h.participantService.GetParticipantsByConversationID(req.ConversationID)
+	participants, err := h.participantService.GetParticipantsByConversationID(ctx, req.ConversationID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -77,7 +96,6 @@ func (h *MessageHanlder) SendMessageToConversation(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode message"})
 		return
 	}
-	println("payload", string(payloadd))
 	h.hub.SendToUser(userid_to, payloadd)
 	c.JSON(http.StatusOK, gin.H{
 		"message": message,
@@ -85,6 +103,7 @@ func (h *MessageHanlder) SendMessageToConversation(c *gin.Context) {
 }
 
 func (h *MessageHanlder) GetAllMessageToConversation(c *gin.Context) {
+	ctx := c.Request.Context()
 
 	userValue, exists := c.Get("user")
 	if !exists {
@@ -108,7 +127,7 @@ func (h *MessageHanlder) GetAllMessageToConversation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "conversation_id is required"})
 		return
 	}
-	messages, err := h.messageService.GetAllMessageToConversation(conversationID, user.ID.String(), limitInt, nil)
+	messages, err := h.messageService.GetAllMessageToConversation(ctx, conversationID, user.ID.String(), limitInt, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"project/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthRedirectConfig cấu hình redirect về frontend sau khi đăng nhập OAuth/OIDC
+type OAuthRedirectConfig struct {
+	FrontendURL   string
+	SecureCookies bool // cookie state/return_url chỉ gửi qua HTTPS (production)
+}
+
+// redirectToFrontendSuccess redirects to frontend with token and return path
+func (r OAuthRedirectConfig) redirectToFrontendSuccess(c *gin.Context, tokenModel *models.Token, returnURL string) {
+	redirectURL := fmt.Sprintf(
+		"%s/auth/success?token=%s&refresh_token=%s&return_url=%s",
+		r.FrontendURL,
+		tokenModel.AccessToken,
+		tokenModel.RefreshToken,
+		url.QueryEscape(returnURL),
+	)
+
+	slog.InfoContext(c.Request.Context(), "oauth redirect to frontend", "url", maskToken(redirectURL))
+	c.Redirect(http.StatusTemporaryRedirect, redirectURL)
+}
+
+// redirectToFrontendError redirects to frontend error page with message
+func (r OAuthRedirectConfig) redirectToFrontendError(c *gin.Context, errMsg string) {
+	redirectURL := fmt.Sprintf(
+		"%s/auth/error?msg=%s",
+		r.FrontendURL,
+		url.QueryEscape(errMsg),
+	)
+
+	slog.WarnContext(c.Request.Context(), "oauth redirect to frontend error page", "url", redirectURL)
+	c.Redirect(http.StatusTemporaryRedirect, redirectURL)
+}
+
+func randomOAuthState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
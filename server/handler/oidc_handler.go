@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"project/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCHandler struct {
+	oidcService *service.OIDCService
+	redirect    OAuthRedirectConfig
+}
+
+func NewOIDCHandler(oidcService *service.OIDCService, redirect OAuthRedirectConfig) *OIDCHandler {
+	return &OIDCHandler{oidcService: oidcService, redirect: redirect}
+}
+
+// ListProviders GET /api/v1/auth/oidc/providers
+func (h *OIDCHandler) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.oidcService.Providers()})
+}
+
+// Login GET /api/v1/auth/oidc/:provider?return_url=...
+func (h *OIDCHandler) Login(c *gin.Context) {
+	ctx := c.Request.Context()
+	providerName := c.Param("provider")
+	authURL, err := h.oidcService.BeginLogin(ctx, providerName, c.Query("return_url"))
+	if err != nil {
+		if errors.Is(err, service.ErrOIDCProviderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		slog.ErrorContext(ctx, "oidc begin login failed", "provider", providerName, "err", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	slog.InfoContext(ctx, "oidc redirect to provider", "provider", providerName)
+	c.Redirect(http.StatusTemporaryRedirect, authURL)
+}
+
+// Callback GET /api/v1/auth/oidc/:provider/callback
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	ctx := c.Request.Context()
+	providerName := c.Param("provider")
+
+	if errMsg := c.Query("error"); errMsg != "" {
+		slog.WarnContext(ctx, "oidc provider returned error", "provider", providerName, "error", errMsg)
+		h.redirect.redirectToFrontendError(c, "Đăng nhập thất bại: "+errMsg)
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		h.redirect.redirectToFrontendError(c, "Thiếu mã xác thực từ "+providerName)
+		return
+	}
+
+	user, tokenModel, device, returnURL, err := h.oidcService.CompleteLogin(
+		ctx,
+		providerName,
+		c.Query("state"),
+		code,
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+	)
+	if err != nil {
+		slog.ErrorContext(ctx, "oidc callback failed", "provider", providerName, "err", err)
+		h.redirect.redirectToFrontendError(c, "Đăng nhập thất bại: "+oidcErrorMessage(err))
+		return
+	}
+
+	slog.InfoContext(ctx, "oidc login succeeded", "provider", providerName, "user_id", user.ID, "device_id", device.ID)
+	if returnURL == "" {
+		returnURL = "/dashboard"
+	}
+	h.redirect.redirectToFrontendSuccess(c, tokenModel, returnURL)
+}
+
+// oidcErrorMessage chỉ trả lỗi nghiệp vụ cho frontend, lỗi kỹ thuật được ẩn đi
+func oidcErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, service.ErrOIDCProviderNotFound),
+		errors.Is(err, service.ErrInvalidOAuthState),
+		errors.Is(err, service.ErrOIDCEmailMissing),
+		errors.Is(err, service.ErrOIDCEmailNotVerified),
+		errors.Is(err, service.ErrOIDCAccountNotLinked):
+		return err.Error()
+	default:
+		return "internal error"
+	}
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"project/models"
+	"project/pkg/storage"
+	"project/service"
+	"project/websocket"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAvatarSize giới hạn kích thước file avatar upload
+const maxAvatarSize = 10 << 20
+
+type ProfileHandler struct {
+	profileService *service.ProfileService
+	hub            *websocket.Hub
+	store          storage.Store
+}
+
+func NewProfileHandler(profileService *service.ProfileService, hub *websocket.Hub, store storage.Store) *ProfileHandler {
+	return &ProfileHandler{
+		profileService: profileService,
+		hub:            hub,
+		store:          store,
+	}
+}
+
+// UpdateProfile xử lý PATCH /users/me: sửa name, bio, status_text
+func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	var req service.ProfileUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	updated, err := h.profileService.UpdateProfile(ctx, user.ID, req)
+	if errors.Is(err, service.ErrInvalidProfile) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastProfile(ctx, updated, nil)
+	c.JSON(http.StatusOK, gin.H{"user": updated})
+}
+
+// UpdateAvatar xử lý PUT /users/me/avatar (multipart form, field "file")
+func (h *ProfileHandler) UpdateAvatar(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAvatarSize+1<<20)
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File not found"})
+		return
+	}
+	if file.Size > maxAvatarSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Avatar is too large"})
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot open file"})
+		return
+	}
+	defer src.Close()
+
+	updated, renditions, err := h.profileService.UpdateAvatar(c.Request.Context(), user.ID, src)
+	if errors.Is(err, service.ErrInvalidProfile) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastProfile(ctx, updated, renditions)
+	c.JSON(http.StatusOK, gin.H{
+		"user":              updated,
+		"avatar_renditions": renditions,
+	})
+}
+
+// SignAvatarURL xử lý GET /users/avatar-url?src=<avatar>: đổi URL avatar lưu trong profile thành signed URL
+func (h *ProfileHandler) SignAvatarURL(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	url, expiresAt, err := h.profileService.SignedAvatarURL(ctx, c.Query("src"), user.ID)
+	if errors.Is(err, service.ErrInvalidAvatarURL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if expiresAt.IsZero() {
+		c.JSON(http.StatusOK, gin.H{"url": url})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"url": url, "expires_at": expiresAt})
+}
+
+// ServeAvatar trả avatar trong storage bằng signed URL: /avatars/<userID>/<version>/<size>.jpg,
+// hoặc avatar cũ lưu dạng key "avatar/<file>" (ảnh Google) tại /avatars/<file>
+func (h *ProfileHandler) ServeAvatar(c *gin.Context) {
+	path := strings.TrimPrefix(c.Param("path"), "/")
+	key, expiresAt, err := h.profileService.VerifyAvatarURL(path, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	// Browser chỉ cache tới khi URL hết hạn
+	maxAge := int(time.Until(expiresAt).Seconds())
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(max(maxAge, 0)))
+	serveObject(c, h.store, key)
+}
+
+// broadcastProfile gửi profile_updated tới bạn bè đang online và các kết nối khác của chính user
+func (h *ProfileHandler) broadcastProfile(ctx context.Context, user *models.User, avatarRenditions map[string]string) {
+	friendIDs, err := h.profileService.FriendIDs(ctx, user.ID)
+	if err != nil {
+		slog.WarnContext(ctx, "list friends for profile broadcast failed", "user_id", user.ID, "err", err)
+		return
+	}
+	payload, err := json.Marshal(service.NewProfileEvent(user, avatarRenditions))
+	if err != nil {
+		return
+	}
+	// Bạn bè offline sẽ thấy profile mới khi tải lại danh sách, không cần log lỗi gửi
+	_ = h.hub.SendToUsers(append(friendIDs, user.ID.String()), payload)
+}
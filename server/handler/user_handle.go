@@ -2,7 +2,7 @@ package handler
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"project/models"
 
@@ -20,10 +20,10 @@ func NewUserHandler(userService *service.UserService) *UserHandler {
 }
 
 func (h *UserHandler) FindUserWithStatusFriends(c *gin.Context) {
+	ctx := c.Request.Context()
 	// Lấy query params
 	email := c.Query("email")
 	if email == "" {
-		log.Println("[FindUserWithStatusFriends] Missing email query parameter")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Email   query parameter is required"})
 		return
 	}
@@ -31,23 +31,19 @@ func (h *UserHandler) FindUserWithStatusFriends(c *gin.Context) {
 	// Lấy user từ context
 	userValue, exists := c.Get("user")
 	if !exists {
-		log.Println("[FindUserWithStatusFriends] User not found in context")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
 		return
 	}
 
 	user, ok := userValue.(*models.User)
 	if !ok {
-		log.Printf("[FindUserWithStatusFriends] Invalid user type: %T", userValue)
+		slog.ErrorContext(ctx, "invalid user type in context", "type", fmt.Sprintf("%T", userValue))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user type"})
 		return
 	}
 
-	log.Printf("[FindUserWithStatusFriends] Authenticated user: %s (%s)", user.Name, user.Email)
-
 	// Check không search chính mình
 	if user.Email == email {
-		log.Printf("[FindUserWithStatusFriends] User tried to search themselves: %s", email)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot search for yourself"})
 		return
 	}
@@ -55,27 +51,26 @@ func (h *UserHandler) FindUserWithStatusFriends(c *gin.Context) {
 	// Check email rỗng
 
 	// Call service
-	result, err := h.userService.FindUserWithStatusFriend(email, user.ID.String())
+	result, err := h.userService.FindUserWithStatusFriend(ctx, email, user.ID.String())
 	if err != nil {
-		log.Printf("[FindUserWithStatusFriends] Error finding user: %v", err)
+		slog.ErrorContext(ctx, "find user with friend status failed", "user_id", user.ID, "err", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Error finding user: " + err.Error()})
 		return
 	}
 
 	// Response
 	if result != nil {
-		log.Printf("[FindUserWithStatusFriends] User found: %+v", result)
 		c.JSON(http.StatusOK, result)
 		return
 	}
 
-	log.Printf("[FindUserWithStatusFriends] User not found for email: %s", email)
+	slog.DebugContext(ctx, "find user with friend status: not found", "user_id", user.ID)
 	c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 }
 
 func (h *UserHandler) FindUserByEmail(c *gin.Context) {
 	email := c.Query("email")
-	fmt.Println("email user find:" + email)
+	slog.DebugContext(c.Request.Context(), "find user by email", "email", email)
 	userValue, exists := c.Get("user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
@@ -1,16 +1,31 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"project/config"
 	"project/database"
 	"project/handler"
 	"project/middleware"
+	"project/pkg/health"
+	"project/pkg/logging"
+	"project/pkg/mailer"
+	"project/pkg/metrics"
+	"project/pkg/oidc"
+	"project/pkg/scanner"
+	"project/pkg/storage"
+	"project/pkg/tracing"
 	"project/repository"
 	"project/router"
 	"project/service"
+	"project/utils"
 	"project/websocket"
 
 	"github.com/gin-gonic/gin"
@@ -22,66 +37,219 @@ import (
 func main() {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
-		log.Println("⚠️ Không tìm thấy file .env, sử dụng biến môi trường hệ thống")
+		slog.Warn(".env not found, using system environment variables")
 	} else {
-		log.Println("✅ Đã load file .env thành công!")
+		slog.Info(".env loaded")
 	}
 
-	// Validate required environment variables
-	validateEnv()
+	// Subcommand: web_chat migrate up|down|status|create
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fatal("migrate failed", "err", err)
+		}
+		return
+	}
+
+	// Load configuration: defaults theo ENV < config.yaml/config.<env>.yaml (hoặc CONFIG_FILE) < biến môi trường
+	cfg, err := config.Load()
+	if err != nil {
+		fatal("invalid configuration", "err", err)
+	}
+	if err := logging.Setup(os.Stderr, cfg.Log.Level, cfg.Log.Format); err != nil {
+		fatal("invalid log configuration", "err", err)
+	}
+	slog.Info("configuration loaded", "config", cfg.String())
 
-	// Log environment configuration
-	logEnvironment()
+	// Tracing: defer đăng ký sớm nên chạy sau cùng, flush span của cả quá trình tắt server
+	if cfg.Tracing.Enabled {
+		shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+			Endpoint:    cfg.Tracing.Endpoint,
+			ServiceName: cfg.Tracing.ServiceName,
+			SampleRatio: cfg.Tracing.SampleRatio,
+		})
+		if err != nil {
+			fatal("init tracing failed", "err", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				slog.Warn("flush traces failed", "err", err)
+			}
+		}()
+		slog.Info("tracing enabled", "service", cfg.Tracing.ServiceName, "sample_ratio", cfg.Tracing.SampleRatio)
+	}
+	utils.SetJWTSecret(cfg.Auth.JWTSecret)
 
-	// Initialize database connections
-	database.ConnectDB()
-	database.InitRedis()
+	// Initialize database connections: main là nơi duy nhất giữ *gorm.DB/*redis.Client và truyền xuống repository
+	db := database.ConnectDB(cfg.Database)
+	defer database.CloseDB(db)
+	rdb := database.InitRedis(cfg.Redis)
 
 	defer func() {
-		database.CloseRedis()
-		log.Println("✅ Đã đóng kết nối Redis")
+		database.CloseRedis(rdb)
+		slog.Info("redis connection closed")
 	}()
 
 	// Initialize repositories
-	userRepo := repository.NewUserRepository()
-	deviceRepo := repository.NewDeviceRepository()
-	tokenRepo := repository.NewTokenRepository()
-	redisRepo := repository.NewRedisRepository()
-	friendRepo := repository.NewFriendshipRepository()
-	conversationRepo := repository.NewConversationRepository()
-	participantRepo := repository.NewParticipantRepository()
-	messageRepo := repository.NewMessageRepository()
+	userRepo := repository.NewUserRepository(db)
+	deviceRepo := repository.NewDeviceRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	redisRepo := repository.NewRedisRepository(rdb)
+	friendRepo := repository.NewFriendshipRepository(db)
+	conversationRepo := repository.NewConversationRepository(db)
+	participantRepo := repository.NewParticipantRepository(db)
+	messageRepo := repository.NewMessageRepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	attachmentRepo := repository.NewAttachmentRepository(db)
+	blobRepo := repository.NewBlobRepository(db)
+
+	// Initialize file storage (STORAGE_DRIVER=local|s3)
+	store, err := storage.New(context.Background(), initStorageConfig(cfg.Storage))
+	if err != nil {
+		fatal("init storage failed", "err", err)
+	}
 
 	// Initialize Google OAuth Config
-	googleOAuthConfig := initGoogleOAuth()
+	googleOAuthConfig := initGoogleOAuth(cfg.Google)
+
+	// Initialize OIDC providers (OIDC_PROVIDERS=github,gitlab,...)
+	oidcRegistry := oidc.LoadRegistry(context.Background(), initOIDCConfigs(cfg.OIDC))
+
+	// Initialize mailer: gửi email qua hàng đợi bất đồng bộ (MAIL_DRIVER=smtp|file|memory)
+	mailBackend, err := mailer.New(mailer.Config{
+		Driver:  cfg.Mail.Driver,
+		From:    cfg.Mail.From,
+		FileDir: cfg.Mail.FileDir,
+		SMTP: mailer.SMTPConfig{
+			Host:     cfg.Mail.SMTP.Host,
+			Port:     cfg.Mail.SMTP.Port,
+			Username: cfg.Mail.SMTP.Email,
+			Password: cfg.Mail.SMTP.Password,
+		},
+	})
+	if err != nil {
+		fatal("init mailer failed", "err", err)
+	}
+	mailTemplates, err := mailer.LoadTemplates()
+	if err != nil {
+		fatal("load email templates failed", "err", err)
+	}
+	mailQueueCfg := mailer.DefaultQueueConfig()
+	mailQueueCfg.Workers = cfg.Mail.QueueWorkers
+	mailQueueCfg.Size = cfg.Mail.QueueSize
+	mailQueueCfg.MaxRetries = cfg.Mail.MaxRetries
+	mailQueue := mailer.NewQueue(mailBackend, mailQueueCfg)
+	defer mailQueue.Close()
+	templateMailer := mailer.NewTemplateMailer(mailQueue, mailTemplates)
+
+	// Initialize content-addressed blob store: file giống hệt nhau chỉ lưu 1 lần
+	blobStore := service.NewBlobStore(blobRepo, store)
+	storageGC := service.NewStorageGC(attachmentRepo, blobRepo, userRepo, store, service.StorageGCConfig{
+		Interval:    cfg.Storage.GC.Interval,
+		GracePeriod: cfg.Storage.GC.Grace,
+		BatchSize:   cfg.Storage.GC.BatchSize,
+	})
+	storageGC.Start()
+	defer storageGC.Close()
+
+	// Initialize image pipeline: tạo thumbnail/preview và xóa EXIF ở background
+	imageCfg := service.DefaultImageProcessorConfig()
+	imageCfg.Workers = cfg.Image.Workers
+	imageCfg.QueueSize = cfg.Image.QueueSize
+	imageCfg.Options.MaxPixels = cfg.Image.MaxPixels
+	imageProcessor := service.NewImageProcessor(attachmentRepo, store, blobStore, imageCfg)
+	defer imageProcessor.Close()
+
+	// Initialize WebSocket hub
+	hub := websocket.NewHub(redisRepo)
+
+	// Initialize malware scanning: file upload chỉ tải được sau khi scanner xác nhận sạch (SCANNER_DRIVER=noop|clamav)
+	fileScanner, err := scanner.New(cfg.Scan.Driver, scanner.ClamAVConfig{Addr: cfg.Scan.ClamAVAddr, Timeout: cfg.Scan.ClamAVTimeout})
+	if err != nil {
+		fatal("init malware scanner failed", "err", err)
+	}
+	scanCfg := service.DefaultScanWorkerConfig()
+	scanCfg.Workers = cfg.Scan.Workers
+	scanCfg.QueueSize = cfg.Scan.QueueSize
+	scanCfg.ScanTimeout = cfg.Scan.Timeout
+	scanCfg.MaxRetries = cfg.Scan.MaxRetries
+	scanCfg.RequeueInterval = cfg.Scan.RequeueInterval
+	scanWorker := service.NewScanWorker(attachmentRepo, store, fileScanner, imageProcessor, hub, scanCfg)
+	defer scanWorker.Close()
+	if n, err := scanWorker.Requeue(context.Background()); err != nil {
+		slog.Warn("requeue pending scans failed", "err", err)
+	} else if n > 0 {
+		slog.Info("requeued pending scans", "count", n)
+	}
+
+	fileURLCfg := service.FileURLConfig{Secret: []byte(cfg.FileURL.Secret), TTL: cfg.FileURL.TTL}
+	uploadCfg := service.UploadConfig{
+		URLExpiry:          cfg.Upload.URLTTL,
+		MultipartThreshold: cfg.Upload.MultipartThreshold,
+		PartSize:           cfg.Upload.PartSize,
+		Policy: service.NewUploadPolicy(cfg.Upload.AllowedTypes, map[string]int64{
+			"image": cfg.Upload.MaxImageSize,
+			"video": cfg.Upload.MaxVideoSize,
+			"audio": cfg.Upload.MaxAudioSize,
+			"":      cfg.Upload.MaxFileSize,
+		}, cfg.Upload.UserQuota),
+	}
+	loginGuardCfg := service.DefaultLoginGuardConfig()
+	loginGuardCfg.MaxAttempts = cfg.Login.MaxAttempts
+	loginGuardCfg.MaxAttemptsPerIP = cfg.Login.MaxAttemptsPerIP
+	loginGuardCfg.LockoutDuration = cfg.Login.LockoutDuration
+	loginGuardCfg.Window = cfg.Login.AttemptWindow
+	frontendURL := cfg.Server.FrontendURL
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, deviceRepo, tokenRepo, redisRepo, googleOAuthConfig)
+	authService := service.NewAuthService(userRepo, deviceRepo, tokenRepo, redisRepo, googleOAuthConfig, store)
 	userService := service.NewUserService(userRepo)
 	conversationService := service.NewConversationService(conversationRepo, participantRepo, messageRepo, redisRepo)
-	messageService := service.NewMessageService(messageRepo, conversationRepo)
+	messageService := service.NewMessageService(messageRepo, conversationRepo, attachmentRepo)
+	attachmentService := service.NewAttachmentService(attachmentRepo, participantRepo, userRepo, store, blobStore, uploadCfg, fileURLCfg, imageProcessor, scanWorker)
 	friendService := service.NewInitFriendService(friendRepo, userRepo)
 	participantService := service.NewParticipantService(participantRepo, redisRepo)
+	verificationService := service.NewVerificationService(userRepo, redisRepo, templateMailer, service.ParseVerificationPolicy(cfg.Verification.RequiredFor, cfg.Verification.ResendInterval), frontendURL)
+	loginGuard := service.NewLoginGuard(redisRepo, userRepo, templateMailer, loginGuardCfg, frontendURL)
+	magicLinkService := service.NewMagicLinkService(userRepo, redisRepo, authService, templateMailer, service.MagicLinkConfig{TTL: cfg.MagicLink.TTL, ResendInterval: cfg.MagicLink.ResendInterval}, frontendURL)
+	passwordResetService := service.NewPasswordResetService(userRepo, templateMailer, frontendURL)
+	profileService := service.NewProfileService(userRepo, friendRepo, store, fileURLCfg)
+	oidcService := service.NewOIDCService(oidcRegistry, authService, userRepo, identityRepo, redisRepo)
 
-	// Initialize WebSocket hub
-	hub := websocket.NewHub()
 	wsHandler := websocket.NewWsHandler(hub, authService)
 
 	// Initialize handlers
 	userHandler := handler.NewUserHandler(userService)
-	authHandler := handler.NewAuthHandler(userService, authService, nil)
+	authHandler := handler.NewAuthHandler(userService, authService, service.NewGoogleService(googleOAuthConfig), verificationService, loginGuard, magicLinkService, passwordResetService)
 	friendHandler := handler.NewFriendHandler(friendService, hub, conversationService)
 	conversationHandler := handler.NewConversationHandler(conversationService)
-	imageHandler := handler.NewImageHandler(authService)
-	authGoogleHandler := handler.NewAuthGoogleHandler(authService, googleOAuthConfig)
+	fileHandler := handler.NewFileHandler(store, attachmentService)
+	oauthRedirect := handler.OAuthRedirectConfig{FrontendURL: cfg.Server.FrontendURL, SecureCookies: cfg.IsProduction()}
+	authGoogleHandler := handler.NewAuthGoogleHandler(authService, googleOAuthConfig, oauthRedirect)
 	messageHandler := handler.NewMessageHandler(*messageService, hub, *participantService)
+	oidcHandler := handler.NewOIDCHandler(oidcService, oauthRedirect)
+	profileHandler := handler.NewProfileHandler(profileService, hub, store)
+
+	// Readiness: orchestrator ngừng gửi traffic khi replica mất kết nối database/Redis/storage
+	// hoặc schema chưa migrate tới phiên bản binary cần
+	checker := health.NewChecker()
+	checker.Add("database", cfg.Server.HealthCheckTimeout, database.PingDB(db))
+	checker.Add("redis", cfg.Server.HealthCheckTimeout, database.PingRedis(rdb))
+	checker.Add("migrations", cfg.Server.HealthCheckTimeout, database.CheckMigrations(db))
+	checker.Add("storage", cfg.Server.HealthCheckTimeout, func(ctx context.Context) error {
+		return storage.Ping(ctx, store)
+	})
+	healthHandler := handler.NewHealthHandler(checker)
 
 	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(authService)
+	authMiddleware := middleware.NewAuthMiddleware(authService, verificationService)
+	rateLimiter := middleware.NewRateLimiter()
+	defer rateLimiter.Close()
 
 	// Start WebSocket hub
 	go hub.Run()
-	log.Println("📡 WebSocket hub đã được khởi động")
+	slog.Info("websocket hub started")
 
 	// Setup router
 	r := router.SetupRouter(
@@ -92,9 +260,14 @@ func main() {
 		friendHandler,
 		wsHandler,
 		authService,
-		imageHandler,
 		conversationHandler,
 		messageHandler,
+		oidcHandler,
+		fileHandler,
+		profileHandler,
+		store,
+		rateLimiter,
+		healthHandler,
 	)
 	r.GET("/ping", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -105,111 +278,137 @@ func main() {
 	r.GET("/api/v1/auth/google", authGoogleHandler.GoogleLoginHandler)
 	r.GET("/api/auth/google/callback", authGoogleHandler.GoogleCallBackHandler)
 
-	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Prometheus metrics
+	if err := metrics.RegisterHub(hub.Stats); err != nil {
+		slog.Warn("register websocket hub metrics failed", "err", err)
+	}
+	var metricsSrv *http.Server
+	if cfg.Metrics.Enabled {
+		if cfg.Metrics.Addr == "" {
+			r.GET("/metrics", gin.WrapH(metrics.Handler(cfg.Metrics.Token)))
+			slog.Info("metrics served on main port", "path", "/metrics")
+		} else {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metrics.Handler(cfg.Metrics.Token))
+			metricsSrv = &http.Server{Addr: cfg.Metrics.Addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+			slog.Info("metrics served", "addr", cfg.Metrics.Addr, "path", "/metrics")
+		}
 	}
 
-	log.Printf("🚀 Server đang chạy trên port %s", port)
-	log.Printf("🌍 Environment: %s", getEnvironment())
+	// Start server
+	slog.Info("server starting", "port", cfg.Server.Port, "env", cfg.Env)
 
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("❌ Không thể khởi động server:", err)
+	srv := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
 	}
-}
+	// Kết nối websocket đã hijack nên Shutdown không chờ, hub tự gửi close frame cho client
+	srv.RegisterOnShutdown(hub.Shutdown)
 
-// initGoogleOAuth initializes Google OAuth configuration from environment
-func initGoogleOAuth() *oauth2.Config {
-	clientID := os.Getenv("GOOGLE_CLIENT_ID")
-	clientSecret := os.Getenv("GOOGLE_CLIENT_SECRET")
-	redirectURL := os.Getenv("GOOGLE_REDIRECT_URL")
-
-	// Auto-construct redirect URL if not set
-	if redirectURL == "" {
-		baseURL := os.Getenv("BASE_URL")
-		if baseURL == "" {
-			baseURL = os.Getenv("DEFAULT_URL_SERVER")
-		}
-		if baseURL == "" {
-			baseURL = "http://localhost:8080"
+	serverErr := make(chan error, 2)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
 		}
-		redirectURL = baseURL + "/api/auth/google/callback"
-		log.Printf("⚠️ [GoogleOAuth] GOOGLE_REDIRECT_URL not set, using: %s", redirectURL)
+	}()
+	if metricsSrv != nil {
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+		}()
 	}
 
-	config := &oauth2.Config{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		RedirectURL:  redirectURL,
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-		},
-		Endpoint: google.Endpoint,
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-serverErr:
+		slog.Error("server failed", "err", err)
+		return
+	case <-ctx.Done():
 	}
+	stop() // Ctrl+C lần 2 sẽ tắt ngay
+	checker.Drain()
 
-	log.Println("✅ [GoogleOAuth] Configuration initialized")
-	log.Printf("   Client ID: %s", maskClientID(clientID))
-	log.Printf("   Redirect URL: %s", redirectURL)
-
-	return config
-}
-
-// validateEnv checks for required environment variables
-func validateEnv() {
-	required := []string{
-		"GOOGLE_CLIENT_ID",
-		"GOOGLE_CLIENT_SECRET",
-		"JWT_SECRET_KEY",
-		"DB_HOST",
-		"DB_USER",
-		"DB_PASSWORD",
-		"DB_NAME",
-	}
-
-	missing := []string{}
-	for _, key := range required {
-		if os.Getenv(key) == "" {
-			missing = append(missing, key)
-		}
+	// Ngừng nhận kết nối mới, chờ request đang xử lý; các worker, Redis, DB được đóng bởi defer ở trên
+	slog.Info("shutting down server", "timeout", cfg.Server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("timed out waiting for in-flight requests", "err", err)
 	}
-
-	if len(missing) > 0 {
-		log.Printf("⚠️ Missing required environment variables: %v", missing)
+	if metricsSrv != nil {
+		metricsSrv.Shutdown(shutdownCtx)
 	}
+	hub.Shutdown()
+	slog.Info("server stopped")
 }
 
-// logEnvironment logs current environment configuration
-func logEnvironment() {
-	log.Println("📋 Environment Configuration:")
-	log.Printf("   ENV: %s", getEnvironment())
-	log.Printf("   BASE_URL: %s", os.Getenv("BASE_URL"))
-	log.Printf("   DEFAULT_URL_SERVER: %s", os.Getenv("DEFAULT_URL_SERVER"))
-	log.Printf("   FRONTEND_URL: %s", getFrontendURL())
-	log.Printf("   DB_HOST: %s", os.Getenv("DB_HOST"))
-	log.Printf("   REDIS_HOST: %s", os.Getenv("REDIS_HOST"))
+// fatal ghi log ERROR rồi thoát, dùng khi server không thể khởi động
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
 
-// getEnvironment returns current environment (production/development)
-func getEnvironment() string {
-	env := os.Getenv("ENV")
-	if env == "" {
-		env = "development"
+// initStorageConfig chuyển cấu hình storage sang config của backend
+func initStorageConfig(cfg config.StorageConfig) storage.Config {
+	return storage.Config{
+		Driver: cfg.Driver,
+		Local: storage.LocalConfig{
+			Root:       cfg.LocalDir,
+			PublicURL:  cfg.PublicURL,
+			SigningKey: cfg.SigningKey,
+		},
+		S3: storage.S3Config{
+			AccessKey:    cfg.S3.AccessKey,
+			SecretKey:    cfg.S3.SecretKey,
+			Region:       cfg.S3.Region,
+			Endpoint:     cfg.S3.Endpoint,
+			Bucket:       cfg.S3.Bucket,
+			UsePathStyle: cfg.S3.UsePathStyle,
+		},
 	}
-	return env
 }
 
-// getFrontendURL returns frontend URL with fallback
-func getFrontendURL() string {
-	url := os.Getenv("FRONTEND_URL")
-	if url == "" {
-		url = os.Getenv("DEFAULT_URL")
+// initOIDCConfigs trả về cấu hình các provider được bật, field trống lấy từ preset của provider
+func initOIDCConfigs(cfg config.OIDCConfig) []oidc.Config {
+	configs := make([]oidc.Config, 0, len(cfg.Providers))
+	for _, name := range cfg.Providers {
+		client := cfg.Clients[name]
+		configs = append(configs, oidc.WithPreset(oidc.Config{
+			Name:         name,
+			DisplayName:  client.DisplayName,
+			ClientID:     client.ClientID,
+			ClientSecret: client.ClientSecret,
+			RedirectURL:  client.RedirectURL,
+			Issuer:       client.Issuer,
+			AuthURL:      client.AuthURL,
+			TokenURL:     client.TokenURL,
+			UserInfoURL:  client.UserInfoURL,
+			Scopes:       client.Scopes,
+			DisablePKCE:  client.DisablePKCE,
+		}))
 	}
-	if url == "" {
-		url = "http://localhost:3000"
+	return configs
+}
+
+// initGoogleOAuth initializes Google OAuth configuration
+func initGoogleOAuth(cfg config.GoogleConfig) *oauth2.Config {
+	oauthConfig := &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes: []string{
+			"https://www.googleapis.com/auth/userinfo.email",
+			"https://www.googleapis.com/auth/userinfo.profile",
+		},
+		Endpoint: google.Endpoint,
 	}
-	return url
+
+	slog.Info("google oauth configured", "client_id", maskClientID(cfg.ClientID), "redirect_url", cfg.RedirectURL)
+
+	return oauthConfig
 }
 
 // maskClientID masks sensitive client ID for logging
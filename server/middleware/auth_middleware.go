@@ -6,19 +6,22 @@ import (
 
 	"net/http"
 
+	"project/models"
 	"project/service"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthMiddleware struct {
-	authService *service.AuthService
+	authService         *service.AuthService
+	verificationService *service.VerificationService
 }
 
-func NewAuthMiddleware(authService *service.AuthService) *AuthMiddleware {
-	return &AuthMiddleware{authService: authService}
+func NewAuthMiddleware(authService *service.AuthService, verificationService *service.VerificationService) *AuthMiddleware {
+	return &AuthMiddleware{authService: authService, verificationService: verificationService}
 }
 func (m *AuthMiddleware) VerifyAccessToken(c *gin.Context) {
+	ctx := c.Request.Context()
 	authHeader := c.GetHeader("Authorization")
 
 	if authHeader == "" {
@@ -35,7 +38,7 @@ func (m *AuthMiddleware) VerifyAccessToken(c *gin.Context) {
 	}
 	accessToken := parts[1]
 
-	user, _, err := m.authService.VerifyAccessToken(accessToken)
+	user, _, err := m.authService.VerifyAccessToken(ctx, accessToken)
 
 	if err != nil && !errors.Is(err, service.ErrInvalidOrExpired) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify access token: " + err.Error()})
@@ -52,3 +55,40 @@ func (m *AuthMiddleware) VerifyAccessToken(c *gin.Context) {
 	c.Set("user", user)
 	c.Next()
 }
+
+// RequireVerifiedEmail chặn user chưa xác thực email nếu policy giới hạn feature.
+// Phải đặt sau VerifyAccessToken.
+func (m *AuthMiddleware) RequireVerifiedEmail(feature string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if !m.verificationService.Policy().Restricts(feature) {
+			c.Next()
+			return
+		}
+
+		userValue, exists := c.Get("user")
+		user, ok := userValue.(*models.User)
+		if !exists || !ok || user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			c.Abort()
+			return
+		}
+
+		verified, err := m.verificationService.IsVerified(ctx, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email verification: " + err.Error()})
+			c.Abort()
+			return
+		}
+		if !verified {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":                "Please verify your email address first",
+				"require_verification": true,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -15,34 +15,64 @@ type ClientLimiter struct {
 }
 
 var (
-	mu        sync.Mutex
-	clients   = make(map[string]*ClientLimiter)
 	rateLimit = rate.Every(10 * time.Millisecond) // ~5 req/s
 	burst     = 5
 )
 
-// cleanup old entries (optional)
-func cleanupOldClients() {
+// RateLimiter giới hạn request theo IP, goroutine dọn IP cũ dừng khi Close
+type RateLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*ClientLimiter
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+func NewRateLimiter() *RateLimiter {
+	l := &RateLimiter{
+		clients: make(map[string]*ClientLimiter),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go l.cleanupOldClients()
+	return l
+}
+
+// cleanup old entries
+func (l *RateLimiter) cleanupOldClients() {
+	defer close(l.done)
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
 	for {
-		time.Sleep(time.Minute)
-		mu.Lock()
-		for ip, c := range clients {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+		l.mu.Lock()
+		for ip, c := range l.clients {
 			if time.Since(c.lastSeen) > 3*time.Minute {
-				delete(clients, ip)
+				delete(l.clients, ip)
 			}
 		}
-		mu.Unlock()
+		l.mu.Unlock()
 	}
 }
 
-func getLimiter(ip string) *rate.Limiter {
-	mu.Lock()
-	defer mu.Unlock()
+// Close dừng goroutine dọn dẹp
+func (l *RateLimiter) Close() {
+	l.once.Do(func() { close(l.stop) })
+	<-l.done
+}
+
+func (l *RateLimiter) getLimiter(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	c, exists := clients[ip]
+	c, exists := l.clients[ip]
 	if !exists {
 		limiter := rate.NewLimiter(rateLimit, burst)
-		clients[ip] = &ClientLimiter{limiter: limiter, lastSeen: time.Now()}
+		l.clients[ip] = &ClientLimiter{limiter: limiter, lastSeen: time.Now()}
 		return limiter
 	}
 
@@ -50,10 +80,10 @@ func getLimiter(ip string) *rate.Limiter {
 	return c.limiter
 }
 
-func RateLimitMiddleware() gin.HandlerFunc {
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ip := c.ClientIP()
-		limiter := getLimiter(ip)
+		limiter := l.getLimiter(ip)
 
 		if !limiter.Allow() {
 			c.JSON(http.StatusTooManyRequests, gin.H{
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"project/config"
+	"project/database"
+	"project/pkg/migrate"
+)
+
+const migrateUsage = `Usage: web_chat migrate <command>
+
+Commands:
+  up            chạy mọi migration chưa chạy
+  down [N]      rollback N migration mới nhất (mặc định 1)
+  status        liệt kê migration và thời điểm đã chạy
+  create NAME   tạo cặp file NAME.up.sql/NAME.down.sql với version kế tiếp`
+
+// runMigrate xử lý "web_chat migrate ...", không khởi động server
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dir := fs.String("dir", database.MigrationsDir, "thư mục migration (chỉ dùng cho create)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), migrateUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing migrate command")
+	}
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+
+	// create chỉ ghi file, không cần cấu hình database
+	if cmd == "create" {
+		if len(rest) != 1 {
+			return fmt.Errorf("usage: migrate create NAME")
+		}
+		up, down, err := migrate.Create(*dir, rest[0])
+		if err != nil {
+			return err
+		}
+		slog.Info("migration created", "up", up, "down", down)
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	database.CreateDBIfNotExists(cfg.Database)
+	db, err := sql.Open("postgres", cfg.Database.DSN())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	m, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch cmd {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			slog.Info("migration applied", "migration", mig.String())
+		}
+		if err == nil && len(applied) == 0 {
+			slog.Info("schema is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(rest) > 0 {
+			if steps, err = strconv.Atoi(rest[0]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid step count %q", rest[0])
+			}
+		}
+		reverted, err := m.Down(ctx, steps)
+		for _, mig := range reverted {
+			slog.Info("migration reverted", "migration", mig.String())
+		}
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%-40s %s\n", s.Migration, applied)
+		}
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown migrate command %q", cmd)
+	}
+}
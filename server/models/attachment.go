@@ -0,0 +1,136 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Attachment là file người dùng upload để gửi kèm message.
+// Sau khi upload MessageID/ConversationID còn nil, được gán khi gửi message.
+type Attachment struct {
+	ID                uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	UploaderID        uuid.UUID  `json:"uploader_id" gorm:"type:uuid;not null;index"`
+	ConversationID    *uuid.UUID `json:"conversation_id,omitempty" gorm:"type:uuid;index"`
+	MessageID         *uuid.UUID `json:"message_id,omitempty" gorm:"type:uuid;index"`
+	StorageKey        string     `json:"-" gorm:"type:varchar(255);not null;index:idx_attachments_blob_key"` // blobs/<sha256>, nhiều attachment dùng chung được
+	FileName          string     `json:"file_name" gorm:"type:varchar(255)"`
+	MimeType          string     `json:"mime_type" gorm:"type:varchar(100);not null"`
+	Size              int64      `json:"size" gorm:"not null"`
+	Width             int        `json:"width,omitempty"`
+	Height            int        `json:"height,omitempty"`
+	Checksum          string     `json:"checksum" gorm:"type:varchar(64);not null"`                                                                       // sha256 hex, rỗng khi đang pending
+	Status            string     `json:"status" gorm:"type:varchar(20);default:'ready';check:status IN ('pending','pending_scan','ready','quarantined')"` // pending: client đang upload thẳng lên storage
+	ScanSignature     string     `json:"scan_signature,omitempty" gorm:"type:varchar(255)"`                                                               // tên malware khi bị quarantined
+	MultipartUploadID string     `json:"-" gorm:"type:varchar(255)"`
+	DominantColor     string     `json:"dominant_color,omitempty" gorm:"type:varchar(7)"` // #rrggbb, client dùng làm placeholder
+	DurationMs        int64      `json:"duration_ms,omitempty"`                           // thời lượng của voice message
+	Waveform          Waveform   `json:"waveform,omitempty" gorm:"type:bytea"`            // độ lớn 0-255 theo thời gian, client vẽ scrubber
+	CreatedAt         time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	URL               string     `json:"url" gorm:"-"`
+
+	Uploader   *User                 `json:"-" gorm:"foreignKey:UploaderID;constraint:OnDelete:CASCADE"`
+	Renditions []AttachmentRendition `json:"renditions,omitempty" gorm:"foreignKey:AttachmentID;constraint:OnDelete:CASCADE"`
+}
+
+// AttachmentRendition là bản thu nhỏ (thumbnail/preview) của ảnh, sinh bởi image pipeline
+type AttachmentRendition struct {
+	ID           uuid.UUID `json:"-" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	AttachmentID uuid.UUID `json:"-" gorm:"type:uuid;not null;uniqueIndex:idx_rendition_attachment_name"`
+	Name         string    `json:"name" gorm:"type:varchar(20);not null;uniqueIndex:idx_rendition_attachment_name"`
+	StorageKey   string    `json:"-" gorm:"type:varchar(255);not null;index:idx_renditions_blob_key"`
+	MimeType     string    `json:"mime_type" gorm:"type:varchar(100);not null"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Size         int64     `json:"size"`
+	URL          string    `json:"url" gorm:"-"`
+}
+
+// AttachmentURL là đường dẫn tải attachment (có kiểm tra quyền), rendition rỗng = file gốc
+func AttachmentURL(id uuid.UUID, rendition string) string {
+	url := "/api/v1/attachments/" + id.String()
+	if rendition != "" {
+		url += "?rendition=" + rendition
+	}
+	return url
+}
+
+func (a *Attachment) AfterFind(tx *gorm.DB) (err error) {
+	a.URL = AttachmentURL(a.ID, "")
+	return
+}
+
+func (a *Attachment) AfterSave(tx *gorm.DB) (err error) {
+	a.URL = AttachmentURL(a.ID, "")
+	return
+}
+
+func (r *AttachmentRendition) AfterFind(tx *gorm.DB) (err error) {
+	r.URL = AttachmentURL(r.AttachmentID, r.Name)
+	return
+}
+
+func (r *AttachmentRendition) AfterSave(tx *gorm.DB) (err error) {
+	r.URL = AttachmentURL(r.AttachmentID, r.Name)
+	return
+}
+
+const (
+	AttachmentStatusPending     = "pending"
+	AttachmentStatusPendingScan = "pending_scan" // đã upload xong, chờ quét malware, chưa tải được
+	AttachmentStatusReady       = "ready"
+	AttachmentStatusQuarantined = "quarantined" // bị scanner phát hiện malware, file chuyển sang quarantine/
+)
+
+func (a *Attachment) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return
+}
+
+// Waveform lưu dạng bytea, JSON là mảng số (mặc định []byte bị encode thành base64)
+type Waveform []uint8
+
+func (w Waveform) MarshalJSON() ([]byte, error) {
+	values := make([]int, len(w))
+	for i, v := range w {
+		values[i] = int(v)
+	}
+	return json.Marshal(values)
+}
+
+func (w *Waveform) UnmarshalJSON(data []byte) error {
+	var values []int
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*w = make(Waveform, len(values))
+	for i, v := range values {
+		(*w)[i] = uint8(v)
+	}
+	return nil
+}
+
+func (w Waveform) Value() (driver.Value, error) {
+	if w == nil {
+		return nil, nil
+	}
+	return []byte(w), nil
+}
+
+func (w *Waveform) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*w = nil
+	case []byte:
+		*w = append(Waveform(nil), v...)
+	default:
+		return fmt.Errorf("waveform: unsupported type %T", src)
+	}
+	return nil
+}
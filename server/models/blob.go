@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Blob là nội dung file lưu trên storage theo sha256 (content-addressed).
+// Nhiều attachment/rendition có thể trỏ cùng StorageKey, blob chỉ bị xóa khi không còn ai tham chiếu.
+type Blob struct {
+	Checksum   string    `json:"checksum" gorm:"type:varchar(64);primaryKey"` // sha256 hex
+	StorageKey string    `json:"-" gorm:"type:varchar(255);not null;uniqueIndex"`
+	Size       int64     `json:"size" gorm:"not null"`
+	MimeType   string    `json:"mime_type" gorm:"type:varchar(100);not null"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+	LastUsedAt time.Time `json:"last_used_at" gorm:"not null;index"` // cập nhật mỗi lần được dùng lại, GC bỏ qua blob mới dùng
+}
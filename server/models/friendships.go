@@ -8,7 +8,7 @@ import (
 )
 
 // Friendship represents a friend relation / request between two users.
-// Status can be: "pending", "accepted", "blocked", "rejected".
+// Status can be: "no_friend", "pending", "friend", "blocked".
 type Friendship struct {
 	ID          uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
 	UserID      uuid.UUID      `gorm:"type:uuid;not null;index;uniqueIndex:idx_user_friend" json:"user_id"`
@@ -12,7 +12,7 @@ type Message struct {
 	ConversationID uuid.UUID      `json:"conversation_id" gorm:"type:uuid;not null;index"`
 	SenderID       *uuid.UUID     `json:"sender_id,omitempty" gorm:"type:uuid;index"`
 	Content        string         `json:"content" gorm:"type:text"`
-	Type           string         `json:"type" gorm:"type:varchar(20);default:'text';check:type IN ('text','image','file','video','system')"`
+	Type           string         `json:"type" gorm:"type:varchar(20);default:'text';check:type IN ('text','image','file','video','voice','system')"`
 	Status         string         `json:"status" gorm:"type:varchar(20);default:'sent';check:status IN ('sent','delivered','read')"`
 	IsEdited       bool           `json:"is_edited" gorm:"default:false"`
 	ReplyToID      *uuid.UUID     `json:"reply_to_id,omitempty" gorm:"type:uuid;index"`
@@ -22,5 +22,6 @@ type Message struct {
 	DeletedAt      gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 
 	Sender       *User         `json:"sender,omitempty" gorm:"foreignKey:SenderID;constraint:OnDelete:SET NULL"`
+	Attachments  []Attachment  `json:"attachments,omitempty" gorm:"foreignKey:MessageID;constraint:OnDelete:SET NULL"`
 	// Conversation *Conversation `json:"conversation,omitempty" gorm:"foreignKey:ConversationID;constraint:OnDelete:CASCADE"`
 }
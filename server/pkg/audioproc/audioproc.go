@@ -0,0 +1,154 @@
+// Package audioproc đọc metadata của file ghi âm (voice message): thời lượng và waveform.
+// Hỗ trợ Ogg/Opus, WebM và MP4/M4A, chỉ đọc container nên không cần decode audio.
+package audioproc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("audioproc: unsupported audio format")
+	ErrNotAudio          = errors.New("audioproc: file contains a video track")
+	ErrInvalid           = errors.New("audioproc: malformed audio file")
+	// ErrUnsupportedCodec: container hỗ trợ nhưng codec không phải của voice message (vd Ogg Vorbis/FLAC)
+	ErrUnsupportedCodec = errors.New("audioproc: unsupported audio codec")
+)
+
+// DefaultWaveformBars là số cột waveform mặc định, đủ cho scrubber trên mobile
+const DefaultWaveformBars = 64
+
+// maxElementSize chặn file giả mạo khai báo element/box rất lớn để bắt server đọc vào memory
+const maxElementSize = 16 << 20
+
+// Info là metadata của file audio
+type Info struct {
+	MimeType string // audio/ogg, audio/webm hoặc audio/mp4
+	Duration time.Duration
+	// Waveform có len = số cột, mỗi giá trị 0-255 là độ lớn tương đối của đoạn đó.
+	// Tính theo bitrate của từng packet: codec VBR (Opus, AAC) dùng ít bit cho đoạn im lặng.
+	Waveform []uint8
+}
+
+// frame là một packet audio trong file, Start tính từ đầu file
+type frame struct {
+	Start time.Duration
+	Size  int
+}
+
+// Probe đọc toàn bộ r (không seek) và trả về metadata, bars là số cột waveform.
+// Trả về ErrNotAudio nếu file có track video, ErrUnsupportedFormat nếu không phải container hỗ trợ,
+// ErrUnsupportedCodec nếu là Ogg nhưng không phải Opus.
+func Probe(r io.Reader, bars int) (*Info, error) {
+	if bars <= 0 {
+		bars = DefaultWaveformBars
+	}
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(8)
+	var (
+		info   *Info
+		frames []frame
+		err    error
+	)
+	switch {
+	case bytes.HasPrefix(head, []byte("OggS")):
+		info, frames, err = probeOgg(br)
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		info, frames, err = probeWebM(br)
+	case len(head) == 8 && string(head[4:8]) == "ftyp":
+		info, frames, err = probeMP4(br)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Duration <= 0 {
+		return nil, ErrInvalid
+	}
+	info.Waveform = waveform(frames, info.Duration, bars)
+	return info, nil
+}
+
+// waveform chia file thành các đoạn bằng nhau, cộng kích thước packet trong mỗi đoạn rồi chuẩn hóa về 0-255
+func waveform(frames []frame, duration time.Duration, bars int) []uint8 {
+	if len(frames) == 0 || duration <= 0 {
+		return nil
+	}
+	sums := make([]int64, bars)
+	for _, f := range frames {
+		i := int(int64(f.Start) * int64(bars) / int64(duration))
+		if i < 0 {
+			i = 0
+		}
+		if i >= bars {
+			i = bars - 1
+		}
+		sums[i] += int64(f.Size)
+	}
+	var peak int64
+	for _, s := range sums {
+		peak = max(peak, s)
+	}
+	out := make([]uint8, bars)
+	if peak == 0 {
+		return out
+	}
+	for i, s := range sums {
+		out[i] = uint8(s * 255 / peak)
+	}
+	return out
+}
+
+// skip bỏ qua n byte, lỗi nếu file kết thúc sớm
+func skip(r io.Reader, n int64) error {
+	copied, err := io.CopyN(io.Discard, r, n)
+	if copied < n {
+		return ErrInvalid
+	}
+	return err
+}
+
+// readPayload đọc n byte của element/box vào memory
+func readPayload(r io.Reader, n int64) ([]byte, error) {
+	if n < 0 || n > maxElementSize {
+		return nil, ErrInvalid
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, ErrInvalid
+	}
+	return buf, nil
+}
+
+// opusPacketDuration tính thời lượng của 1 packet Opus từ TOC byte (RFC 6716 mục 3.1)
+func opusPacketDuration(packet []byte) time.Duration {
+	if len(packet) == 0 {
+		return 0
+	}
+	toc := packet[0]
+	config := toc >> 3
+	var frameSize time.Duration
+	switch {
+	case config < 12: // SILK
+		frameSize = []time.Duration{10, 20, 40, 60}[config%4] * time.Millisecond
+	case config < 16: // Hybrid
+		frameSize = []time.Duration{10, 20}[config%2] * time.Millisecond
+	default: // CELT
+		frameSize = []time.Duration{2500, 5000, 10000, 20000}[config%4] * time.Microsecond
+	}
+	frames := 1
+	switch toc & 3 {
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) < 2 {
+			return 0
+		}
+		frames = int(packet[1] & 0x3f)
+	}
+	return frameSize * time.Duration(frames)
+}
@@ -0,0 +1,194 @@
+package audioproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Packet Opus CELT 20ms, nửa đầu to (tiếng nói), nửa sau nhỏ (im lặng)
+func opusPackets(n int) [][]byte {
+	packets := make([][]byte, n)
+	for i := range packets {
+		size := 200
+		if i >= n/2 {
+			size = 10
+		}
+		packets[i] = append([]byte{31 << 3}, bytes.Repeat([]byte{0x55}, size-1)...)
+	}
+	return packets
+}
+
+func oggPage(granule int64, seq uint32, packet []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("OggS")
+	buf.Write([]byte{0, 0})
+	binary.Write(&buf, binary.LittleEndian, granule)
+	binary.Write(&buf, binary.LittleEndian, uint32(1234))
+	binary.Write(&buf, binary.LittleEndian, seq)
+	buf.Write([]byte{0, 0, 0, 0}) // CRC không được kiểm tra
+	// Packet dài hơn 255 byte được chia thành nhiều segment
+	var lacing []byte
+	n := len(packet)
+	for ; n >= 255; n -= 255 {
+		lacing = append(lacing, 255)
+	}
+	lacing = append(lacing, byte(n))
+	buf.WriteByte(byte(len(lacing)))
+	buf.Write(lacing)
+	buf.Write(packet)
+	return buf.Bytes()
+}
+
+func buildOgg() []byte {
+	const preSkip = 312
+	head := []byte("OpusHead")
+	head = append(head, 1, 1)
+	head = binary.LittleEndian.AppendUint16(head, preSkip)
+	head = binary.LittleEndian.AppendUint32(head, 48000)
+	head = append(head, 0, 0, 0)
+
+	var buf bytes.Buffer
+	buf.Write(oggPage(0, 0, head))
+	buf.Write(oggPage(0, 1, append([]byte("OpusTags"), make([]byte, 300)...)))
+	granule := int64(preSkip)
+	for i, p := range opusPackets(50) {
+		granule += 960
+		buf.Write(oggPage(granule, uint32(i+2), p))
+	}
+	return buf.Bytes()
+}
+
+func ebml(id []byte, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	// Size 8 byte: marker 0x01 + 7 byte giá trị
+	size := binary.BigEndian.AppendUint64(nil, uint64(len(body)))
+	size[0] = 0x01
+	return bytes.Join([][]byte{id, size, body}, nil)
+}
+
+func ebmlUnknown(id []byte, payload ...[]byte) []byte {
+	out := append([]byte{}, id...)
+	out = append(out, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	return append(out, bytes.Join(payload, nil)...)
+}
+
+func buildWebM(trackType byte) []byte {
+	var blocks [][]byte
+	blocks = append(blocks, ebml([]byte{0xE7}, []byte{0}))
+	for i, p := range opusPackets(50) {
+		block := []byte{0x81}
+		block = binary.BigEndian.AppendUint16(block, uint16(i*20))
+		block = append(block, 0x80)
+		blocks = append(blocks, ebml([]byte{0xA3}, block, p))
+	}
+	return bytes.Join([][]byte{
+		ebml([]byte{0x1A, 0x45, 0xDF, 0xA3}, ebml([]byte{0x42, 0x82}, []byte("webm"))),
+		ebmlUnknown([]byte{0x18, 0x53, 0x80, 0x67},
+			ebml([]byte{0x15, 0x49, 0xA9, 0x66}, ebml([]byte{0x2A, 0xD7, 0xB1}, []byte{0x0F, 0x42, 0x40})),
+			ebml([]byte{0x16, 0x54, 0xAE, 0x6B}, ebml([]byte{0xAE},
+				ebml([]byte{0xD7}, []byte{1}),
+				ebml([]byte{0x83}, []byte{trackType}),
+				ebml([]byte{0x86}, []byte("A_OPUS")),
+			)),
+			ebmlUnknown([]byte{0x1F, 0x43, 0xB6, 0x75}, blocks...),
+		),
+	}, nil)
+}
+
+func box(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	out = append(out, typ...)
+	return append(out, body...)
+}
+
+func buildMP4(handler string) []byte {
+	const samples = 40
+	// AAC 1024 sample/frame ở 44.1kHz
+	mdhd := make([]byte, 24)
+	binary.BigEndian.PutUint32(mdhd[12:], 44100)
+	binary.BigEndian.PutUint32(mdhd[16:], samples*1024)
+	hdlr := append(make([]byte, 8), handler...)
+	hdlr = append(hdlr, make([]byte, 13)...)
+	stts := binary.BigEndian.AppendUint32(make([]byte, 4), 1)
+	stts = binary.BigEndian.AppendUint32(stts, samples)
+	stts = binary.BigEndian.AppendUint32(stts, 1024)
+	stsz := binary.BigEndian.AppendUint32(make([]byte, 4), 0)
+	stsz = binary.BigEndian.AppendUint32(stsz, samples)
+	for i := 0; i < samples; i++ {
+		size := uint32(300)
+		if i >= samples/2 {
+			size = 20
+		}
+		stsz = binary.BigEndian.AppendUint32(stsz, size)
+	}
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("M4A "), make([]byte, 4), []byte("M4A mp42isom")),
+		box("mdat", make([]byte, 4096)),
+		box("moov", box("trak", box("mdia",
+			box("mdhd", mdhd),
+			box("hdlr", hdlr),
+			box("minf", box("stbl", box("stts", stts), box("stsz", stsz))),
+		))),
+	}, nil)
+}
+
+func assertVoiceWaveform(t *testing.T, waveform []uint8) {
+	t.Helper()
+	require.Len(t, waveform, 4)
+	assert.Equal(t, uint8(255), max(waveform[0], waveform[1]))
+	assert.Greater(t, min(waveform[0], waveform[1]), uint8(200))
+	assert.Less(t, waveform[2], uint8(30))
+	assert.Less(t, waveform[3], uint8(30))
+}
+
+func TestProbeOggOpus(t *testing.T) {
+	info, err := Probe(bytes.NewReader(buildOgg()), 4)
+	require.NoError(t, err)
+	assert.Equal(t, "audio/ogg", info.MimeType)
+	assert.Equal(t, time.Second, info.Duration)
+	assertVoiceWaveform(t, info.Waveform)
+}
+
+func TestProbeWebM(t *testing.T) {
+	info, err := Probe(bytes.NewReader(buildWebM(2)), 4)
+	require.NoError(t, err)
+	assert.Equal(t, "audio/webm", info.MimeType)
+	assert.Equal(t, time.Second, info.Duration)
+	assertVoiceWaveform(t, info.Waveform)
+
+	_, err = Probe(bytes.NewReader(buildWebM(1)), 4)
+	assert.ErrorIs(t, err, ErrNotAudio)
+}
+
+func TestProbeMP4(t *testing.T) {
+	info, err := Probe(bytes.NewReader(buildMP4("soun")), 4)
+	require.NoError(t, err)
+	assert.Equal(t, "audio/mp4", info.MimeType)
+	assert.InDelta(t, float64(40*1024)/44100, info.Duration.Seconds(), 0.001)
+	assertVoiceWaveform(t, info.Waveform)
+
+	_, err = Probe(bytes.NewReader(buildMP4("vide")), 4)
+	assert.ErrorIs(t, err, ErrNotAudio)
+}
+
+func TestProbeRejectsInvalidFiles(t *testing.T) {
+	_, err := Probe(strings.NewReader("ID3 not a supported container"), 4)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	// File bị cắt giữa chừng
+	ogg := buildOgg()
+	_, err = Probe(bytes.NewReader(ogg[:len(ogg)-5]), 4)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	// Ogg Vorbis: container đúng nhưng không phải Opus
+	vorbis := oggPage(0, 0, append([]byte("\x01vorbis"), make([]byte, 22)...))
+	_, err = Probe(bytes.NewReader(vorbis), 4)
+	assert.ErrorIs(t, err, ErrUnsupportedCodec)
+}
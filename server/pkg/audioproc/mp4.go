@@ -0,0 +1,182 @@
+package audioproc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// Box chứa box con, được duyệt vào trong thay vì bỏ qua
+var mp4Containers = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+}
+
+type mp4Track struct {
+	handler   string // soun, vide...
+	timescale uint32
+	duration  uint64
+	sizes     []uint32 // stsz
+	deltas    []uint32 // stts đã bung ra: thời lượng của từng sample
+}
+
+// probeMP4 đọc MP4/M4A: thời lượng từ mdhd của track audio, waveform từ bảng kích thước sample (stsz)
+// và thời lượng sample (stts). mdat được bỏ qua nên moov nằm trước hay sau mdat đều được.
+func probeMP4(r *bufio.Reader) (*Info, []frame, error) {
+	var tracks []*mp4Track
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, nil, ErrInvalid
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		typ := string(header[4:8])
+		headerLen := int64(8)
+		switch size {
+		case 0:
+			// Box cuối cùng, kéo dài tới hết file
+			if _, err := io.Copy(io.Discard, r); err != nil {
+				return nil, nil, err
+			}
+			continue
+		case 1:
+			if _, err := io.ReadFull(r, header); err != nil {
+				return nil, nil, ErrInvalid
+			}
+			size = int64(binary.BigEndian.Uint64(header))
+			headerLen = 16
+		}
+		if size < headerLen {
+			return nil, nil, ErrInvalid
+		}
+		if mp4Containers[typ] {
+			if typ == "trak" {
+				tracks = append(tracks, &mp4Track{})
+			}
+			continue
+		}
+
+		switch typ {
+		case "hdlr", "mdhd", "stsz", "stts":
+			if len(tracks) == 0 {
+				return nil, nil, ErrInvalid
+			}
+			data, err := readPayload(r, size-headerLen)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := tracks[len(tracks)-1].parse(typ, data); err != nil {
+				return nil, nil, err
+			}
+		default:
+			if err := skip(r, size-headerLen); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	var audio *mp4Track
+	for _, t := range tracks {
+		switch t.handler {
+		case "vide":
+			return nil, nil, ErrNotAudio
+		case "soun":
+			if audio == nil {
+				audio = t
+			}
+		}
+	}
+	if audio == nil || audio.timescale == 0 {
+		return nil, nil, ErrUnsupportedFormat
+	}
+
+	var (
+		frames []frame
+		ticks  uint64
+	)
+	for i, size := range audio.sizes {
+		frames = append(frames, frame{Start: mp4Duration(ticks, audio.timescale), Size: int(size)})
+		if i < len(audio.deltas) {
+			ticks += uint64(audio.deltas[i])
+		}
+	}
+	duration := audio.duration
+	if duration == 0 {
+		duration = ticks
+	}
+	return &Info{MimeType: "audio/mp4", Duration: mp4Duration(duration, audio.timescale)}, frames, nil
+}
+
+func (t *mp4Track) parse(typ string, data []byte) error {
+	if len(data) < 12 {
+		return ErrInvalid
+	}
+	switch typ {
+	case "hdlr":
+		t.handler = string(data[8:12])
+	case "mdhd":
+		// version 1 dùng số 64 bit cho thời gian
+		if data[0] == 1 {
+			if len(data) < 32 {
+				return ErrInvalid
+			}
+			t.timescale = binary.BigEndian.Uint32(data[20:24])
+			t.duration = binary.BigEndian.Uint64(data[24:32])
+		} else {
+			if len(data) < 20 {
+				return ErrInvalid
+			}
+			t.timescale = binary.BigEndian.Uint32(data[12:16])
+			t.duration = uint64(binary.BigEndian.Uint32(data[16:20]))
+		}
+	case "stsz":
+		sampleSize := binary.BigEndian.Uint32(data[4:8])
+		count := int(binary.BigEndian.Uint32(data[8:12]))
+		if sampleSize != 0 {
+			// Mọi sample cùng kích thước (CBR)
+			if count > maxElementSize {
+				return ErrInvalid
+			}
+			t.sizes = make([]uint32, count)
+			for i := range t.sizes {
+				t.sizes[i] = sampleSize
+			}
+			return nil
+		}
+		if len(data) < 12+count*4 {
+			return ErrInvalid
+		}
+		t.sizes = make([]uint32, count)
+		for i := range t.sizes {
+			t.sizes[i] = binary.BigEndian.Uint32(data[12+i*4:])
+		}
+	case "stts":
+		count := int(binary.BigEndian.Uint32(data[4:8]))
+		if len(data) < 8+count*8 {
+			return ErrInvalid
+		}
+		for i := 0; i < count; i++ {
+			n := binary.BigEndian.Uint32(data[8+i*8:])
+			delta := binary.BigEndian.Uint32(data[12+i*8:])
+			if len(t.deltas)+int(n) > maxElementSize {
+				return ErrInvalid
+			}
+			for j := uint32(0); j < n; j++ {
+				t.deltas = append(t.deltas, delta)
+			}
+		}
+	}
+	return nil
+}
+
+func mp4Duration(ticks uint64, timescale uint32) time.Duration {
+	return time.Duration(float64(ticks) / float64(timescale) * float64(time.Second))
+}
@@ -0,0 +1,97 @@
+package audioproc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// opusSampleRate: granule position của Ogg Opus luôn tính theo 48kHz
+const opusSampleRate = 48000
+
+// probeOgg đọc Ogg Opus: thời lượng = granule position của page cuối - pre-skip.
+// Chỉ đọc logical stream đầu tiên, các stream khác bị bỏ qua.
+func probeOgg(r *bufio.Reader) (*Info, []frame, error) {
+	var (
+		serial      uint32
+		pages       int
+		packets     int
+		preSkip     int64
+		lastGranule int64 = -1
+		packet      []byte
+		frames      []frame
+		pos         time.Duration
+	)
+	header := make([]byte, 27)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, nil, ErrInvalid
+		}
+		if !bytes.Equal(header[:4], []byte("OggS")) {
+			return nil, nil, ErrInvalid
+		}
+		granule := int64(binary.LittleEndian.Uint64(header[6:14]))
+		pageSerial := binary.LittleEndian.Uint32(header[14:18])
+		lacing, err := readPayload(r, int64(header[26]))
+		if err != nil {
+			return nil, nil, err
+		}
+		size := 0
+		for _, l := range lacing {
+			size += int(l)
+		}
+		data, err := readPayload(r, int64(size))
+		if err != nil {
+			return nil, nil, err
+		}
+		if pages == 0 {
+			serial = pageSerial
+		}
+		pages++
+		if pageSerial != serial {
+			continue
+		}
+
+		// Packet dài hơn 255 byte nằm trên nhiều segment (và có thể nhiều page), segment < 255 kết thúc packet
+		offset := 0
+		for _, l := range lacing {
+			packet = append(packet, data[offset:offset+int(l)]...)
+			offset += int(l)
+			if l == 255 {
+				continue
+			}
+			switch packets {
+			case 0:
+				if !bytes.HasPrefix(packet, []byte("OpusHead")) {
+					return nil, nil, ErrUnsupportedCodec
+				}
+				if len(packet) < 19 {
+					return nil, nil, ErrInvalid
+				}
+				preSkip = int64(binary.LittleEndian.Uint16(packet[10:12]))
+			case 1:
+				// OpusTags
+			default:
+				frames = append(frames, frame{Start: pos, Size: len(packet)})
+				pos += opusPacketDuration(packet)
+			}
+			packets++
+			packet = packet[:0]
+		}
+		// -1: không có packet nào kết thúc trong page này
+		if granule != -1 {
+			lastGranule = granule
+		}
+	}
+	if packets < 2 || lastGranule < preSkip {
+		return nil, nil, ErrInvalid
+	}
+	duration := time.Duration(lastGranule-preSkip) * time.Second / opusSampleRate
+	return &Info{MimeType: "audio/ogg", Duration: duration}, frames, nil
+}
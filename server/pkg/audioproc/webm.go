@@ -0,0 +1,229 @@
+package audioproc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// Element ID của Matroska/WebM cần dùng
+const (
+	ebmlSegment       = 0x18538067
+	ebmlInfo          = 0x1549A966
+	ebmlTimecodeScale = 0x2AD7B1
+	ebmlDuration      = 0x4489
+	ebmlTracks        = 0x1654AE6B
+	ebmlTrackEntry    = 0xAE
+	ebmlTrackNumber   = 0xD7
+	ebmlTrackType     = 0x83
+	ebmlCodecID       = 0x86
+	ebmlCluster       = 0x1F43B675
+	ebmlTimecode      = 0xE7
+	ebmlSimpleBlock   = 0xA3
+	ebmlBlockGroup    = 0xA0
+	ebmlBlock         = 0xA1
+)
+
+// Master element được duyệt vào trong thay vì bỏ qua
+var ebmlMasters = map[uint64]bool{
+	ebmlSegment:    true,
+	ebmlInfo:       true,
+	ebmlTracks:     true,
+	ebmlTrackEntry: true,
+	ebmlCluster:    true,
+	ebmlBlockGroup: true,
+}
+
+type webmTrack struct {
+	number uint64
+	typ    uint64 // 1 = video, 2 = audio
+	codec  string
+}
+
+// probeWebM duyệt tuần tự các element. MediaRecorder của browser ghi Segment/Cluster không có size
+// và thường không có Duration, khi đó thời lượng lấy từ block cuối cùng.
+func probeWebM(r *bufio.Reader) (*Info, []frame, error) {
+	var (
+		scale     uint64 = 1000000 // ns cho mỗi đơn vị timecode
+		duration  float64
+		tracks    []webmTrack
+		audio     *webmTrack
+		clusterTC uint64
+		frames    []frame
+		end       time.Duration
+	)
+	for {
+		id, _, err := readVint(r, true)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, ErrInvalid
+		}
+		size, unknown, err := readElementSize(r)
+		if err != nil {
+			return nil, nil, ErrInvalid
+		}
+		if ebmlMasters[id] {
+			if id == ebmlTrackEntry {
+				tracks = append(tracks, webmTrack{})
+			}
+			continue
+		}
+		if unknown {
+			return nil, nil, ErrInvalid
+		}
+
+		switch id {
+		case ebmlTimecodeScale, ebmlTrackNumber, ebmlTrackType, ebmlTimecode:
+			data, err := readPayload(r, int64(size))
+			if err != nil || len(data) > 8 {
+				return nil, nil, ErrInvalid
+			}
+			v := readUint(data)
+			switch id {
+			case ebmlTimecodeScale:
+				scale = v
+			case ebmlTimecode:
+				clusterTC = v
+			case ebmlTrackNumber, ebmlTrackType:
+				if len(tracks) == 0 {
+					return nil, nil, ErrInvalid
+				}
+				if id == ebmlTrackNumber {
+					tracks[len(tracks)-1].number = v
+				} else {
+					tracks[len(tracks)-1].typ = v
+				}
+			}
+		case ebmlDuration:
+			data, err := readPayload(r, int64(size))
+			if err != nil {
+				return nil, nil, err
+			}
+			switch len(data) {
+			case 4:
+				duration = float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+			case 8:
+				duration = math.Float64frombits(binary.BigEndian.Uint64(data))
+			default:
+				return nil, nil, ErrInvalid
+			}
+		case ebmlCodecID:
+			data, err := readPayload(r, int64(size))
+			if err != nil || len(tracks) == 0 {
+				return nil, nil, ErrInvalid
+			}
+			tracks[len(tracks)-1].codec = string(data)
+		case ebmlSimpleBlock, ebmlBlock:
+			if audio == nil {
+				// Tracks luôn nằm trước Cluster
+				if audio, err = webmAudioTrack(tracks); err != nil {
+					return nil, nil, err
+				}
+			}
+			data, err := readPayload(r, int64(size))
+			if err != nil {
+				return nil, nil, err
+			}
+			track, n, err := readVint(bytes.NewReader(data), false)
+			if err != nil || len(data) < n+3 {
+				return nil, nil, ErrInvalid
+			}
+			if track != audio.number {
+				continue
+			}
+			relative := int64(int16(binary.BigEndian.Uint16(data[n : n+2])))
+			start := time.Duration((int64(clusterTC) + relative) * int64(scale))
+			payload := data[n+3:]
+			frames = append(frames, frame{Start: start, Size: len(payload)})
+			// Block có lacing chứa nhiều frame, chỉ dùng để ước lượng khi không có Duration
+			if data[n+2]&0x06 == 0 && audio.codec == "A_OPUS" {
+				end = max(end, start+opusPacketDuration(payload))
+			} else {
+				end = max(end, start)
+			}
+		default:
+			if err := skip(r, int64(size)); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if audio == nil {
+		if _, err := webmAudioTrack(tracks); err != nil {
+			return nil, nil, err
+		}
+	}
+	info := &Info{MimeType: "audio/webm", Duration: end}
+	if duration > 0 {
+		info.Duration = time.Duration(duration * float64(scale))
+	}
+	return info, frames, nil
+}
+
+// webmAudioTrack trả về track audio đầu tiên, file có track video không phải voice message
+func webmAudioTrack(tracks []webmTrack) (*webmTrack, error) {
+	var audio *webmTrack
+	for i := range tracks {
+		switch tracks[i].typ {
+		case 1:
+			return nil, ErrNotAudio
+		case 2:
+			if audio == nil {
+				audio = &tracks[i]
+			}
+		}
+	}
+	if audio == nil {
+		return nil, ErrUnsupportedFormat
+	}
+	return audio, nil
+}
+
+// readVint đọc số nguyên độ dài thay đổi của EBML. ID giữ nguyên marker bit (keepMarker), size thì bỏ.
+func readVint(r io.ByteReader, keepMarker bool) (value uint64, length int, err error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	length = 1
+	for mask := byte(0x80); first&mask == 0; mask >>= 1 {
+		if mask == 1 {
+			return 0, 0, ErrInvalid
+		}
+		length++
+	}
+	value = uint64(first)
+	if !keepMarker {
+		value &= uint64(0xFF >> length)
+	}
+	for i := 1; i < length; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, ErrInvalid
+		}
+		value = value<<8 | uint64(b)
+	}
+	return value, length, nil
+}
+
+// readElementSize đọc size của element, unknown = true khi mọi bit giá trị đều là 1 (size không xác định)
+func readElementSize(r io.ByteReader) (size uint64, unknown bool, err error) {
+	size, length, err := readVint(r, false)
+	if err != nil {
+		return 0, false, err
+	}
+	return size, size == 1<<(7*uint(length))-1, nil
+}
+
+func readUint(data []byte) uint64 {
+	var v uint64
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
@@ -0,0 +1,129 @@
+// Package health chạy các kiểm tra phụ thuộc (database, Redis, storage...) cho readiness probe.
+// Mỗi check có timeout riêng và chạy song song nên 1 phụ thuộc treo không làm probe treo theo.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"project/pkg/logging"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// DefaultTimeout cho mỗi check, nhỏ hơn timeout của probe (thường 1-3s)
+const DefaultTimeout = 2 * time.Second
+
+// CheckFunc trả về lỗi khi phụ thuộc không dùng được, phải tôn trọng ctx
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+}
+
+// CheckResult là kết quả của 1 check trong response /readyz
+type CheckResult struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// Report là body JSON của /readyz
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Checker giữ danh sách check, an toàn khi gọi Run đồng thời
+type Checker struct {
+	mu       sync.RWMutex
+	checks   []check
+	draining atomic.Bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Add đăng ký check, timeout <= 0 dùng DefaultTimeout
+func (c *Checker) Add(name string, timeout time.Duration, fn CheckFunc) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, timeout: timeout, fn: fn})
+}
+
+// Drain đánh dấu replica đang tắt: /readyz trả fail để orchestrator ngừng gửi request mới
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Run chạy mọi check song song, Status là fail nếu có 1 check fail hoặc đang drain
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Add(1)
+		go func(i int, chk check) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, chk)
+		}(i, chk)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks)+1)}
+	for i, chk := range checks {
+		report.Checks[chk.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	if c.draining.Load() {
+		report.Status = StatusFail
+		report.Checks["shutdown"] = CheckResult{Status: StatusFail, Error: "server is shutting down"}
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, chk check) (result CheckResult) {
+	ctx, cancel := context.WithTimeout(ctx, chk.timeout)
+	defer cancel()
+	start := time.Now()
+	defer func() {
+		result.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+	}()
+
+	// Chạy trong goroutine để check không tôn trọng ctx cũng không giữ probe quá timeout
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		errCh <- chk.fn(ctx)
+	}()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return CheckResult{Status: StatusFail, Error: logging.Redact(err.Error())}
+		}
+		return CheckResult{Status: StatusOK}
+	case <-ctx.Done():
+		return CheckResult{Status: StatusFail, Error: fmt.Sprintf("timeout after %s", chk.timeout)}
+	}
+}
@@ -0,0 +1,46 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunReportsEachCheck(t *testing.T) {
+	c := NewChecker()
+	c.Add("database", 0, func(ctx context.Context) error { return nil })
+	c.Add("redis", 0, func(ctx context.Context) error {
+		return errors.New("dial redis://:secret@10.0.0.5: token=abc refused")
+	})
+	// Check treo không tôn trọng ctx vẫn bị cắt đúng timeout
+	c.Add("storage", 50*time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	start := time.Now()
+	report := c.Run(context.Background())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, StatusOK, report.Checks["database"].Status)
+	assert.Equal(t, StatusFail, report.Checks["redis"].Status)
+	assert.Contains(t, report.Checks["redis"].Error, "token=[REDACTED]")
+	assert.Equal(t, StatusFail, report.Checks["storage"].Status)
+	assert.Equal(t, "timeout after 50ms", report.Checks["storage"].Error)
+}
+
+func TestDrainFailsReadiness(t *testing.T) {
+	c := NewChecker()
+	c.Add("database", 0, func(ctx context.Context) error { return nil })
+	assert.Equal(t, StatusOK, c.Run(context.Background()).Status)
+
+	c.Drain()
+	report := c.Run(context.Background())
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, StatusOK, report.Checks["database"].Status)
+	assert.Equal(t, StatusFail, report.Checks["shutdown"].Status)
+}
@@ -0,0 +1,200 @@
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	_ "image/gif"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("imageproc: unsupported image format")
+	ErrTooLarge          = errors.New("imageproc: image dimensions too large")
+)
+
+// Spec mô tả một rendition: ảnh được thu nhỏ để cạnh dài nhất không quá MaxSize
+type Spec struct {
+	Name    string
+	MaxSize int
+}
+
+// DefaultSpecs là các rendition mặc định cho ảnh gửi trong chat
+var DefaultSpecs = []Spec{
+	{Name: "thumb", MaxSize: 64},
+	{Name: "small", MaxSize: 320},
+	{Name: "large", MaxSize: 1280},
+}
+
+type Options struct {
+	Specs     []Spec
+	MaxPixels int // chặn decompression bomb, 0 = không giới hạn
+	Quality   int // chất lượng JPEG
+}
+
+func DefaultOptions() Options {
+	return Options{Specs: DefaultSpecs, MaxPixels: 50_000_000, Quality: 85}
+}
+
+type Rendition struct {
+	Name        string
+	Width       int
+	Height      int
+	ContentType string
+	Data        []byte
+}
+
+type Result struct {
+	// Original là ảnh gốc đã xoay theo EXIF orientation và encode lại (mất toàn bộ EXIF/GPS).
+	// nil nếu định dạng không cần xử lý (GIF giữ nguyên để không mất animation).
+	Original            []byte
+	OriginalContentType string
+	Width               int
+	Height              int
+	DominantColor       string // dạng #rrggbb
+	Renditions          []Rendition
+}
+
+// Process decode ảnh, bỏ metadata và tạo các rendition JPEG.
+// Rendition lớn hơn ảnh gốc bị bỏ qua, trừ rendition nhỏ nhất luôn được tạo.
+func Process(r io.Reader, opts Options) (*Result, error) {
+	img, format, err := decode(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Quality <= 0 {
+		opts.Quality = 85
+	}
+	bounds := img.Bounds()
+	result := &Result{
+		Width:         bounds.Dx(),
+		Height:        bounds.Dy(),
+		DominantColor: dominantColor(img),
+	}
+
+	if format != "gif" {
+		var buf bytes.Buffer
+		if format == "png" || !isOpaque(img) {
+			err = png.Encode(&buf, img)
+			result.OriginalContentType = "image/png"
+		} else {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 92})
+			result.OriginalContentType = "image/jpeg"
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Original = buf.Bytes()
+	}
+
+	longest := max(result.Width, result.Height)
+	flattened := flatten(img)
+	for i, spec := range opts.Specs {
+		if i > 0 && spec.MaxSize >= longest {
+			continue
+		}
+		resized := flattened
+		if spec.MaxSize < longest {
+			resized = imaging.Fit(flattened, spec.MaxSize, spec.MaxSize, imaging.Lanczos)
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: opts.Quality}); err != nil {
+			return nil, err
+		}
+		b := resized.Bounds()
+		result.Renditions = append(result.Renditions, Rendition{
+			Name:        spec.Name,
+			Width:       b.Dx(),
+			Height:      b.Dy(),
+			ContentType: "image/jpeg",
+			Data:        buf.Bytes(),
+		})
+	}
+	return result, nil
+}
+
+// decode kiểm tra kích thước trước khi decode (chặn decompression bomb) và xoay ảnh theo EXIF orientation
+func decode(r io.Reader, opts Options) (image.Image, string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedFormat
+	}
+	if opts.MaxPixels > 0 && cfg.Width*cfg.Height > opts.MaxPixels {
+		return nil, "", ErrTooLarge
+	}
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, "", fmt.Errorf("imageproc: decode failed: %w", err)
+	}
+	return img, format, nil
+}
+
+// dominantColor lấy màu trung bình của ảnh, đủ để client vẽ placeholder trước khi tải xong
+func dominantColor(img image.Image) string {
+	c := color.NRGBAModel.Convert(imaging.Resize(flatten(img), 1, 1, imaging.Box).At(0, 0)).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// flatten ghép ảnh có alpha lên nền trắng vì JPEG không có kênh alpha
+func flatten(img image.Image) image.Image {
+	if isOpaque(img) {
+		return img
+	}
+	bg := imaging.New(img.Bounds().Dx(), img.Bounds().Dy(), color.White)
+	return imaging.Overlay(bg, img, image.Pt(0, 0), 1)
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// DefaultAvatarSizes là các kích thước avatar (px), ảnh được cắt vuông ở giữa
+var DefaultAvatarSizes = []int{64, 256, 512}
+
+// Avatar cắt ảnh thành hình vuông ở giữa và tạo rendition JPEG cho từng kích thước.
+// Rendition được đặt tên theo kích thước, vd. "256". Ảnh nhỏ hơn kích thước yêu cầu không bị phóng to.
+func Avatar(r io.Reader, sizes []int, opts Options) ([]Rendition, error) {
+	img, _, err := decode(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Quality <= 0 {
+		opts.Quality = 85
+	}
+	img = flatten(img)
+	side := min(img.Bounds().Dx(), img.Bounds().Dy())
+
+	renditions := make([]Rendition, 0, len(sizes))
+	for _, size := range sizes {
+		name := fmt.Sprint(size)
+		size = min(size, side)
+		cropped := imaging.Fill(img, size, size, imaging.Center, imaging.Lanczos)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, cropped, &jpeg.Options{Quality: opts.Quality}); err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, Rendition{
+			Name:        name,
+			Width:       size,
+			Height:      size,
+			ContentType: "image/jpeg",
+			Data:        buf.Bytes(),
+		})
+	}
+	return renditions, nil
+}
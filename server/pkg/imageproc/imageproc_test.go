@@ -0,0 +1,118 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jpegWithOrientation tạo JPEG có segment EXIF chứa orientation (6 = xoay 90°) và chuỗi GPS giả
+func jpegWithOrientation(t *testing.T, w, h int, orientation uint16) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 20, B: 20, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1) // 1 entry
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	tiff = append(tiff, []byte("GPS-SECRET-LOCATION")...)
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	raw := buf.Bytes()
+	out := append([]byte{}, raw[:2]...)
+	out = append(out, segment...)
+	return append(out, raw[2:]...)
+}
+
+func TestProcessStripsExifAndAppliesOrientation(t *testing.T) {
+	data := jpegWithOrientation(t, 400, 200, 6)
+	require.True(t, bytes.Contains(data, []byte("GPS-SECRET-LOCATION")))
+
+	result, err := Process(bytes.NewReader(data), DefaultOptions())
+	require.NoError(t, err)
+
+	// Đã xoay theo orientation: 400x200 → 200x400
+	assert.Equal(t, 200, result.Width)
+	assert.Equal(t, 400, result.Height)
+	assert.Equal(t, "image/jpeg", result.OriginalContentType)
+	assert.False(t, bytes.Contains(result.Original, []byte("Exif")))
+	assert.False(t, bytes.Contains(result.Original, []byte("GPS-SECRET-LOCATION")))
+
+	// large (1280) lớn hơn ảnh gốc nên bị bỏ qua
+	require.Len(t, result.Renditions, 2)
+	assert.Equal(t, "thumb", result.Renditions[0].Name)
+	assert.Equal(t, 32, result.Renditions[0].Width)
+	assert.Equal(t, 64, result.Renditions[0].Height)
+	assert.Equal(t, "small", result.Renditions[1].Name)
+	assert.Equal(t, 320, result.Renditions[1].Height)
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(result.Renditions[1].Data))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, 160, cfg.Width)
+
+	// Màu trung bình gần với đỏ (JPEG có sai số nén)
+	assert.Regexp(t, `^#[c-d][0-9a-f]1[0-9a-f]1[0-9a-f]$`, result.DominantColor)
+}
+
+func TestProcessTransparentPNG(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	result, err := Process(&buf, DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", result.OriginalContentType)
+	// Ảnh nhỏ hơn mọi rendition vẫn có thumbnail, phần trong suốt thành nền trắng
+	require.Len(t, result.Renditions, 1)
+	assert.Equal(t, 10, result.Renditions[0].Width)
+	assert.Equal(t, "#ffffff", result.DominantColor)
+}
+
+func TestProcessRejectsInvalidInput(t *testing.T) {
+	_, err := Process(bytes.NewReader([]byte("not an image")), DefaultOptions())
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	data := jpegWithOrientation(t, 100, 100, 1)
+	_, err = Process(bytes.NewReader(data), Options{Specs: DefaultSpecs, MaxPixels: 5000})
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestAvatarCropsToSquare(t *testing.T) {
+	data := jpegWithOrientation(t, 600, 300, 1)
+	renditions, err := Avatar(bytes.NewReader(data), []int{64, 256, 512}, DefaultOptions())
+	require.NoError(t, err)
+	require.Len(t, renditions, 3)
+
+	assert.Equal(t, "64", renditions[0].Name)
+	// Cạnh ngắn chỉ có 300px nên rendition 512 không bị phóng to
+	assert.Equal(t, "512", renditions[2].Name)
+	assert.Equal(t, 300, renditions[2].Width)
+	for _, r := range renditions {
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(r.Data))
+		require.NoError(t, err)
+		assert.Equal(t, cfg.Width, cfg.Height)
+		assert.False(t, bytes.Contains(r.Data, []byte("Exif")))
+	}
+}
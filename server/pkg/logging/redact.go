@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Key chứa các từ này bị thay toàn bộ giá trị (password, access_token, client_secret, Authorization...)
+var sensitiveKeyParts = []string{"password", "token", "secret", "authorization", "cookie"}
+
+var (
+	jwtRe = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// token=..., password=... trong query string hoặc message dạng key=value
+	secretParamRe = regexp.MustCompile(`(?i)\b((?:access_|refresh_)?token|password|secret)=([^&\s"]+)`)
+	bearerRe      = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/=-]+`)
+	emailRe       = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+)
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact che token (JWT, Bearer, token=...), password=... và email (giữ ký tự đầu và domain) trong chuỗi
+func Redact(s string) string {
+	s = jwtRe.ReplaceAllString(s, redacted)
+	s = bearerRe.ReplaceAllString(s, "Bearer "+redacted)
+	s = secretParamRe.ReplaceAllString(s, "$1="+redacted)
+	return emailRe.ReplaceAllString(s, "$1***@$2")
+}
+
+// redactAttr là ReplaceAttr của handler, được gọi cho cả message ("msg") và attr trong group
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if a.Key != slog.MessageKey && isSensitiveKey(a.Key) {
+		if a.Value.Kind() == slog.KindString && a.Value.String() == "" {
+			return a
+		}
+		return slog.String(a.Key, redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(Redact(a.Value.String()))
+	case slog.KindAny:
+		// error được đổi thành chuỗi để che token/email trong nội dung lỗi
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(Redact(err.Error()))
+		}
+	}
+	return a
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// FileMailer ghi email ra thư mục (dev mode): file .eml đầy đủ và file .html để mở bằng trình duyệt
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("mailer: create mail dir failed: %w", err)
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg *Message) error {
+	data, err := buildMIME(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s", time.Now().Format("20060102-150405.000000"), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	path := filepath.Join(m.dir, name+".eml")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("mailer: write %s failed: %w", path, err)
+	}
+	if msg.HTML != "" {
+		if err := os.WriteFile(filepath.Join(m.dir, name+".html"), []byte(msg.HTML), 0o644); err != nil {
+			return fmt.Errorf("mailer: write html failed: %w", err)
+		}
+	}
+	slog.InfoContext(ctx, "email saved to file", "subject", msg.Subject, "to", msg.To, "path", path)
+	return nil
+}
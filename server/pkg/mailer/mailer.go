@@ -0,0 +1,123 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+var (
+	ErrQueueFull   = errors.New("mailer: queue is full")
+	ErrQueueClosed = errors.New("mailer: queue is closed")
+)
+
+// Message là một email đã render xong (text + HTML)
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer gửi email qua một backend cụ thể (SMTP, file, memory, queue)
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Config chọn backend: Driver "smtp", "file" (ghi ra FileDir để test offline) hoặc "memory"
+type Config struct {
+	Driver  string
+	From    string // địa chỉ gửi, SMTP.From rỗng thì dùng giá trị này
+	FileDir string
+	SMTP    SMTPConfig
+}
+
+// New tạo mailer theo cfg.Driver
+func New(cfg Config) (Mailer, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "smtp":
+		if cfg.SMTP.Username == "" || cfg.SMTP.Password == "" {
+			return nil, errors.New("mailer: SMTP username or password not set")
+		}
+		if cfg.SMTP.From == "" {
+			cfg.SMTP.From = cfg.From
+		}
+		return NewSMTPMailer(cfg.SMTP), nil
+	case "file":
+		return NewFileMailer(cfg.FileDir, cfg.From)
+	case "memory":
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("mailer: unknown driver %q", cfg.Driver)
+	}
+}
+
+// buildMIME tạo email multipart/alternative (text + HTML), header được encode UTF-8
+func buildMIME(from string, msg *Message) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		if part.content == "" {
+			continue
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", from)
+	fmt.Fprintf(&out, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&out, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "Message-ID: <%s@%s>\r\n", randomID(), domainOf(from))
+	out.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&out, "Content-Type: multipart/alternative; boundary=%s\r\n", writer.Boundary())
+	out.WriteString("\r\n")
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func domainOf(address string) string {
+	address = strings.TrimSuffix(address, ">")
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
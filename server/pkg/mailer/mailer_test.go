@@ -0,0 +1,130 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplatesRenderAllLocales(t *testing.T) {
+	templates, err := LoadTemplates()
+	require.NoError(t, err)
+
+	data := Data{Name: "Thinh", Link: "https://devmess.cloud/x?token=a&b=<c>", ExpiresIn: 15 * time.Minute}
+	for _, locale := range locales {
+		for _, name := range templateNames {
+			msg, err := templates.Render(locale, name, data)
+			require.NoError(t, err, "%s/%s", locale, name)
+			assert.NotEmpty(t, msg.Subject, "%s/%s", locale, name)
+			assert.Contains(t, msg.Text, data.Link, "%s/%s", locale, name)
+			// Link trong HTML phải được escape
+			assert.Contains(t, msg.HTML, "token=a&amp;b=%3cc%3e", "%s/%s", locale, name)
+			assert.Contains(t, msg.HTML, `<html lang="`+locale+`">`)
+		}
+	}
+
+	vi, err := templates.Render(LocaleVI, TemplateMagicLink, data)
+	require.NoError(t, err)
+	assert.Contains(t, vi.Text, "15 phút")
+
+	en, err := templates.Render("fr", TemplateMagicLink, data)
+	require.NoError(t, err)
+	assert.Contains(t, en.Text, "15 minutes")
+
+	_, err = templates.Render(LocaleEN, "unknown", data)
+	assert.Error(t, err)
+}
+
+func TestParseLocale(t *testing.T) {
+	tests := map[string]string{
+		"":                         LocaleEN,
+		"vi":                       LocaleVI,
+		"vi-VN,vi;q=0.9,en;q=0.8":  LocaleVI,
+		"fr-FR,en-US;q=0.8,vi;q=1": LocaleEN,
+		"de, VI-vn":                LocaleVI,
+	}
+	for header, expected := range tests {
+		assert.Equal(t, expected, ParseLocale(header), header)
+	}
+}
+
+func TestDurationFormatter(t *testing.T) {
+	en, vi := durationFormatter(LocaleEN), durationFormatter(LocaleVI)
+	assert.Equal(t, "1 hour", en(time.Hour))
+	assert.Equal(t, "24 hours", en(24*time.Hour))
+	assert.Equal(t, "90 minutes", en(90*time.Minute))
+	assert.Equal(t, "30 seconds", en(30*time.Second))
+	assert.Equal(t, "24 giờ", vi(24*time.Hour))
+}
+
+type flakyMailer struct {
+	failures int32
+	calls    atomic.Int32
+	sent     *MemoryMailer
+}
+
+func (m *flakyMailer) Send(ctx context.Context, msg *Message) error {
+	if m.calls.Add(1) <= m.failures {
+		return errors.New("smtp unavailable")
+	}
+	return m.sent.Send(ctx, msg)
+}
+
+func TestQueueRetriesAndDrainsOnClose(t *testing.T) {
+	backend := &flakyMailer{failures: 2, sent: NewMemoryMailer()}
+	queue := NewQueue(backend, QueueConfig{Workers: 1, Size: 10, MaxRetries: 3, RetryBackoff: time.Millisecond})
+
+	require.NoError(t, queue.Send(context.Background(), &Message{To: "a@example.com", Subject: "hi"}))
+	queue.Close()
+
+	assert.Equal(t, int32(3), backend.calls.Load())
+	assert.Len(t, backend.sent.Messages(), 1)
+	assert.ErrorIs(t, queue.Send(context.Background(), &Message{}), ErrQueueClosed)
+}
+
+func TestQueueGivesUpAfterMaxRetries(t *testing.T) {
+	backend := &flakyMailer{failures: 100, sent: NewMemoryMailer()}
+	queue := NewQueue(backend, QueueConfig{Workers: 1, Size: 10, MaxRetries: 2, RetryBackoff: time.Millisecond})
+
+	require.NoError(t, queue.Send(context.Background(), &Message{To: "a@example.com"}))
+	queue.Close()
+
+	assert.Equal(t, int32(3), backend.calls.Load())
+	assert.Empty(t, backend.sent.Messages())
+}
+
+func TestFileMailerWritesEmail(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewFileMailer(dir, "DevMess <no-reply@devmess.cloud>")
+	require.NoError(t, err)
+
+	templates, err := LoadTemplates()
+	require.NoError(t, err)
+	tm := NewTemplateMailer(m, templates)
+	require.NoError(t, tm.SendTemplate(context.Background(), "user@example.com", LocaleVI, TemplateVerifyEmail, Data{
+		Link:      "https://devmess.cloud/verify-email?token=abc",
+		ExpiresIn: 24 * time.Hour,
+	}))
+
+	emls, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	htmls, _ := filepath.Glob(filepath.Join(dir, "*.html"))
+	require.Len(t, emls, 1)
+	require.Len(t, htmls, 1)
+
+	raw, err := os.ReadFile(emls[0])
+	require.NoError(t, err)
+	content := string(raw)
+	assert.Contains(t, content, "To: user@example.com\r\n")
+	// Subject tiếng Việt phải được encode theo RFC 2047
+	assert.Contains(t, content, "Subject: =?UTF-8?q?")
+	assert.Contains(t, content, "multipart/alternative")
+	assert.True(t, strings.Contains(content, "text/plain") && strings.Contains(content, "text/html"))
+}
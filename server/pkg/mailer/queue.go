@@ -0,0 +1,110 @@
+package mailer
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type QueueConfig struct {
+	Workers      int
+	Size         int
+	MaxRetries   int
+	RetryBackoff time.Duration // thời gian chờ trước lần thử lại đầu tiên, nhân đôi sau mỗi lần
+	SendTimeout  time.Duration
+}
+
+func DefaultQueueConfig() QueueConfig {
+	return QueueConfig{
+		Workers:      2,
+		Size:         100,
+		MaxRetries:   3,
+		RetryBackoff: 2 * time.Second,
+		SendTimeout:  30 * time.Second,
+	}
+}
+
+// Queue gửi email bất đồng bộ qua một Mailer khác, tự thử lại khi lỗi.
+// Queue cũng là một Mailer: Send chỉ đưa email vào hàng đợi.
+type Queue struct {
+	mailer Mailer
+	cfg    QueueConfig
+	jobs   chan *Message
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewQueue(m Mailer, cfg QueueConfig) *Queue {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.SendTimeout <= 0 {
+		cfg.SendTimeout = 30 * time.Second
+	}
+	q := &Queue{
+		mailer: m,
+		cfg:    cfg,
+		jobs:   make(chan *Message, cfg.Size),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	return q
+}
+
+// Send đưa email vào hàng đợi, trả về ErrQueueFull nếu hàng đợi đầy
+func (q *Queue) Send(ctx context.Context, msg *Message) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.jobs <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close ngừng nhận email mới và chờ gửi hết email còn trong hàng đợi
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.jobs)
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for msg := range q.jobs {
+		q.deliver(msg)
+	}
+}
+
+func (q *Queue) deliver(msg *Message) {
+	backoff := q.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), q.cfg.SendTimeout)
+		err := q.mailer.Send(ctx, msg)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt >= q.cfg.MaxRetries {
+			slog.Error("give up sending email", "subject", msg.Subject, "to", msg.To, "attempts", attempt+1, "err", err)
+			return
+		}
+		slog.Warn("send email failed, retrying", "subject", msg.Subject, "attempt", attempt+1, "backoff", backoff, "err", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
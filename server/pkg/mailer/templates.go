@@ -0,0 +1,157 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Tên các template email, mỗi locale có 1 file templates/<locale>/<name>.tmpl
+// định nghĩa 3 block "subject", "text" và "content" (phần HTML nằm trong layout)
+const (
+	TemplateResetPassword = "reset_password"
+	TemplateVerifyEmail   = "verify_email"
+	TemplateMagicLink     = "magic_link"
+	TemplateAccountLocked = "account_locked"
+)
+
+// Các locale được hỗ trợ, khớp với client/public/locales
+const (
+	LocaleEN      = "en"
+	LocaleVI      = "vi"
+	DefaultLocale = LocaleEN
+)
+
+var (
+	//go:embed templates
+	templateFS embed.FS
+
+	locales       = []string{LocaleEN, LocaleVI}
+	templateNames = []string{TemplateResetPassword, TemplateVerifyEmail, TemplateMagicLink, TemplateAccountLocked}
+)
+
+// Data là dữ liệu truyền vào template
+type Data struct {
+	Name      string
+	Link      string
+	ExpiresIn time.Duration
+}
+
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// LoadTemplates parse toàn bộ template đã embed, lỗi ngay khi khởi động nếu thiếu file
+func LoadTemplates() (*Templates, error) {
+	t := &Templates{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+	for _, locale := range locales {
+		layout := fmt.Sprintf("templates/%s/layout.tmpl", locale)
+		for _, name := range templateNames {
+			file := fmt.Sprintf("templates/%s/%s.tmpl", locale, name)
+			funcs := map[string]any{"duration": durationFormatter(locale)}
+
+			text, err := texttemplate.New(name).Funcs(funcs).ParseFS(templateFS, file)
+			if err != nil {
+				return nil, fmt.Errorf("mailer: parse %s failed: %w", file, err)
+			}
+			html, err := htmltemplate.New(name).Funcs(funcs).ParseFS(templateFS, layout, file)
+			if err != nil {
+				return nil, fmt.Errorf("mailer: parse %s failed: %w", file, err)
+			}
+			t.text[locale+"/"+name] = text
+			t.html[locale+"/"+name] = html
+		}
+	}
+	return t, nil
+}
+
+// Render tạo subject, text và HTML cho template theo locale (fallback về DefaultLocale)
+func (t *Templates) Render(locale, name string, data Data) (*Message, error) {
+	key := locale + "/" + name
+	if _, ok := t.text[key]; !ok {
+		key = DefaultLocale + "/" + name
+	}
+	text, ok := t.text[key]
+	if !ok {
+		return nil, fmt.Errorf("mailer: unknown template %q", name)
+	}
+
+	var subject, body, html bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := text.ExecuteTemplate(&body, "text", data); err != nil {
+		return nil, err
+	}
+	if err := t.html[key].ExecuteTemplate(&html, "layout", data); err != nil {
+		return nil, err
+	}
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(body.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// ParseLocale chọn locale từ header Accept-Language (vd. "vi-VN,vi;q=0.9,en;q=0.8")
+func ParseLocale(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		tag = strings.SplitN(tag, "-", 2)[0]
+		for _, locale := range locales {
+			if tag == locale {
+				return locale
+			}
+		}
+	}
+	return DefaultLocale
+}
+
+func durationFormatter(locale string) func(time.Duration) string {
+	units := map[string][3]string{
+		LocaleEN: {"hour", "minute", "second"},
+		LocaleVI: {"giờ", "phút", "giây"},
+	}[locale]
+
+	return func(d time.Duration) string {
+		value, unit := int64(d/time.Second), units[2]
+		switch {
+		case d >= time.Hour && d%time.Hour == 0:
+			value, unit = int64(d/time.Hour), units[0]
+		case d >= time.Minute && d%time.Minute == 0:
+			value, unit = int64(d/time.Minute), units[1]
+		}
+		if locale == LocaleEN && value != 1 {
+			unit += "s"
+		}
+		return fmt.Sprintf("%d %s", value, unit)
+	}
+}
+
+// TemplateMailer render template rồi gửi qua Mailer (thường là Queue)
+type TemplateMailer struct {
+	mailer    Mailer
+	templates *Templates
+}
+
+func NewTemplateMailer(m Mailer, templates *Templates) *TemplateMailer {
+	return &TemplateMailer{mailer: m, templates: templates}
+}
+
+func (m *TemplateMailer) SendTemplate(ctx context.Context, to, locale, name string, data Data) error {
+	msg, err := m.templates.Render(locale, name, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	return m.mailer.Send(ctx, msg)
+}
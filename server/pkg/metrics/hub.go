@@ -0,0 +1,52 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// HubStats là trạng thái websocket hub tại thời điểm scrape
+type HubStats struct {
+	Connections int
+	// QueueSaturation là len/cap của send channel từng client (0 = rỗng, 1 = đầy, sắp bị drop message)
+	QueueSaturation []float64
+}
+
+var saturationBuckets = []float64{0.1, 0.25, 0.5, 0.75, 0.9, 1}
+
+// hubCollector đọc stats khi Prometheus scrape thay vì cập nhật gauge mỗi lần gửi message
+type hubCollector struct {
+	stats       func() HubStats
+	connections *prometheus.Desc
+	saturation  *prometheus.Desc
+}
+
+// RegisterHub đăng ký metric của websocket hub, stats được gọi mỗi lần scrape
+func RegisterHub(stats func() HubStats) error {
+	return Registry.Register(&hubCollector{
+		stats: stats,
+		connections: prometheus.NewDesc(prometheus.BuildFQName(namespace, "ws", "connections"),
+			"Active websocket connections.", nil, nil),
+		saturation: prometheus.NewDesc(prometheus.BuildFQName(namespace, "ws", "send_queue_saturation"),
+			"Distribution of per-user send channel fill ratio (len/cap).", nil, nil),
+	})
+}
+
+func (c *hubCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.connections
+	ch <- c.saturation
+}
+
+func (c *hubCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats()
+	ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(stats.Connections))
+
+	buckets := make(map[float64]uint64, len(saturationBuckets))
+	var sum float64
+	for _, v := range stats.QueueSaturation {
+		sum += v
+		for _, b := range saturationBuckets {
+			if v <= b {
+				buckets[b]++
+			}
+		}
+	}
+	ch <- prometheus.MustNewConstHistogram(c.saturation, uint64(len(stats.QueueSaturation)), sum, buckets)
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, h http.Handler, token string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return rec.Code, string(body)
+}
+
+func TestHandlerRequiresToken(t *testing.T) {
+	h := Handler("s3cret")
+	code, _ := scrape(t, h, "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = scrape(t, h, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	WSDroppedMessages.Inc()
+	code, body := scrape(t, h, "s3cret")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "chat_ws_dropped_messages_total")
+}
+
+func TestRegisterHubReportsSaturation(t *testing.T) {
+	require.NoError(t, RegisterHub(func() HubStats {
+		return HubStats{Connections: 3, QueueSaturation: []float64{0, 0.5, 1}}
+	}))
+
+	_, body := scrape(t, Handler(""), "")
+	assert.Contains(t, body, "chat_ws_connections 3")
+	assert.Contains(t, body, `chat_ws_send_queue_saturation_bucket{le="0.1"} 1`)
+	assert.Contains(t, body, `chat_ws_send_queue_saturation_bucket{le="0.5"} 2`)
+	assert.Contains(t, body, `chat_ws_send_queue_saturation_bucket{le="+Inf"} 3`)
+	assert.Contains(t, body, "chat_ws_send_queue_saturation_sum 1.5")
+}
@@ -0,0 +1,60 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSortsAndPairsFiles(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"0010_add_index.up.sql":    {Data: []byte("CREATE INDEX ...")},
+		"0002_users.up.sql":        {Data: []byte("CREATE TABLE users ()")},
+		"0002_users.down.sql":      {Data: []byte("DROP TABLE users")},
+		"0001_init.up.sql":         {Data: []byte("SELECT 1")},
+		"README.md":                {Data: []byte("bỏ qua file không phải .sql")},
+		"0003_seed/notes.up.sql":   {Data: []byte("thư mục con bị bỏ qua")},
+		"0004_empty_down.up.sql":   {Data: []byte("SELECT 4")},
+		"0004_empty_down.down.sql": {Data: []byte("")},
+	})
+	require.NoError(t, err)
+	require.Len(t, migrations, 4)
+	assert.Equal(t, []int64{1, 2, 4, 10}, []int64{migrations[0].Version, migrations[1].Version, migrations[2].Version, migrations[3].Version})
+	assert.Equal(t, "users", migrations[1].Name)
+	assert.Equal(t, "DROP TABLE users", migrations[1].Down)
+	assert.Equal(t, "0010_add_index", migrations[3].String())
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"bad name":          {"init.up.sql": {Data: []byte("SELECT 1")}},
+		"duplicate version": {"0001_a.up.sql": {Data: []byte("SELECT 1")}, "0001_b.up.sql": {Data: []byte("SELECT 1")}},
+		"down without up":   {"0001_a.down.sql": {Data: []byte("SELECT 1")}},
+	}
+	for name, fsys := range cases {
+		_, err := Load(fsys)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestCreateUsesNextVersion(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0007_init.up.sql"), []byte("SELECT 1"), 0o644))
+
+	up, down, err := Create(dir, "add_reactions")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0008_add_reactions.up.sql"), up)
+	assert.Equal(t, filepath.Join(dir, "0008_add_reactions.down.sql"), down)
+
+	migrations, err := Load(os.DirFS(dir))
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, int64(8), migrations[1].Version)
+
+	_, _, err = Create(dir, "Bad Name")
+	assert.Error(t, err)
+}
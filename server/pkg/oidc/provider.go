@@ -0,0 +1,283 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Config cấu hình một provider OAuth2/OIDC.
+// Nếu có Issuer, các endpoint được lấy qua discovery (/.well-known/openid-configuration),
+// endpoint khai báo tường minh sẽ ghi đè kết quả discovery.
+type Config struct {
+	Name         string
+	DisplayName  string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Issuer       string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	EmailsURL    string // GitHub trả email đã xác thực ở endpoint riêng
+	Scopes       []string
+	DisablePKCE  bool
+}
+
+// UserInfo là thông tin user đã được chuẩn hóa từ các provider khác nhau
+type UserInfo struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+type Provider struct {
+	name        string
+	displayName string
+	oauth       *oauth2.Config
+	userInfoURL string
+	emailsURL   string
+	pkce        bool
+	client      *http.Client
+}
+
+// NewProvider tạo provider, chạy discovery nếu cfg.Issuer được khai báo
+func NewProvider(ctx context.Context, cfg Config) (*Provider, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("oidc: provider name is required")
+	}
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("oidc: client id is required for %s", cfg.Name)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	if cfg.Issuer != "" {
+		doc, err := discover(ctx, client, cfg.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.AuthURL == "" {
+			cfg.AuthURL = doc.AuthorizationEndpoint
+		}
+		if cfg.TokenURL == "" {
+			cfg.TokenURL = doc.TokenEndpoint
+		}
+		if cfg.UserInfoURL == "" {
+			cfg.UserInfoURL = doc.UserInfoEndpoint
+		}
+	}
+	if cfg.AuthURL == "" || cfg.TokenURL == "" || cfg.UserInfoURL == "" {
+		return nil, fmt.Errorf("oidc: %s is missing authorization, token or userinfo endpoint", cfg.Name)
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	displayName := cfg.DisplayName
+	if displayName == "" {
+		displayName = cfg.Name
+	}
+
+	return &Provider{
+		name:        strings.ToLower(cfg.Name),
+		displayName: displayName,
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  cfg.AuthURL,
+				TokenURL: cfg.TokenURL,
+			},
+		},
+		userInfoURL: cfg.UserInfoURL,
+		emailsURL:   cfg.EmailsURL,
+		pkce:        !cfg.DisablePKCE,
+		client:      client,
+	}, nil
+}
+
+func discover(ctx context.Context, client *http.Client, issuer string) (*discoveryDocument, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discovery failed for %s: %w", issuer, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: discovery for %s returned status %d", issuer, resp.StatusCode)
+	}
+
+	var doc discoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("oidc: invalid discovery document: %w", err)
+	}
+	// Issuer phải khớp, trừ issuer multi-tenant dạng https://login.microsoftonline.com/{tenantid}/v2.0
+	if doc.Issuer != "" && strings.TrimSuffix(doc.Issuer, "/") != issuer && !strings.Contains(doc.Issuer, "{tenantid}") {
+		return nil, fmt.Errorf("oidc: issuer mismatch, expected %s got %s", issuer, doc.Issuer)
+	}
+	return &doc, nil
+}
+
+func (p *Provider) Name() string {
+	return p.name
+}
+
+func (p *Provider) DisplayName() string {
+	return p.displayName
+}
+
+// AuthCodeURL tạo URL redirect tới provider. verifier rỗng khi provider tắt PKCE.
+func (p *Provider) AuthCodeURL(state, verifier string) string {
+	opts := []oauth2.AuthCodeOption{}
+	if p.pkce && verifier != "" {
+		opts = append(opts, oauth2.S256ChallengeOption(verifier))
+	}
+	return p.oauth.AuthCodeURL(state, opts...)
+}
+
+// NewVerifier tạo PKCE code verifier (rỗng nếu provider tắt PKCE)
+func (p *Provider) NewVerifier() string {
+	if !p.pkce {
+		return ""
+	}
+	return oauth2.GenerateVerifier()
+}
+
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*oauth2.Token, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	opts := []oauth2.AuthCodeOption{}
+	if p.pkce && verifier != "" {
+		opts = append(opts, oauth2.VerifierOption(verifier))
+	}
+	token, err := p.oauth.Exchange(ctx, code, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: exchange token failed: %w", err)
+	}
+	return token, nil
+}
+
+// UserInfo gọi userinfo endpoint và chuẩn hóa claims (OIDC chuẩn, Google v2, GitHub)
+func (p *Provider) UserInfo(ctx context.Context, token *oauth2.Token) (*UserInfo, error) {
+	var claims map[string]any
+	if err := p.getJSON(ctx, token, p.userInfoURL, &claims); err != nil {
+		return nil, err
+	}
+
+	info := &UserInfo{
+		Subject:       firstString(claims, "sub", "id"),
+		Email:         firstString(claims, "email"),
+		EmailVerified: firstBool(claims, "email_verified", "verified_email"),
+		Name:          firstString(claims, "name", "preferred_username", "login"),
+		Picture:       firstString(claims, "picture", "avatar_url"),
+	}
+	if info.Subject == "" {
+		return nil, errors.New("oidc: userinfo response has no subject")
+	}
+
+	if p.emailsURL != "" {
+		if err := p.loadPrimaryEmail(ctx, token, info); err != nil {
+			return nil, err
+		}
+	}
+	info.Email = strings.ToLower(strings.TrimSpace(info.Email))
+	if info.Email == "" {
+		info.EmailVerified = false
+	}
+	return info, nil
+}
+
+// loadPrimaryEmail lấy email chính đã xác thực (GitHub không trả email_verified trong /user)
+func (p *Provider) loadPrimaryEmail(ctx context.Context, token *oauth2.Token, info *UserInfo) error {
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, token, p.emailsURL, &emails); err != nil {
+		return err
+	}
+	for _, e := range emails {
+		if e.Primary {
+			info.Email = e.Email
+			info.EmailVerified = e.Verified
+			return nil
+		}
+	}
+	info.EmailVerified = false
+	return nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, token *oauth2.Token, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	token.SetAuthHeader(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("oidc: get %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("oidc: %s returned status %d: %s", url, resp.StatusCode, body)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("oidc: invalid response from %s: %w", url, err)
+	}
+	return nil
+}
+
+func firstString(claims map[string]any, keys ...string) string {
+	for _, key := range keys {
+		switch v := claims[key].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			// GitHub trả id dạng số
+			return strconv.FormatInt(int64(v), 10)
+		}
+	}
+	return ""
+}
+
+func firstBool(claims map[string]any, keys ...string) bool {
+	for _, key := range keys {
+		switch v := claims[key].(type) {
+		case bool:
+			return v
+		case string:
+			// Một số provider (vd. AWS Cognito) trả "true" dạng chuỗi
+			return v == "true"
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMockServer giả lập một OIDC provider: discovery, token endpoint có kiểm tra PKCE và userinfo
+func newMockServer(t *testing.T, claims map[string]any) *httptest.Server {
+	t.Helper()
+	var challenge string
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"userinfo_endpoint":      srv.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		challenge = r.URL.Query().Get("code_challenge")
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "mock-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer mock-access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(claims)
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"email": "secondary@example.com", "primary": false, "verified": true},
+			{"email": "Primary@Example.com", "primary": true, "verified": true},
+		})
+	})
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// authorize giả lập trình duyệt đi tới trang đăng nhập của provider
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	resp, err := http.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	return u.Query()
+}
+
+func TestProviderDiscoveryAndPKCEFlow(t *testing.T) {
+	srv := newMockServer(t, map[string]any{
+		"sub":            "user-123",
+		"email":          "Alice@Example.com",
+		"email_verified": true,
+		"name":           "Alice",
+	})
+
+	provider, err := NewProvider(context.Background(), Config{
+		Name:        "Keycloak",
+		ClientID:    "client",
+		RedirectURL: "http://localhost:8080/api/v1/auth/oidc/keycloak/callback",
+		Issuer:      srv.URL,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "keycloak", provider.Name())
+
+	verifier := provider.NewVerifier()
+	query := authorize(t, provider.AuthCodeURL("state-abc", verifier))
+	assert.Equal(t, "state-abc", query.Get("state"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.Equal(t, "openid email profile", query.Get("scope"))
+
+	// Sai verifier → provider từ chối
+	_, err = provider.Exchange(context.Background(), "good-code", "wrong-verifier-wrong-verifier-wrong-verifier")
+	assert.Error(t, err)
+
+	token, err := provider.Exchange(context.Background(), "good-code", verifier)
+	require.NoError(t, err)
+
+	info, err := provider.UserInfo(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, &UserInfo{
+		Subject:       "user-123",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Name:          "Alice",
+	}, info)
+}
+
+func TestProviderGitHubStyleUserInfo(t *testing.T) {
+	srv := newMockServer(t, map[string]any{
+		"id":         float64(987654),
+		"login":      "octocat",
+		"email":      nil,
+		"avatar_url": "https://example.com/a.png",
+	})
+
+	provider, err := NewProvider(context.Background(), Config{
+		Name:        "github",
+		ClientID:    "client",
+		AuthURL:     srv.URL + "/authorize",
+		TokenURL:    srv.URL + "/token",
+		UserInfoURL: srv.URL + "/userinfo",
+		EmailsURL:   srv.URL + "/user/emails",
+	})
+	require.NoError(t, err)
+
+	verifier := provider.NewVerifier()
+	authorize(t, provider.AuthCodeURL("state", verifier))
+	token, err := provider.Exchange(context.Background(), "good-code", verifier)
+	require.NoError(t, err)
+
+	info, err := provider.UserInfo(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "987654", info.Subject)
+	assert.Equal(t, "octocat", info.Name)
+	assert.Equal(t, "primary@example.com", info.Email)
+	assert.True(t, info.EmailVerified)
+	assert.Equal(t, "https://example.com/a.png", info.Picture)
+}
+
+func TestNewProviderIssuerMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 "https://evil.example.com",
+			"authorization_endpoint": "https://evil.example.com/authorize",
+			"token_endpoint":         "https://evil.example.com/token",
+			"userinfo_endpoint":      "https://evil.example.com/userinfo",
+		})
+	}))
+	defer srv.Close()
+
+	_, err := NewProvider(context.Background(), Config{Name: "bad", ClientID: "client", Issuer: srv.URL})
+	assert.ErrorContains(t, err, "issuer mismatch")
+}
+
+func TestWithPreset(t *testing.T) {
+	github := WithPreset(Config{Name: "GitHub", ClientID: "gh-id", RedirectURL: "https://api.example.com/api/v1/auth/oidc/github/callback"})
+	assert.Equal(t, "github", github.Name)
+	assert.Equal(t, "gh-id", github.ClientID)
+	assert.Equal(t, "GitHub", github.DisplayName)
+	assert.Equal(t, "https://api.github.com/user/emails", github.EmailsURL)
+	assert.Equal(t, []string{"read:user", "user:email"}, github.Scopes)
+	assert.Equal(t, "https://api.example.com/api/v1/auth/oidc/github/callback", github.RedirectURL)
+
+	keycloak := WithPreset(Config{Name: "keycloak", ClientID: "kc-id", Issuer: "https://sso.example.com/realms/devmess", Scopes: []string{"openid", "email"}})
+	assert.Equal(t, "Keycloak", keycloak.DisplayName)
+	assert.Equal(t, "https://sso.example.com/realms/devmess", keycloak.Issuer)
+	assert.Equal(t, []string{"openid", "email"}, keycloak.Scopes)
+
+	// Provider không có preset dùng nguyên cấu hình
+	custom := WithPreset(Config{Name: "corp", Issuer: "https://id.corp.example.com"})
+	assert.Equal(t, "https://id.corp.example.com", custom.Issuer)
+	assert.Empty(t, custom.DisplayName)
+}
@@ -0,0 +1,109 @@
+package oidc
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// presets chứa cấu hình mặc định cho các provider phổ biến, chỉ cần khai báo client id/secret
+var presets = map[string]Config{
+	"github": {
+		DisplayName: "GitHub",
+		AuthURL:     "https://github.com/login/oauth/authorize",
+		TokenURL:    "https://github.com/login/oauth/access_token",
+		UserInfoURL: "https://api.github.com/user",
+		EmailsURL:   "https://api.github.com/user/emails",
+		Scopes:      []string{"read:user", "user:email"},
+	},
+	"gitlab": {
+		DisplayName: "GitLab",
+		Issuer:      "https://gitlab.com",
+	},
+	"microsoft": {
+		DisplayName: "Microsoft",
+		Issuer:      "https://login.microsoftonline.com/common/v2.0",
+	},
+	"google": {
+		DisplayName: "Google",
+		Issuer:      "https://accounts.google.com",
+	},
+	"keycloak": {
+		DisplayName: "Keycloak",
+	},
+}
+
+// Registry quản lý các provider theo tên
+type Registry struct {
+	providers map[string]*Provider
+	names     []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{providers: make(map[string]*Provider)}
+}
+
+func (r *Registry) Register(p *Provider) {
+	if _, exists := r.providers[p.Name()]; !exists {
+		r.names = append(r.names, p.Name())
+	}
+	r.providers[p.Name()] = p
+}
+
+func (r *Registry) Get(name string) (*Provider, bool) {
+	p, ok := r.providers[strings.ToLower(name)]
+	return p, ok
+}
+
+// Providers trả về danh sách provider theo thứ tự đăng ký
+func (r *Registry) Providers() []*Provider {
+	list := make([]*Provider, 0, len(r.names))
+	for _, name := range r.names {
+		list = append(list, r.providers[name])
+	}
+	return list
+}
+
+// WithPreset trả về cfg với các field rỗng lấy từ preset của provider cùng tên (nếu có),
+// provider có preset chỉ cần khai báo client id/secret
+func WithPreset(cfg Config) Config {
+	cfg.Name = strings.ToLower(cfg.Name)
+	preset := presets[cfg.Name]
+	override(&preset.DisplayName, cfg.DisplayName)
+	override(&preset.Issuer, cfg.Issuer)
+	override(&preset.AuthURL, cfg.AuthURL)
+	override(&preset.TokenURL, cfg.TokenURL)
+	override(&preset.UserInfoURL, cfg.UserInfoURL)
+	override(&preset.EmailsURL, cfg.EmailsURL)
+	if len(cfg.Scopes) > 0 {
+		preset.Scopes = cfg.Scopes
+	}
+	preset.Name = cfg.Name
+	preset.ClientID = cfg.ClientID
+	preset.ClientSecret = cfg.ClientSecret
+	preset.RedirectURL = cfg.RedirectURL
+	preset.DisablePKCE = cfg.DisablePKCE
+	return preset
+}
+
+func override(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+// LoadRegistry tạo registry từ danh sách cấu hình (đã qua WithPreset).
+// Provider cấu hình sai hoặc discovery lỗi chỉ bị bỏ qua, không làm server dừng.
+func LoadRegistry(ctx context.Context, configs []Config) *Registry {
+	registry := NewRegistry()
+	for _, cfg := range configs {
+		provider, err := NewProvider(ctx, cfg)
+		if err != nil {
+			slog.WarnContext(ctx, "skip oidc provider", "provider", cfg.Name, "err", err)
+			continue
+		}
+		registry.Register(provider)
+		slog.InfoContext(ctx, "oidc provider registered", "provider", cfg.Name, "redirect_url", cfg.RedirectURL)
+	}
+	return registry
+}
@@ -0,0 +1,107 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+type ClamAVConfig struct {
+	Addr      string        // host:port của clamd, mặc định localhost:3310
+	Timeout   time.Duration // thời gian tối đa cho 1 lần quét
+	ChunkSize int
+}
+
+// ClamAV gửi file tới clamd bằng lệnh INSTREAM, không cần clamd đọc được file trên disk của server
+type ClamAV struct {
+	cfg ClamAVConfig
+}
+
+func NewClamAV(cfg ClamAVConfig) *ClamAV {
+	if cfg.Addr == "" {
+		cfg.Addr = "localhost:3310"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Minute
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = 64 << 10
+	}
+	return &ClamAV{cfg: cfg}
+}
+
+// Scan gửi "zINSTREAM\0", rồi từng chunk [độ dài uint32 big-endian][dữ liệu], kết thúc bằng chunk độ dài 0.
+// clamd trả "stream: OK", "stream: <signature> FOUND" hoặc "... ERROR".
+func (c *ClamAV) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("scanner: connect clamd failed: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("scanner: write command failed: %w", err)
+	}
+	buf := make([]byte, 4+c.cfg.ChunkSize)
+	for {
+		n, readErr := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				// clamd đóng kết nối khi vượt StreamMaxLength, đọc reply để biết lý do
+				if reply, replyErr := readReply(conn); replyErr == nil {
+					return nil, fmt.Errorf("scanner: clamd: %s", reply)
+				}
+				return nil, fmt.Errorf("scanner: write chunk failed: %w", err)
+			}
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, fmt.Errorf("scanner: write end of stream failed: %w", err)
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return nil, fmt.Errorf("scanner: read reply failed: %w", err)
+	}
+	return parseReply(reply)
+}
+
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(errors.Is(err, io.EOF) && reply != "") {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimRight(reply, "\x00")), nil
+}
+
+func parseReply(reply string) (*Result, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return &Result{Clean: true}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &Result{Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("scanner: clamd: %s", reply)
+	}
+}
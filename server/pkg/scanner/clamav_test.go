@@ -0,0 +1,90 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd nhận INSTREAM như clamd thật và báo FOUND nếu nội dung chứa chuỗi EICAR
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				cmd, err := r.ReadString(0)
+				if err != nil || cmd != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var data bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+						return
+					}
+				}
+				if strings.Contains(data.String(), "EICAR-STANDARD-ANTIVIRUS-TEST-FILE") {
+					conn.Write([]byte("stream: Win.Test.EICAR_HDB-1 FOUND\x00"))
+					return
+				}
+				conn.Write([]byte("stream: OK\x00"))
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestClamAVScan(t *testing.T) {
+	ctx := context.Background()
+	clam := NewClamAV(ClamAVConfig{Addr: fakeClamd(t), ChunkSize: 16})
+
+	result, err := clam.Scan(ctx, strings.NewReader("just a normal file with several chunks"))
+	require.NoError(t, err)
+	assert.True(t, result.Clean)
+
+	result, err = clam.Scan(ctx, strings.NewReader("prefix "+eicar))
+	require.NoError(t, err)
+	assert.False(t, result.Clean)
+	assert.Equal(t, "Win.Test.EICAR_HDB-1", result.Signature)
+}
+
+func TestClamAVUnavailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	_, err = NewClamAV(ClamAVConfig{Addr: addr}).Scan(context.Background(), strings.NewReader("x"))
+	assert.Error(t, err)
+}
+
+func TestParseReplyError(t *testing.T) {
+	_, err := parseReply("INSTREAM size limit exceeded. ERROR")
+	assert.ErrorContains(t, err, "size limit exceeded")
+}
@@ -0,0 +1,46 @@
+// Package scanner quét file upload tìm malware. Backend: clamav (clamd qua TCP) hoặc noop.
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Result là kết quả quét, Signature là tên mẫu malware khi Clean = false
+type Result struct {
+	Clean     bool
+	Signature string
+}
+
+// Scanner quét nội dung r. Lỗi (scanner không chạy, timeout...) khác với file bị nhiễm:
+// khi có lỗi caller nên thử lại thay vì coi file là sạch.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (*Result, error)
+}
+
+// Noop coi mọi file là sạch, dùng khi không cấu hình scanner (dev) hoặc trong test
+type Noop struct{}
+
+func (Noop) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	return &Result{Clean: true}, nil
+}
+
+// Func biến một hàm thành Scanner, tiện cho test
+type Func func(ctx context.Context, r io.Reader) (*Result, error)
+
+func (f Func) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	return f(ctx, r)
+}
+
+// New chọn backend theo driver (clamav | noop, rỗng = noop)
+func New(driver string, clamav ClamAVConfig) (Scanner, error) {
+	switch driver {
+	case "", "noop":
+		return Noop{}, nil
+	case "clamav":
+		return NewClamAV(clamav), nil
+	default:
+		return nil, fmt.Errorf("scanner: unknown driver %q", driver)
+	}
+}
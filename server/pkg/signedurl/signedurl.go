@@ -0,0 +1,69 @@
+// Package signedurl ký URL tải file bằng HMAC-SHA256. Chữ ký gắn với resource (file), user và thời hạn
+// nên URL bị lộ chỉ dùng được cho đúng file đó trong thời gian ngắn, không lộ access token.
+package signedurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("signedurl: invalid signature")
+	ErrExpired          = errors.New("signedurl: url expired")
+)
+
+// Tên các query param được thêm vào URL
+const (
+	ParamUser      = "uid"
+	ParamExpires   = "expires"
+	ParamSignature = "signature"
+)
+
+type Signer struct {
+	key []byte
+	now func() time.Time
+}
+
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key, now: time.Now}
+}
+
+// Sign trả về query param (uid, expires, signature) cho resource, caller tự ghép vào URL
+func (s *Signer) Sign(resource, userID string, ttl time.Duration) (url.Values, time.Time) {
+	expiresAt := s.now().Add(ttl).Truncate(time.Second)
+	exp := strconv.FormatInt(expiresAt.Unix(), 10)
+	q := url.Values{}
+	q.Set(ParamUser, userID)
+	q.Set(ParamExpires, exp)
+	q.Set(ParamSignature, s.sign(resource, userID, exp))
+	return q, expiresAt
+}
+
+// Verify kiểm tra chữ ký và thời hạn, trả về user mà URL được cấp cho và thời điểm hết hạn
+func (s *Signer) Verify(resource string, q url.Values) (string, time.Time, error) {
+	userID, exp := q.Get(ParamUser), q.Get(ParamExpires)
+	expected := s.sign(resource, userID, exp)
+	if userID == "" || !hmac.Equal([]byte(expected), []byte(q.Get(ParamSignature))) {
+		return "", time.Time{}, ErrInvalidSignature
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return "", time.Time{}, ErrInvalidSignature
+	}
+	expiresAt := time.Unix(unix, 0)
+	if s.now().After(expiresAt) {
+		return "", time.Time{}, ErrExpired
+	}
+	return userID, expiresAt, nil
+}
+
+func (s *Signer) sign(resource, userID, expires string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(resource + "\n" + userID + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,38 @@
+package signedurl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	s := NewSigner([]byte("secret"))
+	q, expiresAt := s.Sign("attachments/a", "user-1", time.Minute)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, 2*time.Second)
+
+	userID, exp, err := s.Verify("attachments/a", q)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+	assert.True(t, exp.Equal(expiresAt))
+
+	// Chữ ký gắn với resource và user
+	_, _, err = s.Verify("attachments/b", q)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	forged := map[string][]string{ParamUser: {"user-2"}, ParamExpires: q[ParamExpires], ParamSignature: q[ParamSignature]}
+	_, _, err = s.Verify("attachments/a", forged)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, _, err = NewSigner([]byte("other")).Verify("attachments/a", q)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestVerifyExpired(t *testing.T) {
+	s := NewSigner([]byte("secret"))
+	q, _ := s.Sign("attachments/a", "user-1", time.Minute)
+	s.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	_, _, err := s.Verify("attachments/a", q)
+	assert.ErrorIs(t, err, ErrExpired)
+}
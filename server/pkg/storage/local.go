@@ -0,0 +1,302 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// metaDir chứa metadata (content type) của từng object, nằm trong thư mục gốc
+	metaDir = ".meta"
+	// uploadsDir chứa các part của multipart upload đang dở
+	uploadsDir = ".uploads"
+)
+
+type LocalConfig struct {
+	Root       string // thư mục lưu file
+	PublicURL  string // URL gốc của Handler, vd. http://localhost:8080/api/v1/storage
+	SigningKey string // khóa HMAC để ký presigned URL
+}
+
+// LocalStore lưu object trên disk, presigned URL được phục vụ bởi Handler()
+type LocalStore struct {
+	root      string
+	publicURL string
+	key       []byte
+}
+
+type localMeta struct {
+	ContentType string `json:"content_type"`
+}
+
+func NewLocalStore(cfg LocalConfig) (*LocalStore, error) {
+	if err := os.MkdirAll(cfg.Root, 0o755); err != nil {
+		return nil, fmt.Errorf("storage: create root dir failed: %w", err)
+	}
+	return &LocalStore{
+		root:      cfg.Root,
+		publicURL: strings.TrimSuffix(cfg.PublicURL, "/"),
+		key:       []byte(cfg.SigningKey),
+	}, nil
+}
+
+func (s *LocalStore) paths(key string) (string, string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	for _, dir := range []string{metaDir, uploadsDir} {
+		if key == dir || strings.HasPrefix(key, dir+"/") {
+			return "", "", ErrInvalidKey
+		}
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)),
+		filepath.Join(s.root, metaDir, filepath.FromSlash(key)+".json"), nil
+}
+
+// Put ghi vào file tạm rồi rename để reader không thấy file ghi dở
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	filePath, metaPath, err := s.paths(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("storage: write %s failed: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return err
+	}
+
+	if contentType == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0o755); err != nil {
+		return err
+	}
+	data, _ := json.Marshal(localMeta{ContentType: contentType})
+	return os.WriteFile(metaPath, data, 0o644)
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	filePath, _, _ := s.paths(key)
+	f, err := os.Open(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	filePath, metaPath, err := s.paths(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(metaPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) Copy(ctx context.Context, srcKey, dstKey string) error {
+	rc, info, err := s.Get(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return s.Put(ctx, dstKey, rc, info.Size, info.ContentType)
+}
+
+func (s *LocalStore) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	filePath, metaPath, err := s.paths(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, ErrNotFound
+	}
+
+	contentType := ""
+	if data, err := os.ReadFile(metaPath); err == nil {
+		var meta localMeta
+		if json.Unmarshal(data, &meta) == nil {
+			contentType = meta.ContentType
+		}
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(filePath))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	cleanKey, _ := CleanKey(key)
+	return &ObjectInfo{
+		Key:         cleanKey,
+		Size:        fi.Size(),
+		ContentType: contentType,
+		ModTime:     fi.ModTime(),
+	}, nil
+}
+
+func (s *LocalStore) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return s.presign(http.MethodGet, key, "", expires, nil)
+}
+
+func (s *LocalStore) PresignPut(ctx context.Context, key string, contentType string, expires time.Duration) (string, error) {
+	return s.presign(http.MethodPut, key, contentType, expires, nil)
+}
+
+// presign ký URL, q chứa thêm tham số (uploadId, partNumber) cũng được đưa vào chữ ký
+func (s *LocalStore) presign(method, key, contentType string, expires time.Duration, q url.Values) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if q == nil {
+		q = url.Values{}
+	}
+	exp := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	q.Set("expires", exp)
+	q.Set("signature", s.sign(method, key, contentType, exp, partParams(q)))
+	return fmt.Sprintf("%s/%s?%s", s.publicURL, escapeKey(key), q.Encode()), nil
+}
+
+func (s *LocalStore) sign(method, key, contentType, expires, extra string) string {
+	mac := hmac.New(sha256.New, s.key)
+	payload := method + "\n" + key + "\n" + contentType + "\n" + expires
+	if extra != "" {
+		payload += "\n" + extra
+	}
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *LocalStore) verify(method, key, contentType string, q url.Values) bool {
+	expires := q.Get("expires")
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	expected := s.sign(method, key, contentType, expires, partParams(q))
+	return hmac.Equal([]byte(expected), []byte(q.Get("signature")))
+}
+
+// partParams trả về phần chữ ký riêng của request upload part, rỗng với request thường
+func partParams(q url.Values) string {
+	if q.Get("uploadId") == "" && q.Get("partNumber") == "" {
+		return ""
+	}
+	return q.Get("uploadId") + "\n" + q.Get("partNumber")
+}
+
+// Handler phục vụ presigned URL của LocalStore (GET hỗ trợ Range, PUT để upload trực tiếp).
+// Mount tại PublicURL, key là phần path còn lại của request.
+func (s *LocalStore) Handler(prefix string) http.Handler {
+	return http.StripPrefix(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, err := CleanKey(r.URL.Path)
+		if err != nil {
+			http.Error(w, "invalid key", http.StatusBadRequest)
+			return
+		}
+		q := r.URL.Query()
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			if !s.verify(http.MethodGet, key, "", q) {
+				http.Error(w, "invalid or expired signature", http.StatusForbidden)
+				return
+			}
+			rc, info, err := s.Get(r.Context(), key)
+			if err != nil {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			defer rc.Close()
+			w.Header().Set("Content-Type", info.ContentType)
+			http.ServeContent(w, r, key, info.ModTime, rc.(io.ReadSeeker))
+
+		case http.MethodPut:
+			if q.Get("uploadId") != "" {
+				if !s.verify(http.MethodPut, key, "", q) {
+					http.Error(w, "invalid or expired signature", http.StatusForbidden)
+					return
+				}
+				partNumber, _ := strconv.Atoi(q.Get("partNumber"))
+				etag, err := s.putPart(key, q.Get("uploadId"), partNumber, r.Body)
+				if err != nil {
+					http.Error(w, "upload part failed", http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("ETag", etag)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			contentType := r.Header.Get("Content-Type")
+			if !s.verify(http.MethodPut, key, contentType, q) {
+				http.Error(w, "invalid or expired signature", http.StatusForbidden)
+				return
+			}
+			if err := s.Put(r.Context(), key, r.Body, r.ContentLength, contentType); err != nil {
+				http.Error(w, "upload failed", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+}
+
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
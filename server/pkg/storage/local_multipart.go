@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// localUpload là thông tin của một multipart upload, lưu tại .uploads/<uploadID>/upload.json
+type localUpload struct {
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+}
+
+func (s *LocalStore) uploadDir(uploadID string) (string, error) {
+	// uploadID do store tự sinh (hex), chặn mọi giá trị khác để tránh path traversal
+	if len(uploadID) != 32 {
+		return "", ErrInvalidPart
+	}
+	if _, err := hex.DecodeString(uploadID); err != nil {
+		return "", ErrInvalidPart
+	}
+	return filepath.Join(s.root, uploadsDir, uploadID), nil
+}
+
+func (s *LocalStore) loadUpload(key, uploadID string) (string, *localUpload, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := s.uploadDir(uploadID)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "upload.json"))
+	if err != nil {
+		return "", nil, ErrInvalidPart
+	}
+	var upload localUpload
+	if err := json.Unmarshal(data, &upload); err != nil || upload.Key != key {
+		return "", nil, ErrInvalidPart
+	}
+	return dir, &upload, nil
+}
+
+func (s *LocalStore) CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	if _, _, err := s.paths(key); err != nil {
+		return "", err
+	}
+	cleanKey, _ := CleanKey(key)
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	uploadID := hex.EncodeToString(buf)
+	dir, _ := s.uploadDir(uploadID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	data, _ := json.Marshal(localUpload{Key: cleanKey, ContentType: contentType})
+	if err := os.WriteFile(filepath.Join(dir, "upload.json"), data, 0o644); err != nil {
+		return "", err
+	}
+	return uploadID, nil
+}
+
+func (s *LocalStore) PresignUploadPart(ctx context.Context, key string, uploadID string, partNumber int, expires time.Duration) (string, error) {
+	if partNumber < 1 || partNumber > MaxParts {
+		return "", ErrInvalidPart
+	}
+	q := url.Values{}
+	q.Set("uploadId", uploadID)
+	q.Set("partNumber", strconv.Itoa(partNumber))
+	return s.presign(http.MethodPut, key, "", expires, q)
+}
+
+// putPart ghi 1 part, ETag là md5 của nội dung giống S3
+func (s *LocalStore) putPart(key, uploadID string, partNumber int, r io.Reader) (string, error) {
+	if partNumber < 1 || partNumber > MaxParts {
+		return "", ErrInvalidPart
+	}
+	dir, _, err := s.loadUpload(key, uploadID)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".part-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), r); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, strconv.Itoa(partNumber))); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+}
+
+// CompleteMultipartUpload ghép các part theo thứ tự thành object, kiểm tra ETag và kích thước part như S3
+func (s *LocalStore) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []CompletedPart) error {
+	dir, upload, err := s.loadUpload(key, uploadID)
+	if err != nil {
+		return err
+	}
+	if len(parts) == 0 {
+		return ErrInvalidPart
+	}
+
+	files := make([]*os.File, 0, len(parts))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	readers := make([]io.Reader, 0, len(parts))
+	var total int64
+	for i, p := range parts {
+		if i > 0 && p.PartNumber <= parts[i-1].PartNumber {
+			return fmt.Errorf("%w: parts must be in ascending order", ErrInvalidPart)
+		}
+		f, err := os.Open(filepath.Join(dir, strconv.Itoa(p.PartNumber)))
+		if err != nil {
+			return fmt.Errorf("%w: part %d not found", ErrInvalidPart, p.PartNumber)
+		}
+		files = append(files, f)
+
+		hash := md5.New()
+		size, err := io.Copy(hash, f)
+		if err != nil {
+			return err
+		}
+		if `"`+hex.EncodeToString(hash.Sum(nil))+`"` != p.ETag {
+			return fmt.Errorf("%w: etag mismatch for part %d", ErrInvalidPart, p.PartNumber)
+		}
+		if i < len(parts)-1 && size < MinPartSize {
+			return fmt.Errorf("%w: part %d is too small", ErrInvalidPart, p.PartNumber)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		readers = append(readers, f)
+		total += size
+	}
+
+	if err := s.Put(ctx, upload.Key, io.MultiReader(readers...), total, upload.ContentType); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+func (s *LocalStore) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	dir, _, err := s.loadUpload(key, uploadID)
+	if errors.Is(err, ErrInvalidPart) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
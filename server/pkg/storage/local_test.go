@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLocalStore(t *testing.T, publicURL string) *LocalStore {
+	t.Helper()
+	store, err := NewLocalStore(LocalConfig{Root: t.TempDir(), PublicURL: publicURL, SigningKey: "test-key"})
+	require.NoError(t, err)
+	return store
+}
+
+func TestLocalStoreCRUD(t *testing.T) {
+	ctx := context.Background()
+	store := newTestLocalStore(t, "http://localhost/storage")
+
+	require.NoError(t, store.Put(ctx, "avatar/a.bin", strings.NewReader("hello"), 5, "image/png"))
+
+	info, err := store.Stat(ctx, "avatar/a.bin")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), info.Size)
+	assert.Equal(t, "image/png", info.ContentType)
+
+	rc, _, err := store.Get(ctx, "/avatar/a.bin")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	assert.Equal(t, "hello", string(data))
+
+	// Không có metadata → đoán theo đuôi file
+	require.NoError(t, store.Put(ctx, "b.json", strings.NewReader("{}"), 2, ""))
+	info, err = store.Stat(ctx, "b.json")
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", info.ContentType)
+
+	require.NoError(t, store.Copy(ctx, "avatar/a.bin", "blobs/copy"))
+	info, err = store.Stat(ctx, "blobs/copy")
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", info.ContentType)
+	assert.ErrorIs(t, store.Copy(ctx, "missing", "blobs/x"), ErrNotFound)
+
+	require.NoError(t, store.Delete(ctx, "avatar/a.bin"))
+	_, err = store.Stat(ctx, "avatar/a.bin")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, store.Delete(ctx, "avatar/a.bin"))
+}
+
+func TestLocalStoreRejectsInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	store := newTestLocalStore(t, "http://localhost/storage")
+
+	for _, key := range []string{"", "../etc/passwd", "a/../../b", "a//b", ".meta/x.json", "./a"} {
+		err := store.Put(ctx, key, strings.NewReader("x"), 1, "")
+		assert.ErrorIs(t, err, ErrInvalidKey, key)
+	}
+}
+
+func TestLocalStorePresignedURLs(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	store := newTestLocalStore(t, srv.URL+"/storage")
+	mux.Handle("/storage/", store.Handler("/storage"))
+	ctx := context.Background()
+
+	// Upload qua presigned PUT
+	putURL, err := store.PresignPut(ctx, "files/report 1.txt", "text/plain", time.Minute)
+	require.NoError(t, err)
+	req, _ := http.NewRequest(http.MethodPut, putURL, strings.NewReader("0123456789"))
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Sai content type so với lúc ký → 403
+	req, _ = http.NewRequest(http.MethodPut, putURL, strings.NewReader("evil"))
+	req.Header.Set("Content-Type", "text/html")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Presigned GET hỗ trợ Range
+	getURL, err := store.PresignGet(ctx, "files/report 1.txt", time.Minute)
+	require.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodGet, getURL, nil)
+	req.Header.Set("Range", "bytes=2-4")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "234", string(body))
+
+	// Chữ ký của GET không dùng được cho key khác
+	u, _ := url.Parse(getURL)
+	u.Path = "/storage/files/other.txt"
+	resp, err = http.Get(u.String())
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// URL hết hạn
+	expiredURL, err := store.PresignGet(ctx, "files/report 1.txt", -time.Minute)
+	require.NoError(t, err)
+	resp, err = http.Get(expiredURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestLocalStoreMultipartUpload(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	store := newTestLocalStore(t, srv.URL+"/storage")
+	mux.Handle("/storage/", store.Handler("/storage"))
+	ctx := context.Background()
+
+	uploadID, err := store.CreateMultipartUpload(ctx, "videos/big.mp4", "video/mp4")
+	require.NoError(t, err)
+
+	chunks := []string{strings.Repeat("a", MinPartSize), "tail"}
+	var parts []CompletedPart
+	for i, chunk := range chunks {
+		partURL, err := store.PresignUploadPart(ctx, "videos/big.mp4", uploadID, i+1, time.Minute)
+		require.NoError(t, err)
+		req, _ := http.NewRequest(http.MethodPut, partURL, strings.NewReader(chunk))
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		parts = append(parts, CompletedPart{PartNumber: i + 1, ETag: resp.Header.Get("ETag")})
+	}
+
+	// Chữ ký của part không dùng được cho part khác
+	partURL, _ := store.PresignUploadPart(ctx, "videos/big.mp4", uploadID, 1, time.Minute)
+	forged := strings.Replace(partURL, "partNumber=1", "partNumber=2", 1)
+	req, _ := http.NewRequest(http.MethodPut, forged, strings.NewReader("evil"))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// ETag sai → từ chối
+	bad := []CompletedPart{parts[0], {PartNumber: 2, ETag: `"deadbeef"`}}
+	assert.ErrorIs(t, store.CompleteMultipartUpload(ctx, "videos/big.mp4", uploadID, bad), ErrInvalidPart)
+
+	require.NoError(t, store.CompleteMultipartUpload(ctx, "videos/big.mp4", uploadID, parts))
+	info, err := store.Stat(ctx, "videos/big.mp4")
+	require.NoError(t, err)
+	assert.Equal(t, int64(MinPartSize+4), info.Size)
+	assert.Equal(t, "video/mp4", info.ContentType)
+
+	// Upload đã hoàn tất thì không dùng lại được
+	assert.ErrorIs(t, store.CompleteMultipartUpload(ctx, "videos/big.mp4", uploadID, parts), ErrInvalidPart)
+	_, err = store.CreateMultipartUpload(ctx, ".uploads/x", "")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
@@ -1,110 +0,0 @@
-package storage
-
-import (
-	"bytes"
-	"context"
-	"fmt"
-	"io"
-	"mime/multipart"
-	"time"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
-)
-
-type SpacesClient struct {
-	Client *s3.Client
-	Bucket string
-	URL    string
-}
-
-func NewSpacesClient(key, secret, region, endpoint, bucket string) (*SpacesClient, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(key, secret, "")),
-		config.WithEndpointResolver(
-			aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
-				return aws.Endpoint{URL: endpoint}, nil
-			}),
-		),
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	client := s3.NewFromConfig(cfg)
-
-	return &SpacesClient{
-		Client: client,
-		Bucket: bucket,
-		URL:    fmt.Sprintf("%s/%s", endpoint, bucket),
-	}, nil
-}
-
-func (s *SpacesClient) UploadFile(file multipart.File, filename string, contentType string) (string, error) {
-	buf := new(bytes.Buffer)
-	_, err := buf.ReadFrom(file)
-	if err != nil {
-		return "", err
-	}
-	_, err = s.Client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket:      aws.String(s.Bucket),
-		Key:         aws.String(filename),
-		Body:        bytes.NewReader(buf.Bytes()),
-		ContentType: aws.String(contentType),
-		ACL:         s3types.ObjectCannedACLPublicRead,
-	})
-	if err != nil {
-		return "", err
-	}
-
-	url := fmt.Sprintf("%s/%s", s.URL, filename)
-	return url, nil
-}
-
-func (s *SpacesClient) GetFile(filename string) ([]byte, string, error) {
-	resp, err := s.Client.GetObject(context.TODO(), &s3.GetObjectInput{
-		Bucket: aws.String(s.Bucket),
-		Key:    aws.String(filename),
-	})
-	if err != nil {
-		return nil, "", err
-	}
-	defer resp.Body.Close()
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, "", err
-	}
-
-	contentType := ""
-	if resp.ContentType != nil {
-		contentType = *resp.ContentType
-	}
-
-	return data, contentType, nil
-}
-
-// Trả về presigned GET URL (có thời hạn) để client truy cập trực tiếp.
-func (s *SpacesClient) PresignedGetURL(filename string, expires time.Duration) (string, error) {
-	presigner := s3.NewPresignClient(s.Client)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	params := &s3.GetObjectInput{
-		Bucket: aws.String(s.Bucket),
-		Key:    aws.String(filename),
-	}
-	out, err := presigner.PresignGetObject(ctx, params, func(po *s3.PresignOptions) {
-		po.Expires = expires
-	})
-	if err != nil {
-		return "", err
-	}
-
-	// out.URL là URL đã ký
-	return out.URL, nil
-}
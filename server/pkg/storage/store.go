@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("storage: object not found")
+	ErrInvalidKey  = errors.New("storage: invalid object key")
+	ErrInvalidPart = errors.New("storage: invalid multipart upload part")
+)
+
+// ObjectInfo là metadata của một object trong store
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Store là interface chung cho mọi backend lưu file (local disk, S3/Spaces, MinIO).
+// Key dạng "avatar/abc.jpg", không bắt đầu bằng "/" và không chứa "..".
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get trả về reader của object, caller phải Close. Với local store reader còn là io.ReadSeeker.
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Copy sao chép object (kể cả content type) sang key khác ngay trên storage, không tải về server
+	Copy(ctx context.Context, srcKey, dstKey string) error
+	// PresignGet trả về URL có thời hạn để client tải object trực tiếp
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+	// PresignPut trả về URL có thời hạn để client upload trực tiếp với đúng contentType
+	PresignPut(ctx context.Context, key string, contentType string, expires time.Duration) (string, error)
+}
+
+// healthCheckKey không bao giờ được ghi, Stat key này chỉ để biết backend còn trả lời
+const healthCheckKey = "_healthcheck/ping"
+
+// Ping kiểm tra store trả lời được (readiness probe). ErrNotFound nghĩa là backend vẫn hoạt động.
+func Ping(ctx context.Context, s Store) error {
+	_, err := s.Stat(ctx, healthCheckKey)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// CompletedPart là một part client đã upload qua presigned URL, ETag lấy từ response header
+type CompletedPart struct {
+	PartNumber int    `json:"part_number"`
+	ETag       string `json:"etag"`
+}
+
+// MultipartStore là backend hỗ trợ upload file lớn thành nhiều part, mỗi part qua 1 presigned URL.
+// Part number bắt đầu từ 1, mọi part trừ part cuối phải >= MinPartSize.
+type MultipartStore interface {
+	Store
+	CreateMultipartUpload(ctx context.Context, key string, contentType string) (uploadID string, err error)
+	PresignUploadPart(ctx context.Context, key string, uploadID string, partNumber int, expires time.Duration) (string, error)
+	CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []CompletedPart) error
+	AbortMultipartUpload(ctx context.Context, key string, uploadID string) error
+}
+
+const (
+	// MinPartSize là kích thước part tối thiểu của S3 (trừ part cuối)
+	MinPartSize = 5 << 20
+	// MaxParts là số part tối đa của một multipart upload
+	MaxParts = 10000
+)
+
+// CleanKey chuẩn hóa key và chặn path traversal
+func CleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(strings.ReplaceAll(key, "\\", "/"), "/")
+	if key == "" {
+		return "", ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." || part == "." || part == "" {
+			return "", ErrInvalidKey
+		}
+	}
+	return path.Clean(key), nil
+}
+
+// Config chọn backend: Driver "local" lưu ra disk, "s3" dùng S3-compatible storage
+type Config struct {
+	Driver string
+	Local  LocalConfig
+	S3     S3Config
+}
+
+// New tạo store theo cfg.Driver
+func New(ctx context.Context, cfg Config) (Store, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "local":
+		return NewLocalStore(cfg.Local)
+	case "s3":
+		return NewS3Store(ctx, cfg.S3)
+	default:
+		return nil, fmt.Errorf("storage: unknown driver %q", cfg.Driver)
+	}
+}
+
+var (
+	_ MultipartStore = (*LocalStore)(nil)
+	_ MultipartStore = (*S3Store)(nil)
+)
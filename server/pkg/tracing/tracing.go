@@ -0,0 +1,107 @@
+// Package tracing cấu hình OpenTelemetry: TracerProvider gửi span qua OTLP/HTTP, propagator W3C
+// (traceparent, baggage) và exporter in-memory để test kiểm tra span.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"project/pkg/logging"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const instrumentationPrefix = "project/"
+
+// Options của TracerProvider, map từ config.TracingConfig
+type Options struct {
+	// Endpoint là URL gốc của collector (vd "http://otel-collector:4318"), rỗng = mặc định của SDK
+	Endpoint    string
+	ServiceName string
+	// SampleRatio áp dụng cho trace mới, trace có parent đi theo quyết định của parent
+	SampleRatio float64
+}
+
+// Setup cài TracerProvider toàn cục. Gọi shutdown khi tắt server để flush span còn trong batch.
+func Setup(ctx context.Context, opts Options) (shutdown func(context.Context) error, err error) {
+	var exporterOpts []otlptracehttp.Option
+	if opts.Endpoint != "" {
+		endpoint, err := tracesURL(opts.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		exporterOpts = append(exporterOpts, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: create exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(opts.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: resource: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	install(tp)
+	return tp.Shutdown, nil
+}
+
+// tracesURL giữ ngữ nghĩa của OTEL_EXPORTER_OTLP_ENDPOINT: URL gốc, span gửi tới <endpoint>/v1/traces
+func tracesURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("tracing: invalid endpoint %q", endpoint)
+	}
+	if !strings.HasSuffix(u.Path, "/v1/traces") {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/traces"
+	}
+	return u.String(), nil
+}
+
+// UseInMemory cài TracerProvider ghi span đồng bộ vào exporter trong bộ nhớ (dùng trong test).
+// Hàm trả về đặt lại provider no-op.
+func UseInMemory() (*tracetest.InMemoryExporter, func()) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	install(tp)
+	return exporter, func() {
+		_ = tp.Shutdown(context.Background())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	}
+}
+
+func install(tp trace.TracerProvider) {
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Start mở span con của span trong ctx. Tracer lấy từ provider toàn cục mỗi lần gọi
+// (không cache) để test thay provider được.
+func Start(ctx context.Context, component, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationPrefix+component).Start(ctx, spanName, opts...)
+}
+
+// RecordError đánh dấu span lỗi, nội dung lỗi được che token/email như log
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	msg := logging.Redact(err.Error())
+	span.AddEvent("exception", trace.WithAttributes(semconv.ExceptionMessage(msg)))
+	span.SetStatus(codes.Error, msg)
+}
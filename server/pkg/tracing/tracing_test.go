@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestTracesURL(t *testing.T) {
+	cases := map[string]string{
+		"http://collector:4318":           "http://collector:4318/v1/traces",
+		"https://collector:4318/otlp/":    "https://collector:4318/otlp/v1/traces",
+		"http://collector:4318/v1/traces": "http://collector:4318/v1/traces",
+	}
+	for in, want := range cases {
+		got, err := tracesURL(in)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := tracesURL("collector:4318")
+	assert.Error(t, err)
+}
+
+func TestInMemoryRecordsSpans(t *testing.T) {
+	exporter, restore := UseInMemory()
+	t.Cleanup(restore)
+
+	// traceparent từ client được tiếp nối
+	carrier := propagation.MapCarrier{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+
+	ctx, parent := Start(ctx, "http", "POST /api/v1/messages")
+	_, child := Start(ctx, "service", "MessageService.SendMessageToConversation")
+	RecordError(child, errors.New("user alice@example.com token=abc not allowed"))
+	child.End()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "MessageService.SendMessageToConversation", spans[0].Name)
+	assert.Equal(t, "project/service", spans[0].InstrumentationScope.Name)
+	assert.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[1].SpanContext.TraceID().String())
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "user a***@example.com token=[REDACTED] not allowed", spans[0].Status.Description)
+}
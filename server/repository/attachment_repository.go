@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"project/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAttachmentsUnavailable: có attachment không gán được vào message (đã gửi ở message khác, không thuộc người gửi)
+var ErrAttachmentsUnavailable = errors.New("attachments are no longer available")
+
+type AttachmentRepository interface {
+	CreateAttachment(ctx context.Context, attachment *models.Attachment) error
+	GetAttachmentByID(ctx context.Context, id uuid.UUID) (*models.Attachment, error)
+	GetAttachmentsByIDs(ctx context.Context, ids []uuid.UUID) ([]models.Attachment, error)
+	UpdateAttachment(ctx context.Context, attachment *models.Attachment) error
+	// FinishScan ghi kết quả quét cho attachment còn pending_scan, trả về false nếu attachment đã đổi trạng thái
+	FinishScan(ctx context.Context, id uuid.UUID, status, signature, storageKey string) (bool, error)
+	DeleteAttachment(ctx context.Context, id uuid.UUID) error
+	// SaveProcessedImage ghi file gốc đã xử lý và kích thước ảnh cho attachment ready, đồng thời cộng
+	// sizeDelta vào storage_used của uploader trong cùng transaction. Trả về false nếu attachment không còn ready.
+	SaveProcessedImage(ctx context.Context, attachment *models.Attachment, sizeDelta int64) (bool, error)
+	// SaveRenditions thay toàn bộ rendition của attachment
+	SaveRenditions(ctx context.Context, attachmentID uuid.UUID, renditions []models.AttachmentRendition) error
+	// ListOrphanAttachments trả về attachment tạo trước `before` mà không thuộc message nào
+	// (upload dở, upload không gửi, hoặc message đã bị xóa hẳn)
+	ListOrphanAttachments(ctx context.Context, before time.Time, limit int) ([]models.Attachment, error)
+	ListAttachmentsByStatus(ctx context.Context, status string, limit int) ([]models.Attachment, error)
+}
+
+type attachmentRepo struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepo{
+		db: db,
+	}
+}
+
+func (r *attachmentRepo) CreateAttachment(ctx context.Context, attachment *models.Attachment) error {
+	return r.db.WithContext(ctx).Create(attachment).Error
+}
+
+// GetAttachmentByID trả về nil, nil nếu không tồn tại
+func (r *attachmentRepo) GetAttachmentByID(ctx context.Context, id uuid.UUID) (*models.Attachment, error) {
+	var attachment models.Attachment
+	err := r.db.WithContext(ctx).Preload("Renditions").Where("id = ?", id).First(&attachment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (r *attachmentRepo) GetAttachmentsByIDs(ctx context.Context, ids []uuid.UUID) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	if len(ids) == 0 {
+		return attachments, nil
+	}
+	if err := r.db.WithContext(ctx).Preload("Renditions").Where("id IN ?", ids).Order("created_at").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (r *attachmentRepo) UpdateAttachment(ctx context.Context, attachment *models.Attachment) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Save(attachment).Error
+}
+
+func (r *attachmentRepo) FinishScan(ctx context.Context, id uuid.UUID, status, signature, storageKey string) (bool, error) {
+	// Chỉ cập nhật cột của kết quả quét, không ghi đè message_id/conversation_id được gán trong lúc quét
+	result := r.db.WithContext(ctx).Model(&models.Attachment{}).
+		Where("id = ? AND status = ?", id, models.AttachmentStatusPendingScan).
+		Updates(map[string]interface{}{
+			"status":         status,
+			"scan_signature": signature,
+			"storage_key":    storageKey,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *attachmentRepo) DeleteAttachment(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&models.Attachment{}).Error
+}
+
+func (r *attachmentRepo) SaveProcessedImage(ctx context.Context, attachment *models.Attachment, sizeDelta int64) (bool, error) {
+	updated := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Attachment{}).
+			Where("id = ? AND status = ?", attachment.ID, models.AttachmentStatusReady).
+			Updates(map[string]interface{}{
+				"storage_key":    attachment.StorageKey,
+				"checksum":       attachment.Checksum,
+				"size":           attachment.Size,
+				"mime_type":      attachment.MimeType,
+				"width":          attachment.Width,
+				"height":         attachment.Height,
+				"dominant_color": attachment.DominantColor,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		updated = true
+		if sizeDelta == 0 {
+			return nil
+		}
+		return tx.Model(&models.User{}).Where("id = ?", attachment.UploaderID).
+			Update("storage_used", gorm.Expr("GREATEST(storage_used + ?, 0)", sizeDelta)).Error
+	})
+	return updated && err == nil, err
+}
+
+func (r *attachmentRepo) SaveRenditions(ctx context.Context, attachmentID uuid.UUID, renditions []models.AttachmentRendition) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("attachment_id = ?", attachmentID).Delete(&models.AttachmentRendition{}).Error; err != nil {
+			return err
+		}
+		if len(renditions) == 0 {
+			return nil
+		}
+		for i := range renditions {
+			renditions[i].AttachmentID = attachmentID
+		}
+		return tx.Create(&renditions).Error
+	})
+}
+
+// attachToMessage gán attachment chưa dùng của uploader vào message, trả về số dòng được gán
+func attachToMessage(tx *gorm.DB, ids []uuid.UUID, uploaderID, conversationID, messageID uuid.UUID) (int64, error) {
+	// Điều kiện message_id IS NULL chặn 2 request gửi cùng 1 attachment đồng thời.
+	// Attachment đang chờ quét vẫn gửi được, người nhận chỉ tải được sau khi quét xong.
+	result := tx.Model(&models.Attachment{}).
+		Where("id IN ? AND uploader_id = ? AND message_id IS NULL AND status IN ?", ids, uploaderID,
+			[]string{models.AttachmentStatusReady, models.AttachmentStatusPendingScan}).
+		Updates(map[string]interface{}{
+			"message_id":      messageID,
+			"conversation_id": conversationID,
+		})
+	return result.RowsAffected, result.Error
+}
+
+func (r *attachmentRepo) ListOrphanAttachments(ctx context.Context, before time.Time, limit int) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	// File bị quarantine được giữ lại để kiểm tra
+	err := r.db.WithContext(ctx).Where("message_id IS NULL AND created_at < ? AND status <> ?", before, models.AttachmentStatusQuarantined).
+		Order("created_at").
+		Limit(limit).
+		Find(&attachments).Error
+	return attachments, err
+}
+
+func (r *attachmentRepo) ListAttachmentsByStatus(ctx context.Context, status string, limit int) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := r.db.WithContext(ctx).Where("status = ?", status).
+		Order("created_at").
+		Limit(limit).
+		Find(&attachments).Error
+	return attachments, err
+}
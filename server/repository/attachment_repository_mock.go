@@ -0,0 +1,93 @@
+package repository
+
+// MockAttachmentRepository mô phỏng AttachmentRepository (dùng cho unit test)
+import (
+	"context"
+	"project/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MockAttachmentRepository struct {
+	MockCreateAttachment        func(ctx context.Context, attachment *models.Attachment) error
+	MockGetAttachmentByID       func(ctx context.Context, id uuid.UUID) (*models.Attachment, error)
+	MockGetAttachmentsByIDs     func(ctx context.Context, ids []uuid.UUID) ([]models.Attachment, error)
+	MockUpdateAttachment        func(ctx context.Context, attachment *models.Attachment) error
+	MockFinishScan              func(ctx context.Context, id uuid.UUID, status, signature, storageKey string) (bool, error)
+	MockDeleteAttachment        func(ctx context.Context, id uuid.UUID) error
+	MockSaveProcessedImage      func(ctx context.Context, attachment *models.Attachment, sizeDelta int64) (bool, error)
+	MockSaveRenditions          func(ctx context.Context, attachmentID uuid.UUID, renditions []models.AttachmentRendition) error
+	MockListOrphanAttachments   func(ctx context.Context, before time.Time, limit int) ([]models.Attachment, error)
+	MockListAttachmentsByStatus func(ctx context.Context, status string, limit int) ([]models.Attachment, error)
+}
+
+func (m *MockAttachmentRepository) CreateAttachment(ctx context.Context, attachment *models.Attachment) error {
+	if m.MockCreateAttachment != nil {
+		return m.MockCreateAttachment(ctx, attachment)
+	}
+	return nil
+}
+
+func (m *MockAttachmentRepository) GetAttachmentByID(ctx context.Context, id uuid.UUID) (*models.Attachment, error) {
+	if m.MockGetAttachmentByID != nil {
+		return m.MockGetAttachmentByID(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *MockAttachmentRepository) GetAttachmentsByIDs(ctx context.Context, ids []uuid.UUID) ([]models.Attachment, error) {
+	if m.MockGetAttachmentsByIDs != nil {
+		return m.MockGetAttachmentsByIDs(ctx, ids)
+	}
+	return nil, nil
+}
+
+func (m *MockAttachmentRepository) UpdateAttachment(ctx context.Context, attachment *models.Attachment) error {
+	if m.MockUpdateAttachment != nil {
+		return m.MockUpdateAttachment(ctx, attachment)
+	}
+	return nil
+}
+
+func (m *MockAttachmentRepository) FinishScan(ctx context.Context, id uuid.UUID, status, signature, storageKey string) (bool, error) {
+	if m.MockFinishScan != nil {
+		return m.MockFinishScan(ctx, id, status, signature, storageKey)
+	}
+	return true, nil
+}
+
+func (m *MockAttachmentRepository) DeleteAttachment(ctx context.Context, id uuid.UUID) error {
+	if m.MockDeleteAttachment != nil {
+		return m.MockDeleteAttachment(ctx, id)
+	}
+	return nil
+}
+
+func (m *MockAttachmentRepository) SaveProcessedImage(ctx context.Context, attachment *models.Attachment, sizeDelta int64) (bool, error) {
+	if m.MockSaveProcessedImage != nil {
+		return m.MockSaveProcessedImage(ctx, attachment, sizeDelta)
+	}
+	return true, nil
+}
+
+func (m *MockAttachmentRepository) SaveRenditions(ctx context.Context, attachmentID uuid.UUID, renditions []models.AttachmentRendition) error {
+	if m.MockSaveRenditions != nil {
+		return m.MockSaveRenditions(ctx, attachmentID, renditions)
+	}
+	return nil
+}
+
+func (m *MockAttachmentRepository) ListOrphanAttachments(ctx context.Context, before time.Time, limit int) ([]models.Attachment, error) {
+	if m.MockListOrphanAttachments != nil {
+		return m.MockListOrphanAttachments(ctx, before, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockAttachmentRepository) ListAttachmentsByStatus(ctx context.Context, status string, limit int) ([]models.Attachment, error) {
+	if m.MockListAttachmentsByStatus != nil {
+		return m.MockListAttachmentsByStatus(ctx, status, limit)
+	}
+	return nil, nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"project/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BlobRepository interface {
+	// CreateBlob bỏ qua nếu checksum đã tồn tại (2 upload cùng nội dung chạy song song)
+	CreateBlob(ctx context.Context, blob *models.Blob) error
+	// TouchBlob đánh dấu blob vừa được dùng lại, trả về blob hoặc nil nếu không tồn tại (hoặc vừa bị GC xóa)
+	TouchBlob(ctx context.Context, checksum string) (*models.Blob, error)
+	// ListOrphanBlobs trả về blob không còn attachment/rendition nào tham chiếu và không được dùng từ trước `before`
+	ListOrphanBlobs(ctx context.Context, before time.Time, limit int) ([]models.Blob, error)
+	// DeleteOrphanBlob khóa dòng blob, kiểm tra lại vẫn là orphan rồi gọi deleteObject trước khi xóa dòng.
+	// TouchBlob đồng thời sẽ chờ tới khi xong và thấy blob đã mất. Trả về false nếu blob đã được dùng lại.
+	DeleteOrphanBlob(ctx context.Context, checksum string, before time.Time, deleteObject func(key string) error) (bool, error)
+}
+
+type blobRepo struct {
+	db *gorm.DB
+}
+
+func NewBlobRepository(db *gorm.DB) BlobRepository {
+	return &blobRepo{
+		db: db,
+	}
+}
+
+// orphanCondition: không attachment hay rendition nào trỏ tới storage key của blob
+const orphanCondition = `NOT EXISTS (SELECT 1 FROM attachments a WHERE a.storage_key = blobs.storage_key)
+	AND NOT EXISTS (SELECT 1 FROM attachment_renditions r WHERE r.storage_key = blobs.storage_key)`
+
+func (r *blobRepo) CreateBlob(ctx context.Context, blob *models.Blob) error {
+	if blob.LastUsedAt.IsZero() {
+		blob.LastUsedAt = time.Now()
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(blob).Error
+}
+
+func (r *blobRepo) TouchBlob(ctx context.Context, checksum string) (*models.Blob, error) {
+	var blob models.Blob
+	result := r.db.WithContext(ctx).Model(&blob).
+		Clauses(clause.Returning{}).
+		Where("checksum = ?", checksum).
+		Update("last_used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &blob, nil
+}
+
+func (r *blobRepo) ListOrphanBlobs(ctx context.Context, before time.Time, limit int) ([]models.Blob, error) {
+	var blobs []models.Blob
+	err := r.db.WithContext(ctx).Where("last_used_at < ?", before).
+		Where(orphanCondition).
+		Order("last_used_at").
+		Limit(limit).
+		Find(&blobs).Error
+	return blobs, err
+}
+
+func (r *blobRepo) DeleteOrphanBlob(ctx context.Context, checksum string, before time.Time, deleteObject func(key string) error) (bool, error) {
+	deleted := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var blob models.Blob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("checksum = ? AND last_used_at < ?", checksum, before).
+			Where(orphanCondition).
+			First(&blob).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := deleteObject(blob.StorageKey); err != nil {
+			return err
+		}
+		deleted = true
+		return tx.Delete(&blob).Error
+	})
+	return deleted, err
+}
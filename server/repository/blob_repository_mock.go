@@ -0,0 +1,43 @@
+package repository
+
+// MockBlobRepository mô phỏng BlobRepository (dùng cho unit test)
+import (
+	"context"
+	"project/models"
+	"time"
+)
+
+type MockBlobRepository struct {
+	MockCreateBlob       func(ctx context.Context, blob *models.Blob) error
+	MockTouchBlob        func(ctx context.Context, checksum string) (*models.Blob, error)
+	MockListOrphanBlobs  func(ctx context.Context, before time.Time, limit int) ([]models.Blob, error)
+	MockDeleteOrphanBlob func(ctx context.Context, checksum string, before time.Time, deleteObject func(key string) error) (bool, error)
+}
+
+func (m *MockBlobRepository) CreateBlob(ctx context.Context, blob *models.Blob) error {
+	if m.MockCreateBlob != nil {
+		return m.MockCreateBlob(ctx, blob)
+	}
+	return nil
+}
+
+func (m *MockBlobRepository) TouchBlob(ctx context.Context, checksum string) (*models.Blob, error) {
+	if m.MockTouchBlob != nil {
+		return m.MockTouchBlob(ctx, checksum)
+	}
+	return nil, nil
+}
+
+func (m *MockBlobRepository) ListOrphanBlobs(ctx context.Context, before time.Time, limit int) ([]models.Blob, error) {
+	if m.MockListOrphanBlobs != nil {
+		return m.MockListOrphanBlobs(ctx, before, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockBlobRepository) DeleteOrphanBlob(ctx context.Context, checksum string, before time.Time, deleteObject func(key string) error) (bool, error) {
+	if m.MockDeleteOrphanBlob != nil {
+		return m.MockDeleteOrphanBlob(ctx, checksum, before, deleteObject)
+	}
+	return false, nil
+}
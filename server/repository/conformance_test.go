@@ -0,0 +1,603 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"project/database"
+	"project/models"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Conformance suite: cùng 1 bộ test chạy trên repository trong bộ nhớ và repository thật, để fake luôn đúng hành vi.
+// Repository thật chỉ chạy khi có TEST_DATABASE_DSN (Postgres, migration được chạy tự động)
+// và TEST_REDIS_ADDR, ví dụ:
+//
+//	TEST_DATABASE_DSN="host=localhost user=admin dbname=chat_test sslmode=disable" TEST_REDIS_ADDR=localhost:6379 go test ./repository/
+
+type dbRepos struct {
+	messages      MessageRepository
+	conversations ConversationRepository
+	participants  ParticipantRepository
+	friendships   FriendshipRepository
+	devices       DeviceRepository
+	addUser       func(t *testing.T) models.User
+	addToken      func(t *testing.T, deviceID uuid.UUID)
+}
+
+var (
+	testDBOnce sync.Once
+	testDB     *gorm.DB
+	testDBErr  error
+)
+
+func postgresDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+	testDBOnce.Do(func() {
+		testDB, testDBErr = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if testDBErr == nil {
+			testDBErr = database.MigrateUp(context.Background(), testDB)
+		}
+	})
+	require.NoError(t, testDBErr)
+	return testDB
+}
+
+func newTestUser() models.User {
+	id := uuid.New()
+	return models.User{ID: id, Name: "user " + id.String()[:8], Email: id.String() + "@example.com", Password: "hashed"}
+}
+
+func eachDB(t *testing.T, run func(t *testing.T, r dbRepos)) {
+	t.Run("memory", func(t *testing.T) {
+		store := NewMemoryStore()
+		run(t, dbRepos{
+			messages:      NewMemoryMessageRepository(store),
+			conversations: NewMemoryConversationRepository(store),
+			participants:  NewMemoryParticipantRepository(store),
+			friendships:   NewMemoryFriendshipRepository(store),
+			devices:       NewMemoryDeviceRepository(store),
+			addUser: func(t *testing.T) models.User {
+				user := newTestUser()
+				store.AddUser(user)
+				return user
+			},
+			addToken: func(t *testing.T, deviceID uuid.UUID) {
+				store.AddToken(models.Token{ID: uuid.New(), DeviceID: deviceID, AccessToken: uuid.NewString(), RefreshToken: uuid.NewString()})
+			},
+		})
+	})
+	t.Run("postgres", func(t *testing.T) {
+		db := postgresDB(t)
+		run(t, dbRepos{
+			messages:      NewMessageRepository(db),
+			conversations: NewConversationRepository(db),
+			participants:  NewParticipantRepository(db),
+			friendships:   NewFriendshipRepository(db),
+			devices:       NewDeviceRepository(db),
+			addUser: func(t *testing.T) models.User {
+				user := newTestUser()
+				require.NoError(t, db.Create(&user).Error)
+				return user
+			},
+			addToken: func(t *testing.T, deviceID uuid.UUID) {
+				token := models.Token{ID: uuid.New(), DeviceID: deviceID, AccessToken: uuid.NewString(), RefreshToken: uuid.NewString(), ExpiresAt: time.Now().Add(time.Hour).Unix()}
+				require.NoError(t, db.Create(&token).Error)
+			},
+		})
+	})
+}
+
+func eachRedis(t *testing.T, run func(t *testing.T, r RedisRepository)) {
+	t.Run("memory", func(t *testing.T) {
+		run(t, NewMemoryRedisRepository())
+	})
+	t.Run("redis", func(t *testing.T) {
+		addr := os.Getenv("TEST_REDIS_ADDR")
+		if addr == "" {
+			t.Skip("TEST_REDIS_ADDR not set")
+		}
+		rdb := redis.NewClient(&redis.Options{Addr: addr})
+		t.Cleanup(func() { rdb.Close() })
+		run(t, NewRedisRepository(rdb))
+	})
+}
+
+// newConversation tạo conversation group kèm participant cho các user
+func newConversation(t *testing.T, r dbRepos, members ...models.User) *models.Conversation {
+	ctx := context.Background()
+	conversation, err := r.conversations.CreateConversation(ctx, &models.Conversation{Type: "group", Name: "team"})
+	require.NoError(t, err)
+	for _, u := range members {
+		require.NoError(t, r.participants.CreateParticipant(ctx, &models.Participant{ConversationID: conversation.ID, UserID: u.ID}))
+	}
+	return conversation
+}
+
+// sendMessage tạo message, chờ 1 chút để created_at của các message khác nhau kể cả sau khi Postgres làm tròn micro giây
+func sendMessage(t *testing.T, r dbRepos, conversationID uuid.UUID, sender *models.User, content string) *models.Message {
+	message := &models.Message{ConversationID: conversationID, Content: content}
+	if sender != nil {
+		message.SenderID = &sender.ID
+	} else {
+		message.Type = "system"
+	}
+	created, err := r.messages.CreateMessage(context.Background(), message)
+	require.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+	return created
+}
+
+func messageIDs(messages []*models.Message) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(messages))
+	for _, m := range messages {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func TestMessageRepositoryConformance(t *testing.T) {
+	eachDB(t, func(t *testing.T, r dbRepos) {
+		ctx := context.Background()
+		alice, bob := r.addUser(t), r.addUser(t)
+		conversation := newConversation(t, r, alice, bob)
+
+		system := sendMessage(t, r, conversation.ID, nil, "welcome")
+		m1 := sendMessage(t, r, conversation.ID, &alice, "Hello Bob")
+		m2 := sendMessage(t, r, conversation.ID, &bob, "hi alice")
+		m3 := sendMessage(t, r, conversation.ID, &alice, "how are you?")
+
+		assert.Equal(t, "text", m1.Type)
+		assert.Equal(t, "sent", m1.Status)
+		require.NotNil(t, m1.Sender)
+		assert.Equal(t, alice.ID, m1.Sender.ID)
+
+		got, err := r.messages.GetMessageByID(ctx, m2.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "hi alice", got.Content)
+		require.NotNil(t, got.Sender)
+		assert.Equal(t, bob.ID, got.Sender.ID)
+		_, err = r.messages.GetMessageByID(ctx, uuid.New())
+		assert.Error(t, err)
+
+		// Trang mới nhất trước, mỗi trang theo thứ tự tăng dần
+		page, err := r.messages.GetMessagesByConversationID(ctx, conversation.ID, 2, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{m2.ID, m3.ID}, messageIDs(page))
+		page, err = r.messages.GetMessagesByConversationID(ctx, conversation.ID, 2, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{system.ID, m1.ID}, messageIDs(page))
+
+		last, err := r.messages.GetLastMessageByConversationID(ctx, conversation.ID)
+		require.NoError(t, err)
+		assert.Equal(t, m3.ID, last.ID)
+		last, err = r.messages.GetLastMessageByConversationID(ctx, uuid.New())
+		require.NoError(t, err)
+		assert.Nil(t, last)
+
+		found, err := r.messages.SearchMessages(ctx, conversation.ID, "ALICE", 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{m2.ID}, messageIDs(found))
+
+		byUser, err := r.messages.GetMessagesByUser(ctx, alice.ID, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{m3.ID, m1.ID}, messageIDs(byUser))
+
+		after, err := r.messages.GetMessagesAfterTime(ctx, conversation.ID, m1.CreatedAt)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{m2.ID, m3.ID}, messageIDs(after))
+
+		between, err := r.messages.GetMessagesBetweenDates(ctx, conversation.ID, m1.CreatedAt, m2.CreatedAt)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{m1.ID, m2.ID}, messageIDs(between))
+
+		// Chỉ participant mới đọc được lịch sử
+		before, err := r.messages.GetMessagesBeforeTime(ctx, conversation.ID, bob.ID, &m3.CreatedAt, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{m2.ID, m1.ID, system.ID}, messageIDs(before))
+		before, err = r.messages.GetMessagesBeforeTime(ctx, conversation.ID, uuid.New(), nil, 10)
+		require.NoError(t, err)
+		assert.Empty(t, before)
+
+		// Chưa đọc: message của người khác, không tính message hệ thống
+		unread, err := r.messages.CountUnreadMessages(ctx, conversation.ID, bob.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), unread)
+		participant, err := r.participants.GetParticipant(ctx, conversation.ID, bob.ID)
+		require.NoError(t, err)
+		participant.LastReadAt = &m1.CreatedAt
+		require.NoError(t, r.participants.UpdateParticipant(ctx, participant))
+		unreadMessages, err := r.messages.GetUnreadMessages(ctx, conversation.ID, bob.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{m3.ID}, messageIDs(unreadMessages))
+
+		require.NoError(t, r.messages.UpdateMessageStatus(ctx, m1.ID, "read"))
+		got, err = r.messages.GetMessageByID(ctx, m1.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "read", got.Status)
+		assert.Error(t, r.messages.UpdateMessageStatus(ctx, m1.ID, "seen"))
+		assert.Error(t, r.messages.UpdateMessageStatus(ctx, uuid.New(), "read"))
+
+		m2.Content = "hi alice (edited)"
+		m2.IsEdited = true
+		updated, err := r.messages.UpdateMessage(ctx, m2)
+		require.NoError(t, err)
+		assert.True(t, updated.IsEdited)
+		got, err = r.messages.GetMessageByID(ctx, m2.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "hi alice (edited)", got.Content)
+
+		// Attachment không gán được thì message cũng không được tạo
+		rejected := &models.Message{ConversationID: conversation.ID, SenderID: &alice.ID, Content: "with file"}
+		_, err = r.messages.CreateMessageWithAttachments(ctx, rejected, []uuid.UUID{uuid.New()})
+		assert.ErrorIs(t, err, ErrAttachmentsUnavailable)
+		_, err = r.messages.GetMessageByID(ctx, rejected.ID)
+		assert.Error(t, err)
+
+		// Xóa mềm ẩn message khỏi mọi truy vấn
+		require.NoError(t, r.messages.SoftDeleteMessage(ctx, m3.ID, alice.ID))
+		assert.Error(t, r.messages.SoftDeleteMessage(ctx, m3.ID, alice.ID))
+		_, err = r.messages.GetMessageByID(ctx, m3.ID)
+		assert.Error(t, err)
+		count, err := r.messages.CountMessagesByConversationID(ctx, conversation.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+
+		require.NoError(t, r.messages.DeleteMessage(ctx, m3.ID))
+		assert.Error(t, r.messages.DeleteMessage(ctx, m3.ID))
+		require.NoError(t, r.messages.DeleteMessagesByConversationID(ctx, conversation.ID))
+		count, err = r.messages.CountMessagesByConversationID(ctx, conversation.ID)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+}
+
+func TestConversationRepositoryConformance(t *testing.T) {
+	eachDB(t, func(t *testing.T, r dbRepos) {
+		ctx := context.Background()
+		alice, bob, carol := r.addUser(t), r.addUser(t), r.addUser(t)
+
+		direct, err := r.conversations.CreateDirectConversation(ctx, alice.ID, bob.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "direct", direct.Type)
+		require.NotNil(t, direct.LastMessageID)
+
+		// Gọi lại theo chiều ngược vẫn trả về conversation cũ
+		again, err := r.conversations.CreateDirectConversation(ctx, bob.ID, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, direct.ID, again.ID)
+
+		for _, find := range []func(ctx context.Context, a, b uuid.UUID) (*models.Conversation, error){
+			r.conversations.FindConversationByTwoUserID,
+			r.conversations.GetDirectConversation,
+		} {
+			found, err := find(ctx, bob.ID, alice.ID)
+			require.NoError(t, err)
+			require.NotNil(t, found)
+			assert.Equal(t, direct.ID, found.ID)
+			found, err = find(ctx, alice.ID, carol.ID)
+			require.NoError(t, err)
+			assert.Nil(t, found)
+		}
+
+		withParticipants, err := r.conversations.GetConversationWithParticipants(ctx, direct.ID)
+		require.NoError(t, err)
+		require.Len(t, withParticipants.Participants, 2)
+		memberIDs := []uuid.UUID{withParticipants.Participants[0].User.ID, withParticipants.Participants[1].User.ID}
+		assert.ElementsMatch(t, []uuid.UUID{alice.ID, bob.ID}, memberIDs)
+
+		group := newConversation(t, r, alice, carol)
+		time.Sleep(2 * time.Millisecond)
+		m := sendMessage(t, r, group.ID, &carol, "hello group")
+		require.NoError(t, r.conversations.UpdateLastMessageIDInConversation(ctx, group.ID, m.ID))
+
+		// Conversation cập nhật gần nhất đứng đầu, kèm participants và message cuối
+		list, err := r.conversations.GetConversationsByUserID(ctx, alice.ID, 10, nil)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		assert.Equal(t, group.ID, list[0].ID)
+		assert.Equal(t, direct.ID, list[1].ID)
+		require.NotNil(t, list[0].LastMessage)
+		assert.Equal(t, "hello group", list[0].LastMessage.Content)
+		require.NotNil(t, list[1].LastMessage)
+		assert.Equal(t, "system", list[1].LastMessage.Type)
+		assert.Len(t, list[1].Participants, 2)
+
+		older, err := r.conversations.GetConversationsByUserID(ctx, alice.ID, 10, &list[0].UpdatedAt)
+		require.NoError(t, err)
+		require.Len(t, older, 1)
+		assert.Equal(t, direct.ID, older[0].ID)
+
+		count, err := r.conversations.CountConversationsByUserID(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		messages, err := r.conversations.GetMessageByConversationID(ctx, group.ID, 10, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{m.ID}, messageIDs(messages))
+
+		// Save ghi đè mọi cột nên phải cập nhật từ bản ghi mới nhất
+		got, err := r.conversations.GetConversationByID(ctx, group.ID)
+		require.NoError(t, err)
+		got.Name = "renamed"
+		_, err = r.conversations.UpdateConversation(ctx, got)
+		require.NoError(t, err)
+		got, err = r.conversations.GetConversationByID(ctx, group.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "renamed", got.Name)
+		require.NotNil(t, got.LastMessageID)
+		assert.Equal(t, m.ID, *got.LastMessageID)
+
+		require.NoError(t, r.conversations.DeleteConversation(ctx, direct.ID))
+		assert.Error(t, r.conversations.DeleteConversation(ctx, direct.ID))
+		_, err = r.conversations.GetConversationByID(ctx, direct.ID)
+		assert.Error(t, err)
+		found, err := r.conversations.FindConversationByTwoUserID(ctx, alice.ID, bob.ID)
+		require.NoError(t, err)
+		assert.Nil(t, found)
+	})
+}
+
+func TestParticipantRepositoryConformance(t *testing.T) {
+	eachDB(t, func(t *testing.T, r dbRepos) {
+		ctx := context.Background()
+		alice, bob := r.addUser(t), r.addUser(t)
+		conversation := newConversation(t, r, alice)
+
+		p := &models.Participant{ConversationID: conversation.ID, UserID: bob.ID}
+		require.NoError(t, r.participants.CreateParticipant(ctx, p))
+		assert.NotEqual(t, uuid.Nil, p.ID)
+		assert.Equal(t, "member", p.Role)
+		assert.Error(t, r.participants.CreateParticipant(ctx, &models.Participant{ConversationID: conversation.ID, UserID: bob.ID}))
+
+		got, err := r.participants.GetParticipant(ctx, conversation.ID, bob.ID)
+		require.NoError(t, err)
+		assert.Equal(t, p.ID, got.ID)
+		ok, err := r.participants.IsParticipant(ctx, conversation.ID, bob.ID)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		members, err := r.participants.GetParticipantsByConversationID(ctx, conversation.ID)
+		require.NoError(t, err)
+		require.Len(t, *members, 2)
+		assert.Equal(t, alice.ID, (*members)[0].User.ID)
+
+		byUser, err := r.participants.GetParticipantsByUserID(ctx, bob.ID)
+		require.NoError(t, err)
+		require.Len(t, byUser, 1)
+		assert.Equal(t, conversation.ID, byUser[0].ConversationID)
+
+		got.Role = "admin"
+		require.NoError(t, r.participants.UpdateParticipant(ctx, got))
+		got, err = r.participants.GetParticipant(ctx, conversation.ID, bob.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "admin", got.Role)
+
+		// Rời nhóm là xóa mềm: không còn là participant nhưng unique index vẫn giữ cặp user/conversation
+		require.NoError(t, r.participants.DeleteParticipant(ctx, conversation.ID, bob.ID))
+		assert.Error(t, r.participants.DeleteParticipant(ctx, conversation.ID, bob.ID))
+		ok, err = r.participants.IsParticipant(ctx, conversation.ID, bob.ID)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		_, err = r.participants.GetParticipant(ctx, conversation.ID, bob.ID)
+		assert.Error(t, err)
+		assert.Error(t, r.participants.CreateParticipant(ctx, &models.Participant{ConversationID: conversation.ID, UserID: bob.ID}))
+
+		require.NoError(t, r.participants.DeleteParticipantsByConversationID(ctx, conversation.ID))
+		members, err = r.participants.GetParticipantsByConversationID(ctx, conversation.ID)
+		require.NoError(t, err)
+		assert.Empty(t, *members)
+	})
+}
+
+func TestFriendshipRepositoryConformance(t *testing.T) {
+	eachDB(t, func(t *testing.T, r dbRepos) {
+		ctx := context.Background()
+		alice, bob, carol := r.addUser(t), r.addUser(t), r.addUser(t)
+
+		f, err := r.friendships.CreateFriendship(ctx, &models.Friendship{UserID: alice.ID, FriendID: bob.ID, RequestedBy: alice.ID})
+		require.NoError(t, err)
+		assert.Equal(t, "pending", f.Status)
+		assert.Equal(t, bob.ID, f.Friend.ID)
+		_, err = r.friendships.CreateFriendship(ctx, &models.Friendship{UserID: alice.ID, FriendID: bob.ID, RequestedBy: alice.ID})
+		assert.Error(t, err)
+
+		got, err := r.friendships.GetFriendship(ctx, bob.ID, alice.ID)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, f.ID, got.ID)
+		got, err = r.friendships.GetFriendship(ctx, alice.ID, carol.ID)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+
+		// Lời mời chỉ hiện với người nhận
+		pending, err := r.friendships.ListPendingRequests(ctx, bob.ID)
+		require.NoError(t, err)
+		assert.Len(t, pending, 1)
+		pending, err = r.friendships.ListPendingRequests(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+
+		ok, err := r.friendships.AreFriends(ctx, alice.ID, bob.ID)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		require.NoError(t, r.friendships.ChangeStatus(ctx, f.ID, "friend"))
+		ok, err = r.friendships.AreFriends(ctx, bob.ID, alice.ID)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Error(t, r.friendships.ChangeStatus(ctx, f.ID, "accepted"))
+		assert.Error(t, r.friendships.ChangeStatus(ctx, uuid.New(), "friend"))
+
+		friends, err := r.friendships.ListFriends(ctx, bob.ID, "friend")
+		require.NoError(t, err)
+		require.Len(t, friends, 1)
+		assert.Equal(t, alice.ID, friends[0].User.ID)
+
+		got, err = r.friendships.GetByID(ctx, f.ID)
+		require.NoError(t, err)
+		got.Status = "blocked"
+		updated, err := r.friendships.UpdateFriendship(ctx, got)
+		require.NoError(t, err)
+		assert.Equal(t, "blocked", updated.Status)
+
+		saved, err := r.friendships.SaveFriendship(ctx, updated)
+		require.NoError(t, err)
+		assert.Nil(t, saved)
+
+		require.NoError(t, r.friendships.DeleteFriendship(ctx, f.ID))
+		got, err = r.friendships.GetByID(ctx, f.ID)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+		got, err = r.friendships.GetFriendshipBetweenUsers(ctx, alice.ID, bob.ID)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+}
+
+func TestDeviceRepositoryConformance(t *testing.T) {
+	eachDB(t, func(t *testing.T, r dbRepos) {
+		ctx := context.Background()
+		alice := r.addUser(t)
+
+		device, err := r.devices.CreateDevice(ctx, &models.Device{UserID: alice.ID, Type: "Web", Name: "Chrome", IP: "10.0.0.1", UserAgent: "Mozilla/5.0"})
+		require.NoError(t, err)
+		assert.NotEqual(t, uuid.Nil, device.ID)
+
+		// Device chưa có token chưa được tính là đã đăng nhập
+		found, err := r.devices.GetDeviceByInfo(ctx, alice.ID, "10.0.0.1", "Mozilla/5.0")
+		require.NoError(t, err)
+		assert.Nil(t, found)
+		r.addToken(t, device.ID)
+		found, err = r.devices.GetDeviceByInfo(ctx, alice.ID, "10.0.0.1", "Mozilla/5.0")
+		require.NoError(t, err)
+		require.NotNil(t, found)
+		assert.Equal(t, device.ID, found.ID)
+		found, err = r.devices.GetDeviceByInfo(ctx, alice.ID, "10.0.0.2", "Mozilla/5.0")
+		require.NoError(t, err)
+		assert.Nil(t, found)
+
+		device.Name = "Firefox"
+		_, err = r.devices.UpdateDevice(ctx, device)
+		require.NoError(t, err)
+		devices, err := r.devices.GetDevicesByUser(ctx, alice.ID)
+		require.NoError(t, err)
+		require.Len(t, devices, 1)
+		assert.Equal(t, "Firefox", devices[0].Name)
+	})
+}
+
+func TestRedisRepositoryConformance(t *testing.T) {
+	eachRedis(t, func(t *testing.T, r RedisRepository) {
+		ctx := context.Background()
+		prefix := uuid.NewString()
+
+		user := newTestUser()
+		require.NoError(t, r.SetToken(ctx, prefix, &user, time.Minute))
+		got, err := r.GetUserByToken(ctx, prefix)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, got.ID)
+		require.NoError(t, r.DeleteToken(ctx, prefix))
+		_, err = r.GetUserByToken(ctx, prefix)
+		assert.ErrorIs(t, err, redis.Nil)
+
+		participants := []models.Participant{{UserID: user.ID, Role: "admin"}}
+		require.NoError(t, r.SetTokenParticipant(ctx, prefix, &participants, 0))
+		cached, err := r.GetParticipantByToken(ctx, prefix)
+		require.NoError(t, err)
+		assert.Equal(t, "admin", (*cached)[0].Role)
+		_, err = r.GetParticipantByToken(ctx, uuid.NewString())
+		assert.ErrorIs(t, err, redis.Nil)
+
+		require.NoError(t, r.UpdateUserOnline(ctx, prefix))
+		online, since, err := r.IsUserOnline(ctx, prefix)
+		require.NoError(t, err)
+		assert.True(t, online)
+		assert.Less(t, since, 5*time.Second)
+		online, _, err = r.IsUserOnline(ctx, uuid.NewString())
+		require.NoError(t, err)
+		assert.False(t, online)
+
+		userA, userB := uuid.NewString(), uuid.NewString()
+		assert.Equal(t, r.CreateKeyConversationTwoUserID(userA, userB), r.CreateKeyConversationTwoUserID(userB, userA))
+		conversation := &models.Conversation{ID: uuid.New(), Type: "direct"}
+		require.NoError(t, r.SetKeyConversationTwoUserID(ctx, userA, userB, conversation, time.Minute))
+		cachedConversation, err := r.GetConversationIDByTwoUserID(ctx, userB, userA)
+		require.NoError(t, err)
+		assert.Equal(t, conversation.ID, cachedConversation.ID)
+		_, err = r.GetConversationIDByTwoUserID(ctx, userA, uuid.NewString())
+		assert.ErrorIs(t, err, redis.Nil)
+
+		acquired, _, err := r.AcquireThrottle(ctx, prefix, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, acquired)
+		acquired, remaining, err := r.AcquireThrottle(ctx, prefix, time.Minute)
+		require.NoError(t, err)
+		assert.False(t, acquired)
+		assert.InDelta(t, time.Minute.Seconds(), remaining.Seconds(), 2)
+		require.NoError(t, r.SetThrottle(ctx, prefix, 10*time.Second))
+		ttl, err := r.GetThrottleTTL(ctx, prefix)
+		require.NoError(t, err)
+		assert.InDelta(t, 10, ttl.Seconds(), 2)
+		ttl, err = r.GetThrottleTTL(ctx, uuid.NewString())
+		require.NoError(t, err)
+		assert.Zero(t, ttl)
+
+		for want := int64(1); want <= 3; want++ {
+			n, err := r.IncrementCounter(ctx, prefix, time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, want, n)
+		}
+		require.NoError(t, r.ResetCounter(ctx, prefix))
+		n, err := r.IncrementCounter(ctx, prefix, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+
+		// State và magic link chỉ dùng được 1 lần
+		require.NoError(t, r.SaveOAuthState(ctx, prefix, []byte(`{"provider":"github"}`), time.Minute))
+		state, err := r.ConsumeOAuthState(ctx, prefix)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"provider":"github"}`, string(state))
+		_, err = r.ConsumeOAuthState(ctx, prefix)
+		assert.ErrorIs(t, err, redis.Nil)
+
+		require.NoError(t, r.SaveMagicLink(ctx, prefix, user.ID.String(), time.Minute))
+		userID, err := r.ConsumeMagicLink(ctx, prefix)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID.String(), userID)
+		_, err = r.ConsumeMagicLink(ctx, prefix)
+		assert.ErrorIs(t, err, redis.Nil)
+	})
+}
+
+func TestMemoryRedisExpiry(t *testing.T) {
+	now := time.Now()
+	r := NewMemoryRedisRepositoryWithClock(func() time.Time { return now })
+	ctx := context.Background()
+
+	require.NoError(t, r.UpdateUserOnline(ctx, "alice"))
+	n, err := r.IncrementCounter(ctx, "login:alice", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	now = now.Add(6 * time.Minute)
+	online, since, err := r.IsUserOnline(ctx, "alice")
+	require.NoError(t, err)
+	assert.False(t, online)
+	assert.InDelta(t, (6 * time.Minute).Seconds(), since.Seconds(), 1)
+	n, err = r.IncrementCounter(ctx, "login:alice", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"project/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ctx đã hủy (client ngắt kết nối) phải dừng query ngay, không đợi kết nối hay câu lệnh SQL.
+// Bản "unreachable" trỏ tới port không có server: query chỉ trả về được lỗi context.Canceled
+// nếu ctx được truyền xuống driver, nếu không sẽ là lỗi kết nối.
+func TestRepositoriesAbortOnCancelledContext(t *testing.T) {
+	run := func(t *testing.T, db *gorm.DB) {
+		id := uuid.New()
+		calls := map[string]func(ctx context.Context) error{
+			"UserRepository.GetUserByID": func(ctx context.Context) error {
+				_, err := NewUserRepository(db).GetUserByID(ctx, id)
+				return err
+			},
+			"UserRepository.CreateUser": func(ctx context.Context) error {
+				user := newTestUser()
+				_, err := NewUserRepository(db).CreateUser(ctx, &user)
+				return err
+			},
+			"MessageRepository.GetMessagesByConversationID": func(ctx context.Context) error {
+				_, err := NewMessageRepository(db).GetMessagesByConversationID(ctx, id, 20, 0)
+				return err
+			},
+			"MessageRepository.CreateMessageWithAttachments": func(ctx context.Context) error {
+				_, err := NewMessageRepository(db).CreateMessageWithAttachments(ctx,
+					&models.Message{ID: uuid.New(), ConversationID: id, SenderID: &id, Content: "hi"}, []uuid.UUID{uuid.New()})
+				return err
+			},
+			"ConversationRepository.GetConversationByID": func(ctx context.Context) error {
+				_, err := NewConversationRepository(db).GetConversationByID(ctx, id)
+				return err
+			},
+			"ParticipantRepository.GetParticipantsByUserID": func(ctx context.Context) error {
+				_, err := NewParticipantRepository(db).GetParticipantsByUserID(ctx, id)
+				return err
+			},
+			"FriendshipRepository.ListFriends": func(ctx context.Context) error {
+				_, err := NewFriendshipRepository(db).ListFriends(ctx, id, "friend")
+				return err
+			},
+			"DeviceRepository.GetDevicesByUser": func(ctx context.Context) error {
+				_, err := NewDeviceRepository(db).GetDevicesByUser(ctx, id)
+				return err
+			},
+			"TokenRepository.GetTokenByAccess": func(ctx context.Context) error {
+				_, err := NewTokenRepository(db).GetTokenByAccess(ctx, "access")
+				return err
+			},
+			"AttachmentRepository.GetAttachmentByID": func(ctx context.Context) error {
+				_, err := NewAttachmentRepository(db).GetAttachmentByID(ctx, id)
+				return err
+			},
+			"BlobRepository.ListOrphanBlobs": func(ctx context.Context) error {
+				_, err := NewBlobRepository(db).ListOrphanBlobs(ctx, time.Now(), 10)
+				return err
+			},
+			"IdentityRepository.GetIdentitiesByUserID": func(ctx context.Context) error {
+				_, err := NewIdentityRepository(db).GetIdentitiesByUserID(ctx, id)
+				return err
+			},
+		}
+		for name, call := range calls {
+			t.Run(name, func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				start := time.Now()
+				err := call(ctx)
+				assert.ErrorIs(t, err, context.Canceled)
+				assert.Less(t, time.Since(start), time.Second)
+			})
+		}
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1 sslmode=disable connect_timeout=5"}),
+			&gorm.Config{DisableAutomaticPing: true, Logger: logger.Default.LogMode(logger.Silent)})
+		require.NoError(t, err)
+		run(t, db)
+	})
+	t.Run("postgres", func(t *testing.T) {
+		run(t, postgresDB(t))
+	})
+}
@@ -1,9 +1,9 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"project/database"
 	"project/models"
 	"time"
 
@@ -12,37 +12,38 @@ import (
 )
 
 type ConversationRepository interface {
-	CreateConversation(conversation *models.Conversation) (*models.Conversation, error)
-	CreateDirectConversation(userID1 uuid.UUID, userID2 uuid.UUID) (*models.Conversation, error)
-	GetConversationByID(conversationID uuid.UUID) (*models.Conversation, error)
+	CreateConversation(ctx context.Context, conversation *models.Conversation) (*models.Conversation, error)
+	CreateDirectConversation(ctx context.Context, userID1 uuid.UUID, userID2 uuid.UUID) (*models.Conversation, error)
+	GetConversationByID(ctx context.Context, conversationID uuid.UUID) (*models.Conversation, error)
 
 	GetConversationsByUserID(
+		ctx context.Context,
 		userID uuid.UUID,
 		limit int,
 		before *time.Time, // con trỏ thời gian: chỉ lấy các cuộc trò chuyện cũ hơn thời điểm này
 	) ([]*models.Conversation, error)
-	CountConversationsByUserID(userID uuid.UUID) (int64, error)
-	UpdateLastMessageIDInConversation(conversationID uuid.UUID, messageID uuid.UUID) error
-	UpdateConversation(conversation *models.Conversation) (*models.Conversation, error)
-	DeleteConversation(conversationID uuid.UUID) error
-	GetDirectConversation(userID1, userID2 uuid.UUID) (*models.Conversation, error)
-	GetConversationWithParticipants(conversationID uuid.UUID) (*models.Conversation, error)
-	GetMessageByConversationID(conversationID uuid.UUID, limit int, before *time.Time) ([]*models.Message, error)
-	FindConversationByTwoUserID(userID1 uuid.UUID, userID2 uuid.UUID) (*models.Conversation, error)
+	CountConversationsByUserID(ctx context.Context, userID uuid.UUID) (int64, error)
+	UpdateLastMessageIDInConversation(ctx context.Context, conversationID uuid.UUID, messageID uuid.UUID) error
+	UpdateConversation(ctx context.Context, conversation *models.Conversation) (*models.Conversation, error)
+	DeleteConversation(ctx context.Context, conversationID uuid.UUID) error
+	GetDirectConversation(ctx context.Context, userID1, userID2 uuid.UUID) (*models.Conversation, error)
+	GetConversationWithParticipants(ctx context.Context, conversationID uuid.UUID) (*models.Conversation, error)
+	GetMessageByConversationID(ctx context.Context, conversationID uuid.UUID, limit int, before *time.Time) ([]*models.Message, error)
+	FindConversationByTwoUserID(ctx context.Context, userID1 uuid.UUID, userID2 uuid.UUID) (*models.Conversation, error)
 }
 
 type conversationRepo struct {
 	db *gorm.DB
 }
 
-func NewConversationRepository() ConversationRepository {
+func NewConversationRepository(db *gorm.DB) ConversationRepository {
 	return &conversationRepo{
-		db: database.DB,
+		db: db,
 	}
 }
 
 // CreateConversation - Tạo conversation mới
-func (r *conversationRepo) CreateConversation(conversation *models.Conversation) (*models.Conversation, error) {
+func (r *conversationRepo) CreateConversation(ctx context.Context, conversation *models.Conversation) (*models.Conversation, error) {
 	if conversation == nil {
 		return nil, errors.New("conversation is nil")
 	}
@@ -54,15 +55,15 @@ func (r *conversationRepo) CreateConversation(conversation *models.Conversation)
 	conversation.CreatedAt = time.Now()
 	conversation.UpdatedAt = time.Now()
 
-	if err := r.db.Create(conversation).Error; err != nil {
+	if err := r.db.WithContext(ctx).Create(conversation).Error; err != nil {
 		return nil, fmt.Errorf("failed to create conversation: %w", err)
 	}
 
 	return conversation, nil
 }
 
-func (r *conversationRepo) UpdateLastMessageIDInConversation(conversationID uuid.UUID, messageID uuid.UUID) error {
-	result := r.db.Model(&models.Conversation{}).
+func (r *conversationRepo) UpdateLastMessageIDInConversation(ctx context.Context, conversationID uuid.UUID, messageID uuid.UUID) error {
+	result := r.db.WithContext(ctx).Model(&models.Conversation{}).
 		Where("id = ?", conversationID).
 		Update("last_message_id", messageID)
 	if result.Error != nil {
@@ -71,9 +72,9 @@ func (r *conversationRepo) UpdateLastMessageIDInConversation(conversationID uuid
 	return nil
 }
 
-func (r *conversationRepo) GetMessageByConversationID(conversationID uuid.UUID, limit int, before *time.Time) ([]*models.Message, error) {
+func (r *conversationRepo) GetMessageByConversationID(ctx context.Context, conversationID uuid.UUID, limit int, before *time.Time) ([]*models.Message, error) {
 	var messages []*models.Message
-	query := r.db.Where("conversation_id = ?", conversationID)
+	query := r.db.WithContext(ctx).Where("conversation_id = ?", conversationID)
 	// Nếu có cursor "before" (load thêm các message cũ hơn)
 	if before != nil {
 		query = query.Where("created_at < ?", before)
@@ -87,11 +88,11 @@ func (r *conversationRepo) GetMessageByConversationID(conversationID uuid.UUID,
 	return messages, nil
 }
 
-func (r *conversationRepo) CreateDirectConversation(userID1 uuid.UUID, userID2 uuid.UUID) (*models.Conversation, error) {
+func (r *conversationRepo) CreateDirectConversation(ctx context.Context, userID1 uuid.UUID, userID2 uuid.UUID) (*models.Conversation, error) {
 	var existing models.Conversation
 
 	// 🔍 Kiểm tra đã có conversation direct giữa 2 người này chưa
-	err := r.db.
+	err := r.db.WithContext(ctx).
 		Joins("JOIN participants p1 ON p1.conversation_id = conversations.id").
 		Joins("JOIN participants p2 ON p2.conversation_id = conversations.id").
 		Where("conversations.type = ?", "direct").
@@ -119,7 +120,7 @@ func (r *conversationRepo) CreateDirectConversation(userID1 uuid.UUID, userID2 u
 	}
 
 	// ⚡ Transaction để đảm bảo toàn vẹn dữ liệu
-	tx := r.db.Begin()
+	tx := r.db.WithContext(ctx).Begin()
 	if err := tx.Create(conversation).Error; err != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("failed to create conversation: %w", err)
@@ -167,10 +168,10 @@ func (r *conversationRepo) CreateDirectConversation(userID1 uuid.UUID, userID2 u
 }
 
 // GetConversationByID - Lấy conversation theo ID
-func (r *conversationRepo) GetConversationByID(conversationID uuid.UUID) (*models.Conversation, error) {
+func (r *conversationRepo) GetConversationByID(ctx context.Context, conversationID uuid.UUID) (*models.Conversation, error) {
 	var conversation models.Conversation
 
-	err := r.db.Where("id = ?", conversationID).First(&conversation).Error
+	err := r.db.WithContext(ctx).Where("id = ?", conversationID).First(&conversation).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, fmt.Errorf("conversation not found")
@@ -183,13 +184,13 @@ func (r *conversationRepo) GetConversationByID(conversationID uuid.UUID) (*model
 
 // GetConversationsByUserID - Lấy danh sách conversations của user với pagination (cursor-based)
 func (r *conversationRepo) GetConversationsByUserID(
+	ctx context.Context,
 	userID uuid.UUID,
 	limit int,
 	before *time.Time, // con trỏ thời gian: chỉ lấy các cuộc trò chuyện cũ hơn thời điểm này
 ) ([]*models.Conversation, error) {
-	fmt.Println("userId", userID)
 	var conversations []*models.Conversation
-	query := r.db.Table("conversations").
+	query := r.db.WithContext(ctx).Table("conversations").
 		Joins("JOIN participants ON participants.conversation_id = conversations.id").
 		Where("participants.user_id = ?", userID)
 
@@ -211,12 +212,12 @@ func (r *conversationRepo) GetConversationsByUserID(
 }
 
 // CountConversationsByUserID - Đếm số lượng conversations của user
-func (r *conversationRepo) CountConversationsByUserID(userID uuid.UUID) (int64, error) {
+func (r *conversationRepo) CountConversationsByUserID(ctx context.Context, userID uuid.UUID) (int64, error) {
 	var count int64
 
-	err := r.db.Table("conversations").
+	err := r.db.WithContext(ctx).Table("conversations").
 		Joins("JOIN participants ON participants.conversation_id = conversations.id").
-		Where("participants.user_id = ? AND participants.left_at IS NULL", userID).
+		Where("participants.user_id = ? AND participants.deleted_at IS NULL AND conversations.deleted_at IS NULL", userID).
 		Count(&count).Error
 
 	if err != nil {
@@ -227,14 +228,14 @@ func (r *conversationRepo) CountConversationsByUserID(userID uuid.UUID) (int64,
 }
 
 // UpdateConversation - Cập nhật conversation
-func (r *conversationRepo) UpdateConversation(conversation *models.Conversation) (*models.Conversation, error) {
+func (r *conversationRepo) UpdateConversation(ctx context.Context, conversation *models.Conversation) (*models.Conversation, error) {
 	if conversation == nil {
 		return nil, errors.New("conversation is nil")
 	}
 
 	conversation.UpdatedAt = time.Now()
 
-	if err := r.db.Save(conversation).Error; err != nil {
+	if err := r.db.WithContext(ctx).Save(conversation).Error; err != nil {
 		return nil, fmt.Errorf("failed to update conversation: %w", err)
 	}
 
@@ -242,8 +243,8 @@ func (r *conversationRepo) UpdateConversation(conversation *models.Conversation)
 }
 
 // DeleteConversation - Xóa conversation
-func (r *conversationRepo) DeleteConversation(conversationID uuid.UUID) error {
-	result := r.db.Delete(&models.Conversation{}, "id = ?", conversationID)
+func (r *conversationRepo) DeleteConversation(ctx context.Context, conversationID uuid.UUID) error {
+	result := r.db.WithContext(ctx).Delete(&models.Conversation{}, "id = ?", conversationID)
 
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete conversation: %w", result.Error)
@@ -257,16 +258,16 @@ func (r *conversationRepo) DeleteConversation(conversationID uuid.UUID) error {
 }
 
 // GetDirectConversation - Lấy direct conversation giữa 2 users
-func (r *conversationRepo) GetDirectConversation(userID1, userID2 uuid.UUID) (*models.Conversation, error) {
+func (r *conversationRepo) GetDirectConversation(ctx context.Context, userID1, userID2 uuid.UUID) (*models.Conversation, error) {
 	var conversation models.Conversation
 
 	// Tìm conversation có type = 'direct' và có cả 2 users làm participants
-	err := r.db.Table("conversations").
+	err := r.db.WithContext(ctx).Table("conversations").
 		Joins("JOIN participants p1 ON p1.conversation_id = conversations.id").
 		Joins("JOIN participants p2 ON p2.conversation_id = conversations.id").
 		Where(`conversations.type = 'direct' 
-               AND p1.user_id = ? AND p1.left_at IS NULL
-               AND p2.user_id = ? AND p2.left_at IS NULL
+               AND p1.user_id = ? AND p1.deleted_at IS NULL
+               AND p2.user_id = ? AND p2.deleted_at IS NULL
                AND p1.user_id != p2.user_id`, userID1, userID2).
 		First(&conversation).Error
 
@@ -281,10 +282,10 @@ func (r *conversationRepo) GetDirectConversation(userID1, userID2 uuid.UUID) (*m
 }
 
 // GetConversationWithParticipants - Lấy conversation kèm participants
-func (r *conversationRepo) GetConversationWithParticipants(conversationID uuid.UUID) (*models.Conversation, error) {
+func (r *conversationRepo) GetConversationWithParticipants(ctx context.Context, conversationID uuid.UUID) (*models.Conversation, error) {
 	var conversation models.Conversation
 
-	err := r.db.Preload("Participants").
+	err := r.db.WithContext(ctx).Preload("Participants").
 		Preload("Participants.User").
 		Where("id = ?", conversationID).
 		First(&conversation).Error
@@ -299,9 +300,9 @@ func (r *conversationRepo) GetConversationWithParticipants(conversationID uuid.U
 	return &conversation, nil
 }
 
-func (r *conversationRepo) FindConversationByTwoUserID(userID1 uuid.UUID, userID2 uuid.UUID) (*models.Conversation, error) {
+func (r *conversationRepo) FindConversationByTwoUserID(ctx context.Context, userID1 uuid.UUID, userID2 uuid.UUID) (*models.Conversation, error) {
 	var conversation models.Conversation
-	err := r.db.Table("conversations").
+	err := r.db.WithContext(ctx).Table("conversations").
 		Joins("JOIN participants p1 ON p1.conversation_id = conversations.id").
 		Joins("JOIN participants p2 ON p2.conversation_id = conversations.id").
 		Where("conversations.type = ?", "direct").
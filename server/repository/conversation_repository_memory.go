@@ -0,0 +1,315 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"project/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memoryConversationRepo là ConversationRepository trong bộ nhớ, cùng hành vi với conversationRepo
+type memoryConversationRepo struct {
+	store *MemoryStore
+}
+
+func NewMemoryConversationRepository(store *MemoryStore) ConversationRepository {
+	return &memoryConversationRepo{
+		store: store,
+	}
+}
+
+// putConversation lưu bản sao conversation. Participants đi kèm được insert nếu chưa có,
+// giống GORM lưu association với ON CONFLICT DO NOTHING.
+func (s *MemoryStore) putConversation(conversation *models.Conversation, create bool) error {
+	if create && conversation.Type == "" {
+		conversation.Type = "direct"
+	}
+	for _, p := range conversation.Participants {
+		if p == nil {
+			continue
+		}
+		p.ConversationID = conversation.ID
+		if p.ID == uuid.Nil {
+			p.ID = uuid.New()
+		}
+		if _, ok := s.participants[p.ID]; ok {
+			continue
+		}
+		if p.JoinedAt.IsZero() {
+			p.JoinedAt = s.now()
+		}
+		if err := s.putParticipant(p, true); err != nil {
+			return err
+		}
+	}
+	row := *conversation
+	row.LastMessageID = cloneUUID(conversation.LastMessageID)
+	row.Participants = nil
+	row.LastMessage = nil
+	s.conversations[row.ID] = row
+	return nil
+}
+
+// loadConversation trả về bản sao conversation, preload Participants.User và LastMessage khi cần
+func (s *MemoryStore) loadConversation(row models.Conversation, preload bool) *models.Conversation {
+	conversation := row
+	conversation.LastMessageID = cloneUUID(row.LastMessageID)
+	if !preload {
+		return &conversation
+	}
+	conversation.Participants = s.findParticipants(func(p models.Participant) bool {
+		return p.ConversationID == row.ID
+	}, false)
+	if row.LastMessageID != nil {
+		if m, ok := s.messages[*row.LastMessageID]; ok && !m.DeletedAt.Valid {
+			conversation.LastMessage = s.loadMessage(m)
+			conversation.LastMessage.Sender = nil
+		}
+	}
+	return &conversation
+}
+
+// hasMember kiểm tra user có dòng participant trong conversation (JOIN participants, kể cả đã rời nếu includeLeft)
+func (s *MemoryStore) hasMember(conversationID, userID uuid.UUID, includeLeft bool) bool {
+	for _, p := range s.participants {
+		if p.ConversationID == conversationID && p.UserID == userID && (includeLeft || !p.DeletedAt.Valid) {
+			return true
+		}
+	}
+	return false
+}
+
+// findDirect tìm conversation direct chưa xóa giữa 2 user, lấy id nhỏ nhất giống First
+func (s *MemoryStore) findDirect(userID1, userID2 uuid.UUID, includeLeft bool) (models.Conversation, bool) {
+	var ids []uuid.UUID
+	for id, c := range s.conversations {
+		if c.DeletedAt.Valid || c.Type != "direct" {
+			continue
+		}
+		if s.hasMember(id, userID1, includeLeft) && s.hasMember(id, userID2, includeLeft) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return models.Conversation{}, false
+	}
+	sortByID(ids)
+	return s.conversations[ids[0]], true
+}
+
+func (r *memoryConversationRepo) CreateConversation(ctx context.Context, conversation *models.Conversation) (*models.Conversation, error) {
+	if conversation == nil {
+		return nil, errors.New("conversation is nil")
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if conversation.ID == uuid.Nil {
+		conversation.ID = uuid.New()
+	}
+	if _, ok := r.store.conversations[conversation.ID]; ok {
+		return nil, fmt.Errorf("failed to create conversation: duplicate key %s", conversation.ID)
+	}
+	conversation.CreatedAt = r.store.now()
+	conversation.UpdatedAt = conversation.CreatedAt
+	if err := r.store.putConversation(conversation, true); err != nil {
+		return nil, fmt.Errorf("failed to create conversation: %w", err)
+	}
+	return conversation, nil
+}
+
+func (r *memoryConversationRepo) CreateDirectConversation(ctx context.Context, userID1 uuid.UUID, userID2 uuid.UUID) (*models.Conversation, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if existing, ok := r.store.findDirect(userID1, userID2, true); ok {
+		return r.store.loadConversation(existing, false), nil
+	}
+
+	now := r.store.now()
+	message := &models.Message{
+		ID:             uuid.New(),
+		ConversationID: uuid.New(),
+		Type:           "system",
+		Content:        "Đây là cuộc trò chuyện riêng tư hãy chào nhau đi!",
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	conversation := &models.Conversation{
+		ID:            message.ConversationID,
+		Type:          "direct",
+		LastMessageID: &message.ID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Participants: []*models.Participant{
+			{ConversationID: message.ConversationID, UserID: userID1, JoinedAt: now},
+			{ConversationID: message.ConversationID, UserID: userID2, JoinedAt: now},
+		},
+	}
+	// Kiểm tra participant trước khi ghi để lỗi không để lại dữ liệu dở dang (như rollback transaction)
+	if userID1 == userID2 {
+		return nil, fmt.Errorf("failed to add participants: duplicate key value violates unique constraint idx_conversation_user")
+	}
+	if err := r.store.putMessage(message, true); err != nil {
+		return nil, fmt.Errorf("failed to create initial message: %w", err)
+	}
+	if err := r.store.putConversation(conversation, true); err != nil {
+		return nil, fmt.Errorf("failed to add participants: %w", err)
+	}
+	conversation.Participants = nil
+	return conversation, nil
+}
+
+func (r *memoryConversationRepo) GetConversationByID(ctx context.Context, conversationID uuid.UUID) (*models.Conversation, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	c, ok := r.store.conversations[conversationID]
+	if !ok || c.DeletedAt.Valid {
+		return nil, fmt.Errorf("conversation not found")
+	}
+	return r.store.loadConversation(c, false), nil
+}
+
+func (r *memoryConversationRepo) GetConversationsByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+	limit int,
+	before *time.Time,
+) ([]*models.Conversation, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var rows []models.Conversation
+	for id, c := range r.store.conversations {
+		if c.DeletedAt.Valid || !r.store.hasMember(id, userID, true) {
+			continue
+		}
+		if before != nil && !c.UpdatedAt.Before(*before) {
+			continue
+		}
+		rows = append(rows, c)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].UpdatedAt.After(rows[j].UpdatedAt) })
+	start, end := pageBounds(len(rows), limit, 0)
+
+	conversations := make([]*models.Conversation, 0, end-start)
+	for _, c := range rows[start:end] {
+		conversations = append(conversations, r.store.loadConversation(c, true))
+	}
+	return conversations, nil
+}
+
+func (r *memoryConversationRepo) CountConversationsByUserID(ctx context.Context, userID uuid.UUID) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var count int64
+	for id, c := range r.store.conversations {
+		if !c.DeletedAt.Valid && r.store.hasMember(id, userID, false) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryConversationRepo) UpdateLastMessageIDInConversation(ctx context.Context, conversationID uuid.UUID, messageID uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	c, ok := r.store.conversations[conversationID]
+	if !ok || c.DeletedAt.Valid {
+		return nil
+	}
+	c.LastMessageID = &messageID
+	c.UpdatedAt = r.store.now()
+	r.store.conversations[conversationID] = c
+	return nil
+}
+
+func (r *memoryConversationRepo) UpdateConversation(ctx context.Context, conversation *models.Conversation) (*models.Conversation, error) {
+	if conversation == nil {
+		return nil, errors.New("conversation is nil")
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	conversation.UpdatedAt = r.store.now()
+	if err := r.store.putConversation(conversation, false); err != nil {
+		return nil, fmt.Errorf("failed to update conversation: %w", err)
+	}
+	return conversation, nil
+}
+
+func (r *memoryConversationRepo) DeleteConversation(ctx context.Context, conversationID uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	c, ok := r.store.conversations[conversationID]
+	if !ok || c.DeletedAt.Valid {
+		return fmt.Errorf("conversation not found")
+	}
+	c.DeletedAt = gorm.DeletedAt{Time: r.store.now(), Valid: true}
+	r.store.conversations[conversationID] = c
+	return nil
+}
+
+func (r *memoryConversationRepo) GetDirectConversation(ctx context.Context, userID1, userID2 uuid.UUID) (*models.Conversation, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	if userID1 == userID2 {
+		return nil, nil
+	}
+	c, ok := r.store.findDirect(userID1, userID2, false)
+	if !ok {
+		return nil, nil
+	}
+	return r.store.loadConversation(c, false), nil
+}
+
+func (r *memoryConversationRepo) GetConversationWithParticipants(ctx context.Context, conversationID uuid.UUID) (*models.Conversation, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	c, ok := r.store.conversations[conversationID]
+	if !ok || c.DeletedAt.Valid {
+		return nil, fmt.Errorf("conversation not found")
+	}
+	conversation := r.store.loadConversation(c, true)
+	conversation.LastMessage = nil
+	return conversation, nil
+}
+
+func (r *memoryConversationRepo) GetMessageByConversationID(ctx context.Context, conversationID uuid.UUID, limit int, before *time.Time) ([]*models.Message, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	messages := r.store.findMessages(func(m models.Message) bool {
+		return m.ConversationID == conversationID && (before == nil || m.CreatedAt.Before(*before))
+	}, true)
+	start, end := pageBounds(len(messages), limit, 0)
+	messages = messages[start:end]
+	// Query này không preload Sender
+	for _, m := range messages {
+		m.Sender = nil
+	}
+	return messages, nil
+}
+
+func (r *memoryConversationRepo) FindConversationByTwoUserID(ctx context.Context, userID1 uuid.UUID, userID2 uuid.UUID) (*models.Conversation, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	c, ok := r.store.findDirect(userID1, userID2, true)
+	if !ok {
+		return nil, nil
+	}
+	return r.store.loadConversation(c, false), nil
+}
@@ -1,9 +1,9 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"project/database"
 	"project/models"
 
 	"github.com/google/uuid"
@@ -11,47 +11,47 @@ import (
 )
 
 type DeviceRepository interface {
-	CreateDevice(device *models.Device) (*models.Device, error)
-	GetDeviceByInfo(userID uuid.UUID, ip, userAgent string) (*models.Device, error)
-	GetDevicesByUser(userID uuid.UUID) ([]*models.Device, error)
-	UpdateDevice(device *models.Device) (*models.Device, error)
+	CreateDevice(ctx context.Context, device *models.Device) (*models.Device, error)
+	GetDeviceByInfo(ctx context.Context, userID uuid.UUID, ip, userAgent string) (*models.Device, error)
+	GetDevicesByUser(ctx context.Context, userID uuid.UUID) ([]*models.Device, error)
+	UpdateDevice(ctx context.Context, device *models.Device) (*models.Device, error)
 }
 type deviceRepo struct {
 	db *gorm.DB
 }
 
-var NewDeviceRepository = func() DeviceRepository {
+func NewDeviceRepository(db *gorm.DB) DeviceRepository {
 	return &deviceRepo{
-		db: database.DB,
+		db: db,
 	}
 }
 
 // CreateDevice tạo mới device
-func (r *deviceRepo) CreateDevice(device *models.Device) (*models.Device, error) {
+func (r *deviceRepo) CreateDevice(ctx context.Context, device *models.Device) (*models.Device, error) {
 	if device.ID == uuid.Nil {
 		device.ID = uuid.New()
 	}
 
-	if err := r.db.Create(device).Error; err != nil {
+	if err := r.db.WithContext(ctx).Create(device).Error; err != nil {
 		return nil, err
 	}
 	return device, nil
 }
-func (r *deviceRepo) UpdateDevice(device *models.Device) (*models.Device, error) {
+func (r *deviceRepo) UpdateDevice(ctx context.Context, device *models.Device) (*models.Device, error) {
 	if device == nil {
 		return nil, errors.New("device is Nil")
 	}
 
-	if err := r.db.Save(device).Error; err != nil {
+	if err := r.db.WithContext(ctx).Save(device).Error; err != nil {
 		return nil, fmt.Errorf("fail update device %s", err)
 	}
 	return device, nil
 }
 
 // GetDeviceByInfo tìm device theo UserID + IP + UserAgent
-func (r *deviceRepo) GetDeviceByInfo(userID uuid.UUID, ip, userAgent string) (*models.Device, error) {
+func (r *deviceRepo) GetDeviceByInfo(ctx context.Context, userID uuid.UUID, ip, userAgent string) (*models.Device, error) {
 	var device models.Device
-	err := r.db.Table("devices").Joins("JOIN tokens on tokens.device_id = devices.id").Where("devices.user_id = ? AND devices.ip = ? AND devices.user_agent = ?", userID, ip, userAgent).First(&device).Error
+	err := r.db.WithContext(ctx).Table("devices").Joins("JOIN tokens on tokens.device_id = devices.id").Where("devices.user_id = ? AND devices.ip = ? AND devices.user_agent = ?", userID, ip, userAgent).First(&device).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil // chưa tồn tại
@@ -62,9 +62,9 @@ func (r *deviceRepo) GetDeviceByInfo(userID uuid.UUID, ip, userAgent string) (*m
 }
 
 // Optional: Get all devices of a user
-func (r *deviceRepo) GetDevicesByUser(userID uuid.UUID) ([]*models.Device, error) {
+func (r *deviceRepo) GetDevicesByUser(ctx context.Context, userID uuid.UUID) ([]*models.Device, error) {
 	var devices []*models.Device
-	if err := r.db.Where("user_id = ?", userID).Find(&devices).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&devices).Error; err != nil {
 		return nil, err
 	}
 	return devices, nil
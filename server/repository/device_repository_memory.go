@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"project/models"
+
+	"github.com/google/uuid"
+)
+
+// memoryDeviceRepo là DeviceRepository trong bộ nhớ, cùng hành vi với deviceRepo
+type memoryDeviceRepo struct {
+	store *MemoryStore
+}
+
+func NewMemoryDeviceRepository(store *MemoryStore) DeviceRepository {
+	return &memoryDeviceRepo{
+		store: store,
+	}
+}
+
+// putDevice lưu bản sao device, Token đi kèm (nếu có) được lưu như association của GORM
+func (s *MemoryStore) putDevice(device *models.Device) {
+	if device.Token != (models.Token{}) {
+		device.Token.DeviceID = device.ID
+		s.tokens[device.ID] = device.Token
+	}
+	row := *device
+	row.Token = models.Token{}
+	s.devices[row.ID] = row
+}
+
+func (r *memoryDeviceRepo) CreateDevice(ctx context.Context, device *models.Device) (*models.Device, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if device.ID == uuid.Nil {
+		device.ID = uuid.New()
+	}
+	if _, ok := r.store.devices[device.ID]; ok {
+		return nil, fmt.Errorf("duplicate key %s", device.ID)
+	}
+	r.store.putDevice(device)
+	return device, nil
+}
+
+func (r *memoryDeviceRepo) UpdateDevice(ctx context.Context, device *models.Device) (*models.Device, error) {
+	if device == nil {
+		return nil, errors.New("device is Nil")
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.putDevice(device)
+	return device, nil
+}
+
+func (r *memoryDeviceRepo) GetDeviceByInfo(ctx context.Context, userID uuid.UUID, ip, userAgent string) (*models.Device, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	// JOIN tokens: device chưa có token thì coi như chưa tồn tại
+	var ids []uuid.UUID
+	for id, d := range r.store.devices {
+		if _, ok := r.store.tokens[id]; !ok {
+			continue
+		}
+		if d.UserID == userID && d.IP == ip && d.UserAgent == userAgent {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	sortByID(ids)
+	device := r.store.devices[ids[0]]
+	return &device, nil
+}
+
+func (r *memoryDeviceRepo) GetDevicesByUser(ctx context.Context, userID uuid.UUID) ([]*models.Device, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	devices := []*models.Device{}
+	for _, d := range r.store.devices {
+		if d.UserID == userID {
+			device := d
+			devices = append(devices, &device)
+		}
+	}
+	return devices, nil
+}
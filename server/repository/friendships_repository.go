@@ -1,8 +1,8 @@
 package repository
 
 import (
+	"context"
 	"errors"
-	"project/database"
 	"project/models"
 
 	"github.com/google/uuid"
@@ -10,57 +10,57 @@ import (
 )
 
 type FriendshipRepository interface {
-	CreateFriendship(f *models.Friendship) (*models.Friendship, error)
-	SaveFriendship(f *models.Friendship) (*models.Friendship, error)
-	GetByID(id uuid.UUID) (*models.Friendship, error)
-	GetFriendship(userID, friendID uuid.UUID) (*models.Friendship, error)
-	ListFriends(userID uuid.UUID, status string) ([]models.Friendship, error)
-	ListPendingRequests(userID uuid.UUID) ([]models.Friendship, error)
-	UpdateFriendship(f *models.Friendship) (*models.Friendship, error)
-	DeleteFriendship(id uuid.UUID) error
-	ChangeStatus(id uuid.UUID, newStatus string) error
-	AreFriends(userID, friendID uuid.UUID) (bool, error)
-	GetFriendshipBetweenUsers(userID uuid.UUID, friendID uuid.UUID) (*models.Friendship, error)
+	CreateFriendship(ctx context.Context, f *models.Friendship) (*models.Friendship, error)
+	SaveFriendship(ctx context.Context, f *models.Friendship) (*models.Friendship, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Friendship, error)
+	GetFriendship(ctx context.Context, userID, friendID uuid.UUID) (*models.Friendship, error)
+	ListFriends(ctx context.Context, userID uuid.UUID, status string) ([]models.Friendship, error)
+	ListPendingRequests(ctx context.Context, userID uuid.UUID) ([]models.Friendship, error)
+	UpdateFriendship(ctx context.Context, f *models.Friendship) (*models.Friendship, error)
+	DeleteFriendship(ctx context.Context, id uuid.UUID) error
+	ChangeStatus(ctx context.Context, id uuid.UUID, newStatus string) error
+	AreFriends(ctx context.Context, userID, friendID uuid.UUID) (bool, error)
+	GetFriendshipBetweenUsers(ctx context.Context, userID uuid.UUID, friendID uuid.UUID) (*models.Friendship, error)
 }
 
 type friendshipRepository struct {
 	db *gorm.DB
 }
 
-func NewFriendshipRepository() FriendshipRepository {
+func NewFriendshipRepository(db *gorm.DB) FriendshipRepository {
 	return &friendshipRepository{
-		db: database.DB,
+		db: db,
 	}
 }
 
-func (r *friendshipRepository) SaveFriendship(f *models.Friendship) (*models.Friendship, error) {
+func (r *friendshipRepository) SaveFriendship(ctx context.Context, f *models.Friendship) (*models.Friendship, error) {
 	if f == nil {
 		return nil, errors.New("friendship is nil")
 	}
-	if err := r.db.Unscoped().Save(f).Error; err != nil {
+	if err := r.db.WithContext(ctx).Unscoped().Save(f).Error; err != nil {
 		return nil, err
 	}
 	return nil, nil
 }
 
-func (r *friendshipRepository) ChangeStatus(id uuid.UUID, newStatus string) error {
+func (r *friendshipRepository) ChangeStatus(ctx context.Context, id uuid.UUID, newStatus string) error {
 	var f models.Friendship
-	if err := r.db.First(&f, "id = ?", id).Error; err != nil {
+	if err := r.db.WithContext(ctx).First(&f, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errors.New("friendship not found")
 		}
 		return err
 	}
 	f.Status = newStatus
-	if err := r.db.Save(&f).Error; err != nil {
+	if err := r.db.WithContext(ctx).Save(&f).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *friendshipRepository) GetFriendshipBetweenUsers(userID uuid.UUID, friendID uuid.UUID) (*models.Friendship, error) {
+func (r *friendshipRepository) GetFriendshipBetweenUsers(ctx context.Context, userID uuid.UUID, friendID uuid.UUID) (*models.Friendship, error) {
 	var f models.Friendship
-	err := r.db.Unscoped().
+	err := r.db.WithContext(ctx).Unscoped().
 		Where("(user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)", userID, friendID, friendID, userID).
 		Preload("User").
 		Preload("Friend").
@@ -75,24 +75,24 @@ func (r *friendshipRepository) GetFriendshipBetweenUsers(userID uuid.UUID, frien
 	return &f, nil
 }
 
-func (r *friendshipRepository) CreateFriendship(f *models.Friendship) (*models.Friendship, error) {
+func (r *friendshipRepository) CreateFriendship(ctx context.Context, f *models.Friendship) (*models.Friendship, error) {
 	if f == nil {
 		return nil, errors.New("friendship is nil")
 	}
 	if f.ID == uuid.Nil {
 		f.ID = uuid.New()
 	}
-	if err := r.db.Create(f).Error; err != nil {
+	if err := r.db.WithContext(ctx).Create(f).Error; err != nil {
 		return nil, err
 	}
 	// preload relations for convenience
-	_ = r.db.Preload("User").Preload("Friend").First(f, "id = ?", f.ID).Error
+	_ = r.db.WithContext(ctx).Preload("User").Preload("Friend").First(f, "id = ?", f.ID).Error
 	return f, nil
 }
 
-func (r *friendshipRepository) GetByID(id uuid.UUID) (*models.Friendship, error) {
+func (r *friendshipRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Friendship, error) {
 	var f models.Friendship
-	if err := r.db.Preload("User").Preload("Friend").First(&f, "id = ?", id).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("User").Preload("Friend").First(&f, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -101,9 +101,9 @@ func (r *friendshipRepository) GetByID(id uuid.UUID) (*models.Friendship, error)
 	return &f, nil
 }
 
-func (r *friendshipRepository) GetFriendship(userID, friendID uuid.UUID) (*models.Friendship, error) {
+func (r *friendshipRepository) GetFriendship(ctx context.Context, userID, friendID uuid.UUID) (*models.Friendship, error) {
 	var f models.Friendship
-	err := r.db.
+	err := r.db.WithContext(ctx).
 		Where("(user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)", userID, friendID, friendID, userID).
 		Preload("User").
 		Preload("Friend").
@@ -117,9 +117,9 @@ func (r *friendshipRepository) GetFriendship(userID, friendID uuid.UUID) (*model
 	return &f, nil
 }
 
-func (r *friendshipRepository) ListFriends(userID uuid.UUID, status string) ([]models.Friendship, error) {
+func (r *friendshipRepository) ListFriends(ctx context.Context, userID uuid.UUID, status string) ([]models.Friendship, error) {
 	var list []models.Friendship
-	query := r.db.Unscoped().Preload("Friend").Preload("User").Where("user_id = ? or friend_id = ?", userID, userID)
+	query := r.db.WithContext(ctx).Unscoped().Preload("Friend").Preload("User").Where("user_id = ? or friend_id = ?", userID, userID)
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
@@ -130,38 +130,38 @@ func (r *friendshipRepository) ListFriends(userID uuid.UUID, status string) ([]m
 	return list, nil
 }
 
-func (r *friendshipRepository) ListPendingRequests(userID uuid.UUID) ([]models.Friendship, error) {
+func (r *friendshipRepository) ListPendingRequests(ctx context.Context, userID uuid.UUID) ([]models.Friendship, error) {
 	var list []models.Friendship
 	// pending requests received by user (friend_id = userID and status = 'pending')
-	if err := r.db.Preload("User").Preload("Friend").
+	if err := r.db.WithContext(ctx).Preload("User").Preload("Friend").
 		Where("(friend_id = ? or user_id = ?) AND status = ? And requested_by<>?", userID, userID, "pending", userID).Find(&list).Error; err != nil {
 		return nil, err
 	}
 	return list, nil
 }
 
-func (r *friendshipRepository) UpdateFriendship(f *models.Friendship) (*models.Friendship, error) {
+func (r *friendshipRepository) UpdateFriendship(ctx context.Context, f *models.Friendship) (*models.Friendship, error) {
 	if f == nil {
 		return nil, errors.New("friendship is nil")
 	}
-	if err := r.db.Save(f).Error; err != nil {
+	if err := r.db.WithContext(ctx).Save(f).Error; err != nil {
 		return nil, err
 	}
-	_ = r.db.Preload("User").Preload("Friend").First(f, "id = ?", f.ID).Error
+	_ = r.db.WithContext(ctx).Preload("User").Preload("Friend").First(f, "id = ?", f.ID).Error
 	return f, nil
 }
 
-func (r *friendshipRepository) DeleteFriendship(id uuid.UUID) error {
-	if err := r.db.Unscoped().Delete(&models.Friendship{}, "id = ?", id).Error; err != nil {
+func (r *friendshipRepository) DeleteFriendship(ctx context.Context, id uuid.UUID) error {
+	if err := r.db.WithContext(ctx).Unscoped().Delete(&models.Friendship{}, "id = ?", id).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *friendshipRepository) AreFriends(userID, friendID uuid.UUID) (bool, error) {
+func (r *friendshipRepository) AreFriends(ctx context.Context, userID, friendID uuid.UUID) (bool, error) {
 	var count int64
-	if err := r.db.Model(&models.Friendship{}).
-		Where("((user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)) AND status = ?", userID, friendID, friendID, userID, "accepted").
+	if err := r.db.WithContext(ctx).Model(&models.Friendship{}).
+		Where("((user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)) AND status = ?", userID, friendID, friendID, userID, "friend").
 		Count(&count).Error; err != nil {
 		return false, err
 	}
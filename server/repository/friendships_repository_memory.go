@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"project/models"
+
+	"github.com/google/uuid"
+)
+
+// memoryFriendshipRepo là FriendshipRepository trong bộ nhớ, cùng hành vi với friendshipRepository
+type memoryFriendshipRepo struct {
+	store *MemoryStore
+}
+
+func NewMemoryFriendshipRepository(store *MemoryStore) FriendshipRepository {
+	return &memoryFriendshipRepo{
+		store: store,
+	}
+}
+
+var friendshipStatuses = map[string]bool{"no_friend": true, "pending": true, "friend": true, "blocked": true}
+
+// putFriendship lưu bản sao friendship, giữ unique index idx_user_friend và check constraint status
+func (s *MemoryStore) putFriendship(f *models.Friendship, create bool) error {
+	if create && f.Status == "" {
+		f.Status = "pending"
+	}
+	if !friendshipStatuses[f.Status] {
+		return fmt.Errorf("violates check constraint chk_friendships_status: %q", f.Status)
+	}
+	for id, existing := range s.friendships {
+		if id != f.ID && existing.UserID == f.UserID && existing.FriendID == f.FriendID {
+			return fmt.Errorf("duplicate key value violates unique constraint idx_user_friend")
+		}
+	}
+	row := *f
+	row.User = models.User{}
+	row.Friend = models.User{}
+	s.friendships[row.ID] = row
+	return nil
+}
+
+// loadFriendship trả về bản sao friendship kèm User/Friend giống Preload
+func (s *MemoryStore) loadFriendship(row models.Friendship) *models.Friendship {
+	f := row
+	f.User = s.user(row.UserID)
+	f.Friend = s.user(row.FriendID)
+	return &f
+}
+
+// findFriendshipBetween tìm friendship theo cả 2 chiều, lấy id nhỏ nhất giống First
+func (s *MemoryStore) findFriendshipBetween(userID, friendID uuid.UUID, unscoped bool) (models.Friendship, bool) {
+	var ids []uuid.UUID
+	for id, f := range s.friendships {
+		if f.DeletedAt.Valid && !unscoped {
+			continue
+		}
+		if (f.UserID == userID && f.FriendID == friendID) || (f.UserID == friendID && f.FriendID == userID) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return models.Friendship{}, false
+	}
+	sortByID(ids)
+	return s.friendships[ids[0]], true
+}
+
+func (r *memoryFriendshipRepo) CreateFriendship(ctx context.Context, f *models.Friendship) (*models.Friendship, error) {
+	if f == nil {
+		return nil, errors.New("friendship is nil")
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	if _, ok := r.store.friendships[f.ID]; ok {
+		return nil, fmt.Errorf("duplicate key %s", f.ID)
+	}
+	now := r.store.now()
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = now
+	}
+	if f.UpdatedAt.IsZero() {
+		f.UpdatedAt = now
+	}
+	if err := r.store.putFriendship(f, true); err != nil {
+		return nil, err
+	}
+	*f = *r.store.loadFriendship(r.store.friendships[f.ID])
+	return f, nil
+}
+
+func (r *memoryFriendshipRepo) SaveFriendship(ctx context.Context, f *models.Friendship) (*models.Friendship, error) {
+	if f == nil {
+		return nil, errors.New("friendship is nil")
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := r.save(f); err != nil {
+		return nil, err
+	}
+	// Repository thật trả về nil khi thành công
+	return nil, nil
+}
+
+// save giống Save của GORM: cập nhật mọi cột (kể cả bản ghi đã xóa mềm), chưa có thì insert
+func (r *memoryFriendshipRepo) save(f *models.Friendship) error {
+	now := r.store.now()
+	_, exists := r.store.friendships[f.ID]
+	if !exists && f.CreatedAt.IsZero() {
+		f.CreatedAt = now
+	}
+	f.UpdatedAt = now
+	return r.store.putFriendship(f, false)
+}
+
+func (r *memoryFriendshipRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Friendship, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	f, ok := r.store.friendships[id]
+	if !ok || f.DeletedAt.Valid {
+		return nil, nil
+	}
+	return r.store.loadFriendship(f), nil
+}
+
+func (r *memoryFriendshipRepo) GetFriendship(ctx context.Context, userID, friendID uuid.UUID) (*models.Friendship, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	f, ok := r.store.findFriendshipBetween(userID, friendID, false)
+	if !ok {
+		return nil, nil
+	}
+	return r.store.loadFriendship(f), nil
+}
+
+func (r *memoryFriendshipRepo) GetFriendshipBetweenUsers(ctx context.Context, userID uuid.UUID, friendID uuid.UUID) (*models.Friendship, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	f, ok := r.store.findFriendshipBetween(userID, friendID, true)
+	if !ok {
+		return nil, nil
+	}
+	return r.store.loadFriendship(f), nil
+}
+
+func (r *memoryFriendshipRepo) ListFriends(ctx context.Context, userID uuid.UUID, status string) ([]models.Friendship, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	// Unscoped giống repository thật: trả cả bản ghi đã xóa mềm
+	list := []models.Friendship{}
+	for _, f := range r.store.friendships {
+		if f.UserID != userID && f.FriendID != userID {
+			continue
+		}
+		if status != "" && f.Status != status {
+			continue
+		}
+		list = append(list, *r.store.loadFriendship(f))
+	}
+	return list, nil
+}
+
+func (r *memoryFriendshipRepo) ListPendingRequests(ctx context.Context, userID uuid.UUID) ([]models.Friendship, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	list := []models.Friendship{}
+	for _, f := range r.store.friendships {
+		if f.DeletedAt.Valid || f.Status != "pending" || f.RequestedBy == userID {
+			continue
+		}
+		if f.UserID == userID || f.FriendID == userID {
+			list = append(list, *r.store.loadFriendship(f))
+		}
+	}
+	return list, nil
+}
+
+func (r *memoryFriendshipRepo) UpdateFriendship(ctx context.Context, f *models.Friendship) (*models.Friendship, error) {
+	if f == nil {
+		return nil, errors.New("friendship is nil")
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := r.save(f); err != nil {
+		return nil, err
+	}
+	if row, ok := r.store.friendships[f.ID]; ok && !row.DeletedAt.Valid {
+		*f = *r.store.loadFriendship(row)
+	}
+	return f, nil
+}
+
+func (r *memoryFriendshipRepo) DeleteFriendship(ctx context.Context, id uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.friendships, id)
+	return nil
+}
+
+func (r *memoryFriendshipRepo) ChangeStatus(ctx context.Context, id uuid.UUID, newStatus string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	f, ok := r.store.friendships[id]
+	if !ok || f.DeletedAt.Valid {
+		return errors.New("friendship not found")
+	}
+	f.Status = newStatus
+	return r.save(&f)
+}
+
+func (r *memoryFriendshipRepo) AreFriends(ctx context.Context, userID, friendID uuid.UUID) (bool, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, f := range r.store.friendships {
+		if f.DeletedAt.Valid || f.Status != "friend" {
+			continue
+		}
+		if (f.UserID == userID && f.FriendID == friendID) || (f.UserID == friendID && f.FriendID == userID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"project/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type IdentityRepository interface {
+	GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	GetIdentitiesByUserID(ctx context.Context, userID uuid.UUID) ([]models.UserIdentity, error)
+	CreateIdentity(ctx context.Context, identity *models.UserIdentity) error
+}
+
+type identityRepo struct {
+	db *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) IdentityRepository {
+	return &identityRepo{
+		db: db,
+	}
+}
+
+// GetIdentity trả về nil, nil nếu chưa có identity
+func (r *identityRepo) GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *identityRepo) GetIdentitiesByUserID(ctx context.Context, userID uuid.UUID) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func (r *identityRepo) CreateIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
@@ -0,0 +1,36 @@
+package repository
+
+// MockIdentityRepository mô phỏng IdentityRepository (dùng cho unit test)
+import (
+	"context"
+	"project/models"
+
+	"github.com/google/uuid"
+)
+
+type MockIdentityRepository struct {
+	MockGetIdentity           func(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	MockGetIdentitiesByUserID func(ctx context.Context, userID uuid.UUID) ([]models.UserIdentity, error)
+	MockCreateIdentity        func(ctx context.Context, identity *models.UserIdentity) error
+}
+
+func (m *MockIdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	if m.MockGetIdentity != nil {
+		return m.MockGetIdentity(ctx, provider, subject)
+	}
+	return nil, nil
+}
+
+func (m *MockIdentityRepository) GetIdentitiesByUserID(ctx context.Context, userID uuid.UUID) ([]models.UserIdentity, error) {
+	if m.MockGetIdentitiesByUserID != nil {
+		return m.MockGetIdentitiesByUserID(ctx, userID)
+	}
+	return nil, nil
+}
+
+func (m *MockIdentityRepository) CreateIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	if m.MockCreateIdentity != nil {
+		return m.MockCreateIdentity(ctx, identity)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"project/models"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore là "database" trong bộ nhớ dùng chung cho các repository NewMemory*,
+// đóng vai trò như *gorm.DB truyền cho repository thật. Dùng cho test service:
+// không kiểm tra khóa ngoại, nhưng giữ đúng soft delete, unique index và giá trị default của schema.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	users         map[uuid.UUID]models.User
+	tokens        map[uuid.UUID]models.Token // device_id -> token
+	messages      map[uuid.UUID]models.Message
+	conversations map[uuid.UUID]models.Conversation
+	participants  map[uuid.UUID]models.Participant
+	friendships   map[uuid.UUID]models.Friendship
+	devices       map[uuid.UUID]models.Device
+
+	now func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:         make(map[uuid.UUID]models.User),
+		tokens:        make(map[uuid.UUID]models.Token),
+		messages:      make(map[uuid.UUID]models.Message),
+		conversations: make(map[uuid.UUID]models.Conversation),
+		participants:  make(map[uuid.UUID]models.Participant),
+		friendships:   make(map[uuid.UUID]models.Friendship),
+		devices:       make(map[uuid.UUID]models.Device),
+		now:           time.Now,
+	}
+}
+
+// AddUser thêm user để các repository preload Sender/User/Friend giống bảng users
+func (s *MemoryStore) AddUser(user models.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[user.ID] = user
+}
+
+// AddToken thêm token cho device (GetDeviceByInfo chỉ trả device đã có token)
+func (s *MemoryStore) AddToken(token models.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token.DeviceID] = token
+}
+
+// userPtr trả về bản sao user để preload, nil nếu chưa có
+func (s *MemoryStore) userPtr(id *uuid.UUID) *models.User {
+	if id == nil {
+		return nil
+	}
+	user, ok := s.users[*id]
+	if !ok {
+		return nil
+	}
+	return &user
+}
+
+// user trả về bản sao user để preload quan hệ không phải con trỏ (Participant.User, Friendship.User)
+func (s *MemoryStore) user(id uuid.UUID) models.User {
+	return s.users[id]
+}
+
+// pageBounds tính khoảng [start, end) giống LIMIT/OFFSET của GORM: limit < 0 là không giới hạn
+func pageBounds(n, limit, offset int) (int, int) {
+	start := offset
+	if start < 0 {
+		start = 0
+	}
+	if start > n {
+		start = n
+	}
+	end := n
+	if limit >= 0 && start+limit < end {
+		end = start + limit
+	}
+	return start, end
+}
+
+// sortByID sắp xếp theo khóa chính như First của GORM (ORDER BY id)
+func sortByID(ids []uuid.UUID) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+}
+
+func cloneUUID(id *uuid.UUID) *uuid.UUID {
+	if id == nil {
+		return nil
+	}
+	v := *id
+	return &v
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := *t
+	return &v
+}
@@ -1,9 +1,9 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"project/database"
 	"project/models"
 	"time"
 
@@ -12,43 +12,46 @@ import (
 )
 
 type MessageRepository interface {
-	CreateMessage(message *models.Message) (*models.Message, error)
-	GetMessageByID(messageID uuid.UUID) (*models.Message, error)
-	GetMessagesByConversationID(conversationID uuid.UUID, limit, offset int) ([]*models.Message, error)
-	GetLastMessageByConversationID(conversationID uuid.UUID) (*models.Message, error)
-	UpdateMessage(message *models.Message) (*models.Message, error)
-	DeleteMessage(messageID uuid.UUID) error
-	DeleteMessagesByConversationID(conversationID uuid.UUID) error
-	SoftDeleteMessage(messageID uuid.UUID, deletedBy uuid.UUID) error
+	CreateMessage(ctx context.Context, message *models.Message) (*models.Message, error)
+	// CreateMessageWithAttachments tạo message và gán attachment của người gửi trong cùng transaction,
+	// trả về ErrAttachmentsUnavailable nếu không gán được đủ attachment
+	CreateMessageWithAttachments(ctx context.Context, message *models.Message, attachmentIDs []uuid.UUID) (*models.Message, error)
+	GetMessageByID(ctx context.Context, messageID uuid.UUID) (*models.Message, error)
+	GetMessagesByConversationID(ctx context.Context, conversationID uuid.UUID, limit, offset int) ([]*models.Message, error)
+	GetLastMessageByConversationID(ctx context.Context, conversationID uuid.UUID) (*models.Message, error)
+	UpdateMessage(ctx context.Context, message *models.Message) (*models.Message, error)
+	DeleteMessage(ctx context.Context, messageID uuid.UUID) error
+	DeleteMessagesByConversationID(ctx context.Context, conversationID uuid.UUID) error
+	SoftDeleteMessage(ctx context.Context, messageID uuid.UUID, deletedBy uuid.UUID) error
 
 	// Search and filter
-	SearchMessages(conversationID uuid.UUID, query string, limit, offset int) ([]*models.Message, error)
-	GetMessagesByUser(userID uuid.UUID, limit, offset int) ([]*models.Message, error)
-	GetUnreadMessages(conversationID, userID uuid.UUID) ([]*models.Message, error)
+	SearchMessages(ctx context.Context, conversationID uuid.UUID, query string, limit, offset int) ([]*models.Message, error)
+	GetMessagesByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*models.Message, error)
+	GetUnreadMessages(ctx context.Context, conversationID, userID uuid.UUID) ([]*models.Message, error)
 
 	// Message stats
-	CountMessagesByConversationID(conversationID uuid.UUID) (int64, error)
-	CountUnreadMessages(conversationID, userID uuid.UUID) (int64, error)
+	CountMessagesByConversationID(ctx context.Context, conversationID uuid.UUID) (int64, error)
+	CountUnreadMessages(ctx context.Context, conversationID, userID uuid.UUID) (int64, error)
 
 	// Message reactions and status
-	UpdateMessageStatus(messageID uuid.UUID, status string) error
-	GetMessagesAfterTime(conversationID uuid.UUID, after time.Time) ([]*models.Message, error)
-	GetMessagesBeforeTime(conversationID uuid.UUID, userID uuid.UUID, before *time.Time, limit int) ([]*models.Message, error)
-	GetMessagesBetweenDates(conversationID uuid.UUID, startDate, endDate time.Time) ([]*models.Message, error)
+	UpdateMessageStatus(ctx context.Context, messageID uuid.UUID, status string) error
+	GetMessagesAfterTime(ctx context.Context, conversationID uuid.UUID, after time.Time) ([]*models.Message, error)
+	GetMessagesBeforeTime(ctx context.Context, conversationID uuid.UUID, userID uuid.UUID, before *time.Time, limit int) ([]*models.Message, error)
+	GetMessagesBetweenDates(ctx context.Context, conversationID uuid.UUID, startDate, endDate time.Time) ([]*models.Message, error)
 }
 
 type messageRepo struct {
 	db *gorm.DB
 }
 
-func NewMessageRepository() MessageRepository {
+func NewMessageRepository(db *gorm.DB) MessageRepository {
 	return &messageRepo{
-		db: database.DB,
+		db: db,
 	}
 }
 
 // CreateMessage - Tạo message mới
-func (r *messageRepo) CreateMessage(message *models.Message) (*models.Message, error) {
+func (r *messageRepo) CreateMessage(ctx context.Context, message *models.Message) (*models.Message, error) {
 	if message == nil {
 		return nil, errors.New("message is nil")
 	}
@@ -60,12 +63,56 @@ func (r *messageRepo) CreateMessage(message *models.Message) (*models.Message, e
 	message.CreatedAt = time.Now()
 	message.UpdatedAt = time.Now()
 
-	if err := r.db.Create(message).Error; err != nil {
+	if err := r.db.WithContext(ctx).Create(message).Error; err != nil {
 		return nil, fmt.Errorf("failed to create message: %w", err)
 	}
 
 	// Load relationships
-	if err := r.db.Preload("Sender").First(message, message.ID).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("Sender").First(message, message.ID).Error; err != nil {
+		return message, nil // Return message even if preload fails
+	}
+
+	return message, nil
+}
+
+// CreateMessageWithAttachments - Tạo message kèm attachment, lỗi ở bất kỳ bước nào thì rollback cả message
+func (r *messageRepo) CreateMessageWithAttachments(ctx context.Context, message *models.Message, attachmentIDs []uuid.UUID) (*models.Message, error) {
+	if message == nil {
+		return nil, errors.New("message is nil")
+	}
+	if len(attachmentIDs) > 0 && message.SenderID == nil {
+		return nil, errors.New("message with attachments needs a sender")
+	}
+
+	if message.ID == uuid.Nil {
+		message.ID = uuid.New()
+	}
+
+	message.CreatedAt = time.Now()
+	message.UpdatedAt = time.Now()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return fmt.Errorf("failed to create message: %w", err)
+		}
+		if len(attachmentIDs) == 0 {
+			return nil
+		}
+		attached, err := attachToMessage(tx, attachmentIDs, *message.SenderID, message.ConversationID, message.ID)
+		if err != nil {
+			return fmt.Errorf("failed to attach attachments: %w", err)
+		}
+		if attached != int64(len(attachmentIDs)) {
+			return ErrAttachmentsUnavailable
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Load relationships
+	if err := r.db.WithContext(ctx).Preload("Sender").First(message, message.ID).Error; err != nil {
 		return message, nil // Return message even if preload fails
 	}
 
@@ -73,11 +120,10 @@ func (r *messageRepo) CreateMessage(message *models.Message) (*models.Message, e
 }
 
 // GetMessageByID - Lấy message theo ID
-func (r *messageRepo) GetMessageByID(messageID uuid.UUID) (*models.Message, error) {
+func (r *messageRepo) GetMessageByID(ctx context.Context, messageID uuid.UUID) (*models.Message, error) {
 	var message models.Message
 
-	err := r.db.Preload("Sender").
-		Preload("Conversation").
+	err := r.db.WithContext(ctx).Preload("Sender").
 		Where("id = ?", messageID).
 		First(&message).Error
 
@@ -92,10 +138,10 @@ func (r *messageRepo) GetMessageByID(messageID uuid.UUID) (*models.Message, erro
 }
 
 // GetMessagesByConversationID - Lấy messages của conversation với pagination
-func (r *messageRepo) GetMessagesByConversationID(conversationID uuid.UUID, limit, offset int) ([]*models.Message, error) {
+func (r *messageRepo) GetMessagesByConversationID(ctx context.Context, conversationID uuid.UUID, limit, offset int) ([]*models.Message, error) {
 	var messages []*models.Message
 
-	err := r.db.Preload("Sender").
+	err := r.db.WithContext(ctx).Preload("Sender").
 		Where("conversation_id = ? AND deleted_at IS NULL", conversationID).
 		Order("created_at DESC").
 		Limit(limit).
@@ -115,10 +161,10 @@ func (r *messageRepo) GetMessagesByConversationID(conversationID uuid.UUID, limi
 }
 
 // GetLastMessageByConversationID - Lấy message cuối cùng của conversation
-func (r *messageRepo) GetLastMessageByConversationID(conversationID uuid.UUID) (*models.Message, error) {
+func (r *messageRepo) GetLastMessageByConversationID(ctx context.Context, conversationID uuid.UUID) (*models.Message, error) {
 	var message models.Message
 
-	err := r.db.Preload("Sender").
+	err := r.db.WithContext(ctx).Preload("Sender").
 		Where("conversation_id = ? AND deleted_at IS NULL", conversationID).
 		Order("created_at DESC").
 		First(&message).Error
@@ -134,19 +180,19 @@ func (r *messageRepo) GetLastMessageByConversationID(conversationID uuid.UUID) (
 }
 
 // UpdateMessage - Cập nhật message
-func (r *messageRepo) UpdateMessage(message *models.Message) (*models.Message, error) {
+func (r *messageRepo) UpdateMessage(ctx context.Context, message *models.Message) (*models.Message, error) {
 	if message == nil {
 		return nil, errors.New("message is nil")
 	}
 
 	message.UpdatedAt = time.Now()
 
-	if err := r.db.Save(message).Error; err != nil {
+	if err := r.db.WithContext(ctx).Save(message).Error; err != nil {
 		return nil, fmt.Errorf("failed to update message: %w", err)
 	}
 
 	// Load relationships
-	if err := r.db.Preload("Sender").First(message, message.ID).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("Sender").First(message, message.ID).Error; err != nil {
 		return message, nil
 	}
 
@@ -154,8 +200,8 @@ func (r *messageRepo) UpdateMessage(message *models.Message) (*models.Message, e
 }
 
 // DeleteMessage - Xóa message vĩnh viễn
-func (r *messageRepo) DeleteMessage(messageID uuid.UUID) error {
-	result := r.db.Unscoped().Delete(&models.Message{}, "id = ?", messageID)
+func (r *messageRepo) DeleteMessage(ctx context.Context, messageID uuid.UUID) error {
+	result := r.db.WithContext(ctx).Unscoped().Delete(&models.Message{}, "id = ?", messageID)
 
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete message: %w", result.Error)
@@ -168,15 +214,15 @@ func (r *messageRepo) DeleteMessage(messageID uuid.UUID) error {
 	return nil
 }
 
-// SoftDeleteMessage - Xóa message soft delete
-func (r *messageRepo) SoftDeleteMessage(messageID uuid.UUID, deletedBy uuid.UUID) error {
+// SoftDeleteMessage - Xóa message soft delete (schema chưa có cột deleted_by nên deletedBy chưa được lưu)
+func (r *messageRepo) SoftDeleteMessage(ctx context.Context, messageID uuid.UUID, deletedBy uuid.UUID) error {
 	now := time.Now()
 
-	result := r.db.Model(&models.Message{}).
+	result := r.db.WithContext(ctx).Model(&models.Message{}).
 		Where("id = ?", messageID).
 		Updates(map[string]interface{}{
 			"deleted_at": now,
-			"deleted_by": deletedBy,
+			"deleted":    true,
 			"updated_at": now,
 		})
 
@@ -192,8 +238,8 @@ func (r *messageRepo) SoftDeleteMessage(messageID uuid.UUID, deletedBy uuid.UUID
 }
 
 // DeleteMessagesByConversationID - Xóa tất cả messages của conversation
-func (r *messageRepo) DeleteMessagesByConversationID(conversationID uuid.UUID) error {
-	if err := r.db.Unscoped().Delete(&models.Message{}, "conversation_id = ?", conversationID).Error; err != nil {
+func (r *messageRepo) DeleteMessagesByConversationID(ctx context.Context, conversationID uuid.UUID) error {
+	if err := r.db.WithContext(ctx).Unscoped().Delete(&models.Message{}, "conversation_id = ?", conversationID).Error; err != nil {
 		return fmt.Errorf("failed to delete messages: %w", err)
 	}
 
@@ -201,10 +247,10 @@ func (r *messageRepo) DeleteMessagesByConversationID(conversationID uuid.UUID) e
 }
 
 // SearchMessages - Tìm kiếm messages trong conversation
-func (r *messageRepo) SearchMessages(conversationID uuid.UUID, query string, limit, offset int) ([]*models.Message, error) {
+func (r *messageRepo) SearchMessages(ctx context.Context, conversationID uuid.UUID, query string, limit, offset int) ([]*models.Message, error) {
 	var messages []*models.Message
 
-	err := r.db.Preload("Sender").
+	err := r.db.WithContext(ctx).Preload("Sender").
 		Where("conversation_id = ? AND content ILIKE ? AND deleted_at IS NULL",
 			conversationID, "%"+query+"%").
 		Order("created_at DESC").
@@ -220,11 +266,10 @@ func (r *messageRepo) SearchMessages(conversationID uuid.UUID, query string, lim
 }
 
 // GetMessagesByUser - Lấy messages của user
-func (r *messageRepo) GetMessagesByUser(userID uuid.UUID, limit, offset int) ([]*models.Message, error) {
+func (r *messageRepo) GetMessagesByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*models.Message, error) {
 	var messages []*models.Message
 
-	err := r.db.Preload("Sender").
-		Preload("Conversation").
+	err := r.db.WithContext(ctx).Preload("Sender").
 		Where("sender_id = ? AND deleted_at IS NULL", userID).
 		Order("created_at DESC").
 		Limit(limit).
@@ -238,19 +283,16 @@ func (r *messageRepo) GetMessagesByUser(userID uuid.UUID, limit, offset int) ([]
 	return messages, nil
 }
 
-// GetUnreadMessages - Lấy messages chưa đọc
-func (r *messageRepo) GetUnreadMessages(conversationID, userID uuid.UUID) ([]*models.Message, error) {
+// GetUnreadMessages - Lấy messages chưa đọc (của người khác, sau last_read_at của participant)
+func (r *messageRepo) GetUnreadMessages(ctx context.Context, conversationID, userID uuid.UUID) ([]*models.Message, error) {
 	var messages []*models.Message
 
-	// Subquery để lấy last_read_message_id từ participants
-	subQuery := r.db.Model(&models.Participant{}).
-		Select("last_read_message_id").
-		Where("conversation_id = ? AND user_id = ?", conversationID, userID)
+	query, err := r.unreadQuery(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
 
-	err := r.db.Preload("Sender").
-		Where("conversation_id = ? AND sender_id != ? AND deleted_at IS NULL", conversationID, userID).
-		Where("id NOT IN (?) OR created_at > (SELECT created_at FROM messages WHERE id = (?))",
-			subQuery, subQuery).
+	err = query.Preload("Sender").
 		Order("created_at ASC").
 		Find(&messages).Error
 
@@ -261,11 +303,28 @@ func (r *messageRepo) GetUnreadMessages(conversationID, userID uuid.UUID) ([]*mo
 	return messages, nil
 }
 
+// unreadQuery - Điều kiện chung của message chưa đọc. User chưa tham gia thì mọi message của người khác đều chưa đọc.
+func (r *messageRepo) unreadQuery(ctx context.Context, conversationID, userID uuid.UUID) (*gorm.DB, error) {
+	var participant models.Participant
+	err := r.db.WithContext(ctx).Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		First(&participant).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get participant: %w", err)
+	}
+
+	query := r.db.WithContext(ctx).Model(&models.Message{}).
+		Where("conversation_id = ? AND sender_id != ? AND deleted_at IS NULL", conversationID, userID)
+	if participant.LastReadAt != nil {
+		query = query.Where("created_at > ?", *participant.LastReadAt)
+	}
+	return query, nil
+}
+
 // CountMessagesByConversationID - Đếm số messages trong conversation
-func (r *messageRepo) CountMessagesByConversationID(conversationID uuid.UUID) (int64, error) {
+func (r *messageRepo) CountMessagesByConversationID(ctx context.Context, conversationID uuid.UUID) (int64, error) {
 	var count int64
 
-	err := r.db.Model(&models.Message{}).
+	err := r.db.WithContext(ctx).Model(&models.Message{}).
 		Where("conversation_id = ? AND deleted_at IS NULL", conversationID).
 		Count(&count).Error
 
@@ -277,45 +336,15 @@ func (r *messageRepo) CountMessagesByConversationID(conversationID uuid.UUID) (i
 }
 
 // CountUnreadMessages - Đếm số messages chưa đọc
-func (r *messageRepo) CountUnreadMessages(conversationID, userID uuid.UUID) (int64, error) {
+func (r *messageRepo) CountUnreadMessages(ctx context.Context, conversationID, userID uuid.UUID) (int64, error) {
 	var count int64
 
-	// Get participant's last read message
-	var participant models.Participant
-	err := r.db.Where("conversation_id = ? AND user_id = ?", conversationID, userID).
-		First(&participant).Error
-
+	query, err := r.unreadQuery(ctx, conversationID, userID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// If no participant record, count all messages
-			return r.CountMessagesByConversationID(conversationID)
-		}
-		return 0, fmt.Errorf("failed to get participant: %w", err)
-	}
-
-	// Try to read last_read_message_id column directly in case the Participant struct doesn't include it
-	var lastReadMessageID *uuid.UUID
-	_ = r.db.Model(&models.Participant{}).
-		Select("last_read_message_id").
-		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
-		Scan(&lastReadMessageID).Error
-
-	query := r.db.Model(&models.Message{}).
-		Where("conversation_id = ? AND sender_id != ? AND deleted_at IS NULL", conversationID, userID)
-
-	if lastReadMessageID != nil {
-		// Count messages after last read message
-		var lastReadTime time.Time
-		r.db.Model(&models.Message{}).
-			Select("created_at").
-			Where("id = ?", *lastReadMessageID).
-			Scan(&lastReadTime)
-
-		query = query.Where("created_at > ?", lastReadTime)
+		return 0, err
 	}
 
-	err = query.Count(&count).Error
-	if err != nil {
+	if err := query.Count(&count).Error; err != nil {
 		return 0, fmt.Errorf("failed to count unread messages: %w", err)
 	}
 
@@ -323,8 +352,8 @@ func (r *messageRepo) CountUnreadMessages(conversationID, userID uuid.UUID) (int
 }
 
 // UpdateMessageStatus - Cập nhật status của message
-func (r *messageRepo) UpdateMessageStatus(messageID uuid.UUID, status string) error {
-	result := r.db.Model(&models.Message{}).
+func (r *messageRepo) UpdateMessageStatus(ctx context.Context, messageID uuid.UUID, status string) error {
+	result := r.db.WithContext(ctx).Model(&models.Message{}).
 		Where("id = ?", messageID).
 		Update("status", status)
 
@@ -340,10 +369,10 @@ func (r *messageRepo) UpdateMessageStatus(messageID uuid.UUID, status string) er
 }
 
 // GetMessagesAfterTime - Lấy messages sau thời điểm cụ thể
-func (r *messageRepo) GetMessagesAfterTime(conversationID uuid.UUID, after time.Time) ([]*models.Message, error) {
+func (r *messageRepo) GetMessagesAfterTime(ctx context.Context, conversationID uuid.UUID, after time.Time) ([]*models.Message, error) {
 	var messages []*models.Message
 
-	err := r.db.Preload("Sender").
+	err := r.db.WithContext(ctx).Preload("Sender").
 		Where("conversation_id = ? AND created_at > ? AND deleted_at IS NULL", conversationID, after).
 		Order("created_at ASC").
 		Find(&messages).Error
@@ -355,14 +384,14 @@ func (r *messageRepo) GetMessagesAfterTime(conversationID uuid.UUID, after time.
 	return messages, nil
 }
 
-func (r *messageRepo) GetMessagesBeforeTime(conversationID uuid.UUID, userID uuid.UUID, before *time.Time, limit int) ([]*models.Message, error) {
+func (r *messageRepo) GetMessagesBeforeTime(ctx context.Context, conversationID uuid.UUID, userID uuid.UUID, before *time.Time, limit int) ([]*models.Message, error) {
 	var messages []*models.Message
 
 	if limit <= 0 {
 		limit = 50
 	}
 
-	query := r.db.Table("messages").
+	query := r.db.WithContext(ctx).Table("messages").
 		Select("messages.*").
 		Joins("JOIN conversations ON conversations.id = messages.conversation_id").
 		Joins("JOIN participants ON participants.conversation_id = conversations.id").
@@ -375,6 +404,7 @@ func (r *messageRepo) GetMessagesBeforeTime(conversationID uuid.UUID, userID uui
 
 	err := query.
 		Preload("Sender").
+		Preload("Attachments.Renditions").
 		Order("messages.created_at DESC").
 		Limit(limit).
 		Find(&messages).Error
@@ -387,10 +417,10 @@ func (r *messageRepo) GetMessagesBeforeTime(conversationID uuid.UUID, userID uui
 }
 
 // GetMessagesBetweenDates - Lấy messages trong khoảng thời gian
-func (r *messageRepo) GetMessagesBetweenDates(conversationID uuid.UUID, startDate, endDate time.Time) ([]*models.Message, error) {
+func (r *messageRepo) GetMessagesBetweenDates(ctx context.Context, conversationID uuid.UUID, startDate, endDate time.Time) ([]*models.Message, error) {
 	var messages []*models.Message
 
-	err := r.db.Preload("Sender").
+	err := r.db.WithContext(ctx).Preload("Sender").
 		Where("conversation_id = ? AND created_at BETWEEN ? AND ? AND deleted_at IS NULL",
 			conversationID, startDate, endDate).
 		Order("created_at ASC").
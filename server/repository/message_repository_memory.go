@@ -0,0 +1,335 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"project/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memoryMessageRepo là MessageRepository trong bộ nhớ, cùng hành vi với messageRepo
+type memoryMessageRepo struct {
+	store *MemoryStore
+}
+
+func NewMemoryMessageRepository(store *MemoryStore) MessageRepository {
+	return &memoryMessageRepo{
+		store: store,
+	}
+}
+
+var (
+	messageTypes    = map[string]bool{"text": true, "image": true, "file": true, "video": true, "voice": true, "system": true}
+	messageStatuses = map[string]bool{"sent": true, "delivered": true, "read": true}
+)
+
+// putMessage lưu bản sao message (bỏ quan hệ) và kiểm tra check constraint của bảng messages.
+// Default của cột chỉ áp dụng khi insert, Save ghi cả giá trị rỗng giống GORM.
+func (s *MemoryStore) putMessage(message *models.Message, create bool) error {
+	if create && message.Type == "" {
+		message.Type = "text"
+	}
+	if create && message.Status == "" {
+		message.Status = "sent"
+	}
+	if !messageTypes[message.Type] {
+		return fmt.Errorf("violates check constraint chk_messages_type: %q", message.Type)
+	}
+	if !messageStatuses[message.Status] {
+		return fmt.Errorf("violates check constraint chk_messages_status: %q", message.Status)
+	}
+	row := *message
+	row.SenderID = cloneUUID(message.SenderID)
+	row.ReplyToID = cloneUUID(message.ReplyToID)
+	row.Sender = nil
+	row.Attachments = nil
+	s.messages[row.ID] = row
+	return nil
+}
+
+// loadMessage trả về bản sao message kèm Sender giống Preload("Sender")
+func (s *MemoryStore) loadMessage(row models.Message) *models.Message {
+	message := row
+	message.SenderID = cloneUUID(row.SenderID)
+	message.ReplyToID = cloneUUID(row.ReplyToID)
+	message.Sender = s.userPtr(row.SenderID)
+	return &message
+}
+
+// findMessages lọc message chưa bị xóa và sắp xếp theo created_at
+func (s *MemoryStore) findMessages(match func(m models.Message) bool, desc bool) []*models.Message {
+	var rows []models.Message
+	for _, m := range s.messages {
+		if m.DeletedAt.Valid || !match(m) {
+			continue
+		}
+		rows = append(rows, m)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if desc {
+			return rows[i].CreatedAt.After(rows[j].CreatedAt)
+		}
+		return rows[i].CreatedAt.Before(rows[j].CreatedAt)
+	})
+	messages := make([]*models.Message, 0, len(rows))
+	for _, m := range rows {
+		messages = append(messages, s.loadMessage(m))
+	}
+	return messages
+}
+
+func (r *memoryMessageRepo) CreateMessage(ctx context.Context, message *models.Message) (*models.Message, error) {
+	if message == nil {
+		return nil, errors.New("message is nil")
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if message.ID == uuid.Nil {
+		message.ID = uuid.New()
+	}
+	if _, ok := r.store.messages[message.ID]; ok {
+		return nil, fmt.Errorf("failed to create message: duplicate key %s", message.ID)
+	}
+	message.CreatedAt = r.store.now()
+	message.UpdatedAt = message.CreatedAt
+	if err := r.store.putMessage(message, true); err != nil {
+		return nil, fmt.Errorf("failed to create message: %w", err)
+	}
+	message.Sender = r.store.userPtr(message.SenderID)
+	return message, nil
+}
+
+// CreateMessageWithAttachments: MemoryStore không lưu attachment nên message kèm attachment luôn bị từ chối
+func (r *memoryMessageRepo) CreateMessageWithAttachments(ctx context.Context, message *models.Message, attachmentIDs []uuid.UUID) (*models.Message, error) {
+	if len(attachmentIDs) > 0 {
+		return nil, ErrAttachmentsUnavailable
+	}
+	return r.CreateMessage(ctx, message)
+}
+
+func (r *memoryMessageRepo) GetMessageByID(ctx context.Context, messageID uuid.UUID) (*models.Message, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	m, ok := r.store.messages[messageID]
+	if !ok || m.DeletedAt.Valid {
+		return nil, fmt.Errorf("message not found")
+	}
+	return r.store.loadMessage(m), nil
+}
+
+func (r *memoryMessageRepo) GetMessagesByConversationID(ctx context.Context, conversationID uuid.UUID, limit, offset int) ([]*models.Message, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	messages := r.store.findMessages(func(m models.Message) bool {
+		return m.ConversationID == conversationID
+	}, true)
+	start, end := pageBounds(len(messages), limit, offset)
+	messages = messages[start:end]
+
+	// Trả về theo thứ tự tăng dần thời gian giống repository thật
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+func (r *memoryMessageRepo) GetLastMessageByConversationID(ctx context.Context, conversationID uuid.UUID) (*models.Message, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	messages := r.store.findMessages(func(m models.Message) bool {
+		return m.ConversationID == conversationID
+	}, true)
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	return messages[0], nil
+}
+
+func (r *memoryMessageRepo) UpdateMessage(ctx context.Context, message *models.Message) (*models.Message, error) {
+	if message == nil {
+		return nil, errors.New("message is nil")
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// Save của GORM: cập nhật mọi cột, chưa có thì insert
+	message.UpdatedAt = r.store.now()
+	if err := r.store.putMessage(message, false); err != nil {
+		return nil, fmt.Errorf("failed to update message: %w", err)
+	}
+	message.Sender = r.store.userPtr(message.SenderID)
+	return message, nil
+}
+
+func (r *memoryMessageRepo) DeleteMessage(ctx context.Context, messageID uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.messages[messageID]; !ok {
+		return fmt.Errorf("message not found")
+	}
+	delete(r.store.messages, messageID)
+	return nil
+}
+
+func (r *memoryMessageRepo) DeleteMessagesByConversationID(ctx context.Context, conversationID uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, m := range r.store.messages {
+		if m.ConversationID == conversationID {
+			delete(r.store.messages, id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryMessageRepo) SoftDeleteMessage(ctx context.Context, messageID uuid.UUID, deletedBy uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	m, ok := r.store.messages[messageID]
+	if !ok || m.DeletedAt.Valid {
+		return fmt.Errorf("message not found")
+	}
+	now := r.store.now()
+	m.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	m.Deleted = true
+	m.UpdatedAt = now
+	r.store.messages[messageID] = m
+	return nil
+}
+
+func (r *memoryMessageRepo) SearchMessages(ctx context.Context, conversationID uuid.UUID, query string, limit, offset int) ([]*models.Message, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	// ILIKE '%query%'
+	query = strings.ToLower(query)
+	messages := r.store.findMessages(func(m models.Message) bool {
+		return m.ConversationID == conversationID && strings.Contains(strings.ToLower(m.Content), query)
+	}, true)
+	start, end := pageBounds(len(messages), limit, offset)
+	return messages[start:end], nil
+}
+
+func (r *memoryMessageRepo) GetMessagesByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*models.Message, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	messages := r.store.findMessages(func(m models.Message) bool {
+		return m.SenderID != nil && *m.SenderID == userID
+	}, true)
+	start, end := pageBounds(len(messages), limit, offset)
+	return messages[start:end], nil
+}
+
+// unreadMatch giống unreadQuery: message của người khác (sender_id NULL không tính) sau last_read_at
+func (s *MemoryStore) unreadMatch(conversationID, userID uuid.UUID) func(m models.Message) bool {
+	var lastReadAt *time.Time
+	if p, ok := s.findParticipant(conversationID, userID); ok {
+		lastReadAt = p.LastReadAt
+	}
+	return func(m models.Message) bool {
+		if m.ConversationID != conversationID || m.SenderID == nil || *m.SenderID == userID {
+			return false
+		}
+		return lastReadAt == nil || m.CreatedAt.After(*lastReadAt)
+	}
+}
+
+func (r *memoryMessageRepo) GetUnreadMessages(ctx context.Context, conversationID, userID uuid.UUID) ([]*models.Message, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.store.findMessages(r.store.unreadMatch(conversationID, userID), false), nil
+}
+
+func (r *memoryMessageRepo) CountMessagesByConversationID(ctx context.Context, conversationID uuid.UUID) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	messages := r.store.findMessages(func(m models.Message) bool {
+		return m.ConversationID == conversationID
+	}, false)
+	return int64(len(messages)), nil
+}
+
+func (r *memoryMessageRepo) CountUnreadMessages(ctx context.Context, conversationID, userID uuid.UUID) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return int64(len(r.store.findMessages(r.store.unreadMatch(conversationID, userID), false))), nil
+}
+
+func (r *memoryMessageRepo) UpdateMessageStatus(ctx context.Context, messageID uuid.UUID, status string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	m, ok := r.store.messages[messageID]
+	if !ok || m.DeletedAt.Valid {
+		return fmt.Errorf("message not found")
+	}
+	m.Status = status
+	m.UpdatedAt = r.store.now()
+	if err := r.store.putMessage(&m, false); err != nil {
+		return fmt.Errorf("failed to update message status: %w", err)
+	}
+	return nil
+}
+
+func (r *memoryMessageRepo) GetMessagesAfterTime(ctx context.Context, conversationID uuid.UUID, after time.Time) ([]*models.Message, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.store.findMessages(func(m models.Message) bool {
+		return m.ConversationID == conversationID && m.CreatedAt.After(after)
+	}, false), nil
+}
+
+func (r *memoryMessageRepo) GetMessagesBeforeTime(ctx context.Context, conversationID uuid.UUID, userID uuid.UUID, before *time.Time, limit int) ([]*models.Message, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	if limit <= 0 {
+		limit = 50
+	}
+	// JOIN participants không lọc participant đã rời (giống query thật)
+	member := false
+	for _, p := range r.store.participants {
+		if p.ConversationID == conversationID && p.UserID == userID {
+			member = true
+			break
+		}
+	}
+	if !member {
+		return []*models.Message{}, nil
+	}
+
+	messages := r.store.findMessages(func(m models.Message) bool {
+		return m.ConversationID == conversationID && (before == nil || m.CreatedAt.Before(*before))
+	}, true)
+	start, end := pageBounds(len(messages), limit, 0)
+	return messages[start:end], nil
+}
+
+func (r *memoryMessageRepo) GetMessagesBetweenDates(ctx context.Context, conversationID uuid.UUID, startDate, endDate time.Time) ([]*models.Message, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	// BETWEEN lấy cả 2 đầu mút
+	return r.store.findMessages(func(m models.Message) bool {
+		return m.ConversationID == conversationID && !m.CreatedAt.Before(startDate) && !m.CreatedAt.After(endDate)
+	}, false), nil
+}
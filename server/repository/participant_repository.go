@@ -1,9 +1,9 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"project/database"
 	"project/models"
 	"time"
 
@@ -12,28 +12,28 @@ import (
 )
 
 type ParticipantRepository interface {
-	CreateParticipant(participant *models.Participant) error
-	GetParticipant(conversationID, userID uuid.UUID) (*models.Participant, error)
-	GetParticipantsByConversationID(conversationID uuid.UUID) (*[]models.Participant, error)
-	GetParticipantsByUserID(userID uuid.UUID) ([]*models.Participant, error)
-	UpdateParticipant(participant *models.Participant) error
-	DeleteParticipant(conversationID, userID uuid.UUID) error
-	DeleteParticipantsByConversationID(conversationID uuid.UUID) error
-	IsParticipant(conversationID, userID uuid.UUID) (bool, error)
+	CreateParticipant(ctx context.Context, participant *models.Participant) error
+	GetParticipant(ctx context.Context, conversationID, userID uuid.UUID) (*models.Participant, error)
+	GetParticipantsByConversationID(ctx context.Context, conversationID uuid.UUID) (*[]models.Participant, error)
+	GetParticipantsByUserID(ctx context.Context, userID uuid.UUID) ([]*models.Participant, error)
+	UpdateParticipant(ctx context.Context, participant *models.Participant) error
+	DeleteParticipant(ctx context.Context, conversationID, userID uuid.UUID) error
+	DeleteParticipantsByConversationID(ctx context.Context, conversationID uuid.UUID) error
+	IsParticipant(ctx context.Context, conversationID, userID uuid.UUID) (bool, error)
 }
 
 type participantRepo struct {
 	db *gorm.DB
 }
 
-func NewParticipantRepository() ParticipantRepository {
+func NewParticipantRepository(db *gorm.DB) ParticipantRepository {
 	return &participantRepo{
-		db: database.DB,
+		db: db,
 	}
 }
 
 // CreateParticipant - Tạo participant mới
-func (r *participantRepo) CreateParticipant(participant *models.Participant) error {
+func (r *participantRepo) CreateParticipant(ctx context.Context, participant *models.Participant) error {
 	if participant == nil {
 		return errors.New("participant is nil")
 	}
@@ -44,7 +44,7 @@ func (r *participantRepo) CreateParticipant(participant *models.Participant) err
 
 	participant.JoinedAt = time.Now()
 
-	if err := r.db.Create(participant).Error; err != nil {
+	if err := r.db.WithContext(ctx).Create(participant).Error; err != nil {
 		return fmt.Errorf("failed to create participant: %w", err)
 	}
 
@@ -52,10 +52,10 @@ func (r *participantRepo) CreateParticipant(participant *models.Participant) err
 }
 
 // GetParticipant - Lấy participant theo conversation ID và user ID
-func (r *participantRepo) GetParticipant(conversationID, userID uuid.UUID) (*models.Participant, error) {
+func (r *participantRepo) GetParticipant(ctx context.Context, conversationID, userID uuid.UUID) (*models.Participant, error) {
 	var participant models.Participant
 
-	err := r.db.Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+	err := r.db.WithContext(ctx).Where("conversation_id = ? AND user_id = ?", conversationID, userID).
 		First(&participant).Error
 
 	if err != nil {
@@ -69,10 +69,10 @@ func (r *participantRepo) GetParticipant(conversationID, userID uuid.UUID) (*mod
 }
 
 // GetParticipantsByConversationID - Lấy tất cả participants của conversation
-func (r *participantRepo) GetParticipantsByConversationID(conversationID uuid.UUID) (*[]models.Participant, error) {
+func (r *participantRepo) GetParticipantsByConversationID(ctx context.Context, conversationID uuid.UUID) (*[]models.Participant, error) {
 	var participants *[]models.Participant
 
-	err := r.db.Preload("User").
+	err := r.db.WithContext(ctx).Preload("User").
 		Where("conversation_id = ? ", conversationID).
 		Order("joined_at ASC").
 		Find(&participants).Error
@@ -85,11 +85,11 @@ func (r *participantRepo) GetParticipantsByConversationID(conversationID uuid.UU
 }
 
 // GetParticipantsByUserID - Lấy tất cả participants của user
-func (r *participantRepo) GetParticipantsByUserID(userID uuid.UUID) ([]*models.Participant, error) {
+func (r *participantRepo) GetParticipantsByUserID(ctx context.Context, userID uuid.UUID) ([]*models.Participant, error) {
 	var participants []*models.Participant
 
-	err := r.db.Preload("Conversation").
-		Where("user_id = ? AND left_at IS NULL", userID).
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
 		Order("joined_at DESC").
 		Find(&participants).Error
 
@@ -101,12 +101,12 @@ func (r *participantRepo) GetParticipantsByUserID(userID uuid.UUID) ([]*models.P
 }
 
 // UpdateParticipant - Cập nhật participant
-func (r *participantRepo) UpdateParticipant(participant *models.Participant) error {
+func (r *participantRepo) UpdateParticipant(ctx context.Context, participant *models.Participant) error {
 	if participant == nil {
 		return errors.New("participant is nil")
 	}
 
-	if err := r.db.Save(participant).Error; err != nil {
+	if err := r.db.WithContext(ctx).Save(participant).Error; err != nil {
 		return fmt.Errorf("failed to update participant: %w", err)
 	}
 
@@ -114,8 +114,8 @@ func (r *participantRepo) UpdateParticipant(participant *models.Participant) err
 }
 
 // DeleteParticipant - Xóa participant khỏi conversation
-func (r *participantRepo) DeleteParticipant(conversationID, userID uuid.UUID) error {
-	result := r.db.Delete(&models.Participant{},
+func (r *participantRepo) DeleteParticipant(ctx context.Context, conversationID, userID uuid.UUID) error {
+	result := r.db.WithContext(ctx).Delete(&models.Participant{},
 		"conversation_id = ? AND user_id = ?", conversationID, userID)
 
 	if result.Error != nil {
@@ -130,8 +130,8 @@ func (r *participantRepo) DeleteParticipant(conversationID, userID uuid.UUID) er
 }
 
 // DeleteParticipantsByConversationID - Xóa tất cả participants của conversation
-func (r *participantRepo) DeleteParticipantsByConversationID(conversationID uuid.UUID) error {
-	if err := r.db.Delete(&models.Participant{}, "conversation_id = ?", conversationID).Error; err != nil {
+func (r *participantRepo) DeleteParticipantsByConversationID(ctx context.Context, conversationID uuid.UUID) error {
+	if err := r.db.WithContext(ctx).Delete(&models.Participant{}, "conversation_id = ?", conversationID).Error; err != nil {
 		return fmt.Errorf("failed to delete participants: %w", err)
 	}
 
@@ -139,11 +139,11 @@ func (r *participantRepo) DeleteParticipantsByConversationID(conversationID uuid
 }
 
 // IsParticipant - Kiểm tra user có phải participant không
-func (r *participantRepo) IsParticipant(conversationID, userID uuid.UUID) (bool, error) {
+func (r *participantRepo) IsParticipant(ctx context.Context, conversationID, userID uuid.UUID) (bool, error) {
 	var count int64
 
-	err := r.db.Model(&models.Participant{}).
-		Where("conversation_id = ? AND user_id = ? AND left_at IS NULL", conversationID, userID).
+	err := r.db.WithContext(ctx).Model(&models.Participant{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
 		Count(&count).Error
 
 	if err != nil {
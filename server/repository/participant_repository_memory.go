@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"project/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memoryParticipantRepo là ParticipantRepository trong bộ nhớ, cùng hành vi với participantRepo
+type memoryParticipantRepo struct {
+	store *MemoryStore
+}
+
+func NewMemoryParticipantRepository(store *MemoryStore) ParticipantRepository {
+	return &memoryParticipantRepo{
+		store: store,
+	}
+}
+
+// putParticipant lưu bản sao participant, giữ unique index idx_conversation_user (tính cả participant đã rời)
+func (s *MemoryStore) putParticipant(participant *models.Participant, create bool) error {
+	for id, p := range s.participants {
+		if id != participant.ID && p.UserID == participant.UserID && p.ConversationID == participant.ConversationID {
+			return fmt.Errorf("duplicate key value violates unique constraint idx_conversation_user")
+		}
+	}
+	if create && participant.Role == "" {
+		participant.Role = "member"
+	}
+	row := *participant
+	row.LastReadAt = cloneTime(participant.LastReadAt)
+	row.User = models.User{}
+	s.participants[row.ID] = row
+	return nil
+}
+
+// loadParticipant trả về bản sao participant kèm User giống Preload("User")
+func (s *MemoryStore) loadParticipant(row models.Participant) *models.Participant {
+	participant := row
+	participant.LastReadAt = cloneTime(row.LastReadAt)
+	participant.User = s.user(row.UserID)
+	return &participant
+}
+
+// findParticipant tìm participant chưa rời của user trong conversation
+func (s *MemoryStore) findParticipant(conversationID, userID uuid.UUID) (models.Participant, bool) {
+	for _, p := range s.participants {
+		if !p.DeletedAt.Valid && p.ConversationID == conversationID && p.UserID == userID {
+			return p, true
+		}
+	}
+	return models.Participant{}, false
+}
+
+// findParticipants lọc participant chưa rời, sắp xếp theo joined_at
+func (s *MemoryStore) findParticipants(match func(p models.Participant) bool, desc bool) []*models.Participant {
+	var rows []models.Participant
+	for _, p := range s.participants {
+		if p.DeletedAt.Valid || !match(p) {
+			continue
+		}
+		rows = append(rows, p)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if desc {
+			return rows[i].JoinedAt.After(rows[j].JoinedAt)
+		}
+		return rows[i].JoinedAt.Before(rows[j].JoinedAt)
+	})
+	participants := make([]*models.Participant, 0, len(rows))
+	for _, p := range rows {
+		participants = append(participants, s.loadParticipant(p))
+	}
+	return participants
+}
+
+func (r *memoryParticipantRepo) CreateParticipant(ctx context.Context, participant *models.Participant) error {
+	if participant == nil {
+		return errors.New("participant is nil")
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if participant.ID == uuid.Nil {
+		participant.ID = uuid.New()
+	}
+	if _, ok := r.store.participants[participant.ID]; ok {
+		return fmt.Errorf("failed to create participant: duplicate key %s", participant.ID)
+	}
+	participant.JoinedAt = r.store.now()
+	if err := r.store.putParticipant(participant, true); err != nil {
+		return fmt.Errorf("failed to create participant: %w", err)
+	}
+	return nil
+}
+
+func (r *memoryParticipantRepo) GetParticipant(ctx context.Context, conversationID, userID uuid.UUID) (*models.Participant, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	p, ok := r.store.findParticipant(conversationID, userID)
+	if !ok {
+		return nil, fmt.Errorf("participant not found")
+	}
+	participant := p
+	participant.LastReadAt = cloneTime(p.LastReadAt)
+	return &participant, nil
+}
+
+func (r *memoryParticipantRepo) GetParticipantsByConversationID(ctx context.Context, conversationID uuid.UUID) (*[]models.Participant, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	found := r.store.findParticipants(func(p models.Participant) bool {
+		return p.ConversationID == conversationID
+	}, false)
+	participants := make([]models.Participant, 0, len(found))
+	for _, p := range found {
+		participants = append(participants, *p)
+	}
+	return &participants, nil
+}
+
+func (r *memoryParticipantRepo) GetParticipantsByUserID(ctx context.Context, userID uuid.UUID) ([]*models.Participant, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	participants := r.store.findParticipants(func(p models.Participant) bool {
+		return p.UserID == userID
+	}, true)
+	// Repository thật không preload User ở query này
+	for _, p := range participants {
+		p.User = models.User{}
+	}
+	return participants, nil
+}
+
+func (r *memoryParticipantRepo) UpdateParticipant(ctx context.Context, participant *models.Participant) error {
+	if participant == nil {
+		return errors.New("participant is nil")
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := r.store.putParticipant(participant, false); err != nil {
+		return fmt.Errorf("failed to update participant: %w", err)
+	}
+	return nil
+}
+
+func (r *memoryParticipantRepo) DeleteParticipant(ctx context.Context, conversationID, userID uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	p, ok := r.store.findParticipant(conversationID, userID)
+	if !ok {
+		return fmt.Errorf("participant not found")
+	}
+	p.DeletedAt = gorm.DeletedAt{Time: r.store.now(), Valid: true}
+	r.store.participants[p.ID] = p
+	return nil
+}
+
+func (r *memoryParticipantRepo) DeleteParticipantsByConversationID(ctx context.Context, conversationID uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := r.store.now()
+	for id, p := range r.store.participants {
+		if p.ConversationID == conversationID && !p.DeletedAt.Valid {
+			p.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+			r.store.participants[id] = p
+		}
+	}
+	return nil
+}
+
+func (r *memoryParticipantRepo) IsParticipant(ctx context.Context, conversationID, userID uuid.UUID) (bool, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	_, ok := r.store.findParticipant(conversationID, userID)
+	return ok, nil
+}
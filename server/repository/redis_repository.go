@@ -1,31 +1,47 @@
 package repository
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"project/database"
+	"log/slog"
 	"project/models"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
 type RedisRepository interface {
-	SetToken(token string, user *models.User, ttl time.Duration) error
-	GetUserByToken(token string) (*models.User, error)
-	DeleteToken(token string) error
-	SetTokenParticipant(token string, participants *[]models.Participant, ttl time.Duration) error
-	GetParticipantByToken(token string) (*[]models.Participant, error)
-	UpdateUserOnline(user_id string) error
-	IsUserOnline(user_id string) (bool, time.Duration, error)
+	SetToken(ctx context.Context, token string, user *models.User, ttl time.Duration) error
+	GetUserByToken(ctx context.Context, token string) (*models.User, error)
+	DeleteToken(ctx context.Context, token string) error
+	SetTokenParticipant(ctx context.Context, token string, participants *[]models.Participant, ttl time.Duration) error
+	GetParticipantByToken(ctx context.Context, token string) (*[]models.Participant, error)
+	UpdateUserOnline(ctx context.Context, user_id string) error
+	IsUserOnline(ctx context.Context, user_id string) (bool, time.Duration, error)
 	CreateKeyConversationTwoUserID(userID1, userID2 string) string
-	SetKeyConversationTwoUserID(userID1, userID2 string, conversation *models.Conversation, ttl time.Duration) error
-	GetConversationIDByTwoUserID(userID1, userID2 string) (*models.Conversation, error)
+	SetKeyConversationTwoUserID(ctx context.Context, userID1, userID2 string, conversation *models.Conversation, ttl time.Duration) error
+	GetConversationIDByTwoUserID(ctx context.Context, userID1, userID2 string) (*models.Conversation, error)
+	AcquireThrottle(ctx context.Context, key string, ttl time.Duration) (bool, time.Duration, error)
+	SetThrottle(ctx context.Context, key string, ttl time.Duration) error
+	GetThrottleTTL(ctx context.Context, key string) (time.Duration, error)
+	IncrementCounter(ctx context.Context, key string, window time.Duration) (int64, error)
+	ResetCounter(ctx context.Context, key string) error
+	SaveOAuthState(ctx context.Context, state string, data []byte, ttl time.Duration) error
+	ConsumeOAuthState(ctx context.Context, state string) ([]byte, error)
+	SaveMagicLink(ctx context.Context, jti string, userID string, ttl time.Duration) error
+	ConsumeMagicLink(ctx context.Context, jti string) (string, error)
 }
 
-type redisRepo struct{}
+type redisRepo struct {
+	rdb *redis.Client
+}
 
-func NewRedisRepository() RedisRepository {
-	return &redisRepo{}
+func NewRedisRepository(rdb *redis.Client) RedisRepository {
+	return &redisRepo{
+		rdb: rdb,
+	}
 }
 
 func (r *redisRepo) CreateKeyConversationTwoUserID(userID1, userID2 string) string {
@@ -36,11 +52,10 @@ func (r *redisRepo) CreateKeyConversationTwoUserID(userID1, userID2 string) stri
 		key = fmt.Sprintf("conversation:%s:%s", userID2, userID1)
 	}
 
-	log.Printf("🔑 [Redis] CreateKey: %s + %s → %s", userID1, userID2, key)
 	return key
 }
 
-func (r *redisRepo) SetKeyConversationTwoUserID(userID1, userID2 string, conversation *models.Conversation, ttl time.Duration) error {
+func (r *redisRepo) SetKeyConversationTwoUserID(ctx context.Context, userID1, userID2 string, conversation *models.Conversation, ttl time.Duration) error {
 	if ttl == 0 {
 		ttl = time.Hour
 	}
@@ -50,61 +65,60 @@ func (r *redisRepo) SetKeyConversationTwoUserID(userID1, userID2 string, convers
 	// Serialize conversation
 	data, err := json.Marshal(conversation)
 	if err != nil {
-		log.Printf("❌ [Redis.SetConversation] Marshal error: %v", err)
+		slog.ErrorContext(ctx, "marshal cached conversation failed", "key", key, "err", err)
 		return fmt.Errorf("failed to marshal conversation: %w", err)
 	}
 
-	log.Printf("💾 [Redis.SetConversation] Key: %s, TTL: %v, ConvoID: %s", key, ttl, conversation.ID)
+	slog.DebugContext(ctx, "cache conversation", "key", key, "ttl", ttl, "conversation_id", conversation.ID)
 
-	if err := database.RDB.Set(database.Ctx, key, data, ttl).Err(); err != nil {
-		log.Printf("❌ [Redis.SetConversation] Set error: %v", err)
+	if err := r.rdb.Set(ctx, key, data, ttl).Err(); err != nil {
+		slog.ErrorContext(ctx, "cache conversation failed", "key", key, "err", err)
 		return fmt.Errorf("failed to set conversation in redis: %w", err)
 	}
 
-	log.Printf("✅ [Redis.SetConversation] Success - Key: %s", key)
 	return nil
 }
 
-func (r *redisRepo) GetConversationIDByTwoUserID(userID1, userID2 string) (*models.Conversation, error) {
+func (r *redisRepo) GetConversationIDByTwoUserID(ctx context.Context, userID1, userID2 string) (*models.Conversation, error) {
 	key := r.CreateKeyConversationTwoUserID(userID1, userID2)
 
-	log.Printf("🔍 [Redis.GetConversation] Fetching key: %s", key)
-
-	val, err := database.RDB.Get(database.Ctx, key).Result()
+	val, err := r.rdb.Get(ctx, key).Result()
 	if err != nil {
-		log.Printf("❌ [Redis.GetConversation] Get error: %v", err)
+		if !errors.Is(err, redis.Nil) {
+			slog.ErrorContext(ctx, "get cached conversation failed", "key", key, "err", err)
+		}
 		return nil, fmt.Errorf("failed to get conversation from redis: %w", err)
 	}
 
 	var conversation models.Conversation
 	if err := json.Unmarshal([]byte(val), &conversation); err != nil {
-		log.Printf("❌ [Redis.GetConversation] Unmarshal error: %v", err)
+		slog.ErrorContext(ctx, "unmarshal cached conversation failed", "key", key, "err", err)
 		return nil, fmt.Errorf("failed to unmarshal conversation: %w", err)
 	}
 
-	log.Printf("✅ [Redis.GetConversation] Found - ConvoID: %s, Type: %s", conversation.ID, conversation.Type)
+	slog.DebugContext(ctx, "cached conversation hit", "key", key, "conversation_id", conversation.ID)
 	return &conversation, nil
 }
 
-func (r *redisRepo) UpdateUserOnline(user_id string) error {
+func (r *redisRepo) UpdateUserOnline(ctx context.Context, user_id string) error {
 	now := time.Now().Unix()
 	// save timetamp to hash
-	err := database.RDB.HSet(database.Ctx, "user_last_seen", user_id, now).Err()
+	err := r.rdb.HSet(ctx, "user_last_seen", user_id, now).Err()
 	if err != nil {
 		return fmt.Errorf("failed to update user online status: %w", err)
 	}
-	err = database.RDB.SetEx(database.Ctx, "online:"+user_id, 1, 300*time.Second).Err()
+	err = r.rdb.SetEx(ctx, "online:"+user_id, 1, 300*time.Second).Err()
 	if err != nil {
 		return fmt.Errorf("failed to set user online key: %w", err)
 	}
 	return nil
 }
-func (r *redisRepo) IsUserOnline(user_id string) (bool, time.Duration, error) {
-	ttl, err := database.RDB.TTL(database.Ctx, "online:"+user_id).Result()
+func (r *redisRepo) IsUserOnline(ctx context.Context, user_id string) (bool, time.Duration, error) {
+	ttl, err := r.rdb.TTL(ctx, "online:"+user_id).Result()
 	if err != nil {
 		return false, 0, fmt.Errorf("failed to check user online status: %w", err)
 	}
-	lastScreenInt, err := database.RDB.HGet(database.Ctx, "user_last_seen", user_id).Int64()
+	lastScreenInt, err := r.rdb.HGet(ctx, "user_last_seen", user_id).Int64()
 	if err != nil && err.Error() != "redis: nil" {
 		return false, 0, fmt.Errorf("failed to get user last seen: %w", err)
 	}
@@ -112,7 +126,7 @@ func (r *redisRepo) IsUserOnline(user_id string) (bool, time.Duration, error) {
 	offlineDuration := time.Since(lastSceen)
 	return ttl > 1, offlineDuration, nil
 }
-func (r *redisRepo) SetToken(token string, user *models.User, ttl time.Duration) error {
+func (r *redisRepo) SetToken(ctx context.Context, token string, user *models.User, ttl time.Duration) error {
 	if ttl == 0 {
 		ttl = time.Hour
 	}
@@ -120,11 +134,11 @@ func (r *redisRepo) SetToken(token string, user *models.User, ttl time.Duration)
 	if err != nil {
 		return err
 	}
-	return database.RDB.Set(database.Ctx, "token:"+token, data, ttl).Err()
+	return r.rdb.Set(ctx, "token:"+token, data, ttl).Err()
 }
 
-func (r *redisRepo) GetUserByToken(token string) (*models.User, error) {
-	val, err := database.RDB.Get(database.Ctx, "token:"+token).Result()
+func (r *redisRepo) GetUserByToken(ctx context.Context, token string) (*models.User, error) {
+	val, err := r.rdb.Get(ctx, "token:"+token).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -134,7 +148,7 @@ func (r *redisRepo) GetUserByToken(token string) (*models.User, error) {
 }
 
 // set-get participant in redis (token is conversationID)
-func (r *redisRepo) SetTokenParticipant(token string, participants *[]models.Participant, ttl time.Duration) error {
+func (r *redisRepo) SetTokenParticipant(ctx context.Context, token string, participants *[]models.Participant, ttl time.Duration) error {
 	if ttl == 0 {
 		ttl = time.Hour
 	}
@@ -142,10 +156,10 @@ func (r *redisRepo) SetTokenParticipant(token string, participants *[]models.Par
 	if err != nil {
 		return err
 	}
-	return database.RDB.Set(database.Ctx, "token-participants:"+token, data, ttl).Err()
+	return r.rdb.Set(ctx, "token-participants:"+token, data, ttl).Err()
 }
-func (r *redisRepo) GetParticipantByToken(token string) (*[]models.Participant, error) {
-	val, err := database.RDB.Get(database.Ctx, "token-participants:"+token).Result()
+func (r *redisRepo) GetParticipantByToken(ctx context.Context, token string) (*[]models.Participant, error) {
+	val, err := r.rdb.Get(ctx, "token-participants:"+token).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -156,6 +170,86 @@ func (r *redisRepo) GetParticipantByToken(token string) (*[]models.Participant,
 	return &participants, nil
 }
 
-func (r *redisRepo) DeleteToken(token string) error {
-	return database.RDB.Del(database.Ctx, "token:"+token).Err()
+func (r *redisRepo) DeleteToken(ctx context.Context, token string) error {
+	return r.rdb.Del(ctx, "token:"+token).Err()
+}
+
+// AcquireThrottle giữ chỗ key trong ttl. Trả về false kèm thời gian còn lại nếu key đang bị giữ.
+func (r *redisRepo) AcquireThrottle(ctx context.Context, key string, ttl time.Duration) (bool, time.Duration, error) {
+	ok, err := r.rdb.SetNX(ctx, "throttle:"+key, 1, ttl).Result()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to acquire throttle: %w", err)
+	}
+	if ok {
+		return true, 0, nil
+	}
+	remaining, err := r.rdb.TTL(ctx, "throttle:"+key).Result()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to get throttle ttl: %w", err)
+	}
+	return false, remaining, nil
+}
+
+// SetThrottle giữ key trong ttl (ghi đè nếu đã tồn tại)
+func (r *redisRepo) SetThrottle(ctx context.Context, key string, ttl time.Duration) error {
+	if err := r.rdb.Set(ctx, "throttle:"+key, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set throttle: %w", err)
+	}
+	return nil
+}
+
+// GetThrottleTTL trả về thời gian còn lại của key, 0 nếu không bị giữ
+func (r *redisRepo) GetThrottleTTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.rdb.TTL(ctx, "throttle:"+key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get throttle ttl: %w", err)
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// IncrementCounter tăng bộ đếm, bộ đếm tự hết hạn sau window tính từ lần tăng đầu tiên
+func (r *redisRepo) IncrementCounter(ctx context.Context, key string, window time.Duration) (int64, error) {
+	count, err := r.rdb.Incr(ctx, "counter:"+key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment counter: %w", err)
+	}
+	if count == 1 {
+		if err := r.rdb.Expire(ctx, "counter:"+key, window).Err(); err != nil {
+			return count, fmt.Errorf("failed to set counter expiry: %w", err)
+		}
+	}
+	return count, nil
+}
+
+func (r *redisRepo) ResetCounter(ctx context.Context, key string) error {
+	return r.rdb.Del(ctx, "counter:"+key).Err()
+}
+
+// SaveOAuthState lưu dữ liệu của 1 phiên đăng nhập OAuth theo state
+func (r *redisRepo) SaveOAuthState(ctx context.Context, state string, data []byte, ttl time.Duration) error {
+	if err := r.rdb.Set(ctx, "oauth-state:"+state, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save oauth state: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOAuthState đọc và xóa state (mỗi state chỉ dùng được 1 lần)
+func (r *redisRepo) ConsumeOAuthState(ctx context.Context, state string) ([]byte, error) {
+	return r.rdb.GetDel(ctx, "oauth-state:"+state).Bytes()
+}
+
+// SaveMagicLink đánh dấu magic link còn hiệu lực (key theo jti của token)
+func (r *redisRepo) SaveMagicLink(ctx context.Context, jti string, userID string, ttl time.Duration) error {
+	if err := r.rdb.Set(ctx, "magic-link:"+jti, userID, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save magic link: %w", err)
+	}
+	return nil
+}
+
+// ConsumeMagicLink đọc và xóa magic link, trả về user id. Lỗi redis.Nil nếu link đã dùng hoặc hết hạn.
+func (r *redisRepo) ConsumeMagicLink(ctx context.Context, jti string) (string, error) {
+	return r.rdb.GetDel(ctx, "magic-link:"+jti).Result()
 }
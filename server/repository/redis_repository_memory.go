@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"project/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// memoryRedisRepo là RedisRepository trong bộ nhớ, cùng key, TTL và lỗi redis.Nil với redisRepo
+type memoryRedisRepo struct {
+	mu       sync.Mutex
+	values   map[string]memoryRedisValue
+	lastSeen map[string]int64 // hash user_last_seen
+	now      func() time.Time
+}
+
+type memoryRedisValue struct {
+	data      []byte
+	expiresAt time.Time // zero: không hết hạn
+}
+
+func NewMemoryRedisRepository() RedisRepository {
+	return NewMemoryRedisRepositoryWithClock(time.Now)
+}
+
+// NewMemoryRedisRepositoryWithClock dùng now làm đồng hồ để test TTL mà không phải chờ
+func NewMemoryRedisRepositoryWithClock(now func() time.Time) RedisRepository {
+	return &memoryRedisRepo{
+		values:   make(map[string]memoryRedisValue),
+		lastSeen: make(map[string]int64),
+		now:      now,
+	}
+}
+
+// get trả về giá trị còn hạn, key hết hạn bị xóa như Redis
+func (r *memoryRedisRepo) get(key string) ([]byte, bool) {
+	v, ok := r.values[key]
+	if !ok {
+		return nil, false
+	}
+	if !v.expiresAt.IsZero() && !r.now().Before(v.expiresAt) {
+		delete(r.values, key)
+		return nil, false
+	}
+	return v.data, true
+}
+
+// set ghi giá trị, ttl <= 0 là không hết hạn (giống SET không có EX)
+func (r *memoryRedisRepo) set(key string, data []byte, ttl time.Duration) {
+	v := memoryRedisValue{data: data}
+	if ttl > 0 {
+		v.expiresAt = r.now().Add(ttl)
+	}
+	r.values[key] = v
+}
+
+// ttl giống lệnh TTL: -2 nếu không có key, -1 nếu không hết hạn, còn lại làm tròn theo giây
+func (r *memoryRedisRepo) ttl(key string) time.Duration {
+	if _, ok := r.get(key); !ok {
+		return -2
+	}
+	v := r.values[key]
+	if v.expiresAt.IsZero() {
+		return -1
+	}
+	remaining := v.expiresAt.Sub(r.now())
+	return (remaining + 500*time.Millisecond) / time.Second * time.Second
+}
+
+func (r *memoryRedisRepo) getDel(key string) ([]byte, error) {
+	data, ok := r.get(key)
+	if !ok {
+		return nil, redis.Nil
+	}
+	delete(r.values, key)
+	return data, nil
+}
+
+func (r *memoryRedisRepo) CreateKeyConversationTwoUserID(userID1, userID2 string) string {
+	if userID1 < userID2 {
+		return fmt.Sprintf("conversation:%s:%s", userID1, userID2)
+	}
+	return fmt.Sprintf("conversation:%s:%s", userID2, userID1)
+}
+
+func (r *memoryRedisRepo) SetKeyConversationTwoUserID(ctx context.Context, userID1, userID2 string, conversation *models.Conversation, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	data, err := json.Marshal(conversation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal conversation: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.set(r.CreateKeyConversationTwoUserID(userID1, userID2), data, ttl)
+	return nil
+}
+
+func (r *memoryRedisRepo) GetConversationIDByTwoUserID(ctx context.Context, userID1, userID2 string) (*models.Conversation, error) {
+	r.mu.Lock()
+	data, ok := r.get(r.CreateKeyConversationTwoUserID(userID1, userID2))
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("failed to get conversation from redis: %w", redis.Nil)
+	}
+	var conversation models.Conversation
+	if err := json.Unmarshal(data, &conversation); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal conversation: %w", err)
+	}
+	return &conversation, nil
+}
+
+func (r *memoryRedisRepo) UpdateUserOnline(ctx context.Context, user_id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastSeen[user_id] = r.now().Unix()
+	r.set("online:"+user_id, []byte("1"), 300*time.Second)
+	return nil
+}
+
+func (r *memoryRedisRepo) IsUserOnline(ctx context.Context, user_id string) (bool, time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ttl := r.ttl("online:" + user_id)
+	lastSeen := time.Unix(r.lastSeen[user_id], 0)
+	return ttl > 1, r.now().Sub(lastSeen), nil
+}
+
+func (r *memoryRedisRepo) SetToken(ctx context.Context, token string, user *models.User, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.set("token:"+token, data, ttl)
+	return nil
+}
+
+func (r *memoryRedisRepo) GetUserByToken(ctx context.Context, token string) (*models.User, error) {
+	r.mu.Lock()
+	data, ok := r.get("token:" + token)
+	r.mu.Unlock()
+	if !ok {
+		return nil, redis.Nil
+	}
+	var user models.User
+	json.Unmarshal(data, &user)
+	return &user, nil
+}
+
+func (r *memoryRedisRepo) DeleteToken(ctx context.Context, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.values, "token:"+token)
+	return nil
+}
+
+func (r *memoryRedisRepo) SetTokenParticipant(ctx context.Context, token string, participants *[]models.Participant, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	data, err := json.Marshal(participants)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.set("token-participants:"+token, data, ttl)
+	return nil
+}
+
+func (r *memoryRedisRepo) GetParticipantByToken(ctx context.Context, token string) (*[]models.Participant, error) {
+	r.mu.Lock()
+	data, ok := r.get("token-participants:" + token)
+	r.mu.Unlock()
+	if !ok {
+		return nil, redis.Nil
+	}
+	var participants []models.Participant
+	if err := json.Unmarshal(data, &participants); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal participants: %w", err)
+	}
+	return &participants, nil
+}
+
+func (r *memoryRedisRepo) AcquireThrottle(ctx context.Context, key string, ttl time.Duration) (bool, time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.get("throttle:" + key); ok {
+		return false, r.ttl("throttle:" + key), nil
+	}
+	r.set("throttle:"+key, []byte("1"), ttl)
+	return true, 0, nil
+}
+
+func (r *memoryRedisRepo) SetThrottle(ctx context.Context, key string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.set("throttle:"+key, []byte("1"), ttl)
+	return nil
+}
+
+func (r *memoryRedisRepo) GetThrottleTTL(ctx context.Context, key string) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ttl := r.ttl("throttle:" + key)
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (r *memoryRedisRepo) IncrementCounter(ctx context.Context, key string, window time.Duration) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, ok := r.get("counter:" + key)
+	if !ok {
+		r.set("counter:"+key, []byte("1"), window)
+		return 1, nil
+	}
+	count, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment counter: %w", err)
+	}
+	count++
+	// INCR giữ nguyên TTL, hạn chỉ được đặt ở lần tăng đầu tiên
+	v := r.values["counter:"+key]
+	v.data = []byte(strconv.FormatInt(count, 10))
+	r.values["counter:"+key] = v
+	return count, nil
+}
+
+func (r *memoryRedisRepo) ResetCounter(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.values, "counter:"+key)
+	return nil
+}
+
+func (r *memoryRedisRepo) SaveOAuthState(ctx context.Context, state string, data []byte, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.set("oauth-state:"+state, append([]byte(nil), data...), ttl)
+	return nil
+}
+
+func (r *memoryRedisRepo) ConsumeOAuthState(ctx context.Context, state string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.getDel("oauth-state:" + state)
+}
+
+func (r *memoryRedisRepo) SaveMagicLink(ctx context.Context, jti string, userID string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.set("magic-link:"+jti, []byte(userID), ttl)
+	return nil
+}
+
+func (r *memoryRedisRepo) ConsumeMagicLink(ctx context.Context, jti string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := r.getDel("magic-link:" + jti)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -1,8 +1,7 @@
 package repository
 
 import (
-	"fmt"
-	"project/database"
+	"context"
 	"project/models"
 	"time"
 
@@ -11,57 +10,56 @@ import (
 )
 
 type TokenRepository interface {
-	CreateToken(token *models.Token) error
-	UpdateToken(token *models.Token) error
-	GetTokenByRefresh(refresh string) (*models.Token, error)
-	GetTokenByAccess(access string) (*models.Token, error)
-	GetTokensByUserID(device_id string) (*models.Token, error)
-	GetUserForAccessToken(accessToken string) (*models.User, string, error)
-	DeleteTokenByID(ID uuid.UUID) error
-	DeleteTokensByUserID(userID uint) error
-	GetTokensByDeviceID(userID uuid.UUID, deviceID string) (*models.Token, error)
+	CreateToken(ctx context.Context, token *models.Token) error
+	UpdateToken(ctx context.Context, token *models.Token) error
+	GetTokenByRefresh(ctx context.Context, refresh string) (*models.Token, error)
+	GetTokenByAccess(ctx context.Context, access string) (*models.Token, error)
+	GetTokensByUserID(ctx context.Context, device_id string) (*models.Token, error)
+	GetUserForAccessToken(ctx context.Context, accessToken string) (*models.User, string, error)
+	DeleteTokenByID(ctx context.Context, ID uuid.UUID) error
+	DeleteTokensByUserID(ctx context.Context, userID uint) error
+	GetTokensByDeviceID(ctx context.Context, userID uuid.UUID, deviceID string) (*models.Token, error)
 }
 
 type tokenRepo struct {
 	db *gorm.DB
 }
 
-var NewTokenRepository = func() TokenRepository {
+func NewTokenRepository(db *gorm.DB) TokenRepository {
 	return &tokenRepo{
-		db: database.DB,
+		db: db,
 	}
 }
 
-func (r *tokenRepo) UpdateToken(token *models.Token) error {
+func (r *tokenRepo) UpdateToken(ctx context.Context, token *models.Token) error {
 	token.UpdatedAt = time.Now()
-	return r.db.Save(token).Error
+	return r.db.WithContext(ctx).Save(token).Error
 }
 
 // ✅ Tạo token mới (thêm bản ghi)
-func (r *tokenRepo) CreateToken(token *models.Token) error {
-	return r.db.Create(token).Error
+func (r *tokenRepo) CreateToken(ctx context.Context, token *models.Token) error {
+	return r.db.WithContext(ctx).Create(token).Error
 }
 
 // ✅ Lấy token theo refresh token
-func (r *tokenRepo) GetTokenByRefresh(refresh string) (*models.Token, error) {
+func (r *tokenRepo) GetTokenByRefresh(ctx context.Context, refresh string) (*models.Token, error) {
 	var token models.Token
-	if err := r.db.Where("refresh_token = ?", refresh).First(&token).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("refresh_token = ?", refresh).First(&token).Error; err != nil {
 		return nil, err
 	}
 	return &token, nil
 }
-func (r *tokenRepo) GetTokenByAccess(access string) (*models.Token, error) {
+func (r *tokenRepo) GetTokenByAccess(ctx context.Context, access string) (*models.Token, error) {
 	var token models.Token
-	if err := r.db.Where("access_token = ?", access).First(&token).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("access_token = ?", access).First(&token).Error; err != nil {
 		return nil, err
 	}
 	return &token, nil
 }
 
-func (r *tokenRepo) GetTokensByDeviceID(userID uuid.UUID, deviceID string) (*models.Token, error) {
+func (r *tokenRepo) GetTokensByDeviceID(ctx context.Context, userID uuid.UUID, deviceID string) (*models.Token, error) {
 	var token models.Token
-	fmt.Printf("user_id is :%v", userID)
-	err := r.db.
+	err := r.db.WithContext(ctx).
 		Table("tokens").Where("tokens.device_id = ? AND devices.user_id = ?", deviceID, userID).
 		Joins("JOIN devices ON tokens.device_id = devices.id").
 		First(&token).Error
@@ -75,29 +73,28 @@ func (r *tokenRepo) GetTokensByDeviceID(userID uuid.UUID, deviceID string) (*mod
 }
 
 // ✅ Lấy toàn bộ token theo user (nếu user có nhiều device)
-func (r *tokenRepo) GetTokensByUserID(device_id string) (*models.Token, error) {
+func (r *tokenRepo) GetTokensByUserID(ctx context.Context, device_id string) (*models.Token, error) {
 	var tokens models.Token
-	if err := r.db.Table("tokens").Where("tokens.device_id = ?", device_id).First(&tokens).Error; err != nil {
+	if err := r.db.WithContext(ctx).Table("tokens").Where("tokens.device_id = ?", device_id).First(&tokens).Error; err != nil {
 		return nil, err
 	}
 	return &tokens, nil
 }
 
 // ✅ Xóa token cụ thể (logout 1 thiết bị)
-func (r *tokenRepo) DeleteTokenByID(ID uuid.UUID) error {
-	return r.db.Delete(&models.Token{}, ID).Error
+func (r *tokenRepo) DeleteTokenByID(ctx context.Context, ID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.Token{}, ID).Error
 }
-func (r *tokenRepo) DeleteTokenByDeviceID(deviceID uuid.UUID) error {
-	return r.db.Unscoped().Where("device_id = ?", deviceID).Delete(&models.Token{}).Error
+func (r *tokenRepo) DeleteTokenByDeviceID(ctx context.Context, deviceID uuid.UUID) error {
+	return r.db.WithContext(ctx).Unscoped().Where("device_id = ?", deviceID).Delete(&models.Token{}).Error
 }
 
 // ✅ Xóa tất cả token của user (logout all)
-func (r *tokenRepo) DeleteTokensByUserID(userID uint) error {
-	return r.db.Where("user_id = ?", userID).Delete(&models.Token{}).Error
+func (r *tokenRepo) DeleteTokensByUserID(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.Token{}).Error
 }
 
-func (r *tokenRepo) GetUserForAccessToken(accessToken string) (*models.User, string, error) {
-	print("at", accessToken)
+func (r *tokenRepo) GetUserForAccessToken(ctx context.Context, accessToken string) (*models.User, string, error) {
 	var result struct {
 		ID           uuid.UUID
 		Email        string
@@ -108,19 +105,16 @@ func (r *tokenRepo) GetUserForAccessToken(accessToken string) (*models.User, str
 		ExpiresAt    int64
 		RefreshToken string
 	}
-	err := r.db.
+	err := r.db.WithContext(ctx).
 		Table("users").
 		Select("users.*, tokens.expires_at, tokens.refresh_token").
 		Joins("JOIN devices ON devices.user_id = users.id").
 		Joins("JOIN tokens ON tokens.device_id = devices.id").
 		Where("tokens.access_token = ?", accessToken).
 		First(&result).Error
-	fmt.Println(result)
 	if err != nil {
 		return nil, "", err
 	}
-	fmt.Println(time.Now())
-	fmt.Println(time.Unix(int64(result.ExpiresAt), 0))
 	if time.Now().After(time.Unix(int64(result.ExpiresAt), 0)) {
 
 		return nil, result.RefreshToken, nil
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"project/models"
 
 	"github.com/google/uuid"
@@ -8,70 +9,78 @@ import (
 
 // ✅ MockTokenRepository — mô phỏng TokenRepository cho unit test
 type MockTokenRepository struct {
-	MockCreateToken           func(token *models.Token) error
-	MockGetTokenByRefresh     func(refresh string) (*models.Token, error)
-	MockGetTokenByAccess      func(access string) (*models.Token, error)
-	MockGetTokensByUserID     func(deviceID string) (*models.Token, error)
-	MockGetUserForAccessToken func(accessToken string) (*models.User, string, error)
-	MockDeleteTokenByID       func(ID uuid.UUID) error
-	MockDeleteTokensByUserID  func(userID uint) error
-	MockGetTokensByDeviceID   func(userId uuid.UUID, deviceID string) (*models.Token, error)
+	MockCreateToken           func(ctx context.Context, token *models.Token) error
+	MockUpdateToken           func(ctx context.Context, token *models.Token) error
+	MockGetTokenByRefresh     func(ctx context.Context, refresh string) (*models.Token, error)
+	MockGetTokenByAccess      func(ctx context.Context, access string) (*models.Token, error)
+	MockGetTokensByUserID     func(ctx context.Context, deviceID string) (*models.Token, error)
+	MockGetUserForAccessToken func(ctx context.Context, accessToken string) (*models.User, string, error)
+	MockDeleteTokenByID       func(ctx context.Context, ID uuid.UUID) error
+	MockDeleteTokensByUserID  func(ctx context.Context, userID uint) error
+	MockGetTokensByDeviceID   func(ctx context.Context, userId uuid.UUID, deviceID string) (*models.Token, error)
 }
 
 // Implement interface TokenRepository ↓↓↓
 
-func (m *MockTokenRepository) CreateToken(token *models.Token) error {
+func (m *MockTokenRepository) CreateToken(ctx context.Context, token *models.Token) error {
 	if m.MockCreateToken != nil {
-		return m.MockCreateToken(token)
+		return m.MockCreateToken(ctx, token)
 	}
 	return nil
 }
 
-func (m *MockTokenRepository) GetTokenByRefresh(refresh string) (*models.Token, error) {
+func (m *MockTokenRepository) UpdateToken(ctx context.Context, token *models.Token) error {
+	if m.MockUpdateToken != nil {
+		return m.MockUpdateToken(ctx, token)
+	}
+	return nil
+}
+
+func (m *MockTokenRepository) GetTokenByRefresh(ctx context.Context, refresh string) (*models.Token, error) {
 	if m.MockGetTokenByRefresh != nil {
-		return m.MockGetTokenByRefresh(refresh)
+		return m.MockGetTokenByRefresh(ctx, refresh)
 	}
 	return nil, nil
 }
 
-func (m *MockTokenRepository) GetTokenByAccess(access string) (*models.Token, error) {
+func (m *MockTokenRepository) GetTokenByAccess(ctx context.Context, access string) (*models.Token, error) {
 	if m.MockGetTokenByAccess != nil {
-		return m.MockGetTokenByAccess(access)
+		return m.MockGetTokenByAccess(ctx, access)
 	}
 	return nil, nil
 }
 
-func (m *MockTokenRepository) GetTokensByUserID(deviceID string) (*models.Token, error) {
+func (m *MockTokenRepository) GetTokensByUserID(ctx context.Context, deviceID string) (*models.Token, error) {
 	if m.MockGetTokensByUserID != nil {
-		return m.MockGetTokensByUserID(deviceID)
+		return m.MockGetTokensByUserID(ctx, deviceID)
 	}
 	return nil, nil
 }
 
-func (m *MockTokenRepository) GetUserForAccessToken(accessToken string) (*models.User, string, error) {
+func (m *MockTokenRepository) GetUserForAccessToken(ctx context.Context, accessToken string) (*models.User, string, error) {
 	if m.MockGetUserForAccessToken != nil {
-		return m.MockGetUserForAccessToken(accessToken)
+		return m.MockGetUserForAccessToken(ctx, accessToken)
 	}
 	return nil, "", nil
 }
 
-func (m *MockTokenRepository) DeleteTokenByID(ID uuid.UUID) error {
+func (m *MockTokenRepository) DeleteTokenByID(ctx context.Context, ID uuid.UUID) error {
 	if m.MockDeleteTokenByID != nil {
-		return m.MockDeleteTokenByID(ID)
+		return m.MockDeleteTokenByID(ctx, ID)
 	}
 	return nil
 }
 
-func (m *MockTokenRepository) DeleteTokensByUserID(userID uint) error {
+func (m *MockTokenRepository) DeleteTokensByUserID(ctx context.Context, userID uint) error {
 	if m.MockDeleteTokensByUserID != nil {
-		return m.MockDeleteTokensByUserID(userID)
+		return m.MockDeleteTokensByUserID(ctx, userID)
 	}
 	return nil
 }
 
-func (m *MockTokenRepository) GetTokensByDeviceID(userId uuid.UUID, deviceID string) (*models.Token, error) {
+func (m *MockTokenRepository) GetTokensByDeviceID(ctx context.Context, userId uuid.UUID, deviceID string) (*models.Token, error) {
 	if m.MockGetTokensByDeviceID != nil {
-		return m.MockGetTokensByDeviceID(userId, deviceID)
+		return m.MockGetTokensByDeviceID(ctx, userId, deviceID)
 	}
 	return nil, nil
 }
@@ -1,10 +1,11 @@
 package repository
 
 import (
+	"context"
 	"errors"
-	"log"
-	"project/database"
+	"math"
 	"project/models"
+	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -12,15 +13,19 @@ import (
 )
 
 type UserRepository interface {
-	CreateUser(user *models.User) (*models.User, error)
-	GetUserByID(id uuid.UUID) (*models.User, error)
-	GetUserByEmail(email string) (*models.User, error)
-	LoginPassword(email string, password string, provider string) (*models.User, error)
-	UpdateUser(user *models.User) error
-	DeleteUser(id uint) error
-	GetAllUsers() ([]models.User, error)
-	FindUserWithStatusFriend(email string, user_id uuid.UUID) (*models.User, string, error)
-	GetUserByAccesToken(accessToken string) (*models.User, error)
+	CreateUser(ctx context.Context, user *models.User) (*models.User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	LoginPassword(ctx context.Context, email string, password string, provider string) (*models.User, error)
+	UpdateUser(ctx context.Context, user *models.User) error
+	DeleteUser(ctx context.Context, id uint) error
+	GetAllUsers(ctx context.Context) ([]models.User, error)
+	FindUserWithStatusFriend(ctx context.Context, email string, user_id uuid.UUID) (*models.User, string, error)
+	GetUserByAccesToken(ctx context.Context, accessToken string) (*models.User, error)
+	MarkEmailVerified(ctx context.Context, id uuid.UUID, verifiedAt time.Time) error
+	UpdateProfile(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error
+	ReserveStorage(ctx context.Context, id uuid.UUID, bytes int64, defaultQuota int64) (bool, error)
+	ReleaseStorage(ctx context.Context, id uuid.UUID, bytes int64) error
 }
 
 type userRepo struct {
@@ -34,49 +39,53 @@ type UserWithStatusFriend struct {
 	StatusFriend string    `gorm:"column:status_friend"`
 }
 
-func NewUserRepository() UserRepository {
+func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepo{
-		db: database.DB, // database.DB là connection GORM chính
+		db: db,
 	}
 }
 
 // 🧱 Create user
-func (r *userRepo) CreateUser(user *models.User) (*models.User, error) {
-	if err := r.db.Create(user).Error; err != nil {
+func (r *userRepo) CreateUser(ctx context.Context, user *models.User) (*models.User, error) {
+	if err := r.db.WithContext(ctx).Create(user).Error; err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (r *userRepo) LoginPassword(email string, password string, provider string) (*models.User, error) {
+// ErrInvalidCredentials dùng chung cho mọi trường hợp đăng nhập sai để không lộ email có tồn tại hay không
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// dummyPasswordHash dùng để so sánh khi không tìm thấy user, giữ thời gian phản hồi giống nhau
+const dummyPasswordHash = "$2a$10$khJIQsyzK5qTQfOmI9i1KOdA7zr8L6eljyoml6v8l0N7g6tDcimmy"
+
+func (r *userRepo) LoginPassword(ctx context.Context, email string, password string, provider string) (*models.User, error) {
 	var user models.User
-	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
-		return nil, errors.New("invalid email or password")
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+		return nil, ErrInvalidCredentials
 	}
 
-	if user.Provider != provider {
-		return nil, errors.New("please login with " + user.Provider)
-	}
-	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
-		return nil, errors.New("invalid email or password")
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil || user.Provider != provider {
+		return nil, ErrInvalidCredentials
 	}
 
 	return &user, nil
 }
 
 // 🔍 Find user by ID
-func (r *userRepo) GetUserByID(id uuid.UUID) (*models.User, error) {
+func (r *userRepo) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	var user models.User
-	if err := r.db.First(&user, id).Error; err != nil {
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
 // 🔍 Find user by email
-func (r *userRepo) GetUserByEmail(email string) (*models.User, error) {
+func (r *userRepo) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -87,43 +96,38 @@ func (r *userRepo) GetUserByEmail(email string) (*models.User, error) {
 }
 
 // 📝 Update user info
-func (r *userRepo) UpdateUser(user *models.User) error {
-	return r.db.Save(user).Error
+func (r *userRepo) UpdateUser(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Save(user).Error
 }
 
 // ❌ Delete user
-func (r *userRepo) DeleteUser(id uint) error {
-	return r.db.Delete(&models.User{}, id).Error
+func (r *userRepo) DeleteUser(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.User{}, id).Error
 }
 
 // 📃 Get all users
-func (r *userRepo) GetAllUsers() ([]models.User, error) {
+func (r *userRepo) GetAllUsers(ctx context.Context) ([]models.User, error) {
 	var users []models.User
-	if err := r.db.Find(&users).Error; err != nil {
+	if err := r.db.WithContext(ctx).Find(&users).Error; err != nil {
 		return nil, err
 	}
 	return users, nil
 }
 
-func (r *userRepo) FindUserWithStatusFriend(email string, userID uuid.UUID) (*models.User, string, error) {
-	log.Printf("[FindUserWithStatusFriend][Repo] Input email: %s, userID: %s", email, userID)
+func (r *userRepo) FindUserWithStatusFriend(ctx context.Context, email string, userID uuid.UUID) (*models.User, string, error) {
 
 	var result UserWithStatusFriend
-	err := r.db.Debug().Table("users").
+	err := r.db.WithContext(ctx).Debug().Table("users").
 		Select(`users.*,friendships.status as status_friend`).
 		Joins("LEFT JOIN friendships ON (users.id = friendships.requested_by OR users.id = friendships.friend_id)").
 		Where("users.email = ?", email).
 		Scan(&result).Error
 
 	if err != nil {
-		log.Printf("[FindUserWithStatusFriend][Repo] SQL error: %v", err)
 		return nil, "", err
 	}
 
-	log.Printf("[FindUserWithStatusFriend][Repo] Scan result: %+v", result)
-
 	if result.ID != uuid.Nil {
-		log.Printf("[FindUserWithStatusFriend][Repo] User found: ID=%s, Status=%s", result.ID, result.StatusFriend)
 		user := models.User{
 			ID:     result.ID,
 			Name:   result.Name,
@@ -133,13 +137,12 @@ func (r *userRepo) FindUserWithStatusFriend(email string, userID uuid.UUID) (*mo
 		return &user, result.StatusFriend, nil
 	}
 
-	log.Printf("[FindUserWithStatusFriend][Repo] User not found for email: %s", email)
 	return nil, "", nil
 }
 
-func (r *userRepo) GetUserByAccesToken(accessToken string) (*models.User, error) {
+func (r *userRepo) GetUserByAccesToken(ctx context.Context, accessToken string) (*models.User, error) {
 	var result models.User
-	err := r.db.Table("users").
+	err := r.db.WithContext(ctx).Table("users").
 		Select("users.*").
 		Joins("JOIN devices ON devices.user_id = users.id").
 		Joins("JOIN tokens ON tokens.device_id = devices.id").
@@ -151,3 +154,40 @@ func (r *userRepo) GetUserByAccesToken(accessToken string) (*models.User, error)
 	return &result, nil
 
 }
+
+// ✅ Đánh dấu email của user đã được xác thực
+func (r *userRepo) MarkEmailVerified(ctx context.Context, id uuid.UUID, verifiedAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ?", id).
+		Update("email_verified_at", verifiedAt).Error
+}
+
+// ✏️ Cập nhật một số cột profile (name, bio, status_text, avatar)
+func (r *userRepo) UpdateProfile(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ?", id).
+		Updates(fields).Error
+}
+
+// 💾 Cộng dung lượng đã dùng nếu còn trong quota (quota riêng của user, hoặc defaultQuota; <= 0 = không giới hạn).
+// Kiểm tra và cộng trong cùng 1 câu UPDATE nên các upload song song không vượt quota.
+func (r *userRepo) ReserveStorage(ctx context.Context, id uuid.UUID, bytes int64, defaultQuota int64) (bool, error) {
+	if defaultQuota <= 0 {
+		defaultQuota = math.MaxInt64
+	}
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ?", id).
+		Where("storage_used + ? <= CASE WHEN storage_quota > 0 THEN storage_quota ELSE ? END", bytes, defaultQuota).
+		Update("storage_used", gorm.Expr("storage_used + ?", bytes))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// 💾 Trả lại dung lượng khi file bị xóa hoặc upload bị hủy
+func (r *userRepo) ReleaseStorage(ctx context.Context, id uuid.UUID, bytes int64) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ?", id).
+		Update("storage_used", gorm.Expr("GREATEST(storage_used - ?, 0)", bytes)).Error
+}
@@ -2,83 +2,117 @@ package repository
 
 // MockUserRepository là struct mô phỏng UserRepository (dùng cho unit test)
 import (
+	"context"
 	"project/models"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type MockUserRepository struct {
-	MockCreateUser          func(user *models.User) (*models.User, error)
-	MockGetUserByID         func(id uuid.UUID) (*models.User, error)
-	MockGetUserByEmail      func(email string) (*models.User, error)
-	MockLoginPassword       func(email string, password string, provider string) (*models.User, error)
-	MockUpdateUser          func(user *models.User) error
-	MockDeleteUser          func(id uint) error
-	MockGetAllUsers         func() ([]models.User, error)
-	MockFindUserWithFriends func(email string, user_id uuid.UUID) (*models.User, string, error)
-	MockGetUserByAccesToken func(accessToken string) (*models.User, error)
+	MockCreateUser          func(ctx context.Context, user *models.User) (*models.User, error)
+	MockGetUserByID         func(ctx context.Context, id uuid.UUID) (*models.User, error)
+	MockGetUserByEmail      func(ctx context.Context, email string) (*models.User, error)
+	MockLoginPassword       func(ctx context.Context, email string, password string, provider string) (*models.User, error)
+	MockUpdateUser          func(ctx context.Context, user *models.User) error
+	MockDeleteUser          func(ctx context.Context, id uint) error
+	MockGetAllUsers         func(ctx context.Context) ([]models.User, error)
+	MockFindUserWithFriends func(ctx context.Context, email string, user_id uuid.UUID) (*models.User, string, error)
+	MockGetUserByAccesToken func(ctx context.Context, accessToken string) (*models.User, error)
+	MockMarkEmailVerified   func(ctx context.Context, id uuid.UUID, verifiedAt time.Time) error
+	MockUpdateProfile       func(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error
+	MockReserveStorage      func(ctx context.Context, id uuid.UUID, bytes int64, defaultQuota int64) (bool, error)
+	MockReleaseStorage      func(ctx context.Context, id uuid.UUID, bytes int64) error
 }
 
 // Implement interface UserRepository ↓↓↓
 
-func (m *MockUserRepository) CreateUser(user *models.User) (*models.User, error) {
+func (m *MockUserRepository) CreateUser(ctx context.Context, user *models.User) (*models.User, error) {
 	if m.MockCreateUser != nil {
-		return m.MockCreateUser(user)
+		return m.MockCreateUser(ctx, user)
 	}
 	return nil, nil
 }
 
-func (m *MockUserRepository) LoginPassword(email string, password string, provider string) (*models.User, error) {
+func (m *MockUserRepository) LoginPassword(ctx context.Context, email string, password string, provider string) (*models.User, error) {
 	if m.MockLoginPassword != nil {
-		return m.MockLoginPassword(email, password, provider)
+		return m.MockLoginPassword(ctx, email, password, provider)
 	}
 	return nil, nil
 }
 
-func (m *MockUserRepository) GetUserByID(id uuid.UUID) (*models.User, error) {
+func (m *MockUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	if m.MockGetUserByID != nil {
-		return m.MockGetUserByID(id)
+		return m.MockGetUserByID(ctx, id)
 	}
 	return nil, nil
 }
 
-func (m *MockUserRepository) GetUserByEmail(email string) (*models.User, error) {
+func (m *MockUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	if m.MockGetUserByEmail != nil {
-		return m.MockGetUserByEmail(email)
+		return m.MockGetUserByEmail(ctx, email)
 	}
 	return nil, nil
 }
 
-func (m *MockUserRepository) UpdateUser(user *models.User) error {
+func (m *MockUserRepository) UpdateUser(ctx context.Context, user *models.User) error {
 	if m.MockUpdateUser != nil {
-		return m.MockUpdateUser(user)
+		return m.MockUpdateUser(ctx, user)
 	}
 	return nil
 }
 
-func (m *MockUserRepository) DeleteUser(id uint) error {
+func (m *MockUserRepository) DeleteUser(ctx context.Context, id uint) error {
 	if m.MockDeleteUser != nil {
-		return m.MockDeleteUser(id)
+		return m.MockDeleteUser(ctx, id)
 	}
 	return nil
 }
 
-func (m *MockUserRepository) GetAllUsers() ([]models.User, error) {
+func (m *MockUserRepository) GetAllUsers(ctx context.Context) ([]models.User, error) {
 	if m.MockGetAllUsers != nil {
-		return m.MockGetAllUsers()
+		return m.MockGetAllUsers(ctx)
 	}
 	return nil, nil
 }
 
-func (m *MockUserRepository) FindUserWithStatusFriend(email string, user_id uuid.UUID) (*models.User, string, error) {
+func (m *MockUserRepository) FindUserWithStatusFriend(ctx context.Context, email string, user_id uuid.UUID) (*models.User, string, error) {
 	if m.MockFindUserWithFriends != nil {
-		return m.MockFindUserWithFriends(email, user_id)
+		return m.MockFindUserWithFriends(ctx, email, user_id)
 	}
 	return nil, "", nil
 }
-func (m *MockUserRepository) GetUserByAccesToken(accessToken string) (*models.User, error) {
+func (m *MockUserRepository) GetUserByAccesToken(ctx context.Context, accessToken string) (*models.User, error) {
 	if m.MockGetUserByAccesToken != nil {
-		return m.MockGetUserByAccesToken(accessToken)
+		return m.MockGetUserByAccesToken(ctx, accessToken)
 	}
 	return nil, nil
 }
+
+func (m *MockUserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, verifiedAt time.Time) error {
+	if m.MockMarkEmailVerified != nil {
+		return m.MockMarkEmailVerified(ctx, id, verifiedAt)
+	}
+	return nil
+}
+
+func (m *MockUserRepository) UpdateProfile(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error {
+	if m.MockUpdateProfile != nil {
+		return m.MockUpdateProfile(ctx, id, fields)
+	}
+	return nil
+}
+
+func (m *MockUserRepository) ReserveStorage(ctx context.Context, id uuid.UUID, bytes int64, defaultQuota int64) (bool, error) {
+	if m.MockReserveStorage != nil {
+		return m.MockReserveStorage(ctx, id, bytes, defaultQuota)
+	}
+	return true, nil
+}
+
+func (m *MockUserRepository) ReleaseStorage(ctx context.Context, id uuid.UUID, bytes int64) error {
+	if m.MockReleaseStorage != nil {
+		return m.MockReleaseStorage(ctx, id, bytes)
+	}
+	return nil
+}
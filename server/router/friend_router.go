@@ -3,6 +3,7 @@ package router
 import (
 	"project/handler"
 	"project/middleware"
+	"project/service"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,7 +14,7 @@ func FriendshipRouter(r *gin.Engine, authMiddleware *middleware.AuthMiddleware,
 	{
 		friendship := v1.Group("/friendships", authMiddleware.VerifyAccessToken)
 		{
-			friendship.POST("/send-invite", friendHandler.SendInviteFriend)
+			friendship.POST("/send-invite", authMiddleware.RequireVerifiedEmail(service.FeatureFriendInvite), friendHandler.SendInviteFriend)
 			friendship.POST("/cancel-invite", friendHandler.CancelInviteFriend)
 			friendship.GET("/list-invite-friends", friendHandler.GetListInviteFriend)
 			friendship.POST("/accept-invite", friendHandler.AcceptInviteFriend)
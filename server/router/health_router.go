@@ -0,0 +1,15 @@
+package router
+
+import (
+	"project/handler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthRouter đăng ký probe trước các middleware log/rate limit: probe gọi vài giây 1 lần,
+// không nên ghi log mỗi lần hay bị rate limit chặn
+func HealthRouter(r *gin.Engine, healthHandler *handler.HealthHandler) {
+	r.GET("/healthz", healthHandler.Healthz)
+	r.HEAD("/healthz", healthHandler.Healthz)
+	r.GET("/readyz", healthHandler.Readyz)
+}
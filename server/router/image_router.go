@@ -1,37 +1,27 @@
 package router
 
 import (
-	"fmt"
-
-	"os"
 	"project/handler"
 	"project/middleware"
-	"project/pkg/storage"
+	"project/service"
 
 	"github.com/gin-gonic/gin"
 )
 
-func ImageRouter(r *gin.Engine, authMiddleware *middleware.AuthMiddleware,
-	imageHandler *handler.ImageHandler) {
+func ImageRouter(r *gin.Engine, authMiddleware *middleware.AuthMiddleware, fileHandler *handler.FileHandler) {
 
 	protected := r.Group("/api/v1/", authMiddleware.VerifyAccessToken)
 	{
-		spaces, err := storage.NewSpacesClient(
-			os.Getenv("SPACES_KEY"),
-			os.Getenv("SPACES_SECRET"),
-			os.Getenv("SPACES_REGION"),
-			os.Getenv("SPACES_ENDPOINT"),
-			os.Getenv("SPACES_BUCKET"),
-		)
-		if err != nil {
-			fmt.Println("Failed to init Spaces:", err)
-		}
+		protected.POST("/upload", authMiddleware.RequireVerifiedEmail(service.FeatureUpload), fileHandler.Upload)
+		protected.GET("/attachments/:id", fileHandler.DownloadAttachment)
+		protected.GET("/attachments/:id/url", fileHandler.SignURL)
 
-		fileHandler := handler.NewFileHandler(spaces)
-		protected.GET("/images/:filename", imageHandler.ServeImage)
-		protected.POST("/upload", fileHandler.Upload)
-		protected.GET("/files/:filename", fileHandler.GetFile)
+		// Upload trực tiếp lên storage qua presigned URL
+		protected.POST("/uploads", authMiddleware.RequireVerifiedEmail(service.FeatureUpload), fileHandler.CreateUpload)
+		protected.POST("/uploads/:id/complete", fileHandler.CompleteUpload)
+		protected.DELETE("/uploads/:id", fileHandler.AbortUpload)
+		protected.GET("/users/me/storage", fileHandler.StorageUsage)
 	}
-	// proxy to serve image
-	r.GET("/api/v1/protected", imageHandler.ProtectShowImage)
+	// Signed URL tự mang quyền truy cập nên không qua auth middleware
+	r.GET("/api/v1/files/:id", fileHandler.ServeSignedFile)
 }
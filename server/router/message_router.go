@@ -3,6 +3,7 @@ package router
 import (
 	"project/handler"
 	"project/middleware"
+	"project/service"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,7 +18,7 @@ func MessageRouter(r *gin.Engine,
 		{
 			{
 				messages.GET("/", messageHandler.GetAllMessageToConversation)
-				messages.POST("/send", messageHandler.SendMessageToConversation)
+				messages.POST("/send", authMiddleware.RequireVerifiedEmail(service.FeatureSendMessage), messageHandler.SendMessageToConversation)
 			}
 		}
 	}
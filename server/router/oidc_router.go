@@ -0,0 +1,16 @@
+package router
+
+import (
+	"project/handler"
+
+	"github.com/gin-gonic/gin"
+)
+
+func OIDCRouter(r *gin.Engine, oidcHandler *handler.OIDCHandler) {
+	oidc := r.Group("/api/v1/auth/oidc")
+	{
+		oidc.GET("/providers", oidcHandler.ListProviders)
+		oidc.GET("/:provider", oidcHandler.Login)
+		oidc.GET("/:provider/callback", oidcHandler.Callback)
+	}
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"project/handler"
 	"project/middleware"
+	"project/pkg/storage"
 	"project/service"
 	"project/websocket"
 
@@ -17,11 +18,22 @@ func SetupRouter(hub *websocket.Hub,
 	friendHandler *handler.FriendHandler,
 	wsHandler *websocket.WsHandler,
 	authService *service.AuthService,
-	imageHandler *handler.ImageHandler,
 	conversationHandler *handler.ConversationHandler,
 	messageHandler *handler.MessageHanlder,
+	oidcHandler *handler.OIDCHandler,
+	fileHandler *handler.FileHandler,
+	profileHandler *handler.ProfileHandler,
+	store storage.Store,
+	rateLimiter *middleware.RateLimiter,
+	healthHandler *handler.HealthHandler,
 ) *gin.Engine {
-	r := gin.Default()
+	// gin.Logger bị thay bằng log có cấu trúc vì nó ghi cả query string (/ws?token=...)
+	r := gin.New()
+	r.Use(gin.Recovery())
+	// Route đăng ký trước r.Use chỉ có các middleware đã Use trước đó
+	HealthRouter(r, healthHandler)
+	r.Use(middleware.RequestIDMiddleware(), middleware.RequestLoggerMiddleware())
+	r.Use(middleware.TracingMiddleware(), middleware.MetricsMiddleware())
 
 	// CORS middleware
 	r.Use(cors.New(cors.Config{
@@ -35,21 +47,23 @@ func SetupRouter(hub *websocket.Hub,
 			"https://devmess.cloud",
 		},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", middleware.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", "Content-Range", "Accept-Ranges", "ETag", middleware.RequestIDHeader},
 		AllowCredentials: true,
 	}))
-	r.Use(middleware.RateLimitMiddleware())
+	r.Use(rateLimiter.Middleware())
 
 	// Thêm route cho WebSocket
 	r.GET("/ws", wsHandler.ServeWs())
 
 	// Gọi các module router
 	AuthRouter(r, authHandler, authMiddleware)
-	UserRouter(r, authHandler, authMiddleware, userHandler)
-	ImageRouter(r, authMiddleware, imageHandler)
+	UserRouter(r, authHandler, authMiddleware, userHandler, profileHandler)
+	ImageRouter(r, authMiddleware, fileHandler)
+	StorageRouter(r, store)
 	FriendshipRouter(r, authMiddleware, friendHandler)
 	ConversationRouter(r, authMiddleware, conversationHandler)
 	MessageRouter(r, authMiddleware, messageHandler)
+	OIDCRouter(r, oidcHandler)
 	return r
 }
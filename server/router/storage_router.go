@@ -0,0 +1,22 @@
+package router
+
+import (
+	"project/pkg/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// storagePrefix phải khớp với path của STORAGE_PUBLIC_URL
+const storagePrefix = "/api/v1/storage"
+
+// StorageRouter phục vụ presigned URL khi dùng local store (S3 tự phục vụ presigned URL)
+func StorageRouter(r *gin.Engine, store storage.Store) {
+	local, ok := store.(*storage.LocalStore)
+	if !ok {
+		return
+	}
+	h := gin.WrapH(local.Handler(storagePrefix))
+	r.GET(storagePrefix+"/*key", h)
+	r.HEAD(storagePrefix+"/*key", h)
+	r.PUT(storagePrefix+"/*key", h)
+}
@@ -9,25 +9,37 @@ import (
 
 func UserRouter(r *gin.Engine, authHandler *handler.AuthHandler,
 	authMiddleware *middleware.AuthMiddleware,
-	userHanlder *handler.UserHandler) {
+	userHanlder *handler.UserHandler,
+	profileHandler *handler.ProfileHandler) {
 
 	v1 := r.Group("/api/v1")
 	{
 		auth := v1.Group("/auth")
 		{
-			auth.POST("/check-email", authHandler.CheckEmailExist)
 			auth.POST("/login", authHandler.LoginPassword)
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/forgot-password", authHandler.ForgotPassword)
 			auth.POST("/reset-password", authHandler.ResetPassword)
 			auth.GET("/refresh-token", authHandler.AuthRefreshToken)
+			auth.POST("/verify-email", authHandler.VerifyEmail)
+			auth.POST("/resend-verification", authMiddleware.VerifyAccessToken, authHandler.ResendVerificationEmail)
+			auth.POST("/magic-link", authHandler.RequestMagicLink)
+			auth.POST("/magic-link/consume", authHandler.ConsumeMagicLink)
 		}
 
 		// for user
 		users := v1.Group("/users", authMiddleware.VerifyAccessToken)
 		{
 			users.GET("/search", userHanlder.FindUserWithStatusFriends)
+			users.PATCH("/me", profileHandler.UpdateProfile)
+			users.PUT("/me/avatar", profileHandler.UpdateAvatar)
+			users.GET("/avatar-url", profileHandler.SignAvatarURL)
 		}
-		
+		// Avatar dùng signed URL nên thẻ <img> tải được mà không cần header Authorization
+		avatars := v1.Group("/avatars")
+		{
+			avatars.GET("/*path", profileHandler.ServeAvatar)
+		}
+
 	}
 }
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"errors"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"project/models"
+	"project/pkg/metrics"
+	"project/pkg/signedurl"
+	"project/pkg/storage"
+	"project/pkg/tracing"
+	"project/repository"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAttachmentNotFound  = errors.New("attachment not found")
+	ErrAttachmentNotOwned  = errors.New("attachment does not belong to the sender")
+	ErrAttachmentUsed      = errors.New("attachment already sent in another message")
+	ErrAttachmentForbidden = errors.New("you do not have access to this attachment")
+	ErrAttachmentNotReady  = errors.New("attachment upload is not completed")
+	ErrAttachmentScanning  = errors.New("attachment is being scanned for malware")
+	ErrAttachmentInfected  = errors.New("attachment was quarantined by the malware scanner")
+	ErrTooManyAttachments  = errors.New("too many attachments")
+	ErrEmptyMessage        = errors.New("message must have content or attachments")
+)
+
+// MaxAttachmentsPerMessage giới hạn số file gửi kèm 1 message
+const MaxAttachmentsPerMessage = 10
+
+type AttachmentService struct {
+	attachmentRepo  repository.AttachmentRepository
+	participantRepo repository.ParticipantRepository
+	userRepo        repository.UserRepository
+	store           storage.Store
+	blobs           *BlobStore
+	uploadCfg       UploadConfig
+	images          *ImageProcessor // nil = không xử lý ảnh
+	scans           *ScanWorker     // nil = không quét malware, file ready ngay sau khi upload
+	fileURLCfg      FileURLConfig
+	urlSigner       *signedurl.Signer
+}
+
+func NewAttachmentService(attachmentRepo repository.AttachmentRepository, participantRepo repository.ParticipantRepository, userRepo repository.UserRepository, store storage.Store, blobs *BlobStore, uploadCfg UploadConfig, fileURLCfg FileURLConfig, images *ImageProcessor, scans *ScanWorker) *AttachmentService {
+	return &AttachmentService{
+		attachmentRepo:  attachmentRepo,
+		participantRepo: participantRepo,
+		userRepo:        userRepo,
+		store:           store,
+		blobs:           blobs,
+		uploadCfg:       uploadCfg,
+		images:          images,
+		scans:           scans,
+		fileURLCfg:      fileURLCfg,
+		urlSigner:       signedurl.NewSigner(fileURLCfg.Secret),
+	}
+}
+
+// Upload lưu file vào store và tạo attachment chưa gắn với message nào.
+// Mime type được nhận diện từ nội dung file, file phải qua upload policy và còn đủ quota.
+// Checksum được tính trong lúc stream lên store, kích thước ảnh đọc từ header của file.
+func (s *AttachmentService) Upload(ctx context.Context, uploaderID uuid.UUID, fileName string, r io.ReadSeeker, size int64) (*models.Attachment, error) {
+	ctx, span := tracing.Start(ctx, "service", "AttachmentService.Upload")
+	defer span.End()
+	contentType, _, err := sniffContentType(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := s.uploadCfg.Policy.Check(contentType, size); err != nil {
+		return nil, err
+	}
+	if err := s.reserveStorage(ctx, uploaderID, size); err != nil {
+		return nil, err
+	}
+
+	attachment, err := s.storeUpload(ctx, uploaderID, fileName, r, size, contentType)
+	if err != nil {
+		s.releaseStorage(ctx, uploaderID, size)
+		return nil, err
+	}
+	metrics.UploadBytes.WithLabelValues("proxy").Add(float64(size))
+	s.enqueueProcessing(attachment)
+	return attachment, nil
+}
+
+// storeUpload lưu nội dung theo sha256 (dùng lại blob nếu đã có file giống hệt) rồi tạo attachment
+func (s *AttachmentService) storeUpload(ctx context.Context, uploaderID uuid.UUID, fileName string, r io.ReadSeeker, size int64, contentType string) (*models.Attachment, error) {
+	attachment := &models.Attachment{
+		ID:         uuid.New(),
+		UploaderID: uploaderID,
+		FileName:   filepath.Base(fileName),
+		MimeType:   contentType,
+		Size:       size,
+		Status:     s.uploadedStatus(),
+	}
+
+	// Đọc 1 lượt để tính hash (và kích thước ảnh, metadata audio), cần hash trước khi biết có phải upload lên storage không
+	content, err := inspectContent(r, contentType)
+	if err != nil {
+		return nil, err
+	}
+	content.apply(attachment)
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	blob, err := s.blobs.Put(ctx, content.Checksum, r, size, attachment.MimeType)
+	if err != nil {
+		return nil, err
+	}
+	attachment.StorageKey = blob.StorageKey
+
+	// Lỗi ở đây để lại blob không ai dùng, StorageGC sẽ dọn
+	if err := s.attachmentRepo.CreateAttachment(ctx, attachment); err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// uploadedStatus là status của file vừa upload xong: chờ quét malware nếu có scanner
+func (s *AttachmentService) uploadedStatus() string {
+	if s.scans != nil {
+		return models.AttachmentStatusPendingScan
+	}
+	return models.AttachmentStatusReady
+}
+
+// enqueueProcessing đưa file vừa upload vào hàng đợi quét malware (ScanWorker sẽ đưa tiếp ảnh sạch vào image pipeline),
+// hoặc thẳng vào image pipeline nếu không quét. File bị lỗi enqueue vẫn pending_scan và được quét lại khi Requeue.
+func (s *AttachmentService) enqueueProcessing(attachment *models.Attachment) {
+	if s.scans != nil {
+		if err := s.scans.Enqueue(attachment.ID); err != nil {
+			slog.Warn("enqueue malware scan failed", "attachment_id", attachment.ID, "err", err)
+		}
+		return
+	}
+	if s.images == nil || !strings.HasPrefix(attachment.MimeType, "image/") {
+		return
+	}
+	if err := s.images.Enqueue(attachment.ID); err != nil {
+		slog.Warn("enqueue image processing failed", "attachment_id", attachment.ID, "err", err)
+	}
+}
+
+// uploadKey là key tạm cho file client upload thẳng lên storage, được chuyển thành blob khi complete.
+// Không dùng tên file của client làm key để tránh trùng và path traversal.
+func uploadKey(id uuid.UUID, fileName string) string {
+	return "uploads/" + id.String() + strings.ToLower(filepath.Ext(fileName))
+}
+
+// GetForDownload chỉ cho participant của conversation chứa attachment tải file.
+// Attachment chưa gửi chỉ uploader tải được, file chưa quét xong hoặc bị quarantine không tải được.
+func (s *AttachmentService) GetForDownload(ctx context.Context, attachmentID, userID uuid.UUID) (*models.Attachment, error) {
+	ctx, span := tracing.Start(ctx, "service", "AttachmentService.GetForDownload")
+	defer span.End()
+	attachment, err := s.attachmentRepo.GetAttachmentByID(ctx, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if attachment == nil || attachment.Status == models.AttachmentStatusPending {
+		return nil, ErrAttachmentNotFound
+	}
+	if attachment.ConversationID == nil {
+		if attachment.UploaderID != userID {
+			return nil, ErrAttachmentForbidden
+		}
+	} else {
+		ok, err := s.participantRepo.IsParticipant(ctx, *attachment.ConversationID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrAttachmentForbidden
+		}
+	}
+	switch attachment.Status {
+	case models.AttachmentStatusPendingScan:
+		return nil, ErrAttachmentScanning
+	case models.AttachmentStatusQuarantined:
+		return nil, ErrAttachmentInfected
+	}
+	return attachment, nil
+}
+
+// validateAttachments kiểm tra các attachment thuộc về sender và chưa được gửi
+func validateAttachments(ctx context.Context, repo repository.AttachmentRepository, senderID uuid.UUID, ids []uuid.UUID) ([]models.Attachment, error) {
+	if len(ids) > MaxAttachmentsPerMessage {
+		return nil, ErrTooManyAttachments
+	}
+	attachments, err := repo.GetAttachmentsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(attachments) != len(ids) {
+		return nil, ErrAttachmentNotFound
+	}
+	for _, a := range attachments {
+		if a.UploaderID != senderID {
+			return nil, ErrAttachmentNotOwned
+		}
+		if a.Status == models.AttachmentStatusPending {
+			return nil, ErrAttachmentNotReady
+		}
+		if a.Status == models.AttachmentStatusQuarantined {
+			return nil, ErrAttachmentInfected
+		}
+		if a.MessageID != nil {
+			return nil, ErrAttachmentUsed
+		}
+	}
+	return attachments, nil
+}
+
+// messageTypeForAttachments chọn type của message theo mime type của attachment
+func messageTypeForAttachments(attachments []models.Attachment) string {
+	if len(attachments) == 0 {
+		return "text"
+	}
+	msgType := ""
+	for _, a := range attachments {
+		t := "file"
+		switch {
+		case strings.HasPrefix(a.MimeType, "image/"):
+			t = "image"
+		case strings.HasPrefix(a.MimeType, "video/"):
+			t = "video"
+		case strings.HasPrefix(a.MimeType, "audio/") && a.DurationMs > 0:
+			// File ghi âm đọc được thời lượng (Opus/WebM/M4A) là voice message
+			t = "voice"
+		}
+		if msgType != "" && msgType != t {
+			return "file"
+		}
+		msgType = t
+	}
+	return msgType
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"project/models"
+	"project/repository"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMessageRepo chỉ cài đặt các hàm SendMessageToConversation dùng
+type fakeMessageRepo struct {
+	repository.MessageRepository
+	created   []*models.Message
+	attachErr error // lỗi khi gán attachment, message không được tạo (transaction rollback)
+}
+
+func (r *fakeMessageRepo) CreateMessageWithAttachments(ctx context.Context, message *models.Message, attachmentIDs []uuid.UUID) (*models.Message, error) {
+	if len(attachmentIDs) > 0 && r.attachErr != nil {
+		return nil, r.attachErr
+	}
+	r.created = append(r.created, message)
+	return message, nil
+}
+
+type fakeConversationRepo struct {
+	repository.ConversationRepository
+}
+
+func (r *fakeConversationRepo) UpdateLastMessageIDInConversation(ctx context.Context, conversationID, messageID uuid.UUID) error {
+	return nil
+}
+
+func TestSendMessageWithAttachments(t *testing.T) {
+	senderID, otherID := uuid.New(), uuid.New()
+	conversationID := uuid.New()
+	usedMessageID := uuid.New()
+
+	attachments := map[uuid.UUID]models.Attachment{}
+	add := func(uploader uuid.UUID, mimeType string, messageID *uuid.UUID) string {
+		a := models.Attachment{ID: uuid.New(), UploaderID: uploader, MimeType: mimeType, MessageID: messageID}
+		attachments[a.ID] = a
+		return a.ID.String()
+	}
+	img1 := add(senderID, "image/png", nil)
+	img2 := add(senderID, "image/jpeg", nil)
+	pdf := add(senderID, "application/pdf", nil)
+	foreign := add(otherID, "image/png", nil)
+	used := add(senderID, "image/png", &usedMessageID)
+	song := add(senderID, "audio/mpeg", nil)
+	voice := uuid.New()
+	attachments[voice] = models.Attachment{ID: voice, UploaderID: senderID, MimeType: "audio/ogg", DurationMs: 3200}
+
+	attachmentRepo := &repository.MockAttachmentRepository{
+		MockGetAttachmentsByIDs: func(ctx context.Context, ids []uuid.UUID) ([]models.Attachment, error) {
+			var result []models.Attachment
+			for _, id := range ids {
+				if a, ok := attachments[id]; ok {
+					result = append(result, a)
+				}
+			}
+			return result, nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		content     string
+		ids         []string
+		expectedErr error
+		expectType  string
+	}{
+		{name: "Text only", content: "hi", expectType: "text"},
+		{name: "Empty message", content: "  ", expectedErr: ErrEmptyMessage},
+		{name: "Images only", ids: []string{img1, img2, img1}, expectType: "image"},
+		{name: "Mixed types", content: "docs", ids: []string{img1, pdf}, expectType: "file"},
+		{name: "Voice message", ids: []string{voice.String()}, expectType: "voice"},
+		{name: "Audio without duration", ids: []string{song}, expectType: "file"},
+		{name: "Not owned", ids: []string{foreign}, expectedErr: ErrAttachmentNotOwned},
+		{name: "Already used", ids: []string{used}, expectedErr: ErrAttachmentUsed},
+		{name: "Unknown id", ids: []string{uuid.NewString()}, expectedErr: ErrAttachmentNotFound},
+		{name: "Invalid id", ids: []string{"abc"}, expectedErr: ErrAttachmentNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := NewMessageService(&fakeMessageRepo{}, &fakeConversationRepo{}, attachmentRepo)
+			msg, err := s.SendMessageToConversation(ctx, senderID.String(), conversationID.String(), tt.content, tt.ids)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectType, msg.Type)
+			for _, a := range msg.Attachments {
+				assert.Equal(t, msg.ID, *a.MessageID)
+				assert.Equal(t, conversationID, *a.ConversationID)
+			}
+		})
+	}
+}
+
+func TestSendMessageFailsWhenAttachmentRaced(t *testing.T) {
+	ctx := context.Background()
+	senderID := uuid.New()
+	a := models.Attachment{ID: uuid.New(), UploaderID: senderID, MimeType: "image/png"}
+	attachmentRepo := &repository.MockAttachmentRepository{
+		MockGetAttachmentsByIDs: func(ctx context.Context, ids []uuid.UUID) ([]models.Attachment, error) {
+			return []models.Attachment{a}, nil
+		},
+	}
+	// Request khác đã gán attachment trước
+	messageRepo := &fakeMessageRepo{attachErr: repository.ErrAttachmentsUnavailable}
+	s := NewMessageService(messageRepo, &fakeConversationRepo{}, attachmentRepo)
+
+	_, err := s.SendMessageToConversation(ctx, senderID.String(), uuid.NewString(), "", []string{a.ID.String()})
+	assert.ErrorIs(t, err, ErrAttachmentUsed)
+	assert.Empty(t, messageRepo.created)
+}
+
+func TestGetForDownloadRequiresParticipant(t *testing.T) {
+	ctx := context.Background()
+	uploaderID, memberID, strangerID := uuid.New(), uuid.New(), uuid.New()
+	conversationID := uuid.New()
+	sent := &models.Attachment{ID: uuid.New(), UploaderID: uploaderID, ConversationID: &conversationID}
+	draft := &models.Attachment{ID: uuid.New(), UploaderID: uploaderID}
+
+	attachmentRepo := &repository.MockAttachmentRepository{
+		MockGetAttachmentByID: func(ctx context.Context, id uuid.UUID) (*models.Attachment, error) {
+			switch id {
+			case sent.ID:
+				return sent, nil
+			case draft.ID:
+				return draft, nil
+			}
+			return nil, nil
+		},
+	}
+	s := NewAttachmentService(attachmentRepo, &fakeParticipantRepo{members: map[uuid.UUID]bool{uploaderID: true, memberID: true}}, &repository.MockUserRepository{}, nil, nil, UploadConfig{}, FileURLConfig{Secret: []byte("test"), TTL: time.Minute}, nil, nil)
+
+	_, err := s.GetForDownload(ctx, sent.ID, memberID)
+	assert.NoError(t, err)
+	_, err = s.GetForDownload(ctx, sent.ID, strangerID)
+	assert.ErrorIs(t, err, ErrAttachmentForbidden)
+	_, err = s.GetForDownload(ctx, draft.ID, uploaderID)
+	assert.NoError(t, err)
+	_, err = s.GetForDownload(ctx, draft.ID, memberID)
+	assert.ErrorIs(t, err, ErrAttachmentForbidden)
+	_, err = s.GetForDownload(ctx, uuid.New(), memberID)
+	assert.ErrorIs(t, err, ErrAttachmentNotFound)
+}
+
+func TestSignedURL(t *testing.T) {
+	ctx := context.Background()
+	memberID, strangerID := uuid.New(), uuid.New()
+	conversationID := uuid.New()
+	attachment := &models.Attachment{
+		ID:             uuid.New(),
+		UploaderID:     memberID,
+		ConversationID: &conversationID,
+		StorageKey:     "attachments/a.jpg",
+		Renditions:     []models.AttachmentRendition{{Name: "thumb", StorageKey: "attachments/renditions/a/thumb.jpg"}},
+	}
+	attachmentRepo := &repository.MockAttachmentRepository{
+		MockGetAttachmentByID: func(ctx context.Context, id uuid.UUID) (*models.Attachment, error) {
+			return attachment, nil
+		},
+	}
+	participants := &fakeParticipantRepo{members: map[uuid.UUID]bool{memberID: true}}
+	s := NewAttachmentService(attachmentRepo, participants, &repository.MockUserRepository{}, nil, nil, UploadConfig{}, FileURLConfig{Secret: []byte("test"), TTL: time.Minute}, nil, nil)
+
+	_, _, err := s.SignedURL(ctx, attachment.ID, strangerID, "")
+	assert.ErrorIs(t, err, ErrAttachmentForbidden)
+	_, _, err = s.SignedURL(ctx, attachment.ID, memberID, "huge")
+	assert.ErrorIs(t, err, ErrAttachmentNotFound)
+
+	raw, expiresAt, err := s.SignedURL(ctx, attachment.ID, memberID, "thumb")
+	require.NoError(t, err)
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	assert.Equal(t, FileURLPrefix+attachment.ID.String(), u.Path)
+
+	_, key, exp, err := s.OpenSignedURL(ctx, attachment.ID, u.Query())
+	require.NoError(t, err)
+	assert.Equal(t, "attachments/renditions/a/thumb.jpg", key)
+	assert.True(t, exp.Equal(expiresAt))
+
+	// Đổi rendition hoặc attachment khác làm chữ ký không còn hợp lệ
+	q := u.Query()
+	q.Del("rendition")
+	_, _, _, err = s.OpenSignedURL(ctx, attachment.ID, q)
+	assert.ErrorIs(t, err, ErrInvalidFileURL)
+	_, _, _, err = s.OpenSignedURL(ctx, uuid.New(), u.Query())
+	assert.ErrorIs(t, err, ErrInvalidFileURL)
+
+	// User rời conversation thì URL đã cấp cũng không dùng được nữa
+	participants.members[memberID] = false
+	_, _, _, err = s.OpenSignedURL(ctx, attachment.ID, u.Query())
+	assert.ErrorIs(t, err, ErrAttachmentForbidden)
+}
+
+type fakeParticipantRepo struct {
+	repository.ParticipantRepository
+	members map[uuid.UUID]bool
+}
+
+func (r *fakeParticipantRepo) IsParticipant(ctx context.Context, conversationID, userID uuid.UUID) (bool, error) {
+	return r.members[userID], nil
+}
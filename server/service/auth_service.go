@@ -1,13 +1,18 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
-	"os"
 	"project/models"
+	"project/pkg/imageproc"
+	"project/pkg/storage"
+	"project/pkg/tracing"
 	"project/repository"
 	"project/utils"
 	"strings"
@@ -28,14 +33,23 @@ type AuthService struct {
 	tokenRepo  repository.TokenRepository
 	redisRepo  repository.RedisRepository
 	authGoogle *oauth2.Config
+	store      storage.Store
+	httpClient *http.Client // tải avatar từ provider
 }
 
+const (
+	avatarDownloadTimeout = 10 * time.Second
+	// maxRemoteAvatarSize giới hạn số byte đọc khi tải avatar từ provider
+	maxRemoteAvatarSize = 10 << 20
+)
+
 func NewAuthService(
 	userRepo repository.UserRepository,
 	deviceRepo repository.DeviceRepository,
 	tokenRepo repository.TokenRepository,
 	redisRepo repository.RedisRepository,
 	authGoogle *oauth2.Config,
+	store storage.Store,
 ) *AuthService {
 	return &AuthService{
 		userRepo:   userRepo,
@@ -43,28 +57,41 @@ func NewAuthService(
 		tokenRepo:  tokenRepo,
 		redisRepo:  redisRepo,
 		authGoogle: authGoogle,
+		store:      store,
+		httpClient: &http.Client{Timeout: avatarDownloadTimeout},
 	}
 }
 
 // LoginWithGoogle xử lý logic login Google
-func (a *AuthService) LoginWithGoogle(
+func (a *AuthService) LoginWithGoogle(ctx context.Context,
 	userInfo *models.GoogleUserInfo,
 	ip, userAgent string,
 ) (*models.Token, *models.Device, error) {
-	fmt.Println("🔹 Start LoginWithGoogle")
+	ctx, span := tracing.Start(ctx, "service", "AuthService.LoginWithGoogle")
+	defer span.End()
+	slog.Debug("login with Google", "email", userInfo.Email)
 
 	// 1️⃣ Kiểm tra user đã tồn tại
-	user, err := a.userRepo.GetUserByEmail(userInfo.Email)
+	user, err := a.userRepo.GetUserByEmail(ctx, userInfo.Email)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	if user == nil {
+	if user != nil {
+		// Giống OIDCService.resolveUser: chỉ đăng nhập vào tài khoản có sẵn khi Google đã xác thực email
+		// và tài khoản cũng đã xác thực, tránh chiếm tài khoản đăng ký trước bằng email của người khác
+		if !userInfo.VerifiedEmail {
+			return nil, nil, ErrOIDCEmailNotVerified
+		}
+		if !IsEmailVerified(user) {
+			return nil, nil, ErrOIDCAccountNotLinked
+		}
+	} else {
 		// save avater to server
 		uuid_user := uuid.New()
-		urlImage, err := a.SaveImageGoogle(userInfo.Picture, fmt.Sprintf("%s.jpg", uuid_user.String()))
+		urlImage, err := a.SaveImageGoogle(ctx, userInfo.Picture, fmt.Sprintf("%s.jpg", uuid_user.String()))
 		if err != nil {
-			fmt.Println("❌ Failed to save image:", err)
+			slog.Warn("failed to save Google avatar", "err", err)
 			urlImage = "avatar/img.jpg"
 		}
 		user = &models.User{
@@ -74,54 +101,77 @@ func (a *AuthService) LoginWithGoogle(
 			Avatar:   urlImage,
 			Provider: "google",
 		}
-		user, err = a.userRepo.CreateUser(user)
+		if userInfo.VerifiedEmail {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+		user, err = a.userRepo.CreateUser(ctx, user)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to create user: %w", err)
 		}
 	}
 
 	// Gọi hàm tạo session chung
-	return a.CreateSession(user, ip, userAgent, userInfo.AccessToken, userInfo.RefreshToken, int64(userInfo.ExpiresIn), "google")
+	return a.CreateSession(ctx, user, ip, userAgent, userInfo.AccessToken, userInfo.RefreshToken, int64(userInfo.ExpiresIn), "google")
 }
-func (h *AuthService) SaveImageGoogle(imageURL string, fileName string) (string, error) {
-	// Implement your logic to save the image URL to Google Drive or any other service
-	//download image from imageURL and save to google drive
-	resp, err := http.Get(imageURL)
+
+// SaveImageGoogle tải avatar từ provider và lưu vào storage, trả về key dạng "avatar/<fileName>".
+// URL lấy từ claim picture nên response bị giới hạn thời gian và kích thước, ảnh được decode và
+// encode lại thành JPEG giống avatar upload, nội dung không phải ảnh bị từ chối.
+func (h *AuthService) SaveImageGoogle(ctx context.Context, imageURL string, fileName string) (string, error) {
+	ctx, span := tracing.Start(ctx, "service", "AuthService.SaveImageGoogle")
+	defer span.End()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-
-	// Save the image to Google Drive or any other service
-	// ...
-	if err := os.MkdirAll("uploads/avatar", os.ModePerm); err != nil {
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
 		return "", err
 	}
-	file, err := os.Create("uploads/avatar/" + fileName)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download avatar returned status %d", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "image/") {
+		return "", fmt.Errorf("download avatar: unexpected content type %q", contentType)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteAvatarSize+1))
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
+	if len(data) > maxRemoteAvatarSize {
+		return "", fmt.Errorf("download avatar: larger than %d bytes", maxRemoteAvatarSize)
+	}
 
-	_, err = file.ReadFrom(resp.Body)
+	// Chỉ giữ bản lớn nhất: avatar của provider được lưu một file như trước
+	renditions, err := imageproc.Avatar(bytes.NewReader(data), imageproc.DefaultAvatarSizes[len(imageproc.DefaultAvatarSizes)-1:], imageproc.DefaultOptions())
 	if err != nil {
+		return "", fmt.Errorf("download avatar: %w", err)
+	}
+	avatar := renditions[0]
+
+	key := AvatarKey(fileName)
+	if err := h.store.Put(ctx, key, bytes.NewReader(avatar.Data), int64(len(avatar.Data)), avatar.ContentType); err != nil {
 		return "", err
 	}
-	return "avatar/" + fileName, nil
+	return key, nil
 }
 
 // CreateSession tạo hoặc cập nhật device, tạo token và lưu vào Redis
-func (a *AuthService) CreateSession(
+func (a *AuthService) CreateSession(ctx context.Context,
 
 	user *models.User,
 	ip, userAgent, accessToken, refreshToken string,
 	expiresIn int64,
 	provider string,
 ) (*models.Token, *models.Device, error) {
+	ctx, span := tracing.Start(ctx, "service", "AuthService.CreateSession")
+	defer span.End()
 	// 2️⃣ Nhận dạng thiết bị
 	deviceType, deviceName := detectDevice(userAgent)
 	user.Provider = provider
-	existingDevice, err := a.deviceRepo.GetDeviceByInfo(user.ID, ip, userAgent)
+	existingDevice, err := a.deviceRepo.GetDeviceByInfo(ctx, user.ID, ip, userAgent)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to check existing device: %w", err)
 	}
@@ -136,23 +186,23 @@ func (a *AuthService) CreateSession(
 			IP:        ip,
 			UserAgent: userAgent,
 		}
-		if _, err := a.deviceRepo.CreateDevice(device); err != nil {
+		if _, err := a.deviceRepo.CreateDevice(ctx, device); err != nil {
 			return nil, nil, err
 		}
 	} else {
 		device = existingDevice
-		if _, err := a.deviceRepo.UpdateDevice(device); err != nil {
+		if _, err := a.deviceRepo.UpdateDevice(ctx, device); err != nil {
 			return nil, nil, err
 		}
 	}
 
 	// 3️⃣ Token
-	existingToken, err := a.tokenRepo.GetTokensByDeviceID(user.ID, device.ID.String())
+	existingToken, err := a.tokenRepo.GetTokensByDeviceID(ctx, user.ID, device.ID.String())
 	if err != nil {
 		return nil, nil, err
 	}
 	if existingToken != nil {
-		if err := a.tokenRepo.DeleteTokenByID(existingToken.ID); err != nil {
+		if err := a.tokenRepo.DeleteTokenByID(ctx, existingToken.ID); err != nil {
 			return nil, nil, err
 		}
 	}
@@ -186,13 +236,13 @@ func (a *AuthService) CreateSession(
 		DeviceID:     device.ID,
 		TokenType:    provider,
 	}
-	fmt.Printf("Token struct: %+v\n", token)
-	if err := a.tokenRepo.CreateToken(token); err != nil {
+	slog.Debug("session created", "user_id", user.ID, "device_id", device.ID, "provider", provider)
+	if err := a.tokenRepo.CreateToken(ctx, token); err != nil {
 		return nil, nil, err
 	}
 	time_duration := time.Until(time.Unix(token.ExpiresAt, 0))
-	if err := a.redisRepo.SetToken(token.AccessToken, user, time_duration); err != nil {
-		fmt.Println("❌ Failed to save access token to Redis:", err)
+	if err := a.redisRepo.SetToken(ctx, token.AccessToken, user, time_duration); err != nil {
+		slog.Error("failed to save access token to Redis", "user_id", user.ID, "err", err)
 		return token, device, errors.New("error save access token")
 	}
 	return token, device, nil
@@ -222,13 +272,15 @@ func detectDevice(userAgent string) (string, string) {
 	return deviceType, deviceName
 }
 
-func (a *AuthService) VerifyAccessToken(accessToken string) (*models.User, string, error) {
+func (a *AuthService) VerifyAccessToken(ctx context.Context, accessToken string) (*models.User, string, error) {
+	ctx, span := tracing.Start(ctx, "service", "AuthService.VerifyAccessToken")
+	defer span.End()
 	// check redis exits token
-	if user, err := a.redisRepo.GetUserByToken(accessToken); err == nil {
+	if user, err := a.redisRepo.GetUserByToken(ctx, accessToken); err == nil {
 
 		return user, "", nil
 	}
-	user, refreshToken, err := a.tokenRepo.GetUserForAccessToken(accessToken)
+	user, refreshToken, err := a.tokenRepo.GetUserForAccessToken(ctx, accessToken)
 
 	// 1️⃣ Nếu có lỗi nhưng repo vẫn cấp refresh token mới
 	if err != nil {
@@ -249,61 +301,65 @@ func (a *AuthService) VerifyAccessToken(accessToken string) (*models.User, strin
 	return nil, "", ErrInvalidOrExpired
 }
 
-func RefreshAccessToken(refreshToken string, googleAuthConfig *oauth2.Config) (*oauth2.Token, error) {
+func RefreshAccessToken(ctx context.Context, refreshToken string, googleAuthConfig *oauth2.Config) (*oauth2.Token, error) {
 	token := &oauth2.Token{RefreshToken: refreshToken}
-	newToken, err := TokenSourceFunc(googleAuthConfig, context.Background(), token).Token()
+	newToken, err := TokenSourceFunc(googleAuthConfig, ctx, token).Token()
 	if err != nil {
 		return nil, err
 	}
 
 	return newToken, nil
 }
-func (a *AuthService) RefreshToken(refreshToken string, accessToken string, googleAuthConfig *oauth2.Config) (*models.Token, error) {
-	token, err := a.tokenRepo.GetTokenByRefresh(refreshToken)
+func (a *AuthService) RefreshToken(ctx context.Context, refreshToken string, accessToken string, googleAuthConfig *oauth2.Config) (*models.Token, error) {
+	ctx, span := tracing.Start(ctx, "service", "AuthService.RefreshToken")
+	defer span.End()
+	token, err := a.tokenRepo.GetTokenByRefresh(ctx, refreshToken)
 	var user *models.User
 	if err != nil {
-		a.redisRepo.DeleteToken(accessToken)
+		a.redisRepo.DeleteToken(ctx, accessToken)
 		return nil, err
 	}
 
-	user, err = a.redisRepo.GetUserByToken(accessToken)
+	user, err = a.redisRepo.GetUserByToken(ctx, accessToken)
 	if err != nil {
 		// check in sql
-		user, err = a.userRepo.GetUserByAccesToken(accessToken)
+		user, err = a.userRepo.GetUserByAccesToken(ctx, accessToken)
 		if err != nil {
 			return nil, errors.New("can not get user, please login again")
 		}
 	}
 	if token.TokenType == "google" {
 		// Xử lý làm mới token Google nếu cần thiết
-		// Giả sử bạn có hàm `RefreshGoogleToken` để làm việc này
-		newToken, err := RefreshAccessToken(token.RefreshToken,
+		if googleAuthConfig == nil {
+			return nil, errors.New("google oauth is not configured")
+		}
+		newToken, err := RefreshAccessToken(ctx, token.RefreshToken,
 			googleAuthConfig)
 		if err != nil {
-			a.redisRepo.DeleteToken(accessToken)
+			a.redisRepo.DeleteToken(ctx, accessToken)
 			return nil, err
 		}
 		if newToken != nil {
 			token.AccessToken = newToken.AccessToken
 			token.ExpiresAt = newToken.Expiry.Unix()
 			token.RefreshToken = newToken.RefreshToken
-			if err := a.tokenRepo.UpdateToken(token); err != nil {
+			if err := a.tokenRepo.UpdateToken(ctx, token); err != nil {
 
-				a.redisRepo.DeleteToken(accessToken)
+				a.redisRepo.DeleteToken(ctx, accessToken)
 				return nil, err
 			}
-			a.redisRepo.SetToken(token.AccessToken, user, 0)
+			a.redisRepo.SetToken(ctx, token.AccessToken, user, 0)
 			return token, nil
 		}
 	} else {
 		// kiểm tra refresh token còn thời gian không
 		claims, err := utils.ValidateRefreshToken(token.RefreshToken)
 		if err != nil {
-			a.redisRepo.DeleteToken(accessToken)
+			a.redisRepo.DeleteToken(ctx, accessToken)
 			return nil, err
 		}
 		if claims.ExpiresAt.Unix() < time.Now().Unix() {
-			a.redisRepo.DeleteToken(accessToken)
+			a.redisRepo.DeleteToken(ctx, accessToken)
 			return nil, errors.New("refresh token has expired")
 		}
 		// Xử lý làm mới token thông thường
@@ -315,15 +371,17 @@ func (a *AuthService) RefreshToken(refreshToken string, accessToken string, goog
 		}
 		token.AccessToken = newAccessToken
 
-		if err := a.tokenRepo.UpdateToken(token); err != nil {
+		if err := a.tokenRepo.UpdateToken(ctx, token); err != nil {
 			return nil, err
 		}
-		a.redisRepo.SetToken(token.AccessToken, user, 0)
+		a.redisRepo.SetToken(ctx, token.AccessToken, user, 0)
 		return token, nil
 	}
 	return nil, nil
 }
-func (a *AuthService) HandleGoogleCallback(code, ip, userAgent string, GoogleOAuthConfig *oauth2.Config) (*models.GoogleUserInfo, *models.Token, *models.Device, error) {
+func (a *AuthService) HandleGoogleCallback(ctx context.Context, code, ip, userAgent string, GoogleOAuthConfig *oauth2.Config) (*models.GoogleUserInfo, *models.Token, *models.Device, error) {
+	ctx, span := tracing.Start(ctx, "service", "AuthService.HandleGoogleCallback")
+	defer span.End()
 	if GoogleOAuthConfig == nil {
 		return nil, nil, nil, errors.New("GoogleOAuthConfig not initialized")
 	}
@@ -354,9 +412,9 @@ func (a *AuthService) HandleGoogleCallback(code, ip, userAgent string, GoogleOAu
 	// userInfo.ExpiresIn = token.Expiry.Unix()
 	userInfo.ExpiresIn = time.Now().Add(24 * time.Hour).Unix()
 
-	tokenModel, device, err := a.LoginWithGoogle(&userInfo, ip, userAgent)
+	tokenModel, device, err := a.LoginWithGoogle(ctx, &userInfo, ip, userAgent)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("login failed: %v", err)
+		return nil, nil, nil, fmt.Errorf("login failed: %w", err)
 	}
 
 	return &userInfo, tokenModel, device, nil
@@ -1,14 +1,24 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"errors"
-	// "project/models"
-	// "project/repository"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"project/models"
+	"project/pkg/storage"
+	"project/repository"
+	"time"
 
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
 )
 
@@ -180,7 +190,7 @@ func TestRefreshAccessToken(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			TokenSourceFunc = tc.mockFunc
 
-			token, err := RefreshAccessToken( "refresh_token", &oauth2.Config{})
+			token, err := RefreshAccessToken(context.Background(), "refresh_token", &oauth2.Config{})
 
 			if tc.expectedErr != nil {
 				assert.Error(t, err)
@@ -202,3 +212,118 @@ type mockTokenSource struct {
 func (m mockTokenSource) Token() (*oauth2.Token, error) {
 	return m.token, m.err
 }
+
+func TestLoginWithGoogleExistingAccount(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name           string
+		existing       *models.User
+		googleVerified bool
+		expectedErr    error
+	}{
+		{
+			name:           "Verified Google email logs into verified account",
+			existing:       &models.User{ID: uuid.New(), Email: "a@example.com", Provider: "local", EmailVerifiedAt: &now},
+			googleVerified: true,
+		},
+		{
+			name:           "Unverified Google email",
+			existing:       &models.User{ID: uuid.New(), Email: "a@example.com", Provider: "local", EmailVerifiedAt: &now},
+			googleVerified: false,
+			expectedErr:    ErrOIDCEmailNotVerified,
+		},
+		{
+			name:           "Unverified local account is not taken over",
+			existing:       &models.User{ID: uuid.New(), Email: "a@example.com", Provider: "local"},
+			googleVerified: true,
+			expectedErr:    ErrOIDCAccountNotLinked,
+		},
+		{
+			// Tài khoản Google tạo khi Google báo verified_email=false không được coi là đã xác thực
+			name:           "Google account without verified email",
+			existing:       &models.User{ID: uuid.New(), Email: "a@example.com", Provider: "google"},
+			googleVerified: true,
+			expectedErr:    ErrOIDCAccountNotLinked,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := &repository.MockUserRepository{
+				MockGetUserByEmail: func(ctx context.Context, email string) (*models.User, error) {
+					return tt.existing, nil
+				},
+				MockCreateUser: func(ctx context.Context, user *models.User) (*models.User, error) {
+					t.Fatal("existing account must not be recreated")
+					return nil, nil
+				},
+			}
+			deviceRepo := repository.NewMemoryDeviceRepository(repository.NewMemoryStore())
+			s := NewAuthService(userRepo, deviceRepo, &repository.MockTokenRepository{}, repository.NewMemoryRedisRepository(), nil, nil)
+
+			token, _, err := s.LoginWithGoogle(context.Background(), &models.GoogleUserInfo{
+				Email:         "a@example.com",
+				VerifiedEmail: tt.googleVerified,
+			}, "127.0.0.1", "test")
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Nil(t, token)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, token)
+		})
+	}
+}
+
+func TestSaveImageGoogle(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 40; y++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/avatar.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(buf.Bytes())
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		case "/fake.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("not an image"))
+		case "/huge.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(make([]byte, maxRemoteAvatarSize+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := storage.NewLocalStore(storage.LocalConfig{Root: t.TempDir(), PublicURL: "http://localhost/storage", SigningKey: "test"})
+	require.NoError(t, err)
+	s := NewAuthService(nil, nil, nil, nil, nil, store)
+
+	key, err := s.SaveImageGoogle(ctx, srv.URL+"/avatar.png", "user.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "avatar/user.jpg", key)
+	info, err := store.Stat(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", info.ContentType)
+
+	// Response không phải ảnh hoặc quá lớn không được lưu
+	for _, path := range []string{"/page.html", "/fake.png", "/huge.png", "/missing.png"} {
+		t.Run(path, func(t *testing.T) {
+			_, err := s.SaveImageGoogle(ctx, srv.URL+path, "rejected.jpg")
+			assert.Error(t, err)
+			_, err = store.Stat(ctx, "avatar/rejected.jpg")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"project/models"
+	"project/pkg/storage"
+	"project/pkg/tracing"
+	"project/repository"
+)
+
+// BlobKey là key của nội dung trên storage, chia thư mục theo 2 ký tự đầu của hash
+func BlobKey(checksum string) string {
+	return "blobs/" + checksum[:2] + "/" + checksum
+}
+
+// BlobStore lưu file theo sha256 của nội dung: nội dung trùng chỉ lưu 1 lần trên storage.
+// Blob được tham chiếu qua storage_key của attachments/attachment_renditions và bị StorageGC xóa khi không còn ai dùng.
+type BlobStore struct {
+	blobRepo repository.BlobRepository
+	store    storage.Store
+}
+
+func NewBlobStore(blobRepo repository.BlobRepository, store storage.Store) *BlobStore {
+	return &BlobStore{blobRepo: blobRepo, store: store}
+}
+
+// Put lưu r (có sha256 = checksum) nếu nội dung chưa có, ngược lại chỉ đánh dấu blob cũ được dùng lại
+func (b *BlobStore) Put(ctx context.Context, checksum string, r io.Reader, size int64, contentType string) (*models.Blob, error) {
+	ctx, span := tracing.Start(ctx, "service", "BlobStore.Put")
+	defer span.End()
+	if blob, err := b.blobRepo.TouchBlob(ctx, checksum); err != nil || blob != nil {
+		return blob, err
+	}
+	blob := &models.Blob{Checksum: checksum, StorageKey: BlobKey(checksum), Size: size, MimeType: contentType}
+	if err := b.store.Put(ctx, blob.StorageKey, r, size, contentType); err != nil {
+		return nil, err
+	}
+	if err := b.blobRepo.CreateBlob(ctx, blob); err != nil {
+		return nil, err
+	}
+	return blob, nil
+}
+
+// PutBytes tính sha256 rồi lưu data, dùng cho file sinh ra ở server (rendition, ảnh đã bỏ EXIF)
+func (b *BlobStore) PutBytes(ctx context.Context, data []byte, contentType string) (*models.Blob, error) {
+	ctx, span := tracing.Start(ctx, "service", "BlobStore.PutBytes")
+	defer span.End()
+	sum := sha256.Sum256(data)
+	return b.Put(ctx, hex.EncodeToString(sum[:]), bytes.NewReader(data), int64(len(data)), contentType)
+}
+
+// Adopt chuyển object client đã upload thẳng lên tempKey thành blob: dùng blob có sẵn nếu trùng nội dung,
+// không thì copy sang BlobKey. tempKey luôn bị xóa.
+func (b *BlobStore) Adopt(ctx context.Context, tempKey, checksum string, size int64, contentType string) (*models.Blob, error) {
+	ctx, span := tracing.Start(ctx, "service", "BlobStore.Adopt")
+	defer span.End()
+	blob, err := b.blobRepo.TouchBlob(ctx, checksum)
+	if err != nil {
+		return nil, err
+	}
+	if blob == nil {
+		blob = &models.Blob{Checksum: checksum, StorageKey: BlobKey(checksum), Size: size, MimeType: contentType}
+		if err := b.store.Copy(ctx, tempKey, blob.StorageKey); err != nil {
+			return nil, err
+		}
+		if err := b.blobRepo.CreateBlob(ctx, blob); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.store.Delete(ctx, tempKey); err != nil {
+		slog.WarnContext(ctx, "delete temp upload object failed", "key", tempKey, "err", err)
+	}
+	return blob, nil
+}
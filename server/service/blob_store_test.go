@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"project/models"
+	"project/pkg/storage"
+	"project/repository"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMemBlobRepo giữ bảng blobs trong map, đủ cho các test không cần DB
+func newMemBlobRepo() *repository.MockBlobRepository {
+	blobs := map[string]*models.Blob{}
+	return &repository.MockBlobRepository{
+		MockCreateBlob: func(ctx context.Context, blob *models.Blob) error {
+			if _, ok := blobs[blob.Checksum]; !ok {
+				clone := *blob
+				blobs[blob.Checksum] = &clone
+			}
+			return nil
+		},
+		MockTouchBlob: func(ctx context.Context, checksum string) (*models.Blob, error) {
+			blob, ok := blobs[checksum]
+			if !ok {
+				return nil, nil
+			}
+			blob.LastUsedAt = time.Now()
+			clone := *blob
+			return &clone, nil
+		},
+	}
+}
+
+func TestUploadDeduplicatesIdenticalContent(t *testing.T) {
+	ctx := context.Background()
+	s, attachments := newUploadTestService(t, UploadConfig{})
+
+	first, err := s.Upload(ctx, uuid.New(), "report.pdf", strings.NewReader("%PDF-1.4 same content"), 21)
+	require.NoError(t, err)
+	second, err := s.Upload(ctx, uuid.New(), "copy.pdf", strings.NewReader("%PDF-1.4 same content"), 21)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first.ID, second.ID)
+	assert.Equal(t, first.Checksum, second.Checksum)
+	assert.Equal(t, BlobKey(first.Checksum), first.StorageKey)
+	assert.Equal(t, first.StorageKey, second.StorageKey)
+	assert.Equal(t, "report.pdf", attachments[first.ID].FileName)
+
+	rc, info, err := s.store.Get(ctx, first.StorageKey)
+	require.NoError(t, err)
+	rc.Close()
+	assert.Equal(t, "application/pdf", info.ContentType)
+}
+
+func TestCompleteUploadReusesExistingBlob(t *testing.T) {
+	ctx := context.Background()
+	s, attachments := newUploadTestService(t, UploadConfig{URLExpiry: time.Minute, MultipartThreshold: 1 << 20})
+	userID := uuid.New()
+
+	existing, err := s.Upload(ctx, userID, "a.txt", strings.NewReader("hello"), 5)
+	require.NoError(t, err)
+
+	session, err := s.CreateUpload(ctx, userID, "b.txt", "text/plain", 5)
+	require.NoError(t, err)
+	tempKey := attachments[session.UploadID].StorageKey
+	putTo(t, session.URL, "text/plain", "hello")
+
+	attachment, err := s.CompleteUpload(ctx, userID, session.UploadID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, existing.StorageKey, attachment.StorageKey)
+	_, err = s.store.Stat(ctx, tempKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestStorageGCRemovesOrphans(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewLocalStore(storage.LocalConfig{Root: t.TempDir(), PublicURL: "http://localhost/storage", SigningKey: "test"})
+	require.NoError(t, err)
+	require.NoError(t, store.Put(ctx, "blobs/ab/abc", strings.NewReader("x"), 1, "text/plain"))
+	require.NoError(t, store.Put(ctx, "uploads/draft.txt", strings.NewReader("y"), 1, "text/plain"))
+
+	draft := models.Attachment{ID: uuid.New(), UploaderID: uuid.New(), StorageKey: "uploads/draft.txt", Size: 1, Status: models.AttachmentStatusPending}
+	var deleted []uuid.UUID
+	var released int64
+	attachmentRepo := &repository.MockAttachmentRepository{
+		MockListOrphanAttachments: func(ctx context.Context, before time.Time, limit int) ([]models.Attachment, error) {
+			return []models.Attachment{draft}, nil
+		},
+		MockDeleteAttachment: func(ctx context.Context, id uuid.UUID) error {
+			deleted = append(deleted, id)
+			return nil
+		},
+	}
+	blobRepo := &repository.MockBlobRepository{
+		MockListOrphanBlobs: func(ctx context.Context, before time.Time, limit int) ([]models.Blob, error) {
+			return []models.Blob{{Checksum: "abc", StorageKey: "blobs/ab/abc"}, {Checksum: "reused"}}, nil
+		},
+		MockDeleteOrphanBlob: func(ctx context.Context, checksum string, before time.Time, deleteObject func(key string) error) (bool, error) {
+			// Blob "reused" vừa được dùng lại giữa lúc list và lúc xóa
+			if checksum == "reused" {
+				return false, nil
+			}
+			return true, deleteObject("blobs/ab/" + checksum)
+		},
+	}
+	userRepo := &repository.MockUserRepository{
+		MockReleaseStorage: func(ctx context.Context, id uuid.UUID, bytes int64) error {
+			released += bytes
+			return nil
+		},
+	}
+
+	gc := NewStorageGC(attachmentRepo, blobRepo, userRepo, store, StorageGCConfig{Interval: time.Hour, GracePeriod: time.Hour})
+	result, err := gc.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, GCResult{Attachments: 1, Blobs: 1}, result)
+	assert.Equal(t, []uuid.UUID{draft.ID}, deleted)
+	assert.Equal(t, int64(1), released)
+	for _, key := range []string{"blobs/ab/abc", "uploads/draft.txt"} {
+		_, err := store.Stat(ctx, key)
+		assert.ErrorIs(t, err, storage.ErrNotFound, key)
+	}
+}
@@ -1,8 +1,9 @@
 package service
 
 import (
-	"log"
+	"context"
 	"project/models"
+	"project/pkg/tracing"
 	"project/repository"
 	"project/utils"
 	"time"
@@ -24,22 +25,24 @@ func NewConversationService(conversationRepo repository.ConversationRepository,
 	}
 }
 
-func (s *ConversationService) GetUserConversations(userID string, limit int, before *time.Time) ([]*models.Conversation, error) {
+func (s *ConversationService) GetUserConversations(ctx context.Context, userID string, limit int, before *time.Time) ([]*models.Conversation, error) {
+	ctx, span := tracing.Start(ctx, "service", "ConversationService.GetUserConversations")
+	defer span.End()
 	// convert userID from string to uuid.UUID
 	uid, err := utils.StringToUUID(userID)
 	if err != nil {
 		return nil, err
 	}
-	r, err := s.conversationRepo.GetConversationsByUserID(uid, limit, before)
-	log.Println("[Get Conversation for user] ", r)
-	log.Println("[Get Conversation for user] ", err)
+	r, err := s.conversationRepo.GetConversationsByUserID(ctx, uid, limit, before)
 	if err != nil {
 		return nil, err
 	}
 	return r, nil
 }
 
-func (s *ConversationService) CreateDirectConversation(userID1 string, userID2 string) (*models.Conversation, error) {
+func (s *ConversationService) CreateDirectConversation(ctx context.Context, userID1 string, userID2 string) (*models.Conversation, error) {
+	ctx, span := tracing.Start(ctx, "service", "ConversationService.CreateDirectConversation")
+	defer span.End()
 	uid1, err := utils.StringToUUID(userID1)
 	if err != nil {
 		return nil, err
@@ -48,26 +51,30 @@ func (s *ConversationService) CreateDirectConversation(userID1 string, userID2 s
 	if err != nil {
 		return nil, err
 	}
-	conversation, err := s.conversationRepo.CreateDirectConversation(uid1, uid2)
+	conversation, err := s.conversationRepo.CreateDirectConversation(ctx, uid1, uid2)
 	if err != nil {
 		return nil, err
 	}
 	return conversation, nil
 }
 
-func (s *ConversationService) GetMessageByConversationID(conversationID string, limit int, before *time.Time) ([]*models.Message, error) {
+func (s *ConversationService) GetMessageByConversationID(ctx context.Context, conversationID string, limit int, before *time.Time) ([]*models.Message, error) {
+	ctx, span := tracing.Start(ctx, "service", "ConversationService.GetMessageByConversationID")
+	defer span.End()
 	cid, err := utils.StringToUUID(conversationID)
 	if err != nil {
 		return nil, err
 	}
-	messages, err := s.conversationRepo.GetMessageByConversationID(cid, limit, before)
+	messages, err := s.conversationRepo.GetMessageByConversationID(ctx, cid, limit, before)
 	if err != nil {
 		return nil, err
 	}
 	return messages, nil
 }
 
-func (s *ConversationService) SendMessage(conversationID string, senderID string, content string) (*models.Message, error) {
+func (s *ConversationService) SendMessage(ctx context.Context, conversationID string, senderID string, content string) (*models.Message, error) {
+	ctx, span := tracing.Start(ctx, "service", "ConversationService.SendMessage")
+	defer span.End()
 	cid, err := utils.StringToUUID(conversationID)
 	if err != nil {
 		return nil, err
@@ -85,14 +92,16 @@ func (s *ConversationService) SendMessage(conversationID string, senderID string
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
 	}
-	message, err := s.messageRepo.CreateMessage(mess)
+	message, err := s.messageRepo.CreateMessage(ctx, mess)
 	if err != nil {
 		return nil, err
 	}
 	return message, nil
 }
-func (s *ConversationService) FindConversationBytwoUserIDs(userID1 string, userID2 string) (*models.Conversation, error) {
-	if conversation, err := s.redisRepo.GetConversationIDByTwoUserID(userID1, userID2); err == nil {
+func (s *ConversationService) FindConversationBytwoUserIDs(ctx context.Context, userID1 string, userID2 string) (*models.Conversation, error) {
+	ctx, span := tracing.Start(ctx, "service", "ConversationService.FindConversationBytwoUserIDs")
+	defer span.End()
+	if conversation, err := s.redisRepo.GetConversationIDByTwoUserID(ctx, userID1, userID2); err == nil {
 		return conversation, nil
 	}
 	uid1, err := utils.StringToUUID(userID1)
@@ -103,8 +112,8 @@ func (s *ConversationService) FindConversationBytwoUserIDs(userID1 string, userI
 	if err != nil {
 		return nil, err
 	}
-	conversation, err := s.conversationRepo.FindConversationByTwoUserID(uid1, uid2)
-	s.redisRepo.SetKeyConversationTwoUserID(userID1, userID2, conversation, 0)
+	conversation, err := s.conversationRepo.FindConversationByTwoUserID(ctx, uid1, uid2)
+	s.redisRepo.SetKeyConversationTwoUserID(ctx, userID1, userID2, conversation, 0)
 	if err != nil {
 		return nil, err
 	}
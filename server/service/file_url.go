@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"project/models"
+	"project/pkg/tracing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidFileURL = errors.New("file url is invalid or expired")
+
+// FileURLPrefix là route public phục vụ file qua signed URL
+const FileURLPrefix = "/api/v1/files/"
+
+// FileURLConfig cấu hình signed URL để tải attachment (dùng được trong <img>/<video> không cần header Authorization)
+type FileURLConfig struct {
+	Secret []byte
+	TTL    time.Duration
+}
+
+// fileResource là chuỗi được ký, gắn URL với đúng attachment và rendition
+func fileResource(attachmentID uuid.UUID, rendition string) string {
+	resource := "attachments/" + attachmentID.String()
+	if rendition != "" {
+		resource += "/" + rendition
+	}
+	return resource
+}
+
+// SignedURL cấp URL tải attachment cho user trong thời gian ngắn. Quyền được kiểm tra lúc cấp và lúc tải.
+func (s *AttachmentService) SignedURL(ctx context.Context, attachmentID, userID uuid.UUID, rendition string) (string, time.Time, error) {
+	ctx, span := tracing.Start(ctx, "service", "AttachmentService.SignedURL")
+	defer span.End()
+	attachment, err := s.GetForDownload(ctx, attachmentID, userID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if _, err := storageKeyFor(attachment, rendition); err != nil {
+		return "", time.Time{}, err
+	}
+	q, expiresAt := s.urlSigner.Sign(fileResource(attachmentID, rendition), userID.String(), s.fileURLCfg.TTL)
+	if rendition != "" {
+		q.Set("rendition", rendition)
+	}
+	return FileURLPrefix + attachmentID.String() + "?" + q.Encode(), expiresAt, nil
+}
+
+// OpenSignedURL kiểm tra chữ ký rồi kiểm tra lại membership (user có thể đã rời conversation sau khi nhận URL).
+// Trả về attachment, key trên storage và thời điểm URL hết hạn.
+func (s *AttachmentService) OpenSignedURL(ctx context.Context, attachmentID uuid.UUID, q url.Values) (*models.Attachment, string, time.Time, error) {
+	ctx, span := tracing.Start(ctx, "service", "AttachmentService.OpenSignedURL")
+	defer span.End()
+	rendition := q.Get("rendition")
+	uid, expiresAt, err := s.urlSigner.Verify(fileResource(attachmentID, rendition), q)
+	if err != nil {
+		return nil, "", time.Time{}, ErrInvalidFileURL
+	}
+	userID, err := uuid.Parse(uid)
+	if err != nil {
+		return nil, "", time.Time{}, ErrInvalidFileURL
+	}
+	attachment, err := s.GetForDownload(ctx, attachmentID, userID)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	key, err := storageKeyFor(attachment, rendition)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	return attachment, key, expiresAt, nil
+}
+
+// storageKeyFor trả về key của file gốc hoặc của rendition
+func storageKeyFor(attachment *models.Attachment, rendition string) (string, error) {
+	if rendition == "" {
+		return attachment.StorageKey, nil
+	}
+	for _, r := range attachment.Renditions {
+		if r.Name == rendition {
+			return r.StorageKey, nil
+		}
+	}
+	return "", ErrAttachmentNotFound
+}
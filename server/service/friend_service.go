@@ -1,10 +1,11 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
 	"project/models"
+	"project/pkg/tracing"
 	"project/repository"
 
 	"time"
@@ -24,7 +25,9 @@ func NewInitFriendService(repoFriend repository.FriendshipRepository, repoUser r
 	}
 }
 
-func (f *FriendService) SendInviteFriend(requesterID string, friendID string) error {
+func (f *FriendService) SendInviteFriend(ctx context.Context, requesterID string, friendID string) error {
+	ctx, span := tracing.Start(ctx, "service", "FriendService.SendInviteFriend")
+	defer span.End()
 	if requesterID == "" || friendID == "" {
 		return errors.New("all fields are required")
 	}
@@ -46,7 +49,7 @@ func (f *FriendService) SendInviteFriend(requesterID string, friendID string) er
 		UpdatedAt:   time.Now(),
 	}
 	// Kiểm tra nếu đã có mối quan hệ bạn bè hoặc lời mời kết bạn tồn tại
-	existing, err := f.repoFriend.GetFriendshipBetweenUsers(requesterUUID, friendUUID)
+	existing, err := f.repoFriend.GetFriendshipBetweenUsers(ctx, requesterUUID, friendUUID)
 	if err != nil {
 		return fmt.Errorf("failed to check existing friendship: %w", err)
 
@@ -60,9 +63,8 @@ func (f *FriendService) SendInviteFriend(requesterID string, friendID string) er
 			existing.UserID = requesterUUID
 			existing.RequestedBy = requesterUUID
 			existing.UpdatedAt = time.Now()
-			log.Println("[log exsting friend]", existing)
 			// Lưu thay đổi vào database
-			if _, err := f.repoFriend.SaveFriendship(existing); err != nil {
+			if _, err := f.repoFriend.SaveFriendship(ctx, existing); err != nil {
 				return fmt.Errorf("failed to update friendship: %w", err)
 			}
 			return nil
@@ -70,13 +72,15 @@ func (f *FriendService) SendInviteFriend(requesterID string, friendID string) er
 		return errors.New("friendship or invitation already exists")
 	}
 	// Lưu vào database
-	if _, err := f.repoFriend.CreateFriendship(&friendShip); err != nil {
+	if _, err := f.repoFriend.CreateFriendship(ctx, &friendShip); err != nil {
 		return fmt.Errorf("failed to create friendship: %w", err)
 	}
 	return nil
 }
 
-func (f *FriendService) CancelInviteFriend(requesterID string, friendID string) error {
+func (f *FriendService) CancelInviteFriend(ctx context.Context, requesterID string, friendID string) error {
+	ctx, span := tracing.Start(ctx, "service", "FriendService.CancelInviteFriend")
+	defer span.End()
 	if requesterID == "" || friendID == "" {
 		return errors.New("all fields are required")
 	}
@@ -90,7 +94,7 @@ func (f *FriendService) CancelInviteFriend(requesterID string, friendID string)
 		return fmt.Errorf("invalid friend ID: %w", err)
 	}
 	// Kiểm tra nếu lời mời kết bạn tồn tại
-	existing, err := f.repoFriend.GetFriendshipBetweenUsers(requesterUUID, friendUUID)
+	existing, err := f.repoFriend.GetFriendshipBetweenUsers(ctx, requesterUUID, friendUUID)
 	if err != nil {
 		return fmt.Errorf("failed to check existing friendship: %w", err)
 	}
@@ -100,12 +104,14 @@ func (f *FriendService) CancelInviteFriend(requesterID string, friendID string)
 	}
 	// change status to "no friend"
 	existing.Status = "no_friend"
-	if _, err := f.repoFriend.UpdateFriendship(existing); err != nil {
+	if _, err := f.repoFriend.UpdateFriendship(ctx, existing); err != nil {
 		return fmt.Errorf("failed to update friendship status: %w", err)
 	}
 	return nil
 }
-func (f *FriendService) GetListsFriendInvite(userID string) ([]models.User, error) {
+func (f *FriendService) GetListsFriendInvite(ctx context.Context, userID string) ([]models.User, error) {
+	ctx, span := tracing.Start(ctx, "service", "FriendService.GetListsFriendInvite")
+	defer span.End()
 	if userID == "" {
 		return nil, errors.New("userID is required")
 	}
@@ -115,7 +121,7 @@ func (f *FriendService) GetListsFriendInvite(userID string) ([]models.User, erro
 		return nil, fmt.Errorf("invalid user ID: %w", err)
 	}
 	// Lấy danh sách lời mời kết bạn
-	invites, err := f.repoFriend.ListPendingRequests(userUUID)
+	invites, err := f.repoFriend.ListPendingRequests(ctx, userUUID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list pending requests: %w", err)
 	}
@@ -132,7 +138,9 @@ func (f *FriendService) GetListsFriendInvite(userID string) ([]models.User, erro
 	return users, nil
 }
 
-func (f *FriendService) AcceptInviteFriend(requesterID string, friendID string) error {
+func (f *FriendService) AcceptInviteFriend(ctx context.Context, requesterID string, friendID string) error {
+	ctx, span := tracing.Start(ctx, "service", "FriendService.AcceptInviteFriend")
+	defer span.End()
 	if requesterID == "" || friendID == "" {
 		return errors.New("all fields are required")
 	}
@@ -146,7 +154,7 @@ func (f *FriendService) AcceptInviteFriend(requesterID string, friendID string)
 		return fmt.Errorf("invalid friend ID: %w", err)
 	}
 	// Kiểm tra nếu lời mời kết bạn tồn tại
-	existing, err := f.repoFriend.GetFriendshipBetweenUsers(requesterUUID, friendUUID)
+	existing, err := f.repoFriend.GetFriendshipBetweenUsers(ctx, requesterUUID, friendUUID)
 	if err != nil {
 		return fmt.Errorf("failed to check existing friendship: %w", err)
 	}
@@ -156,13 +164,15 @@ func (f *FriendService) AcceptInviteFriend(requesterID string, friendID string)
 	}
 	// change status to "friends"
 	existing.Status = "friend"
-	if _, err := f.repoFriend.SaveFriendship(existing); err != nil {
+	if _, err := f.repoFriend.SaveFriendship(ctx, existing); err != nil {
 		return fmt.Errorf("failed to update friendship status: %w", err)
 	}
 
 	return nil
 }
-func (f *FriendService) RejectInviteFriend(requesterID string, friendID string) error {
+func (f *FriendService) RejectInviteFriend(ctx context.Context, requesterID string, friendID string) error {
+	ctx, span := tracing.Start(ctx, "service", "FriendService.RejectInviteFriend")
+	defer span.End()
 	if requesterID == "" || friendID == "" {
 		return errors.New("all fields are required")
 	}
@@ -176,7 +186,7 @@ func (f *FriendService) RejectInviteFriend(requesterID string, friendID string)
 		return fmt.Errorf("invalid friend ID: %w", err)
 	}
 	// Kiểm tra nếu lời mời kết bạn tồn tại
-	existing, err := f.repoFriend.GetFriendshipBetweenUsers(requesterUUID, friendUUID)
+	existing, err := f.repoFriend.GetFriendshipBetweenUsers(ctx, requesterUUID, friendUUID)
 	if err != nil {
 		return fmt.Errorf("failed to check existing friendship: %w", err)
 	}
@@ -186,12 +196,14 @@ func (f *FriendService) RejectInviteFriend(requesterID string, friendID string)
 	}
 	// change status to "no friend"
 	existing.Status = "no_friend"
-	if _, err := f.repoFriend.UpdateFriendship(existing); err != nil {
+	if _, err := f.repoFriend.UpdateFriendship(ctx, existing); err != nil {
 		return fmt.Errorf("failed to update friendship status: %w", err)
 	}
 	return nil
 }
-func (f *FriendService) GetListFriends(userID string) ([]models.User, error) {
+func (f *FriendService) GetListFriends(ctx context.Context, userID string) ([]models.User, error) {
+	ctx, span := tracing.Start(ctx, "service", "FriendService.GetListFriends")
+	defer span.End()
 	if userID == "" {
 		return nil, errors.New("userID is required")
 	}
@@ -201,11 +213,10 @@ func (f *FriendService) GetListFriends(userID string) ([]models.User, error) {
 		return nil, fmt.Errorf("invalid user ID: %w", err)
 	}
 	// Lấy danh sách bạn bè
-	friends, err := f.repoFriend.ListFriends(userUUID, "friend")
+	friends, err := f.repoFriend.ListFriends(ctx, userUUID, "friend")
 	if err != nil {
 		return nil, fmt.Errorf("failed to list friends: %w", err)
 	}
-	fmt.Println("List friend", friends)
 	// Trả về danh sách bạn bè
 	var users []models.User
 	for i := range friends {
@@ -218,7 +229,9 @@ func (f *FriendService) GetListFriends(userID string) ([]models.User, error) {
 	return users, nil
 }
 
-func (f *FriendService) RemoveFriend(userID string, friendID string) error {
+func (f *FriendService) RemoveFriend(ctx context.Context, userID string, friendID string) error {
+	ctx, span := tracing.Start(ctx, "service", "FriendService.RemoveFriend")
+	defer span.End()
 	if userID == "" || friendID == "" {
 		return errors.New("all fields are required")
 	}
@@ -232,7 +245,7 @@ func (f *FriendService) RemoveFriend(userID string, friendID string) error {
 		return fmt.Errorf("invalid friend ID: %w", err)
 	}
 	// Kiểm tra nếu mối quan hệ bạn bè tồn tại
-	existing, err := f.repoFriend.GetFriendshipBetweenUsers(userUUID, friendUUID)
+	existing, err := f.repoFriend.GetFriendshipBetweenUsers(ctx, userUUID, friendUUID)
 	if err != nil {
 		return fmt.Errorf("failed to check existing friendship: %w", err)
 	}
@@ -242,7 +255,7 @@ func (f *FriendService) RemoveFriend(userID string, friendID string) error {
 	}
 	// change status to "no friend"
 	existing.Status = "no_friend"
-	if _, err := f.repoFriend.UpdateFriendship(existing); err != nil {
+	if _, err := f.repoFriend.UpdateFriendship(ctx, existing); err != nil {
 		return fmt.Errorf("failed to update friendship status: %w", err)
 	}
 	return nil
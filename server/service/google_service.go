@@ -1,8 +1,7 @@
 package service
 
 import (
-	"fmt"
-	 
+	"log/slog"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -18,8 +17,7 @@ func NewGoogleService(oauthConfig *oauth2.Config) *GoogleService {
 	}
 }
 func (h *GoogleService) InitGoogleOAuth(clientID, clientSecret, redirectURL string) {
-	fmt.Println("Initializing Google OAuth with Client ID:", clientID)
-	fmt.Println("Client Secret:", clientSecret)
+	slog.Info("initializing Google OAuth", "client_id", clientID)
 	h.OAuthConfig = &oauth2.Config{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"project/models"
+	"project/pkg/imageproc"
+	"project/pkg/storage"
+	"project/pkg/tracing"
+	"project/repository"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrImageQueueFull   = errors.New("image processing queue is full")
+	ErrImageQueueClosed = errors.New("image processing queue is closed")
+)
+
+type ImageProcessorConfig struct {
+	Workers        int
+	QueueSize      int
+	ProcessTimeout time.Duration
+	Options        imageproc.Options
+}
+
+// DefaultImageProcessorConfig là cấu hình mặc định, số worker, hàng đợi và MaxPixels lấy từ config
+func DefaultImageProcessorConfig() ImageProcessorConfig {
+	return ImageProcessorConfig{
+		Workers:        2,
+		QueueSize:      100,
+		ProcessTimeout: 2 * time.Minute,
+		Options:        imageproc.DefaultOptions(),
+	}
+}
+
+// ImageProcessor xử lý ảnh upload ở background: xóa EXIF/GPS khỏi file gốc,
+// tạo các rendition (thumbnail/preview) và tính màu chủ đạo.
+type ImageProcessor struct {
+	attachmentRepo repository.AttachmentRepository
+	store          storage.Store
+	blobs          *BlobStore
+	cfg            ImageProcessorConfig
+	jobs           chan uuid.UUID
+	wg             sync.WaitGroup
+	mu             sync.RWMutex
+	closed         bool
+}
+
+func NewImageProcessor(attachmentRepo repository.AttachmentRepository, store storage.Store, blobs *BlobStore, cfg ImageProcessorConfig) *ImageProcessor {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.ProcessTimeout <= 0 {
+		cfg.ProcessTimeout = 2 * time.Minute
+	}
+	p := &ImageProcessor{
+		attachmentRepo: attachmentRepo,
+		store:          store,
+		blobs:          blobs,
+		cfg:            cfg,
+		jobs:           make(chan uuid.UUID, cfg.QueueSize),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	return p
+}
+
+// Enqueue đưa attachment vào hàng đợi xử lý, trả về ErrImageQueueFull nếu hàng đợi đầy
+func (p *ImageProcessor) Enqueue(attachmentID uuid.UUID) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrImageQueueClosed
+	}
+	select {
+	case p.jobs <- attachmentID:
+		return nil
+	default:
+		return ErrImageQueueFull
+	}
+}
+
+// Close ngừng nhận ảnh mới và chờ xử lý xong ảnh còn trong hàng đợi
+func (p *ImageProcessor) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.jobs)
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *ImageProcessor) worker() {
+	defer p.wg.Done()
+	for id := range p.jobs {
+		ctx, cancel := context.WithTimeout(context.Background(), p.cfg.ProcessTimeout)
+		if err := p.Process(ctx, id); err != nil {
+			slog.ErrorContext(ctx, "process image failed", "attachment_id", id, "err", err)
+		}
+		cancel()
+	}
+}
+
+// Process xử lý đồng bộ một attachment ảnh
+func (p *ImageProcessor) Process(ctx context.Context, attachmentID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "service", "ImageProcessor.Process")
+	defer span.End()
+	attachment, err := p.attachmentRepo.GetAttachmentByID(ctx, attachmentID)
+	if err != nil {
+		return err
+	}
+	if attachment == nil || attachment.Status != models.AttachmentStatusReady {
+		return ErrAttachmentNotFound
+	}
+
+	rc, _, err := p.store.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return err
+	}
+	result, err := imageproc.Process(rc, p.cfg.Options)
+	rc.Close()
+	if err != nil {
+		return err
+	}
+
+	renditions := make([]models.AttachmentRendition, 0, len(result.Renditions))
+	for _, r := range result.Renditions {
+		blob, err := p.blobs.PutBytes(ctx, r.Data, r.ContentType)
+		if err != nil {
+			return err
+		}
+		renditions = append(renditions, models.AttachmentRendition{
+			Name:       r.Name,
+			StorageKey: blob.StorageKey,
+			MimeType:   r.ContentType,
+			Width:      r.Width,
+			Height:     r.Height,
+			Size:       int64(len(r.Data)),
+		})
+	}
+	if err := p.attachmentRepo.SaveRenditions(ctx, attachment.ID, renditions); err != nil {
+		return err
+	}
+
+	// File gốc được thay bằng bản đã bỏ metadata (blob mới), blob cũ không còn ai dùng sẽ bị GC.
+	// URL của attachment không đổi vì đi qua attachment ID.
+	originalSize := attachment.Size
+	if result.Original != nil {
+		blob, err := p.blobs.PutBytes(ctx, result.Original, result.OriginalContentType)
+		if err != nil {
+			return err
+		}
+		attachment.StorageKey = blob.StorageKey
+		attachment.Checksum = blob.Checksum
+		attachment.Size = blob.Size
+		attachment.MimeType = result.OriginalContentType
+	}
+	attachment.Width, attachment.Height = result.Width, result.Height
+	attachment.DominantColor = result.DominantColor
+	// Quota tính theo Size nên phần chênh lệch của file gốc mới được cộng/trừ cùng lúc,
+	// StorageGC trả lại đúng Size khi xóa attachment
+	updated, err := p.attachmentRepo.SaveProcessedImage(ctx, attachment, attachment.Size-originalSize)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrAttachmentNotFound
+	}
+	return nil
+}
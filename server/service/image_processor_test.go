@@ -0,0 +1,82 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"project/models"
+	"project/pkg/imageproc"
+	"project/pkg/storage"
+	"project/repository"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageProcessorCreatesRenditions(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewLocalStore(storage.LocalConfig{Root: t.TempDir(), PublicURL: "http://localhost/storage", SigningKey: "test"})
+	require.NoError(t, err)
+
+	img := image.NewRGBA(image.Rect(0, 0, 800, 400))
+	for x := 0; x < 800; x++ {
+		for y := 0; y < 400; y++ {
+			img.Set(x, y, color.RGBA{B: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	attachment := &models.Attachment{
+		ID:         uuid.New(),
+		StorageKey: "attachments/a.png",
+		MimeType:   "image/png",
+		Size:       int64(buf.Len()),
+		Status:     models.AttachmentStatusReady,
+	}
+	require.NoError(t, store.Put(ctx, attachment.StorageKey, bytes.NewReader(buf.Bytes()), int64(buf.Len()), "image/png"))
+
+	var saved []models.AttachmentRendition
+	var updated *models.Attachment
+	var sizeDelta int64
+	repo := &repository.MockAttachmentRepository{
+		MockGetAttachmentByID: func(ctx context.Context, id uuid.UUID) (*models.Attachment, error) {
+			clone := *attachment
+			return &clone, nil
+		},
+		MockSaveRenditions: func(ctx context.Context, attachmentID uuid.UUID, renditions []models.AttachmentRendition) error {
+			saved = renditions
+			return nil
+		},
+		MockSaveProcessedImage: func(ctx context.Context, a *models.Attachment, delta int64) (bool, error) {
+			updated, sizeDelta = a, delta
+			return true, nil
+		},
+	}
+
+	p := NewImageProcessor(repo, store, NewBlobStore(newMemBlobRepo(), store), ImageProcessorConfig{Workers: 1, QueueSize: 1, Options: imageproc.DefaultOptions()})
+	defer p.Close()
+	require.NoError(t, p.Process(ctx, attachment.ID))
+
+	require.Len(t, saved, 2)
+	assert.Equal(t, "thumb", saved[0].Name)
+	assert.Equal(t, 64, saved[0].Width)
+	assert.Equal(t, 320, saved[1].Width)
+	for _, r := range saved {
+		info, err := store.Stat(ctx, r.StorageKey)
+		require.NoError(t, err)
+		assert.Equal(t, "image/jpeg", info.ContentType)
+		assert.Equal(t, r.Size, info.Size)
+	}
+
+	require.NotNil(t, updated)
+	assert.Equal(t, "#0000ff", updated.DominantColor)
+	assert.Equal(t, 800, updated.Width)
+	assert.Len(t, updated.Checksum, 64)
+	// Quota của uploader đổi theo kích thước file gốc mới
+	assert.Equal(t, updated.Size-attachment.Size, sizeDelta)
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"project/pkg/mailer"
+	"project/pkg/tracing"
+	"project/repository"
+	"strings"
+	"time"
+)
+
+var ErrLoginLocked = errors.New("too many failed login attempts, please try again later")
+
+// LoginGuardConfig cấu hình chống brute-force cho đăng nhập bằng mật khẩu
+type LoginGuardConfig struct {
+	MaxAttempts      int           // số lần sai trên 1 tài khoản trước khi khóa
+	MaxAttemptsPerIP int           // số lần sai trên 1 IP trước khi khóa IP
+	BackoffAfter     int           // bắt đầu backoff sau số lần sai này
+	BackoffBase      time.Duration // thời gian chờ đầu tiên, nhân đôi sau mỗi lần sai
+	BackoffMax       time.Duration
+	LockoutDuration  time.Duration
+	Window           time.Duration // bộ đếm lần sai tự reset sau khoảng này
+}
+
+func DefaultLoginGuardConfig() LoginGuardConfig {
+	return LoginGuardConfig{
+		MaxAttempts:      10,
+		MaxAttemptsPerIP: 50,
+		BackoffAfter:     3,
+		BackoffBase:      time.Second,
+		BackoffMax:       time.Minute,
+		LockoutDuration:  15 * time.Minute,
+		Window:           15 * time.Minute,
+	}
+}
+
+// BackoffDelay trả về thời gian phải chờ sau lần sai thứ attempts (0 nếu chưa cần chờ)
+func (cfg LoginGuardConfig) BackoffDelay(attempts int) time.Duration {
+	if attempts <= cfg.BackoffAfter {
+		return 0
+	}
+	delay := cfg.BackoffBase
+	for i := cfg.BackoffAfter + 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= cfg.BackoffMax {
+			return cfg.BackoffMax
+		}
+	}
+	return delay
+}
+
+// LoginGuard đếm số lần đăng nhập sai theo tài khoản và theo IP trong Redis
+type LoginGuard struct {
+	redisRepo   repository.RedisRepository
+	userRepo    repository.UserRepository
+	mailer      *mailer.TemplateMailer
+	cfg         LoginGuardConfig
+	frontendURL string // URL frontend dùng cho các link gửi qua email
+}
+
+func NewLoginGuard(redisRepo repository.RedisRepository, userRepo repository.UserRepository, mail *mailer.TemplateMailer, cfg LoginGuardConfig, frontendURL string) *LoginGuard {
+	return &LoginGuard{
+		redisRepo:   redisRepo,
+		userRepo:    userRepo,
+		mailer:      mail,
+		cfg:         cfg,
+		frontendURL: frontendURL,
+	}
+}
+
+func accountGuardKey(email string) string {
+	return "login:account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipGuardKey(ip string) string {
+	return "login:ip:" + ip
+}
+
+// LoginAttempt là một lượt đăng nhập đã được Reserve tính vào bộ đếm
+type LoginAttempt struct {
+	Email      string
+	IP         string
+	attempts   int64 // thứ tự của lượt này trong bộ đếm theo tài khoản
+	ipAttempts int64 // thứ tự của lượt này trong bộ đếm theo IP
+}
+
+// Reserve tính lượt đăng nhập vào bộ đếm trước khi kiểm tra mật khẩu. INCR và SET NX là nguyên tử nên
+// các request gửi song song không cùng lọt qua trước khi lần sai đầu tiên được ghi nhận.
+// Trả về ErrLoginLocked kèm thời gian chờ nếu tài khoản hoặc IP đang bị khóa, đã hết số lần thử
+// hoặc lượt trước còn trong thời gian backoff. Lượt bị từ chối vẫn được tính.
+// Key theo email được tạo cả khi email không tồn tại để phản hồi luôn giống nhau.
+func (g *LoginGuard) Reserve(ctx context.Context, email, ip string) (*LoginAttempt, time.Duration, error) {
+	ctx, span := tracing.Start(ctx, "service", "LoginGuard.Reserve")
+	defer span.End()
+	accountKey, ipKey := accountGuardKey(email), ipGuardKey(ip)
+	accountTTL, err := g.redisRepo.GetThrottleTTL(ctx, accountKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	ipTTL, err := g.redisRepo.GetThrottleTTL(ctx, ipKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	if retryAfter := max(accountTTL, ipTTL); retryAfter > 0 {
+		return nil, retryAfter, ErrLoginLocked
+	}
+
+	attempt := &LoginAttempt{Email: email, IP: ip}
+	if attempt.attempts, err = g.redisRepo.IncrementCounter(ctx, accountKey, g.cfg.Window); err != nil {
+		return nil, 0, err
+	}
+	if attempt.ipAttempts, err = g.redisRepo.IncrementCounter(ctx, ipKey, g.cfg.Window); err != nil {
+		return nil, 0, err
+	}
+	// Vượt ngưỡng: request song song với lượt cuối cùng, khóa do RecordFailure của lượt đó đặt
+	if int(attempt.attempts) > g.cfg.MaxAttempts || int(attempt.ipAttempts) > g.cfg.MaxAttemptsPerIP {
+		return nil, g.cfg.LockoutDuration, ErrLoginLocked
+	}
+	// Lượt thứ n giữ backoff cho lượt kế tiếp, chỉ 1 trong các request song song giữ được
+	if delay := g.cfg.BackoffDelay(int(attempt.attempts)); delay > 0 {
+		ok, retryAfter, err := g.redisRepo.AcquireThrottle(ctx, accountKey, delay)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			return nil, retryAfter, ErrLoginLocked
+		}
+	}
+	return attempt, 0, nil
+}
+
+// RecordFailure ghi nhận lượt đã Reserve là sai mật khẩu: lượt cuối cùng được phép thì khóa tạm thời
+// tài khoản hoặc IP. locale dùng cho email thông báo khi tài khoản bị khóa.
+func (g *LoginGuard) RecordFailure(ctx context.Context, attempt *LoginAttempt, locale string) error {
+	ctx, span := tracing.Start(ctx, "service", "LoginGuard.RecordFailure")
+	defer span.End()
+	if int(attempt.attempts) >= g.cfg.MaxAttempts {
+		if err := g.lock(ctx, accountGuardKey(attempt.Email)); err != nil {
+			return err
+		}
+		slog.WarnContext(ctx, "account locked after failed logins", "attempts", attempt.attempts, "lockout", g.cfg.LockoutDuration)
+		// Gửi mail sau khi request kết thúc nên không dùng cancel của request
+		go g.notifyLocked(context.WithoutCancel(ctx), attempt.Email, locale)
+	}
+	if int(attempt.ipAttempts) >= g.cfg.MaxAttemptsPerIP {
+		if err := g.lock(ctx, ipGuardKey(attempt.IP)); err != nil {
+			return err
+		}
+		slog.WarnContext(ctx, "ip locked after failed logins", "client_ip", attempt.IP, "attempts", attempt.ipAttempts, "lockout", g.cfg.LockoutDuration)
+	}
+	return nil
+}
+
+// RecordSuccess reset bộ đếm của tài khoản. Bộ đếm theo IP giữ nguyên để
+// attacker không thể reset bằng cách đăng nhập tài khoản của chính mình.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, email string) error {
+	ctx, span := tracing.Start(ctx, "service", "LoginGuard.RecordSuccess")
+	defer span.End()
+	return g.redisRepo.ResetCounter(ctx, accountGuardKey(email))
+}
+
+func (g *LoginGuard) lock(ctx context.Context, key string) error {
+	if err := g.redisRepo.SetThrottle(ctx, key, g.cfg.LockoutDuration); err != nil {
+		return err
+	}
+	return g.redisRepo.ResetCounter(ctx, key)
+}
+
+// notifyLocked gửi email thông báo nếu tài khoản tồn tại (không ảnh hưởng tới response)
+func (g *LoginGuard) notifyLocked(ctx context.Context, email, locale string) {
+	user, err := g.userRepo.GetUserByEmail(ctx, email)
+	if err != nil || user == nil {
+		return
+	}
+	resetLink := fmt.Sprintf("%s/forgot-password", g.frontendURL)
+	if err := g.mailer.SendTemplate(ctx, user.Email, locale, mailer.TemplateAccountLocked, mailer.Data{
+		Name:      user.Name,
+		Link:      resetLink,
+		ExpiresIn: g.cfg.LockoutDuration,
+	}); err != nil {
+		slog.ErrorContext(ctx, "send account locked email failed", "user_id", user.ID, "err", err)
+	}
+}
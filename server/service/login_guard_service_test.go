@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"project/models"
+	"project/pkg/mailer"
+	"project/repository"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginGuardBackoffDelay(t *testing.T) {
+	cfg := DefaultLoginGuardConfig()
+	cfg.BackoffAfter = 3
+	cfg.BackoffBase = time.Second
+	cfg.BackoffMax = 10 * time.Second
+
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 1, expected: 0},
+		{attempts: 3, expected: 0},
+		{attempts: 4, expected: time.Second},
+		{attempts: 5, expected: 2 * time.Second},
+		{attempts: 7, expected: 8 * time.Second},
+		{attempts: 8, expected: 10 * time.Second},
+		{attempts: 50, expected: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, cfg.BackoffDelay(tt.attempts), "attempts=%d", tt.attempts)
+	}
+}
+
+func TestAccountGuardKeyIsCaseInsensitive(t *testing.T) {
+	assert.Equal(t, accountGuardKey("User@Example.com "), accountGuardKey("user@example.com"))
+}
+
+// newTestLoginGuard dùng Redis trong bộ nhớ với đồng hồ do test điều khiển, advance tua đồng hồ
+func newTestLoginGuard(t *testing.T, cfg LoginGuardConfig) (*LoginGuard, *mailer.MemoryMailer, func(time.Duration)) {
+	t.Helper()
+	now := time.Now()
+	var mu sync.Mutex
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+	userRepo := &repository.MockUserRepository{
+		MockGetUserByEmail: func(ctx context.Context, email string) (*models.User, error) {
+			if email == "alice@example.com" {
+				return &models.User{ID: uuid.New(), Name: "Alice", Email: email}, nil
+			}
+			return nil, nil
+		},
+	}
+	templates, err := mailer.LoadTemplates()
+	require.NoError(t, err)
+	mail := mailer.NewMemoryMailer()
+	guard := NewLoginGuard(repository.NewMemoryRedisRepositoryWithClock(clock), userRepo,
+		mailer.NewTemplateMailer(mail, templates), cfg, "http://localhost:5173")
+	return guard, mail, advance
+}
+
+// fail giữ một lượt đăng nhập rồi ghi nhận sai mật khẩu, như handler LoginPassword
+func fail(ctx context.Context, guard *LoginGuard, email, ip string) error {
+	attempt, _, err := guard.Reserve(ctx, email, ip)
+	if err != nil {
+		return err
+	}
+	return guard.RecordFailure(ctx, attempt, "en")
+}
+
+func TestLoginGuardLocksAccount(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultLoginGuardConfig()
+	cfg.MaxAttempts = 3
+	cfg.BackoffAfter = 10
+	guard, mail, advance := newTestLoginGuard(t, cfg)
+
+	for i := 0; i < cfg.MaxAttempts; i++ {
+		require.NoError(t, fail(ctx, guard, "alice@example.com", "10.0.0.1"), "attempt %d", i+1)
+	}
+
+	// Khóa theo tài khoản: IP khác cũng bị chặn, tài khoản khác thì không
+	_, retryAfter, err := guard.Reserve(ctx, "alice@example.com", "10.0.0.2")
+	assert.ErrorIs(t, err, ErrLoginLocked)
+	assert.Equal(t, cfg.LockoutDuration, retryAfter)
+	_, _, err = guard.Reserve(ctx, "bob@example.com", "10.0.0.1")
+	assert.NoError(t, err)
+
+	// Email thông báo gửi ở background
+	assert.Eventually(t, func() bool { return len(mail.Messages()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "alice@example.com", mail.Messages()[0].To)
+
+	advance(cfg.LockoutDuration - time.Minute)
+	_, retryAfter, err = guard.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrLoginLocked)
+	assert.Equal(t, time.Minute, retryAfter)
+
+	// Bộ đếm được reset khi khóa, lần sai tiếp theo không khóa lại ngay
+	advance(time.Minute)
+	require.NoError(t, fail(ctx, guard, "alice@example.com", "10.0.0.1"))
+	_, _, err = guard.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.NoError(t, err)
+}
+
+func TestLoginGuardBackoffClearsAfterDelay(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultLoginGuardConfig()
+	cfg.BackoffAfter = 1
+	cfg.BackoffBase = 2 * time.Second
+	guard, _, advance := newTestLoginGuard(t, cfg)
+
+	require.NoError(t, fail(ctx, guard, "alice@example.com", "10.0.0.1"))
+	require.NoError(t, fail(ctx, guard, "alice@example.com", "10.0.0.1"))
+
+	_, retryAfter, err := guard.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrLoginLocked)
+	assert.Equal(t, 2*time.Second, retryAfter)
+
+	advance(2 * time.Second)
+	_, _, err = guard.Reserve(ctx, "alice@example.com", "10.0.0.1")
+	assert.NoError(t, err)
+}
+
+func TestLoginGuardLocksIP(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultLoginGuardConfig()
+	cfg.MaxAttemptsPerIP = 3
+	cfg.BackoffAfter = 10
+	guard, mail, advance := newTestLoginGuard(t, cfg)
+
+	// Mỗi lần thử một email khác nhau: chỉ bộ đếm theo IP chạm ngưỡng
+	for i := 0; i < cfg.MaxAttemptsPerIP; i++ {
+		require.NoError(t, fail(ctx, guard, uuid.NewString()+"@example.com", "10.0.0.1"), "attempt %d", i+1)
+	}
+
+	_, retryAfter, err := guard.Reserve(ctx, "bob@example.com", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrLoginLocked)
+	assert.Equal(t, cfg.LockoutDuration, retryAfter)
+	_, _, err = guard.Reserve(ctx, "bob@example.com", "10.0.0.2")
+	assert.NoError(t, err)
+
+	// RecordSuccess chỉ reset bộ đếm của tài khoản, IP vẫn bị khóa
+	require.NoError(t, guard.RecordSuccess(ctx, "bob@example.com"))
+	_, _, err = guard.Reserve(ctx, "bob@example.com", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrLoginLocked)
+
+	advance(cfg.LockoutDuration)
+	_, _, err = guard.Reserve(ctx, "bob@example.com", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Empty(t, mail.Messages())
+}
+
+// Các request gửi cùng lúc (trước khi lần sai nào được ghi nhận) không được vượt quá số lượt cho phép
+func TestLoginGuardReserveConcurrent(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     func(cfg *LoginGuardConfig)
+		allowed int
+	}{
+		{
+			name: "max attempts",
+			cfg: func(cfg *LoginGuardConfig) {
+				cfg.MaxAttempts = 3
+				cfg.BackoffAfter = 10
+			},
+			allowed: 3,
+		},
+		{
+			name: "backoff",
+			cfg: func(cfg *LoginGuardConfig) {
+				cfg.BackoffAfter = 0
+				cfg.BackoffBase = time.Minute
+			},
+			allowed: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := DefaultLoginGuardConfig()
+			tt.cfg(&cfg)
+			guard, _, _ := newTestLoginGuard(t, cfg)
+
+			const requests = 20
+			var (
+				wg      sync.WaitGroup
+				mu      sync.Mutex
+				allowed int
+				locked  int
+			)
+			start := make(chan struct{})
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					_, _, err := guard.Reserve(ctx, "alice@example.com", "10.0.0.1")
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case err == nil:
+						allowed++
+					case errors.Is(err, ErrLoginLocked):
+						locked++
+					default:
+						t.Errorf("unexpected error: %v", err)
+					}
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			assert.Equal(t, tt.allowed, allowed)
+			assert.Equal(t, requests-tt.allowed, locked)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"project/models"
+	"project/pkg/mailer"
+	"project/pkg/tracing"
+	"project/repository"
+	"project/utils"
+	"strings"
+	"time"
+)
+
+var ErrMagicLinkInvalid = errors.New("login link is invalid or has already been used")
+
+// MagicLinkConfig cấu hình đăng nhập bằng link gửi qua email
+type MagicLinkConfig struct {
+	TTL            time.Duration // thời gian sống của link
+	ResendInterval time.Duration // mỗi email chỉ được yêu cầu 1 link trong khoảng này
+}
+
+type MagicLinkService struct {
+	userRepo    repository.UserRepository
+	redisRepo   repository.RedisRepository
+	authService *AuthService
+	mailer      *mailer.TemplateMailer
+	cfg         MagicLinkConfig
+	frontendURL string // URL frontend dùng cho các link gửi qua email
+}
+
+func NewMagicLinkService(userRepo repository.UserRepository, redisRepo repository.RedisRepository, authService *AuthService, mail *mailer.TemplateMailer, cfg MagicLinkConfig, frontendURL string) *MagicLinkService {
+	return &MagicLinkService{
+		userRepo:    userRepo,
+		redisRepo:   redisRepo,
+		authService: authService,
+		mailer:      mail,
+		cfg:         cfg,
+		frontendURL: frontendURL,
+	}
+}
+
+// RequestMagicLink gửi link đăng nhập tới email. Email không tồn tại hoặc đang bị giới hạn
+// cũng trả về nil để không lộ thông tin tài khoản.
+func (s *MagicLinkService) RequestMagicLink(ctx context.Context, email, locale string) error {
+	ctx, span := tracing.Start(ctx, "service", "MagicLinkService.RequestMagicLink")
+	defer span.End()
+	email = strings.TrimSpace(email)
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil
+	}
+
+	ok, _, err := s.redisRepo.AcquireThrottle(ctx, "magic-link:"+user.ID.String(), s.cfg.ResendInterval)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		slog.InfoContext(ctx, "magic link request throttled", "user_id", user.ID)
+		return nil
+	}
+
+	token, jti, err := utils.GenerateMagicLinkToken(user.ID, s.cfg.TTL)
+	if err != nil {
+		return fmt.Errorf("failed to create login token: %w", err)
+	}
+	if err := s.redisRepo.SaveMagicLink(ctx, jti, user.ID.String(), s.cfg.TTL); err != nil {
+		return err
+	}
+
+	loginLink := fmt.Sprintf("%s/magic-link?token=%s", s.frontendURL, token)
+	if err := s.mailer.SendTemplate(ctx, user.Email, locale, mailer.TemplateMagicLink, mailer.Data{
+		Name:      user.Name,
+		Link:      loginLink,
+		ExpiresIn: s.cfg.TTL,
+	}); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "magic link sent", "user_id", user.ID)
+	return nil
+}
+
+// ConsumeMagicLink kiểm tra token (chỉ dùng được 1 lần) và tạo session với provider "magic".
+// Mở được link chứng tỏ user sở hữu email nên email được đánh dấu đã xác thực.
+func (s *MagicLinkService) ConsumeMagicLink(ctx context.Context, token, ip, userAgent string) (*models.User, *models.Token, error) {
+	ctx, span := tracing.Start(ctx, "service", "MagicLinkService.ConsumeMagicLink")
+	defer span.End()
+	claims, err := utils.ValidateMagicLinkToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	userID, err := s.redisRepo.ConsumeMagicLink(ctx, claims.ID)
+	if err != nil || userID != claims.UserID.String() {
+		return nil, nil, ErrMagicLinkInvalid
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, claims.UserID)
+	if err != nil || user == nil {
+		return nil, nil, errors.New("user not found")
+	}
+
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		if err := s.userRepo.MarkEmailVerified(ctx, user.ID, now); err != nil {
+			slog.ErrorContext(ctx, "mark email verified failed", "user_id", user.ID, "err", err)
+		} else {
+			user.EmailVerifiedAt = &now
+		}
+	}
+
+	tokenModel, _, err := s.authService.CreateSession(ctx, user, ip, userAgent, "", "", 0, "magic")
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokenModel, nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"project/models"
+	"project/repository"
+	"project/utils"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// magicLinkRedis chỉ cài đặt các hàm magic link, các hàm khác của RedisRepository không được gọi
+type magicLinkRedis struct {
+	repository.RedisRepository
+	links map[string]string
+}
+
+func (r *magicLinkRedis) ConsumeMagicLink(ctx context.Context, jti string) (string, error) {
+	userID, ok := r.links[jti]
+	if !ok {
+		return "", errors.New("redis: nil")
+	}
+	delete(r.links, jti)
+	return userID, nil
+}
+
+func TestConsumeMagicLinkRejectsInvalidTokens(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	redisRepo := &magicLinkRedis{links: map[string]string{}}
+	userRepo := &repository.MockUserRepository{
+		MockGetUserByID: func(ctx context.Context, id uuid.UUID) (*models.User, error) {
+			return &models.User{ID: id}, nil
+		},
+	}
+	s := NewMagicLinkService(userRepo, redisRepo, nil, nil, MagicLinkConfig{TTL: time.Minute}, "http://localhost:3000")
+
+	// Reset token không dùng để đăng nhập được
+	resetToken, err := utils.GenerateResetToken(userID)
+	assert.NoError(t, err)
+	_, _, err = s.ConsumeMagicLink(ctx, resetToken, "127.0.0.1", "test")
+	assert.Error(t, err)
+
+	// Token hợp lệ nhưng jti không còn trong Redis (đã dùng hoặc bị thu hồi)
+	token, _, err := utils.GenerateMagicLinkToken(userID, time.Minute)
+	assert.NoError(t, err)
+	_, _, err = s.ConsumeMagicLink(ctx, token, "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrMagicLinkInvalid)
+
+	// jti thuộc về user khác
+	token, jti, err := utils.GenerateMagicLinkToken(userID, time.Minute)
+	assert.NoError(t, err)
+	redisRepo.links[jti] = uuid.NewString()
+	_, _, err = s.ConsumeMagicLink(ctx, token, "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrMagicLinkInvalid)
+
+	// Token hết hạn
+	expired, jti, err := utils.GenerateMagicLinkToken(userID, -time.Minute)
+	assert.NoError(t, err)
+	redisRepo.links[jti] = userID.String()
+	_, _, err = s.ConsumeMagicLink(ctx, expired, "127.0.0.1", "test")
+	assert.EqualError(t, err, "login link has expired")
+}
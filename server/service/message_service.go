@@ -1,9 +1,13 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"project/models"
+	"project/pkg/tracing"
 	"project/repository"
 	"project/utils"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,15 +16,21 @@ import (
 type MessageService struct {
 	messageRepo      repository.MessageRepository
 	conversationRepo repository.ConversationRepository
+	attachmentRepo   repository.AttachmentRepository
 }
 
-func NewMessageService(messageRepo repository.MessageRepository, conversationService repository.ConversationRepository) *MessageService {
+func NewMessageService(messageRepo repository.MessageRepository, conversationService repository.ConversationRepository, attachmentRepo repository.AttachmentRepository) *MessageService {
 	return &MessageService{
 		messageRepo:      messageRepo,
 		conversationRepo: conversationService,
+		attachmentRepo:   attachmentRepo,
 	}
 }
-func (s *MessageService) SendMessageToConversation(userID string, conversationID string, content string) (*models.Message, error) {
+
+// SendMessageToConversation gửi message kèm các attachment đã upload trước đó (có thể rỗng)
+func (s *MessageService) SendMessageToConversation(ctx context.Context, userID string, conversationID string, content string, attachmentIDs []string) (*models.Message, error) {
+	ctx, span := tracing.Start(ctx, "service", "MessageService.SendMessageToConversation")
+	defer span.End()
 	// convert userID and conversationID from string to uuid.UUID
 	uid, err := utils.StringToUUID(userID)
 	if err != nil {
@@ -30,27 +40,59 @@ func (s *MessageService) SendMessageToConversation(userID string, conversationID
 	if err != nil {
 		return nil, err
 	}
+	ids, err := parseAttachmentIDs(attachmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(content) == "" && len(ids) == 0 {
+		return nil, ErrEmptyMessage
+	}
+
+	var attachments []models.Attachment
+	if len(ids) > 0 {
+		attachments, err = validateAttachments(ctx, s.attachmentRepo, uid, ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	message := &models.Message{
 		ID:             uuid.New(),
 		ConversationID: cid,
 		SenderID:       &uid,
 		Content:        content,
+		Type:           messageTypeForAttachments(attachments),
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
 	}
-	sentMessage, err := s.messageRepo.CreateMessage(message)
+	sentMessage, err := s.messageRepo.CreateMessageWithAttachments(ctx, message, ids)
+	if errors.Is(err, repository.ErrAttachmentsUnavailable) {
+		// Có request khác đã gửi attachment này trong lúc đang xử lý
+		return nil, ErrAttachmentUsed
+	}
 	if err != nil {
 		return nil, err
 	}
+
+	if len(ids) > 0 {
+		for i := range attachments {
+			attachments[i].MessageID = &sentMessage.ID
+			attachments[i].ConversationID = &cid
+		}
+		sentMessage.Attachments = attachments
+	}
+
 	//update last_message_id in conversation
-	err = s.conversationRepo.UpdateLastMessageIDInConversation(cid, sentMessage.ID)
+	err = s.conversationRepo.UpdateLastMessageIDInConversation(ctx, cid, sentMessage.ID)
 	if err != nil {
 		return nil, err
 	}
 	return sentMessage, nil
 }
 
-func (s *MessageService) GetAllMessageToConversation(conversationID string, userID string, limit int, before *time.Time) ([]*models.Message, error) {
+func (s *MessageService) GetAllMessageToConversation(ctx context.Context, conversationID string, userID string, limit int, before *time.Time) ([]*models.Message, error) {
+	ctx, span := tracing.Start(ctx, "service", "MessageService.GetAllMessageToConversation")
+	defer span.End()
 	cid, err := utils.StringToUUID(conversationID)
 	if err != nil {
 		return nil, err
@@ -59,10 +101,27 @@ func (s *MessageService) GetAllMessageToConversation(conversationID string, user
 	if err != nil {
 		return nil, err
 	}
-	messages, err := s.messageRepo.GetMessagesBeforeTime(cid, uid, before, limit)
+	messages, err := s.messageRepo.GetMessagesBeforeTime(ctx, cid, uid, before, limit)
 	if err != nil {
 		return nil, err
 	}
 	return messages, nil
 
 }
+
+// parseAttachmentIDs parse và loại bỏ ID trùng
+func parseAttachmentIDs(raw []string) ([]uuid.UUID, error) {
+	seen := make(map[uuid.UUID]bool, len(raw))
+	ids := make([]uuid.UUID, 0, len(raw))
+	for _, s := range raw {
+		id, err := utils.StringToUUID(s)
+		if err != nil {
+			return nil, ErrAttachmentNotFound
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"project/models"
+	"project/pkg/oidc"
+	"project/pkg/tracing"
+	"project/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrOIDCProviderNotFound = errors.New("oidc provider not found")
+	ErrInvalidOAuthState    = errors.New("invalid or expired oauth state")
+	ErrOIDCEmailMissing     = errors.New("provider did not return an email address")
+	ErrOIDCEmailNotVerified = errors.New("an account with this email already exists, the provider must verify the email before it can be linked")
+	ErrOIDCAccountNotLinked = errors.New("an account with this email exists but is not verified, log in with password and verify it first")
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+// oauthState lưu trong Redis giữa lúc redirect tới provider và lúc callback
+type oauthState struct {
+	Provider  string `json:"provider"`
+	Verifier  string `json:"verifier"`
+	ReturnURL string `json:"return_url"`
+}
+
+type OIDCProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+type OIDCService struct {
+	registry     *oidc.Registry
+	authService  *AuthService
+	userRepo     repository.UserRepository
+	identityRepo repository.IdentityRepository
+	redisRepo    repository.RedisRepository
+}
+
+func NewOIDCService(
+	registry *oidc.Registry,
+	authService *AuthService,
+	userRepo repository.UserRepository,
+	identityRepo repository.IdentityRepository,
+	redisRepo repository.RedisRepository,
+) *OIDCService {
+	return &OIDCService{
+		registry:     registry,
+		authService:  authService,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		redisRepo:    redisRepo,
+	}
+}
+
+// Providers trả về danh sách provider đã cấu hình để frontend hiển thị nút đăng nhập
+func (s *OIDCService) Providers() []OIDCProviderInfo {
+	list := []OIDCProviderInfo{}
+	for _, p := range s.registry.Providers() {
+		list = append(list, OIDCProviderInfo{Name: p.Name(), DisplayName: p.DisplayName()})
+	}
+	return list
+}
+
+// BeginLogin tạo state + PKCE verifier, lưu vào Redis và trả về URL redirect tới provider
+func (s *OIDCService) BeginLogin(ctx context.Context, providerName, returnURL string) (string, error) {
+	ctx, span := tracing.Start(ctx, "service", "OIDCService.BeginLogin")
+	defer span.End()
+	provider, ok := s.registry.Get(providerName)
+	if !ok {
+		return "", ErrOIDCProviderNotFound
+	}
+
+	state, err := randomState()
+	if err != nil {
+		return "", err
+	}
+	st := oauthState{
+		Provider:  provider.Name(),
+		Verifier:  provider.NewVerifier(),
+		ReturnURL: returnURL,
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return "", err
+	}
+	if err := s.redisRepo.SaveOAuthState(ctx, state, data, oauthStateTTL); err != nil {
+		return "", err
+	}
+	return provider.AuthCodeURL(state, st.Verifier), nil
+}
+
+// CompleteLogin kiểm tra state, đổi code lấy token, liên kết/tạo user và tạo session.
+// Trả về return_url đã lưu lúc BeginLogin.
+func (s *OIDCService) CompleteLogin(
+	ctx context.Context,
+	providerName, state, code, ip, userAgent string,
+) (*models.User, *models.Token, *models.Device, string, error) {
+	ctx, span := tracing.Start(ctx, "service", "OIDCService.CompleteLogin")
+	defer span.End()
+	provider, ok := s.registry.Get(providerName)
+	if !ok {
+		return nil, nil, nil, "", ErrOIDCProviderNotFound
+	}
+	if state == "" {
+		return nil, nil, nil, "", ErrInvalidOAuthState
+	}
+
+	data, err := s.redisRepo.ConsumeOAuthState(ctx, state)
+	if err != nil {
+		return nil, nil, nil, "", ErrInvalidOAuthState
+	}
+	var st oauthState
+	if err := json.Unmarshal(data, &st); err != nil || st.Provider != provider.Name() {
+		return nil, nil, nil, "", ErrInvalidOAuthState
+	}
+
+	token, err := provider.Exchange(ctx, code, st.Verifier)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+	info, err := provider.UserInfo(ctx, token)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+
+	user, err := s.resolveUser(ctx, provider.Name(), info)
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+
+	// Provider ngoài chỉ dùng để xác thực, session dùng JWT của hệ thống
+	tokenModel, device, err := s.authService.CreateSession(ctx, user, ip, userAgent, "", "", 0, provider.Name())
+	if err != nil {
+		return nil, nil, nil, "", err
+	}
+	return user, tokenModel, device, st.ReturnURL, nil
+}
+
+// resolveUser tìm user theo identity (provider, subject). Nếu chưa có identity:
+// - email đã được provider xác thực và trùng user có sẵn → liên kết vào user đó
+// - chưa có user với email này → tạo user mới
+func (s *OIDCService) resolveUser(ctx context.Context, providerName string, info *oidc.UserInfo) (*models.User, error) {
+	identity, err := s.identityRepo.GetIdentity(ctx, providerName, info.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+	if identity != nil {
+		user, err := s.userRepo.GetUserByID(ctx, identity.UserID)
+		if err != nil || user == nil {
+			return nil, errors.New("user not found")
+		}
+		return user, nil
+	}
+
+	if info.Email == "" {
+		return nil, ErrOIDCEmailMissing
+	}
+	user, err := s.userRepo.GetUserByEmail(ctx, info.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user != nil {
+		if !info.EmailVerified {
+			return nil, ErrOIDCEmailNotVerified
+		}
+		// Không liên kết vào tài khoản local chưa xác thực email: người tạo tài khoản
+		// đó có thể không phải chủ email (chiếm tài khoản trước khi chủ email đăng ký)
+		if !IsEmailVerified(user) {
+			return nil, ErrOIDCAccountNotLinked
+		}
+		slog.InfoContext(ctx, "link oidc identity to existing user", "provider", providerName, "user_id", user.ID)
+	} else {
+		user, err = s.createUser(ctx, providerName, info)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.identityRepo.CreateIdentity(ctx, &models.UserIdentity{
+		UserID:   user.ID,
+		Provider: providerName,
+		Subject:  info.Subject,
+		Email:    info.Email,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+	return user, nil
+}
+
+func (s *OIDCService) createUser(ctx context.Context, providerName string, info *oidc.UserInfo) (*models.User, error) {
+	userID := uuid.New()
+	avatar := "avatar/img.jpg"
+	if info.Picture != "" {
+		if saved, err := s.authService.SaveImageGoogle(ctx, info.Picture, fmt.Sprintf("%s.jpg", userID.String())); err == nil {
+			avatar = saved
+		} else {
+			slog.WarnContext(ctx, "save oidc avatar failed", "provider", providerName, "err", err)
+		}
+	}
+
+	name := info.Name
+	if name == "" {
+		name = info.Email
+	}
+	user := &models.User{
+		ID:       userID,
+		Name:     name,
+		Email:    info.Email,
+		Avatar:   avatar,
+		Provider: providerName,
+	}
+	if info.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	user, err := s.userRepo.CreateUser(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	return user, nil
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"project/models"
+	"project/pkg/oidc"
+	"project/repository"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOIDCResolveUser(t *testing.T) {
+	now := time.Now()
+	existingID := uuid.New()
+
+	tests := []struct {
+		name         string
+		identity     *models.UserIdentity
+		existingUser *models.User
+		info         *oidc.UserInfo
+		expectedErr  error
+		expectLinked bool
+		expectCreate bool
+	}{
+		{
+			name:     "Existing identity",
+			identity: &models.UserIdentity{UserID: existingID, Provider: "github", Subject: "1"},
+			existingUser: &models.User{
+				ID: existingID, Email: "a@example.com", Provider: "local",
+			},
+			info: &oidc.UserInfo{Subject: "1", Email: "other@example.com"},
+		},
+		{
+			name: "Link verified email to verified account",
+			existingUser: &models.User{
+				ID: existingID, Email: "a@example.com", Provider: "local", EmailVerifiedAt: &now,
+			},
+			info:         &oidc.UserInfo{Subject: "1", Email: "a@example.com", EmailVerified: true},
+			expectLinked: true,
+		},
+		{
+			name: "Unverified provider email cannot link",
+			existingUser: &models.User{
+				ID: existingID, Email: "a@example.com", Provider: "local", EmailVerifiedAt: &now,
+			},
+			info:        &oidc.UserInfo{Subject: "1", Email: "a@example.com"},
+			expectedErr: ErrOIDCEmailNotVerified,
+		},
+		{
+			name: "Unverified local account is not linked",
+			existingUser: &models.User{
+				ID: existingID, Email: "a@example.com", Provider: "local",
+			},
+			info:        &oidc.UserInfo{Subject: "1", Email: "a@example.com", EmailVerified: true},
+			expectedErr: ErrOIDCAccountNotLinked,
+		},
+		{
+			name:        "Missing email",
+			info:        &oidc.UserInfo{Subject: "1"},
+			expectedErr: ErrOIDCEmailMissing,
+		},
+		{
+			name:         "New user",
+			info:         &oidc.UserInfo{Subject: "1", Email: "new@example.com", Name: "New", EmailVerified: true},
+			expectCreate: true,
+			expectLinked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			var linked *models.UserIdentity
+			var created *models.User
+
+			userRepo := &repository.MockUserRepository{
+				MockGetUserByID: func(ctx context.Context, id uuid.UUID) (*models.User, error) {
+					return tt.existingUser, nil
+				},
+				MockGetUserByEmail: func(ctx context.Context, email string) (*models.User, error) {
+					if tt.existingUser != nil && tt.existingUser.Email == email {
+						return tt.existingUser, nil
+					}
+					return nil, nil
+				},
+				MockCreateUser: func(ctx context.Context, user *models.User) (*models.User, error) {
+					created = user
+					return user, nil
+				},
+			}
+			identityRepo := &repository.MockIdentityRepository{
+				MockGetIdentity: func(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+					return tt.identity, nil
+				},
+				MockCreateIdentity: func(ctx context.Context, identity *models.UserIdentity) error {
+					linked = identity
+					return nil
+				},
+			}
+			s := NewOIDCService(oidc.NewRegistry(), nil, userRepo, identityRepo, nil)
+
+			user, err := s.resolveUser(ctx, "github", tt.info)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Nil(t, linked)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, user)
+
+			if tt.expectCreate {
+				assert.NotNil(t, created)
+				assert.Equal(t, "github", created.Provider)
+				assert.Equal(t, "avatar/img.jpg", created.Avatar)
+				assert.NotNil(t, created.EmailVerifiedAt)
+			} else {
+				assert.Nil(t, created)
+			}
+			if tt.expectLinked {
+				assert.NotNil(t, linked)
+				assert.Equal(t, user.ID, linked.UserID)
+				assert.Equal(t, "github", linked.Provider)
+			} else {
+				assert.Nil(t, linked)
+			}
+		})
+	}
+}
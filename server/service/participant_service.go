@@ -1,7 +1,9 @@
 package service
 
 import (
+	"context"
 	"project/models"
+	"project/pkg/tracing"
 	"project/repository"
 	"project/utils"
 )
@@ -18,9 +20,11 @@ func NewParticipantService(repoParticipant repository.ParticipantRepository, red
 	}
 }
 
-func (s *ParticipantService) GetParticipantsByConversationID(conversationID string) (*[]models.Participant, error) {
+func (s *ParticipantService) GetParticipantsByConversationID(ctx context.Context, conversationID string) (*[]models.Participant, error) {
+	ctx, span := tracing.Start(ctx, "service", "ParticipantService.GetParticipantsByConversationID")
+	defer span.End()
 	// check redis cache first
-	if parPtr, err := s.redisRepo.GetParticipantByToken(conversationID); err == nil {
+	if parPtr, err := s.redisRepo.GetParticipantByToken(ctx, conversationID); err == nil {
 		if parPtr != nil {
 			return parPtr, nil
 		}
@@ -29,13 +33,13 @@ func (s *ParticipantService) GetParticipantsByConversationID(conversationID stri
 	if err != nil {
 		return nil, err
 	}
-	participants, err := s.repoParticipant.GetParticipantsByConversationID(conversationIDUUID)
+	participants, err := s.repoParticipant.GetParticipantsByConversationID(ctx, conversationIDUUID)
 	if err != nil {
 		return nil, err
 	}
 	if participants != nil {
 		// set to redis cache
-		s.redisRepo.SetTokenParticipant(conversationID, participants, 0)
+		s.redisRepo.SetTokenParticipant(ctx, conversationID, participants, 0)
 	}
 	return participants, nil
 }
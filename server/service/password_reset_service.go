@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"project/pkg/mailer"
+	"project/pkg/tracing"
+	"project/repository"
+	"project/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// resetTokenTTL khớp với thời hạn của utils.GenerateResetToken
+const resetTokenTTL = time.Hour
+
+var ErrResetUserNotFound = errors.New("user not found")
+
+type PasswordResetService struct {
+	userRepo    repository.UserRepository
+	mailer      *mailer.TemplateMailer
+	frontendURL string // URL frontend dùng cho các link gửi qua email
+}
+
+func NewPasswordResetService(userRepo repository.UserRepository, mail *mailer.TemplateMailer, frontendURL string) *PasswordResetService {
+	return &PasswordResetService{
+		userRepo:    userRepo,
+		mailer:      mail,
+		frontendURL: frontendURL,
+	}
+}
+
+// RequestPasswordReset tạo reset token và gửi link tới email.
+// Email không tồn tại hoặc tài khoản OAuth (không có mật khẩu) trả về nil để không lộ thông tin.
+func (s *PasswordResetService) RequestPasswordReset(ctx context.Context, email, locale string) error {
+	ctx, span := tracing.Start(ctx, "service", "PasswordResetService.RequestPasswordReset")
+	defer span.End()
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil || user.Provider != "local" {
+		return nil
+	}
+
+	token, err := utils.GenerateResetToken(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create reset token: %w", err)
+	}
+
+	// Frontend có route /reset-password nhận token
+	resetLink := fmt.Sprintf("%s/reset-password?token=%s", s.frontendURL, token)
+	if err := s.mailer.SendTemplate(ctx, user.Email, locale, mailer.TemplateResetPassword, mailer.Data{
+		Name:      user.Name,
+		Link:      resetLink,
+		ExpiresIn: resetTokenTTL,
+	}); err != nil {
+		return fmt.Errorf("failed to send reset email: %w", err)
+	}
+	slog.InfoContext(ctx, "password reset link sent", "user_id", user.ID)
+	return nil
+}
+
+// ResetPassword đặt mật khẩu mới cho user của reset token đã được xác thực
+func (s *PasswordResetService) ResetPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	ctx, span := tracing.Start(ctx, "service", "PasswordResetService.ResetPassword")
+	defer span.End()
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil || user == nil {
+		return ErrResetUserNotFound
+	}
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.Password = hashed
+	return s.userRepo.UpdateUser(ctx, user)
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"project/models"
+	"project/pkg/imageproc"
+	"project/pkg/signedurl"
+	"project/pkg/storage"
+	"project/pkg/tracing"
+	"project/repository"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidProfile   = errors.New("invalid profile")
+	ErrInvalidAvatarURL = errors.New("avatar url is invalid or expired")
+)
+
+// AvatarURLPrefix là route phục vụ avatar đã upload, path phía sau tương ứng key "avatar/..." trong storage
+const AvatarURLPrefix = "/api/v1/avatars/"
+
+const (
+	maxNameLength       = 100
+	maxBioLength        = 500
+	maxStatusTextLength = 140
+)
+
+// ProfileUpdate chứa các field cần sửa, nil = giữ nguyên
+type ProfileUpdate struct {
+	Name       *string `json:"name"`
+	Bio        *string `json:"bio"`
+	StatusText *string `json:"status_text"`
+}
+
+// ProfileEvent là payload của event profile_updated gửi tới bạn bè qua websocket
+type ProfileEvent struct {
+	Type             string            `json:"type"`
+	UserID           uuid.UUID         `json:"user_id"`
+	Name             string            `json:"name"`
+	Avatar           string            `json:"avatar,omitempty"`
+	AvatarRenditions map[string]string `json:"avatar_renditions,omitempty"`
+	Bio              string            `json:"bio,omitempty"`
+	StatusText       string            `json:"status_text,omitempty"`
+}
+
+type ProfileService struct {
+	userRepo   repository.UserRepository
+	friendRepo repository.FriendshipRepository
+	store      storage.Store
+	urlSigner  *signedurl.Signer
+	urlTTL     time.Duration
+}
+
+func NewProfileService(userRepo repository.UserRepository, friendRepo repository.FriendshipRepository, store storage.Store, fileURLCfg FileURLConfig) *ProfileService {
+	return &ProfileService{
+		userRepo:   userRepo,
+		friendRepo: friendRepo,
+		store:      store,
+		urlSigner:  signedurl.NewSigner(fileURLCfg.Secret),
+		urlTTL:     fileURLCfg.TTL,
+	}
+}
+
+// UpdateProfile sửa name, bio, status text. Chuỗi được trim, name không được rỗng.
+func (s *ProfileService) UpdateProfile(ctx context.Context, userID uuid.UUID, update ProfileUpdate) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "service", "ProfileService.UpdateProfile")
+	defer span.End()
+	fields := map[string]interface{}{}
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+			return nil, fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidProfile, maxNameLength)
+		}
+		fields["name"] = name
+	}
+	if update.Bio != nil {
+		bio := strings.TrimSpace(*update.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLength {
+			return nil, fmt.Errorf("%w: bio must be at most %d characters", ErrInvalidProfile, maxBioLength)
+		}
+		fields["bio"] = bio
+	}
+	if update.StatusText != nil {
+		statusText := strings.TrimSpace(*update.StatusText)
+		if utf8.RuneCountInString(statusText) > maxStatusTextLength {
+			return nil, fmt.Errorf("%w: status text must be at most %d characters", ErrInvalidProfile, maxStatusTextLength)
+		}
+		fields["status_text"] = statusText
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: nothing to update", ErrInvalidProfile)
+	}
+
+	if err := s.userRepo.UpdateProfile(ctx, userID, fields); err != nil {
+		return nil, err
+	}
+	return s.userRepo.GetUserByID(ctx, userID)
+}
+
+// UpdateAvatar cắt ảnh vuông, lưu các kích thước vào storage dưới avatar/<userID>/<version>/<size>.jpg.
+// User.Avatar là URL bản lớn nhất để client cũ vẫn dùng được, avatar cũ bị xóa.
+// Map trả về là URL theo từng kích thước.
+func (s *ProfileService) UpdateAvatar(ctx context.Context, userID uuid.UUID, r io.Reader) (*models.User, map[string]string, error) {
+	ctx, span := tracing.Start(ctx, "service", "ProfileService.UpdateAvatar")
+	defer span.End()
+	renditions, err := imageproc.Avatar(r, imageproc.DefaultAvatarSizes, imageproc.DefaultOptions())
+	if errors.Is(err, imageproc.ErrUnsupportedFormat) || errors.Is(err, imageproc.ErrTooLarge) {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidProfile, err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	oldAvatar := user.Avatar
+
+	dir := fmt.Sprintf("%s/%d/", userID, time.Now().UnixNano())
+	urls := make(map[string]string, len(renditions))
+	for _, rendition := range renditions {
+		path := dir + rendition.Name + ".jpg"
+		if err := s.store.Put(ctx, AvatarKey(path), bytes.NewReader(rendition.Data), int64(len(rendition.Data)), rendition.ContentType); err != nil {
+			return nil, nil, err
+		}
+		urls[rendition.Name] = AvatarURLPrefix + path
+	}
+	avatar := urls[renditions[len(renditions)-1].Name]
+
+	if err := s.userRepo.UpdateProfile(ctx, userID, map[string]interface{}{"avatar": avatar}); err != nil {
+		return nil, nil, err
+	}
+	s.deleteAvatar(ctx, userID, oldAvatar)
+
+	user.Avatar = avatar
+	return user, urls, nil
+}
+
+// AvatarKey đổi path sau AvatarURLPrefix thành key trong storage
+func AvatarKey(path string) string {
+	return "avatar/" + path
+}
+
+// SignedAvatarURL cấp URL avatar có chữ ký cho user đã đăng nhập. Avatar không nằm trong storage
+// (Google, avatar mặc định) được trả nguyên, expiresAt rỗng.
+func (s *ProfileService) SignedAvatarURL(ctx context.Context, avatar string, userID uuid.UUID) (string, time.Time, error) {
+	_, span := tracing.Start(ctx, "service", "ProfileService.SignedAvatarURL")
+	defer span.End()
+	avatar, _, _ = strings.Cut(avatar, "?")
+	if !strings.HasPrefix(avatar, AvatarURLPrefix) {
+		return avatar, time.Time{}, nil
+	}
+	path := strings.TrimPrefix(avatar, AvatarURLPrefix)
+	if path == "" || strings.Contains(path, "..") {
+		return "", time.Time{}, ErrInvalidAvatarURL
+	}
+	// Hạn được làm tròn lên mốc urlTTL để URL cấp trong cùng khoảng giống nhau, browser dùng lại được cache
+	ttl := 2*s.urlTTL - time.Duration(time.Now().UnixNano()%int64(s.urlTTL))
+	q, expiresAt := s.urlSigner.Sign(AvatarKey(path), userID.String(), ttl)
+	return avatar + "?" + q.Encode(), expiresAt, nil
+}
+
+// VerifyAvatarURL kiểm tra chữ ký URL avatar, trả về key trong storage và thời điểm URL hết hạn
+func (s *ProfileService) VerifyAvatarURL(path string, q url.Values) (string, time.Time, error) {
+	key := AvatarKey(path)
+	if _, expiresAt, err := s.urlSigner.Verify(key, q); err == nil {
+		return key, expiresAt, nil
+	}
+	return "", time.Time{}, ErrInvalidAvatarURL
+}
+
+// deleteAvatar xóa các rendition của avatar cũ, bỏ qua avatar mặc định và avatar từ Google
+func (s *ProfileService) deleteAvatar(ctx context.Context, userID uuid.UUID, avatar string) {
+	prefix := AvatarURLPrefix + userID.String() + "/"
+	if !strings.HasPrefix(avatar, prefix) {
+		return
+	}
+	dir := strings.TrimPrefix(avatar[:strings.LastIndex(avatar, "/")+1], AvatarURLPrefix)
+	for _, size := range imageproc.DefaultAvatarSizes {
+		key := AvatarKey(fmt.Sprintf("%s%d.jpg", dir, size))
+		if err := s.store.Delete(ctx, key); err != nil {
+			slog.WarnContext(ctx, "delete old avatar failed", "key", key, "err", err)
+		}
+	}
+}
+
+// FriendIDs trả về ID của những người đã là bạn, dùng để broadcast profile_updated
+func (s *ProfileService) FriendIDs(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "service", "ProfileService.FriendIDs")
+	defer span.End()
+	friendships, err := s.friendRepo.ListFriends(ctx, userID, "friend")
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(friendships))
+	for _, f := range friendships {
+		if f.UserID == userID {
+			ids = append(ids, f.FriendID.String())
+		} else {
+			ids = append(ids, f.UserID.String())
+		}
+	}
+	return ids, nil
+}
+
+func NewProfileEvent(user *models.User, avatarRenditions map[string]string) ProfileEvent {
+	return ProfileEvent{
+		Type:             "profile_updated",
+		UserID:           user.ID,
+		Name:             user.Name,
+		Avatar:           user.Avatar,
+		AvatarRenditions: avatarRenditions,
+		Bio:              user.Bio,
+		StatusText:       user.StatusText,
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"project/models"
+	"project/pkg/storage"
+	"project/repository"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFileURLConfig = FileURLConfig{Secret: []byte("test"), TTL: time.Minute}
+
+type fakeFriendshipRepo struct {
+	repository.FriendshipRepository
+	friendships []models.Friendship
+}
+
+func (r *fakeFriendshipRepo) ListFriends(ctx context.Context, userID uuid.UUID, status string) ([]models.Friendship, error) {
+	return r.friendships, nil
+}
+
+func TestUpdateProfileValidation(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	var fields map[string]interface{}
+	repo := &repository.MockUserRepository{
+		MockUpdateProfile: func(ctx context.Context, id uuid.UUID, f map[string]interface{}) error {
+			fields = f
+			return nil
+		},
+		MockGetUserByID: func(ctx context.Context, id uuid.UUID) (*models.User, error) {
+			return &models.User{ID: id, Name: "Alice"}, nil
+		},
+	}
+	s := NewProfileService(repo, &fakeFriendshipRepo{}, nil, testFileURLConfig)
+
+	blank, long := "   ", strings.Repeat("a", maxStatusTextLength+1)
+	_, err := s.UpdateProfile(ctx, userID, ProfileUpdate{Name: &blank})
+	assert.ErrorIs(t, err, ErrInvalidProfile)
+	_, err = s.UpdateProfile(ctx, userID, ProfileUpdate{StatusText: &long})
+	assert.ErrorIs(t, err, ErrInvalidProfile)
+	_, err = s.UpdateProfile(ctx, userID, ProfileUpdate{})
+	assert.ErrorIs(t, err, ErrInvalidProfile)
+	assert.Nil(t, fields)
+
+	// Chỉ các field được gửi mới bị sửa, bio rỗng = xóa bio
+	name, bio := "  Alice  ", ""
+	_, err = s.UpdateProfile(ctx, userID, ProfileUpdate{Name: &name, Bio: &bio})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "Alice", "bio": ""}, fields)
+}
+
+func TestUpdateAvatarReplacesOldAvatar(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewLocalStore(storage.LocalConfig{Root: t.TempDir(), PublicURL: "http://localhost/storage", SigningKey: "test"})
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	user := &models.User{ID: userID, Name: "Alice"}
+	repo := &repository.MockUserRepository{
+		MockGetUserByID: func(ctx context.Context, id uuid.UUID) (*models.User, error) {
+			clone := *user
+			return &clone, nil
+		},
+		MockUpdateProfile: func(ctx context.Context, id uuid.UUID, f map[string]interface{}) error {
+			user.Avatar = f["avatar"].(string)
+			return nil
+		},
+	}
+	s := NewProfileService(repo, &fakeFriendshipRepo{}, store, testFileURLConfig)
+
+	img := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	for x := 0; x < 300; x++ {
+		for y := 0; y < 200; y++ {
+			img.Set(x, y, color.RGBA{G: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	_, first, err := s.UpdateAvatar(ctx, userID, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Len(t, first, 3)
+	assert.Equal(t, first["512"], user.Avatar)
+	assert.True(t, strings.HasPrefix(user.Avatar, AvatarURLPrefix+userID.String()+"/"))
+	info, err := store.Stat(ctx, AvatarKey(strings.TrimPrefix(first["256"], AvatarURLPrefix)))
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", info.ContentType)
+
+	updated, second, err := s.UpdateAvatar(ctx, userID, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, second["512"], updated.Avatar)
+	for _, url := range first {
+		_, err := store.Stat(ctx, AvatarKey(strings.TrimPrefix(url, AvatarURLPrefix)))
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	}
+
+	_, _, err = s.UpdateAvatar(ctx, userID, strings.NewReader("not an image"))
+	assert.ErrorIs(t, err, ErrInvalidProfile)
+}
+
+func TestFriendIDs(t *testing.T) {
+	ctx := context.Background()
+	userID, a, b := uuid.New(), uuid.New(), uuid.New()
+	s := NewProfileService(&repository.MockUserRepository{}, &fakeFriendshipRepo{friendships: []models.Friendship{
+		{UserID: userID, FriendID: a},
+		{UserID: b, FriendID: userID},
+	}}, nil, testFileURLConfig)
+	ids, err := s.FriendIDs(ctx, userID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{a.String(), b.String()}, ids)
+}
+
+func TestSignedAvatarURL(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	s := NewProfileService(&repository.MockUserRepository{}, &fakeFriendshipRepo{}, nil, testFileURLConfig)
+
+	// Avatar ngoài storage được trả nguyên
+	external := "https://lh3.googleusercontent.com/a/photo.jpg"
+	raw, expiresAt, err := s.SignedAvatarURL(ctx, external, userID)
+	require.NoError(t, err)
+	assert.Equal(t, external, raw)
+	assert.True(t, expiresAt.IsZero())
+
+	_, _, err = s.SignedAvatarURL(ctx, AvatarURLPrefix+"../secret", userID)
+	assert.ErrorIs(t, err, ErrInvalidAvatarURL)
+
+	path := userID.String() + "/1/128.jpg"
+	raw, expiresAt, err = s.SignedAvatarURL(ctx, AvatarURLPrefix+path, userID)
+	require.NoError(t, err)
+	assert.True(t, expiresAt.After(time.Now().Add(time.Minute-time.Second)))
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	assert.Equal(t, AvatarURLPrefix+path, u.Path)
+
+	key, exp, err := s.VerifyAvatarURL(path, u.Query())
+	require.NoError(t, err)
+	assert.Equal(t, AvatarKey(path), key)
+	assert.True(t, exp.Equal(expiresAt))
+
+	// Chữ ký gắn với đúng path, không dùng được cho avatar khác hoặc khi bị sửa
+	_, _, err = s.VerifyAvatarURL(userID.String()+"/1/512.jpg", u.Query())
+	assert.ErrorIs(t, err, ErrInvalidAvatarURL)
+	q := u.Query()
+	q.Set("expires", "9999999999")
+	_, _, err = s.VerifyAvatarURL(path, q)
+	assert.ErrorIs(t, err, ErrInvalidAvatarURL)
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"project/models"
+	"project/pkg/scanner"
+	"project/pkg/storage"
+	"project/pkg/tracing"
+	"project/repository"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrScanQueueFull   = errors.New("malware scan queue is full")
+	ErrScanQueueClosed = errors.New("malware scan queue is closed")
+)
+
+// UserNotifier gửi message realtime tới các kết nối của user (websocket.Hub)
+type UserNotifier interface {
+	SendToUser(userID string, message []byte) error
+}
+
+type ScanWorkerConfig struct {
+	Workers      int
+	QueueSize    int
+	ScanTimeout  time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration // thời gian chờ trước lần thử lại đầu tiên, nhân đôi sau mỗi lần
+	// RequeueInterval là chu kỳ đưa lại attachment còn pending_scan vào hàng đợi, <= 0 là chỉ requeue khi gọi Requeue
+	RequeueInterval time.Duration
+}
+
+// DefaultScanWorkerConfig là cấu hình mặc định, các giá trị cấu hình được lấy từ config.Scan
+func DefaultScanWorkerConfig() ScanWorkerConfig {
+	return ScanWorkerConfig{
+		Workers:         2,
+		QueueSize:       100,
+		ScanTimeout:     5 * time.Minute,
+		MaxRetries:      3,
+		RetryBackoff:    5 * time.Second,
+		RequeueInterval: time.Minute,
+	}
+}
+
+// AttachmentStatusEvent gửi cho uploader khi quét xong (ready hoặc quarantined)
+type AttachmentStatusEvent struct {
+	Type          string    `json:"type"`
+	AttachmentID  uuid.UUID `json:"attachment_id"`
+	FileName      string    `json:"file_name"`
+	Status        string    `json:"status"`
+	ScanSignature string    `json:"scan_signature,omitempty"`
+}
+
+// ScanWorker quét malware các attachment pending_scan ở background.
+// File sạch chuyển sang ready rồi vào image pipeline, file nhiễm bị chuyển sang quarantine/ và báo cho uploader.
+type ScanWorker struct {
+	attachmentRepo repository.AttachmentRepository
+	store          storage.Store
+	scanner        scanner.Scanner
+	images         *ImageProcessor // nil = không xử lý ảnh
+	notifier       UserNotifier
+	cfg            ScanWorkerConfig
+	jobs           chan uuid.UUID
+	queued         map[uuid.UUID]struct{} // attachment đang trong hàng đợi hoặc đang quét, Requeue bỏ qua
+	queuedMu       sync.Mutex
+	stop           chan struct{}
+	wg             sync.WaitGroup
+	mu             sync.RWMutex
+	closed         bool
+}
+
+func NewScanWorker(attachmentRepo repository.AttachmentRepository, store storage.Store, sc scanner.Scanner, images *ImageProcessor, notifier UserNotifier, cfg ScanWorkerConfig) *ScanWorker {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.ScanTimeout <= 0 {
+		cfg.ScanTimeout = 5 * time.Minute
+	}
+	w := &ScanWorker{
+		attachmentRepo: attachmentRepo,
+		store:          store,
+		scanner:        sc,
+		images:         images,
+		notifier:       notifier,
+		cfg:            cfg,
+		jobs:           make(chan uuid.UUID, cfg.QueueSize),
+		queued:         make(map[uuid.UUID]struct{}),
+		stop:           make(chan struct{}),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		w.wg.Add(1)
+		go w.worker()
+	}
+	if cfg.RequeueInterval > 0 {
+		w.wg.Add(1)
+		go w.requeueLoop()
+	}
+	return w
+}
+
+// requeueLoop định kỳ đưa lại attachment pending_scan bị bỏ sót (hàng đợi đầy, scanner lỗi hết số lần thử)
+func (w *ScanWorker) requeueLoop() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.cfg.RequeueInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			n, err := w.Requeue(context.Background())
+			if err != nil {
+				slog.Error("requeue pending scans failed", "err", err)
+			} else if n > 0 {
+				slog.Info("requeued pending scans", "count", n)
+			}
+		}
+	}
+}
+
+// Enqueue đưa attachment vào hàng đợi quét, trả về ErrScanQueueFull nếu hàng đợi đầy.
+// Attachment đã có trong hàng đợi hoặc đang được quét thì không thêm lần nữa.
+func (w *ScanWorker) Enqueue(attachmentID uuid.UUID) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrScanQueueClosed
+	}
+	w.queuedMu.Lock()
+	defer w.queuedMu.Unlock()
+	if _, ok := w.queued[attachmentID]; ok {
+		return nil
+	}
+	select {
+	case w.jobs <- attachmentID:
+		w.queued[attachmentID] = struct{}{}
+		return nil
+	default:
+		return ErrScanQueueFull
+	}
+}
+
+func (w *ScanWorker) done(attachmentID uuid.UUID) {
+	w.queuedMu.Lock()
+	delete(w.queued, attachmentID)
+	w.queuedMu.Unlock()
+}
+
+// Requeue đưa lại các attachment còn pending_scan (server restart, hàng đợi đầy, scanner lỗi) vào hàng đợi
+func (w *ScanWorker) Requeue(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "service", "ScanWorker.Requeue")
+	defer span.End()
+	attachments, err := w.attachmentRepo.ListAttachmentsByStatus(ctx, models.AttachmentStatusPendingScan, w.cfg.QueueSize)
+	if err != nil {
+		return 0, err
+	}
+	queued := 0
+	for _, a := range attachments {
+		if err := w.Enqueue(a.ID); err != nil {
+			break
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// Close ngừng nhận file mới và chờ quét xong file còn trong hàng đợi
+func (w *ScanWorker) Close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.stop)
+	close(w.jobs)
+	w.mu.Unlock()
+	w.wg.Wait()
+}
+
+func (w *ScanWorker) worker() {
+	defer w.wg.Done()
+	for id := range w.jobs {
+		backoff := w.cfg.RetryBackoff
+		for attempt := 0; ; attempt++ {
+			ctx, cancel := context.WithTimeout(context.Background(), w.cfg.ScanTimeout)
+			err := w.Process(ctx, id)
+			cancel()
+			if err == nil {
+				break
+			}
+			if attempt >= w.cfg.MaxRetries || errors.Is(err, ErrAttachmentNotFound) {
+				// Attachment vẫn pending_scan, Requeue lần sau sẽ quét lại
+				slog.Error("scan attachment failed", "attachment_id", id, "attempts", attempt+1, "err", err)
+				break
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		w.done(id)
+	}
+}
+
+// Process quét đồng bộ một attachment pending_scan
+func (w *ScanWorker) Process(ctx context.Context, attachmentID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "service", "ScanWorker.Process")
+	defer span.End()
+	attachment, err := w.attachmentRepo.GetAttachmentByID(ctx, attachmentID)
+	if err != nil {
+		return err
+	}
+	if attachment == nil || attachment.Status != models.AttachmentStatusPendingScan {
+		return ErrAttachmentNotFound
+	}
+
+	rc, _, err := w.store.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return err
+	}
+	result, err := w.scanner.Scan(ctx, rc)
+	rc.Close()
+	if err != nil {
+		return err
+	}
+
+	if result.Clean {
+		attachment.Status = models.AttachmentStatusReady
+		updated, err := w.attachmentRepo.FinishScan(ctx, attachment.ID, attachment.Status, "", attachment.StorageKey)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			// Worker khác đã ghi kết quả hoặc attachment đã bị xóa trong lúc quét
+			return nil
+		}
+		if w.images != nil && strings.HasPrefix(attachment.MimeType, "image/") {
+			if err := w.images.Enqueue(attachment.ID); err != nil {
+				slog.WarnContext(ctx, "enqueue image processing failed", "attachment_id", attachment.ID, "err", err)
+			}
+		}
+	} else {
+		updated, err := w.quarantine(ctx, attachment, result.Signature)
+		if err != nil || !updated {
+			return err
+		}
+	}
+	w.notify(attachment)
+	return nil
+}
+
+// quarantine chép file sang quarantine/<attachmentID> (blob có thể đang được attachment khác dùng nên không xóa),
+// attachment trỏ sang bản quarantine. Blob không còn ai dùng sẽ bị StorageGC xóa.
+func (w *ScanWorker) quarantine(ctx context.Context, attachment *models.Attachment, signature string) (bool, error) {
+	slog.WarnContext(ctx, "attachment infected", "attachment_id", attachment.ID, "uploader_id", attachment.UploaderID, "signature", signature)
+	key := "quarantine/" + attachment.ID.String()
+	if err := w.store.Copy(ctx, attachment.StorageKey, key); err != nil {
+		return false, err
+	}
+	attachment.StorageKey = key
+	attachment.Status = models.AttachmentStatusQuarantined
+	attachment.ScanSignature = signature
+	return w.attachmentRepo.FinishScan(ctx, attachment.ID, attachment.Status, attachment.ScanSignature, attachment.StorageKey)
+}
+
+func (w *ScanWorker) notify(attachment *models.Attachment) {
+	if w.notifier == nil {
+		return
+	}
+	payload, err := json.Marshal(AttachmentStatusEvent{
+		Type:          "attachment_status",
+		AttachmentID:  attachment.ID,
+		FileName:      attachment.FileName,
+		Status:        attachment.Status,
+		ScanSignature: attachment.ScanSignature,
+	})
+	if err != nil {
+		return
+	}
+	// Uploader offline sẽ thấy status khi tải lại attachment
+	_ = w.notifier.SendToUser(attachment.UploaderID.String(), payload)
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"project/models"
+	"project/pkg/scanner"
+	"project/repository"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages map[string][][]byte
+}
+
+func (n *recordingNotifier) SendToUser(userID string, message []byte) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.messages == nil {
+		n.messages = map[string][][]byte{}
+	}
+	n.messages[userID] = append(n.messages[userID], message)
+	return nil
+}
+
+func TestScanWorkerReleasesCleanAndQuarantinesInfected(t *testing.T) {
+	ctx := context.Background()
+	s, attachments := newUploadTestService(t, UploadConfig{})
+	notifier := &recordingNotifier{}
+	// File có chữ EICAR bị coi là nhiễm
+	sc := scanner.Func(func(ctx context.Context, r io.Reader) (*scanner.Result, error) {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Contains(data, []byte("EICAR")) {
+			return &scanner.Result{Signature: "Eicar-Signature"}, nil
+		}
+		return &scanner.Result{Clean: true}, nil
+	})
+	worker := NewScanWorker(s.attachmentRepo, s.store, sc, nil, notifier, ScanWorkerConfig{Workers: 1, QueueSize: 10})
+	s.scans = worker
+
+	uploaderID := uuid.New()
+	clean, err := s.Upload(ctx, uploaderID, "notes.txt", strings.NewReader("hello world"), 11)
+	require.NoError(t, err)
+	assert.Equal(t, models.AttachmentStatusPendingScan, clean.Status)
+	infected, err := s.Upload(ctx, uploaderID, "eicar.txt", strings.NewReader("X5O EICAR test file"), 19)
+	require.NoError(t, err)
+	blobKey := infected.StorageKey
+
+	worker.Close()
+
+	got, err := s.GetForDownload(ctx, clean.ID, uploaderID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AttachmentStatusReady, got.Status)
+
+	_, err = s.GetForDownload(ctx, infected.ID, uploaderID)
+	assert.ErrorIs(t, err, ErrAttachmentInfected)
+	quarantined := attachments[infected.ID]
+	assert.Equal(t, "quarantine/"+infected.ID.String(), quarantined.StorageKey)
+	assert.Equal(t, "Eicar-Signature", quarantined.ScanSignature)
+	_, err = s.store.Stat(ctx, quarantined.StorageKey)
+	assert.NoError(t, err)
+	assert.NotEqual(t, blobKey, quarantined.StorageKey)
+
+	// Quarantined attachment không gửi kèm message được
+	_, err = validateAttachments(ctx, &repository.MockAttachmentRepository{
+		MockGetAttachmentsByIDs: func(ctx context.Context, ids []uuid.UUID) ([]models.Attachment, error) {
+			return []models.Attachment{*quarantined}, nil
+		},
+	}, uploaderID, []uuid.UUID{infected.ID})
+	assert.ErrorIs(t, err, ErrAttachmentInfected)
+
+	require.Len(t, notifier.messages[uploaderID.String()], 2)
+	statuses := map[uuid.UUID]AttachmentStatusEvent{}
+	for _, raw := range notifier.messages[uploaderID.String()] {
+		var event AttachmentStatusEvent
+		require.NoError(t, json.Unmarshal(raw, &event))
+		assert.Equal(t, "attachment_status", event.Type)
+		statuses[event.AttachmentID] = event
+	}
+	assert.Equal(t, models.AttachmentStatusReady, statuses[clean.ID].Status)
+	assert.Equal(t, models.AttachmentStatusQuarantined, statuses[infected.ID].Status)
+	assert.Equal(t, "Eicar-Signature", statuses[infected.ID].ScanSignature)
+}
+
+func TestScanWorkerRequeuesPeriodicallyAndKeepsConcurrentChanges(t *testing.T) {
+	ctx := context.Background()
+	s, attachments := newUploadTestService(t, UploadConfig{})
+	pending, err := s.Upload(ctx, uuid.New(), "notes.txt", strings.NewReader("hello world"), 11)
+	require.NoError(t, err)
+	// Message được gán trong lúc quét, kết quả quét không được ghi đè
+	messageID := uuid.New()
+	attachments[pending.ID].Status = models.AttachmentStatusPendingScan
+	attachments[pending.ID].MessageID = &messageID
+
+	repo := s.attachmentRepo.(*repository.MockAttachmentRepository)
+	// Attachment đã quét xong bị Process bỏ qua nên danh sách pending_scan có thể cố định
+	snapshot := *attachments[pending.ID]
+	repo.MockListAttachmentsByStatus = func(ctx context.Context, status string, limit int) ([]models.Attachment, error) {
+		return []models.Attachment{snapshot}, nil
+	}
+	notifier := &recordingNotifier{}
+	sc := scanner.Func(func(ctx context.Context, r io.Reader) (*scanner.Result, error) {
+		return &scanner.Result{Clean: true}, nil
+	})
+	// Không gọi Enqueue, attachment chỉ được quét nhờ requeue định kỳ
+	worker := NewScanWorker(repo, s.store, sc, nil, notifier, ScanWorkerConfig{Workers: 1, QueueSize: 10, RequeueInterval: 10 * time.Millisecond})
+	t.Cleanup(worker.Close)
+
+	require.Eventually(t, func() bool {
+		notifier.mu.Lock()
+		defer notifier.mu.Unlock()
+		return len(notifier.messages) > 0
+	}, time.Second, 10*time.Millisecond)
+	worker.Close()
+
+	got := attachments[pending.ID]
+	assert.Equal(t, models.AttachmentStatusReady, got.Status)
+	require.NotNil(t, got.MessageID)
+	assert.Equal(t, messageID, *got.MessageID)
+
+	// Attachment đã đổi trạng thái (quét xong ở nơi khác) thì không báo lại
+	attachments[pending.ID].Status = models.AttachmentStatusPendingScan
+	repo.MockFinishScan = func(ctx context.Context, id uuid.UUID, status, signature, storageKey string) (bool, error) {
+		return false, nil
+	}
+	idle := NewScanWorker(repo, s.store, sc, nil, notifier, ScanWorkerConfig{Workers: 1, QueueSize: 10})
+	defer idle.Close()
+	require.NoError(t, idle.Process(ctx, pending.ID))
+	assert.Len(t, notifier.messages[got.UploaderID.String()], 1)
+}
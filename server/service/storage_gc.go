@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"project/models"
+	"project/pkg/storage"
+	"project/pkg/tracing"
+	"project/repository"
+	"strings"
+	"sync"
+	"time"
+)
+
+type StorageGCConfig struct {
+	Interval    time.Duration
+	GracePeriod time.Duration // chỉ dọn attachment/blob cũ hơn khoảng này, tránh xóa upload đang dở
+	BatchSize   int
+}
+
+// GCResult là số attachment và blob đã dọn trong một lần chạy
+type GCResult struct {
+	Attachments int
+	Blobs       int
+}
+
+// StorageGC định kỳ xóa attachment không thuộc message nào (upload dở/không gửi, message đã xóa)
+// rồi xóa blob không còn attachment hay rendition nào tham chiếu.
+type StorageGC struct {
+	attachmentRepo repository.AttachmentRepository
+	blobRepo       repository.BlobRepository
+	userRepo       repository.UserRepository
+	store          storage.Store
+	cfg            StorageGCConfig
+	stop           chan struct{}
+	wg             sync.WaitGroup
+	once           sync.Once
+}
+
+func NewStorageGC(attachmentRepo repository.AttachmentRepository, blobRepo repository.BlobRepository, userRepo repository.UserRepository, store storage.Store, cfg StorageGCConfig) *StorageGC {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 200
+	}
+	return &StorageGC{
+		attachmentRepo: attachmentRepo,
+		blobRepo:       blobRepo,
+		userRepo:       userRepo,
+		store:          store,
+		cfg:            cfg,
+		stop:           make(chan struct{}),
+	}
+}
+
+// Start chạy GC ở background theo Interval
+func (g *StorageGC) Start() {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		ticker := time.NewTicker(g.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-g.stop:
+				return
+			case <-ticker.C:
+				result, err := g.RunOnce(context.Background())
+				if err != nil {
+					slog.Error("storage gc run failed", "err", err)
+				}
+				if result.Attachments > 0 || result.Blobs > 0 {
+					slog.Info("storage gc removed orphans", "attachments", result.Attachments, "blobs", result.Blobs)
+				}
+			}
+		}
+	}()
+}
+
+// Close dừng GC và chờ lần chạy hiện tại (nếu có) kết thúc
+func (g *StorageGC) Close() {
+	g.once.Do(func() { close(g.stop) })
+	g.wg.Wait()
+}
+
+// RunOnce dọn một batch attachment mồ côi rồi một batch blob không còn tham chiếu
+func (g *StorageGC) RunOnce(ctx context.Context) (GCResult, error) {
+	ctx, span := tracing.Start(ctx, "service", "StorageGC.RunOnce")
+	defer span.End()
+	var result GCResult
+	cutoff := time.Now().Add(-g.cfg.GracePeriod)
+
+	attachments, err := g.attachmentRepo.ListOrphanAttachments(ctx, cutoff, g.cfg.BatchSize)
+	if err != nil {
+		return result, err
+	}
+	for i := range attachments {
+		if err := g.deleteAttachment(ctx, &attachments[i]); err != nil {
+			slog.WarnContext(ctx, "storage gc delete attachment failed", "attachment_id", attachments[i].ID, "err", err)
+			continue
+		}
+		result.Attachments++
+	}
+
+	blobs, err := g.blobRepo.ListOrphanBlobs(ctx, cutoff, g.cfg.BatchSize)
+	if err != nil {
+		return result, err
+	}
+	for _, blob := range blobs {
+		deleted, err := g.blobRepo.DeleteOrphanBlob(ctx, blob.Checksum, cutoff, func(key string) error {
+			return g.store.Delete(ctx, key)
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "storage gc delete blob failed", "checksum", blob.Checksum, "err", err)
+			continue
+		}
+		if deleted {
+			result.Blobs++
+		}
+	}
+	return result, nil
+}
+
+// deleteAttachment xóa attachment và trả quota cho uploader. File của upload dở (key tạm, chưa thành blob)
+// bị xóa luôn, blob thì để bước sau kiểm tra còn ai dùng không.
+func (g *StorageGC) deleteAttachment(ctx context.Context, attachment *models.Attachment) error {
+	if attachment.Status == models.AttachmentStatusPending {
+		if multipartStore, ok := g.store.(storage.MultipartStore); ok && attachment.MultipartUploadID != "" {
+			if err := multipartStore.AbortMultipartUpload(ctx, attachment.StorageKey, attachment.MultipartUploadID); err != nil {
+				slog.WarnContext(ctx, "storage gc abort multipart upload failed", "key", attachment.StorageKey, "err", err)
+			}
+		}
+		if !strings.HasPrefix(attachment.StorageKey, "blobs/") {
+			if err := g.store.Delete(ctx, attachment.StorageKey); err != nil {
+				return err
+			}
+		}
+	}
+	if err := g.attachmentRepo.DeleteAttachment(ctx, attachment.ID); err != nil {
+		return err
+	}
+	if err := g.userRepo.ReleaseStorage(ctx, attachment.UploaderID, attachment.Size); err != nil {
+		slog.WarnContext(ctx, "storage gc release storage failed", "user_id", attachment.UploaderID, "err", err)
+	}
+	return nil
+}
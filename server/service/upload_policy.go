@@ -0,0 +1,190 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"project/pkg/tracing"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ErrUploadRejected là lỗi gốc của mọi UploadPolicyError, dùng với errors.Is
+var ErrUploadRejected = errors.New("upload rejected by policy")
+
+// Mã lỗi trả về cho client khi upload vi phạm policy
+const (
+	UploadErrFileTooLarge   = "file_too_large"
+	UploadErrTypeNotAllowed = "type_not_allowed"
+	UploadErrQuotaExceeded  = "quota_exceeded"
+	UploadErrInvalidMedia   = "invalid_media"
+)
+
+// UploadPolicyError mô tả lý do upload bị từ chối, handler trả nguyên các field cho client
+type UploadPolicyError struct {
+	Code     string
+	Message  string
+	Limit    int64  // giới hạn bytes (file_too_large, quota_exceeded)
+	MimeType string // type đã nhận diện từ nội dung file (type_not_allowed, invalid_media)
+}
+
+func (e *UploadPolicyError) Error() string { return e.Message }
+
+func (e *UploadPolicyError) Unwrap() error { return ErrUploadRejected }
+
+// UploadPolicy giới hạn loại file, kích thước theo loại và tổng dung lượng của mỗi user.
+// Giá trị zero không giới hạn gì (dùng trong test).
+type UploadPolicy struct {
+	// AllowedTypes là danh sách mime type, hỗ trợ wildcard dạng "video/*". Rỗng = cho phép mọi type.
+	AllowedTypes []string
+	// MaxSizes theo type cấp 1 ("image", "video", "audio"), key "" là giới hạn cho các type còn lại. 0 = không giới hạn.
+	MaxSizes map[string]int64
+	// UserQuota là tổng dung lượng mặc định của mỗi user, user có thể có quota riêng trong DB. 0 = không giới hạn.
+	UserQuota int64
+}
+
+// NewUploadPolicy tạo policy, mime type được chuẩn hóa về chữ thường
+func NewUploadPolicy(allowedTypes []string, maxSizes map[string]int64, userQuota int64) UploadPolicy {
+	policy := UploadPolicy{MaxSizes: maxSizes, UserQuota: userQuota}
+	for _, t := range allowedTypes {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			policy.AllowedTypes = append(policy.AllowedTypes, t)
+		}
+	}
+	return policy
+}
+
+// Check kiểm tra mime type (đã bỏ tham số) và kích thước file
+func (p UploadPolicy) Check(mediaType string, size int64) error {
+	if !p.allows(mediaType) {
+		return &UploadPolicyError{
+			Code:     UploadErrTypeNotAllowed,
+			Message:  fmt.Sprintf("file type %s is not allowed", mediaType),
+			MimeType: mediaType,
+		}
+	}
+	if limit := p.maxSize(mediaType); limit > 0 && size > limit {
+		return &UploadPolicyError{
+			Code:    UploadErrFileTooLarge,
+			Message: fmt.Sprintf("file is larger than the %d bytes limit", limit),
+			Limit:   limit,
+		}
+	}
+	return nil
+}
+
+func (p UploadPolicy) allows(mediaType string) bool {
+	if len(p.AllowedTypes) == 0 {
+		return true
+	}
+	mediaType = strings.ToLower(mediaType)
+	for _, t := range p.AllowedTypes {
+		if t == mediaType || t == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p UploadPolicy) maxSize(mediaType string) int64 {
+	major, _, _ := strings.Cut(mediaType, "/")
+	if limit, ok := p.MaxSizes[major]; ok {
+		return limit
+	}
+	return p.MaxSizes[""]
+}
+
+// sniffContentType nhận diện type từ 512 byte đầu của file (không tin Content-Type client gửi)
+// rồi trả về reader đọc lại từ đầu
+func sniffContentType(r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", nil, err
+	}
+	head = head[:n]
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		mediaType = "application/octet-stream"
+	}
+	// http.DetectContentType nhận M4A là video/mp4 (hoặc octet-stream nếu thiếu brand mp4*)
+	if isM4A(head) {
+		mediaType = "audio/mp4"
+	}
+	return mediaType, io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// isM4A kiểm tra box ftyp đầu file có major brand hoặc compatible brand M4A/M4B (file audio AAC của iOS/Android)
+func isM4A(head []byte) bool {
+	if len(head) < 12 || string(head[4:8]) != "ftyp" {
+		return false
+	}
+	size := min(int(binary.BigEndian.Uint32(head[:4])), len(head))
+	for i := 8; i+4 <= size; i += 4 {
+		if i == 12 {
+			continue // minor version
+		}
+		if brand := string(head[i : i+4]); brand == "M4A " || brand == "M4B " {
+			return true
+		}
+	}
+	return false
+}
+
+// StorageUsage là dung lượng đã dùng của user, Quota = 0 nghĩa là không giới hạn
+type StorageUsage struct {
+	Used      int64 `json:"used"`
+	Quota     int64 `json:"quota"`
+	Remaining int64 `json:"remaining,omitempty"`
+}
+
+// StorageUsage trả về dung lượng đã dùng và quota áp dụng cho user
+func (s *AttachmentService) StorageUsage(ctx context.Context, userID uuid.UUID) (*StorageUsage, error) {
+	ctx, span := tracing.Start(ctx, "service", "AttachmentService.StorageUsage")
+	defer span.End()
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	usage := &StorageUsage{Used: user.StorageUsed, Quota: s.uploadCfg.Policy.UserQuota}
+	if user.StorageQuota > 0 {
+		usage.Quota = user.StorageQuota
+	}
+	if usage.Quota > 0 {
+		usage.Remaining = max(usage.Quota-usage.Used, 0)
+	}
+	return usage, nil
+}
+
+// reserveStorage cộng size vào dung lượng đã dùng, lỗi quota_exceeded nếu vượt quota
+func (s *AttachmentService) reserveStorage(ctx context.Context, userID uuid.UUID, size int64) error {
+	ok, err := s.userRepo.ReserveStorage(ctx, userID, size, s.uploadCfg.Policy.UserQuota)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &UploadPolicyError{
+			Code:    UploadErrQuotaExceeded,
+			Message: "storage quota exceeded",
+			Limit:   s.uploadCfg.Policy.UserQuota,
+		}
+	}
+	return nil
+}
+
+// releaseStorage trả lại dung lượng khi upload bị hủy hoặc lỗi, chỉ log lỗi
+func (s *AttachmentService) releaseStorage(ctx context.Context, userID uuid.UUID, size int64) {
+	if err := s.userRepo.ReleaseStorage(ctx, userID, size); err != nil {
+		slog.WarnContext(ctx, "release storage failed", "user_id", userID, "bytes", size, "err", err)
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"project/repository"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPolicy = UploadPolicy{
+	AllowedTypes: []string{"image/png", "video/*", "text/plain"},
+	MaxSizes:     map[string]int64{"image": 1 << 20, "video": 100 << 20, "": 100},
+	UserQuota:    1000,
+}
+
+func TestUploadPolicyCheck(t *testing.T) {
+	assert.NoError(t, testPolicy.Check("video/mp4", 50<<20))
+	assert.NoError(t, testPolicy.Check("image/png", 1<<20))
+
+	var policyErr *UploadPolicyError
+	err := testPolicy.Check("text/html", 10)
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, UploadErrTypeNotAllowed, policyErr.Code)
+	assert.ErrorIs(t, err, ErrUploadRejected)
+
+	err = testPolicy.Check("text/plain", 101)
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, UploadErrFileTooLarge, policyErr.Code)
+	assert.Equal(t, int64(100), policyErr.Limit)
+
+	assert.NoError(t, UploadPolicy{}.Check("application/x-anything", 1<<40))
+}
+
+// quotaUserRepo giữ storage_used trong bộ nhớ như câu UPDATE có điều kiện của repo thật
+func quotaUserRepo(used *int64) *repository.MockUserRepository {
+	return &repository.MockUserRepository{
+		MockReserveStorage: func(ctx context.Context, id uuid.UUID, bytes, defaultQuota int64) (bool, error) {
+			if defaultQuota > 0 && *used+bytes > defaultQuota {
+				return false, nil
+			}
+			*used += bytes
+			return true, nil
+		},
+		MockReleaseStorage: func(ctx context.Context, id uuid.UUID, bytes int64) error {
+			*used = max(*used-bytes, 0)
+			return nil
+		},
+	}
+}
+
+func TestUploadSniffsContentAndEnforcesQuota(t *testing.T) {
+	ctx := context.Background()
+	s, attachments := newUploadTestService(t, UploadConfig{Policy: testPolicy})
+	var used int64
+	s.userRepo = quotaUserRepo(&used)
+	userID := uuid.New()
+
+	// Client khai báo .png nhưng nội dung là HTML
+	html := "<!DOCTYPE html><script>alert(1)</script>"
+	_, err := s.Upload(ctx, userID, "cat.png", strings.NewReader(html), int64(len(html)))
+	var policyErr *UploadPolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, UploadErrTypeNotAllowed, policyErr.Code)
+	assert.Equal(t, "text/html", policyErr.MimeType)
+	assert.Zero(t, used)
+
+	attachment, err := s.Upload(ctx, userID, "notes.txt", strings.NewReader("hello"), 5)
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", attachment.MimeType)
+	assert.Equal(t, int64(5), used)
+
+	used = 998
+	_, err = s.Upload(ctx, userID, "notes.txt", strings.NewReader("hello"), 5)
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, UploadErrQuotaExceeded, policyErr.Code)
+	assert.Equal(t, int64(998), used)
+	assert.Len(t, attachments, 1)
+}
+
+func TestCompleteUploadRejectsDisguisedContent(t *testing.T) {
+	ctx := context.Background()
+	s, attachments := newUploadTestService(t, UploadConfig{URLExpiry: time.Minute, MultipartThreshold: 1 << 20, Policy: testPolicy})
+	var used int64
+	s.userRepo = quotaUserRepo(&used)
+	userID := uuid.New()
+
+	_, err := s.CreateUpload(ctx, userID, "page.html", "text/html", 10)
+	assert.ErrorIs(t, err, ErrUploadRejected)
+
+	body := "<html><body>hi</body></html>"
+	session, err := s.CreateUpload(ctx, userID, "notes.txt", "text/plain", int64(len(body)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(body)), used)
+
+	resp := putTo(t, session.URL, session.Headers["Content-Type"], body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = s.CompleteUpload(ctx, userID, session.UploadID, nil)
+	var policyErr *UploadPolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, UploadErrTypeNotAllowed, policyErr.Code)
+	assert.Empty(t, attachments)
+	assert.Zero(t, used)
+}
+
+func TestUploadRejectsMalformedAudio(t *testing.T) {
+	s, attachments := newUploadTestService(t, UploadConfig{})
+	ogg := "OggS\x00\x02 truncated voice message"
+	_, err := s.Upload(context.Background(), uuid.New(), "voice.ogg", strings.NewReader(ogg), int64(len(ogg)))
+	var policyErr *UploadPolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, UploadErrInvalidMedia, policyErr.Code)
+	assert.Equal(t, "application/ogg", policyErr.MimeType)
+	assert.Empty(t, attachments)
+}
+
+func TestUploadAcceptsNonOpusOgg(t *testing.T) {
+	s, attachments := newUploadTestService(t, UploadConfig{})
+	// Page Ogg đầu tiên chứa header Vorbis thay vì OpusHead
+	vorbis := "OggS\x00\x02" + strings.Repeat("\x00", 20) + "\x01\x1e\x01vorbis" + strings.Repeat("\x00", 23)
+	attachment, err := s.Upload(context.Background(), uuid.New(), "song.ogg", strings.NewReader(vorbis), int64(len(vorbis)))
+	require.NoError(t, err)
+	assert.Equal(t, "application/ogg", attachment.MimeType)
+	assert.Zero(t, attachment.DurationMs)
+	assert.Empty(t, attachment.Waveform)
+	assert.Len(t, attachments, 1)
+}
+
+func TestSniffContentTypeDetectsM4A(t *testing.T) {
+	ftyp := func(major string, compatible ...string) string {
+		body := major + "\x00\x00\x00\x00" + strings.Join(compatible, "")
+		return string([]byte{0, 0, 0, byte(8 + len(body))}) + "ftyp" + body + "\x00\x00\x00\x08free"
+	}
+	for name, tc := range map[string]struct {
+		head string
+		want string
+	}{
+		"m4a major brand":      {ftyp("M4A ", "M4A "), "audio/mp4"},
+		"m4a compatible brand": {ftyp("mp42", "isom", "M4A "), "audio/mp4"},
+		"mp4 video":            {ftyp("mp42", "isom", "mp42"), "video/mp4"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, _, err := sniffContentType(strings.NewReader(tc.head))
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
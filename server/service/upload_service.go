@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"io"
+	"log/slog"
+	"mime"
+	"path/filepath"
+	"project/models"
+	"project/pkg/audioproc"
+	"project/pkg/metrics"
+	"project/pkg/storage"
+	"project/pkg/tracing"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidUpload          = errors.New("invalid upload request")
+	ErrUploadAlreadyCompleted = errors.New("upload already completed")
+	ErrUploadMismatch         = errors.New("uploaded file does not match the declared size or content type")
+)
+
+// UploadConfig cấu hình upload trực tiếp lên storage qua presigned URL
+type UploadConfig struct {
+	URLExpiry          time.Duration // thời hạn của presigned URL
+	MultipartThreshold int64         // file lớn hơn ngưỡng này được chia part
+	PartSize           int64
+	Policy             UploadPolicy
+}
+
+type UploadPart struct {
+	PartNumber int    `json:"part_number"`
+	URL        string `json:"url"`
+}
+
+// UploadSession trả về cho client: PUT file lên URL (hoặc từng part lên Parts[i].URL) rồi gọi complete
+type UploadSession struct {
+	UploadID  uuid.UUID         `json:"upload_id"`
+	URL       string            `json:"url,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"` // header client phải gửi kèm PUT
+	PartSize  int64             `json:"part_size,omitempty"`
+	Parts     []UploadPart      `json:"parts,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// CreateUpload tạo attachment pending và presigned URL để client upload thẳng lên storage.
+// File lớn hơn MultipartThreshold được chia part nếu store hỗ trợ multipart.
+func (s *AttachmentService) CreateUpload(ctx context.Context, uploaderID uuid.UUID, fileName, contentType string, size int64) (*UploadSession, error) {
+	ctx, span := tracing.Start(ctx, "service", "AttachmentService.CreateUpload")
+	defer span.End()
+	if size <= 0 || fileName == "" {
+		return nil, ErrInvalidUpload
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ErrInvalidUpload
+	}
+	if err := s.uploadCfg.Policy.Check(mediaType, size); err != nil {
+		return nil, err
+	}
+	// Quota được giữ ngay khi tạo upload, trả lại khi hủy hoặc file upload không hợp lệ
+	if err := s.reserveStorage(ctx, uploaderID, size); err != nil {
+		return nil, err
+	}
+
+	attachment := &models.Attachment{
+		ID:         uuid.New(),
+		UploaderID: uploaderID,
+		FileName:   filepath.Base(fileName),
+		MimeType:   mediaType,
+		Size:       size,
+		Status:     models.AttachmentStatusPending,
+	}
+	attachment.StorageKey = uploadKey(attachment.ID, fileName)
+	session := &UploadSession{
+		UploadID:  attachment.ID,
+		ExpiresAt: time.Now().Add(s.uploadCfg.URLExpiry),
+	}
+	if err := s.startUpload(ctx, attachment, session); err != nil {
+		s.releaseStorage(ctx, uploaderID, size)
+		return nil, err
+	}
+	return session, nil
+}
+
+// startUpload tạo presigned URL (hoặc multipart upload) và lưu attachment pending
+func (s *AttachmentService) startUpload(ctx context.Context, attachment *models.Attachment, session *UploadSession) error {
+	size, mediaType := attachment.Size, attachment.MimeType
+	multipartStore, ok := s.store.(storage.MultipartStore)
+	if ok && size > s.uploadCfg.MultipartThreshold {
+		partSize := s.uploadCfg.PartSize
+		if partSize < storage.MinPartSize {
+			partSize = storage.MinPartSize
+		}
+		if (size+partSize-1)/partSize > storage.MaxParts {
+			partSize = (size + storage.MaxParts - 1) / storage.MaxParts
+		}
+		partCount := int((size + partSize - 1) / partSize)
+
+		uploadID, err := multipartStore.CreateMultipartUpload(ctx, attachment.StorageKey, mediaType)
+		if err != nil {
+			return err
+		}
+		attachment.MultipartUploadID = uploadID
+		session.PartSize = partSize
+		for n := 1; n <= partCount; n++ {
+			url, err := multipartStore.PresignUploadPart(ctx, attachment.StorageKey, uploadID, n, s.uploadCfg.URLExpiry)
+			if err != nil {
+				s.abortMultipart(ctx, attachment)
+				return err
+			}
+			session.Parts = append(session.Parts, UploadPart{PartNumber: n, URL: url})
+		}
+	} else {
+		url, err := s.store.PresignPut(ctx, attachment.StorageKey, mediaType, s.uploadCfg.URLExpiry)
+		if err != nil {
+			return err
+		}
+		session.URL = url
+		session.Headers = map[string]string{"Content-Type": mediaType}
+	}
+
+	if err := s.attachmentRepo.CreateAttachment(ctx, attachment); err != nil {
+		s.abortMultipart(ctx, attachment)
+		return err
+	}
+	return nil
+}
+
+// CompleteUpload kiểm tra object trên storage (HEAD) khớp size và content type đã khai báo,
+// tính checksum, chuyển file thành blob (bỏ bản upload nếu đã có nội dung giống hệt) rồi chuyển attachment sang ready.
+// Upload sai bị xóa, client phải tạo upload mới.
+func (s *AttachmentService) CompleteUpload(ctx context.Context, uploaderID, uploadID uuid.UUID, parts []storage.CompletedPart) (*models.Attachment, error) {
+	ctx, span := tracing.Start(ctx, "service", "AttachmentService.CompleteUpload")
+	defer span.End()
+	attachment, err := s.attachmentRepo.GetAttachmentByID(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if attachment == nil || attachment.UploaderID != uploaderID {
+		return nil, ErrAttachmentNotFound
+	}
+	if attachment.Status != models.AttachmentStatusPending {
+		return nil, ErrUploadAlreadyCompleted
+	}
+
+	if attachment.MultipartUploadID != "" {
+		multipartStore, ok := s.store.(storage.MultipartStore)
+		if !ok || len(parts) == 0 {
+			return nil, ErrInvalidUpload
+		}
+		if err := multipartStore.CompleteMultipartUpload(ctx, attachment.StorageKey, attachment.MultipartUploadID, parts); err != nil {
+			if errors.Is(err, storage.ErrInvalidPart) {
+				return nil, ErrInvalidUpload
+			}
+			return nil, err
+		}
+		// Multipart upload đã được ghép thành object, upload ID không còn dùng được
+		attachment.MultipartUploadID = ""
+	}
+
+	info, err := s.store.Stat(ctx, attachment.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrAttachmentNotReady
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Size != attachment.Size || !sameMediaType(info.ContentType, attachment.MimeType) {
+		s.discardUpload(ctx, attachment)
+		return nil, ErrUploadMismatch
+	}
+
+	rc, _, err := s.store.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	// Content-Type do client khai báo, kiểm tra lại policy với type thật của nội dung
+	sniffed, body, err := sniffContentType(rc)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.uploadCfg.Policy.Check(sniffed, attachment.Size); err != nil {
+		s.discardUpload(ctx, attachment)
+		return nil, err
+	}
+	// Voice message được kiểm tra theo type khai báo audio/* (sniff nhận WebM/M4A là video/*)
+	probeType := sniffed
+	if strings.HasPrefix(attachment.MimeType, "audio/") {
+		probeType, _, _ = mime.ParseMediaType(attachment.MimeType)
+	}
+	content, err := inspectContent(body, probeType)
+	if errors.Is(err, ErrUploadRejected) {
+		s.discardUpload(ctx, attachment)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	content.apply(attachment)
+	blob, err := s.blobs.Adopt(ctx, attachment.StorageKey, content.Checksum, attachment.Size, attachment.MimeType)
+	if err != nil {
+		return nil, err
+	}
+	attachment.StorageKey = blob.StorageKey
+	attachment.Status = s.uploadedStatus()
+	if err := s.attachmentRepo.UpdateAttachment(ctx, attachment); err != nil {
+		return nil, err
+	}
+	metrics.UploadBytes.WithLabelValues("direct").Add(float64(attachment.Size))
+	s.enqueueProcessing(attachment)
+	return attachment, nil
+}
+
+// AbortUpload hủy upload đang pending và dọn object/part trên storage
+func (s *AttachmentService) AbortUpload(ctx context.Context, uploaderID, uploadID uuid.UUID) error {
+	ctx, span := tracing.Start(ctx, "service", "AttachmentService.AbortUpload")
+	defer span.End()
+	attachment, err := s.attachmentRepo.GetAttachmentByID(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+	if attachment == nil || attachment.UploaderID != uploaderID {
+		return ErrAttachmentNotFound
+	}
+	if attachment.Status != models.AttachmentStatusPending {
+		return ErrUploadAlreadyCompleted
+	}
+	s.discardUpload(ctx, attachment)
+	return nil
+}
+
+func (s *AttachmentService) discardUpload(ctx context.Context, attachment *models.Attachment) {
+	s.abortMultipart(ctx, attachment)
+	if err := s.store.Delete(ctx, attachment.StorageKey); err != nil {
+		slog.WarnContext(ctx, "delete upload object failed", "key", attachment.StorageKey, "err", err)
+	}
+	if err := s.attachmentRepo.DeleteAttachment(ctx, attachment.ID); err != nil {
+		slog.WarnContext(ctx, "delete pending attachment failed", "attachment_id", attachment.ID, "err", err)
+		return
+	}
+	s.releaseStorage(ctx, attachment.UploaderID, attachment.Size)
+}
+
+func (s *AttachmentService) abortMultipart(ctx context.Context, attachment *models.Attachment) {
+	if attachment.MultipartUploadID == "" {
+		return
+	}
+	if multipartStore, ok := s.store.(storage.MultipartStore); ok {
+		if err := multipartStore.AbortMultipartUpload(ctx, attachment.StorageKey, attachment.MultipartUploadID); err != nil {
+			slog.WarnContext(ctx, "abort multipart upload failed", "key", attachment.StorageKey, "err", err)
+		}
+	}
+}
+
+// contentInfo là thông tin đọc từ nội dung file lúc upload
+type contentInfo struct {
+	Checksum      string
+	Width, Height int
+	Audio         *audioproc.Info // file ghi âm Opus/WebM/M4A
+}
+
+// apply ghi thông tin vào attachment. File ghi âm được đổi sang mime type audio/* của container
+// (sniff nhận WebM/M4A là video/*, Ogg là application/ogg).
+func (c *contentInfo) apply(attachment *models.Attachment) {
+	attachment.Checksum = c.Checksum
+	attachment.Width, attachment.Height = c.Width, c.Height
+	if c.Audio != nil {
+		attachment.MimeType = c.Audio.MimeType
+		attachment.DurationMs = c.Audio.Duration.Milliseconds()
+		attachment.Waveform = c.Audio.Waveform
+	}
+}
+
+// audioContainers là các type có thể là voice message, đọc thời lượng và waveform
+var audioContainers = map[string]bool{
+	"application/ogg": true,
+	"audio/ogg":       true,
+	"audio/opus":      true,
+	"audio/webm":      true,
+	"video/webm":      true,
+	"audio/mp4":       true,
+	"audio/x-m4a":     true,
+	"audio/m4a":       true,
+	"video/mp4":       true,
+}
+
+// inspectContent đọc stream 1 lần để tính sha256, kích thước ảnh (nếu là ảnh) và metadata audio.
+// File voice message (Opus, WebM, M4A) không đọc được bị từ chối. Ogg không phải Opus (Vorbis, FLAC)
+// là file audio thường nên chỉ không có waveform, video WebM/MP4 cũng không bắt buộc.
+func inspectContent(r io.Reader, mimeType string) (*contentInfo, error) {
+	hash := sha256.New()
+	tee := io.TeeReader(r, hash)
+	info := &contentInfo{}
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		if cfg, _, err := image.DecodeConfig(tee); err == nil {
+			info.Width, info.Height = cfg.Width, cfg.Height
+		}
+	case audioContainers[mimeType]:
+		audio, err := audioproc.Probe(tee, audioproc.DefaultWaveformBars)
+		switch {
+		case err == nil:
+			info.Audio = audio
+		case errors.Is(err, audioproc.ErrUnsupportedCodec) && mimeType != "audio/opus":
+			// Không phải voice message, lưu như file audio thường
+		case !strings.HasPrefix(mimeType, "video/"):
+			return nil, &UploadPolicyError{
+				Code:     UploadErrInvalidMedia,
+				Message:  "audio file is malformed or uses an unsupported format",
+				MimeType: mimeType,
+			}
+		}
+	}
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return nil, err
+	}
+	info.Checksum = hex.EncodeToString(hash.Sum(nil))
+	return info, nil
+}
+
+func sameMediaType(a, b string) bool {
+	ma, _, errA := mime.ParseMediaType(a)
+	mb, _, errB := mime.ParseMediaType(b)
+	return errA == nil && errB == nil && strings.EqualFold(ma, mb)
+}
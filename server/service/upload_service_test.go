@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"project/models"
+	"project/pkg/storage"
+	"project/repository"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUploadTestService dùng LocalStore thật phía sau httptest server, attachment lưu trong map
+func newUploadTestService(t *testing.T, cfg UploadConfig) (*AttachmentService, map[uuid.UUID]*models.Attachment) {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	store, err := storage.NewLocalStore(storage.LocalConfig{Root: t.TempDir(), PublicURL: srv.URL + "/storage", SigningKey: "test"})
+	require.NoError(t, err)
+	mux.Handle("/storage/", store.Handler("/storage"))
+
+	attachments := map[uuid.UUID]*models.Attachment{}
+	// Worker quét chạy ở goroutine khác nên map cần khóa
+	var mu sync.Mutex
+	repo := &repository.MockAttachmentRepository{
+		MockCreateAttachment: func(ctx context.Context, a *models.Attachment) error {
+			mu.Lock()
+			defer mu.Unlock()
+			attachments[a.ID] = a
+			return nil
+		},
+		MockGetAttachmentByID: func(ctx context.Context, id uuid.UUID) (*models.Attachment, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if a, ok := attachments[id]; ok {
+				clone := *a
+				return &clone, nil
+			}
+			return nil, nil
+		},
+		MockUpdateAttachment: func(ctx context.Context, a *models.Attachment) error {
+			mu.Lock()
+			defer mu.Unlock()
+			attachments[a.ID] = a
+			return nil
+		},
+		MockFinishScan: func(ctx context.Context, id uuid.UUID, status, signature, storageKey string) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			a, ok := attachments[id]
+			if !ok || a.Status != models.AttachmentStatusPendingScan {
+				return false, nil
+			}
+			// Bản sao mới giống UpdateAttachment, con trỏ trả về từ Upload không bị sửa
+			clone := *a
+			clone.Status, clone.ScanSignature, clone.StorageKey = status, signature, storageKey
+			attachments[id] = &clone
+			return true, nil
+		},
+		MockDeleteAttachment: func(ctx context.Context, id uuid.UUID) error {
+			mu.Lock()
+			defer mu.Unlock()
+			delete(attachments, id)
+			return nil
+		},
+	}
+	return NewAttachmentService(repo, nil, &repository.MockUserRepository{}, store, NewBlobStore(newMemBlobRepo(), store), cfg, FileURLConfig{}, nil, nil), attachments
+}
+
+func putTo(t *testing.T, url, contentType, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	return resp
+}
+
+func TestDirectUploadSinglePut(t *testing.T) {
+	ctx := context.Background()
+	s, attachments := newUploadTestService(t, UploadConfig{URLExpiry: time.Minute, MultipartThreshold: 1 << 20, PartSize: storage.MinPartSize})
+	userID := uuid.New()
+
+	session, err := s.CreateUpload(ctx, userID, "notes.txt", "text/plain", 5)
+	require.NoError(t, err)
+	require.NotEmpty(t, session.URL)
+	assert.Empty(t, session.Parts)
+	assert.Equal(t, models.AttachmentStatusPending, attachments[session.UploadID].Status)
+
+	// Chưa upload thì chưa complete được
+	_, err = s.CompleteUpload(ctx, userID, session.UploadID, nil)
+	assert.ErrorIs(t, err, ErrAttachmentNotReady)
+
+	putTo(t, session.URL, session.Headers["Content-Type"], "hello")
+
+	// Người khác không complete được upload
+	_, err = s.CompleteUpload(ctx, uuid.New(), session.UploadID, nil)
+	assert.ErrorIs(t, err, ErrAttachmentNotFound)
+
+	attachment, err := s.CompleteUpload(ctx, userID, session.UploadID, nil)
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte("hello"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), attachment.Checksum)
+	assert.Equal(t, models.AttachmentStatusReady, attachments[session.UploadID].Status)
+
+	_, err = s.CompleteUpload(ctx, userID, session.UploadID, nil)
+	assert.ErrorIs(t, err, ErrUploadAlreadyCompleted)
+}
+
+func TestDirectUploadSizeMismatchIsDiscarded(t *testing.T) {
+	ctx := context.Background()
+	s, attachments := newUploadTestService(t, UploadConfig{URLExpiry: time.Minute, MultipartThreshold: 1 << 20, PartSize: storage.MinPartSize})
+	userID := uuid.New()
+
+	session, err := s.CreateUpload(ctx, userID, "a.txt", "text/plain", 3)
+	require.NoError(t, err)
+	putTo(t, session.URL, "text/plain", "much longer than declared")
+
+	_, err = s.CompleteUpload(ctx, userID, session.UploadID, nil)
+	assert.ErrorIs(t, err, ErrUploadMismatch)
+	assert.NotContains(t, attachments, session.UploadID)
+}
+
+func TestDirectUploadMultipart(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newUploadTestService(t, UploadConfig{URLExpiry: time.Minute, MultipartThreshold: 1 << 20, PartSize: storage.MinPartSize})
+	userID := uuid.New()
+	content := strings.Repeat("v", storage.MinPartSize+10)
+
+	session, err := s.CreateUpload(ctx, userID, "clip.mp4", "video/mp4", int64(len(content)))
+	require.NoError(t, err)
+	require.Len(t, session.Parts, 2)
+	assert.Equal(t, int64(storage.MinPartSize), session.PartSize)
+
+	var parts []storage.CompletedPart
+	for i, p := range session.Parts {
+		start := int64(i) * session.PartSize
+		end := start + session.PartSize
+		if end > int64(len(content)) {
+			end = int64(len(content))
+		}
+		resp := putTo(t, p.URL, "", content[start:end])
+		parts = append(parts, storage.CompletedPart{PartNumber: p.PartNumber, ETag: resp.Header.Get("ETag")})
+	}
+
+	_, err = s.CompleteUpload(ctx, userID, session.UploadID, nil)
+	assert.ErrorIs(t, err, ErrInvalidUpload)
+
+	attachment, err := s.CompleteUpload(ctx, userID, session.UploadID, parts)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), attachment.Size)
+	assert.Equal(t, "video/mp4", attachment.MimeType)
+}
@@ -1,11 +1,12 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"log"
+	"log/slog"
 	"project/models"
+	"project/pkg/tracing"
 	"project/repository"
 
 	"project/utils"
@@ -20,7 +21,9 @@ func NewInitUserService(repoUser *repository.UserRepository) *UserService {
 		repoUser: *repoUser,
 	}
 }
-func (s *UserService) RegisterUser(name string, email string, password string) (*models.User, error) {
+func (s *UserService) RegisterUser(ctx context.Context, name string, email string, password string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "service", "UserService.RegisterUser")
+	defer span.End()
 
 	if name == "" || email == "" || password == "" {
 		return nil, errors.New("all fields are required")
@@ -41,7 +44,7 @@ func (s *UserService) RegisterUser(name string, email string, password string) (
 		Provider: "local",
 	}
 
-	createdUser, err := s.repoUser.CreateUser(user)
+	createdUser, err := s.repoUser.CreateUser(ctx, user)
 	if err != nil {
 
 		return nil, err
@@ -54,45 +57,45 @@ func NewUserService(r repository.UserRepository) *UserService {
 	return &UserService{repoUser: r}
 }
 
-func (s *UserService) CheckEmail(email string) (bool, error) {
-	user, err := s.repoUser.GetUserByEmail(email)
+func (s *UserService) CheckEmail(ctx context.Context, email string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "service", "UserService.CheckEmail")
+	defer span.End()
+	user, err := s.repoUser.GetUserByEmail(ctx, email)
 	if err != nil {
 		return false, err
 	}
 	return user != nil, nil
 }
-func (s *UserService) LoginPassword(email string, password string) (*models.User, error) {
-	fmt.Println(email, password)
-	user, err := s.repoUser.LoginPassword(email, password, "local")
-	if user == nil {
-		return nil, err
-	}
+func (s *UserService) LoginPassword(ctx context.Context, email string, password string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "service", "UserService.LoginPassword")
+	defer span.End()
+	user, err := s.repoUser.LoginPassword(ctx, email, password, "local")
 	if err != nil {
 		return nil, err
 	}
+	if user == nil {
+		return nil, repository.ErrInvalidCredentials
+	}
 	return user, nil
 }
 
-func (s *UserService) FindUserWithStatusFriend(email string, userID string) (map[string]interface{}, error) {
-	log.Printf("[FindUserWithStatusFriend] Input email: %s, userID: %s", email, userID)
+func (s *UserService) FindUserWithStatusFriend(ctx context.Context, email string, userID string) (map[string]interface{}, error) {
+	ctx, span := tracing.Start(ctx, "service", "UserService.FindUserWithStatusFriend")
+	defer span.End()
 
 	// Convert user_id sang uuid
 	uuidUserID, err := utils.StringToUUID(userID)
 	if err != nil {
-		log.Printf("[FindUserWithStatusFriend] Invalid userID: %v", err)
 		return nil, err
 	}
-	log.Printf("[FindUserWithStatusFriend] Converted userID to UUID: %s", uuidUserID)
 
 	// Gọi repo để lấy user + status
-	user, status, err := s.repoUser.FindUserWithStatusFriend(email, uuidUserID)
+	user, status, err := s.repoUser.FindUserWithStatusFriend(ctx, email, uuidUserID)
 	if err != nil {
-		log.Printf("[FindUserWithStatusFriend] Repo error: %v", err)
 		return nil, err
 	}
 
 	if user == nil {
-		log.Printf("[FindUserWithStatusFriend] User not found for email: %s", email)
 		return nil, nil
 	}
 
@@ -100,17 +103,15 @@ func (s *UserService) FindUserWithStatusFriend(email string, userID string) (map
 	userMap := make(map[string]interface{})
 	data, err := json.Marshal(user)
 	if err != nil {
-		log.Printf("[FindUserWithStatusFriend] Error marshaling user: %v", err)
 		return nil, err
 	}
 
 	if err := json.Unmarshal(data, &userMap); err != nil {
-		log.Printf("[FindUserWithStatusFriend] Error unmarshaling to map: %v", err)
 		return nil, err
 	}
 
 	userMap["status"] = status
-	log.Printf("[FindUserWithStatusFriend] Result userMap: %+v", userMap)
+	slog.DebugContext(ctx, "find user with friend status", "user_id", user.ID, "status", status)
 
 	return userMap, nil
 }
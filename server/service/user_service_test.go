@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"project/models"
 	"project/repository"
@@ -13,14 +14,14 @@ func TestCheckEmail(t *testing.T) {
 	tests := []struct {
 		name          string
 		email         string
-		mockRepo      func(email string) (*models.User, error)
+		mockRepo      func(ctx context.Context, email string) (*models.User, error)
 		expectedEmail bool
 		expectError   bool
 	}{
 		{
 			name:  "Email exists",
 			email: "a@example.com",
-			mockRepo: func(email string) (*models.User, error) {
+			mockRepo: func(ctx context.Context, email string) (*models.User, error) {
 				return &models.User{Email: email}, nil
 			},
 			expectedEmail: true,
@@ -29,7 +30,7 @@ func TestCheckEmail(t *testing.T) {
 		{
 			name:  "Email not exists",
 			email: "b@example.com",
-			mockRepo: func(email string) (*models.User, error) {
+			mockRepo: func(ctx context.Context, email string) (*models.User, error) {
 				return nil, nil
 			},
 			expectedEmail: false,
@@ -38,7 +39,7 @@ func TestCheckEmail(t *testing.T) {
 		{
 			name:  "Repo error",
 			email: "c@example.com",
-			mockRepo: func(email string) (*models.User, error) {
+			mockRepo: func(ctx context.Context, email string) (*models.User, error) {
 				return nil, errors.New("db error")
 			},
 			expectedEmail: false,
@@ -48,6 +49,7 @@ func TestCheckEmail(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
 
 			mockRepo := &repository.MockUserRepository{
 				MockGetUserByEmail: tt.mockRepo,
@@ -57,7 +59,7 @@ func TestCheckEmail(t *testing.T) {
 				repoUser: mockRepo,
 			}
 
-			actual, err := service.CheckEmail(tt.email)
+			actual, err := service.CheckEmail(ctx, tt.email)
 
 			assert.Equal(t, tt.expectedEmail, actual)
 			if tt.expectError {
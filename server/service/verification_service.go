@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"project/models"
+	"project/pkg/mailer"
+	"project/pkg/tracing"
+	"project/repository"
+	"project/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrEmailAlreadyVerified  = errors.New("email already verified")
+	ErrVerificationThrottled = errors.New("verification email was sent recently, please wait")
+)
+
+// Các tính năng có thể bị giới hạn khi user chưa xác thực email
+const (
+	FeatureFriendInvite = "friend_invite"
+	FeatureSendMessage  = "send_message"
+	FeatureUpload       = "upload"
+)
+
+// VerificationPolicy quy định các tính năng cần email đã xác thực
+type VerificationPolicy struct {
+	RequiredFor    map[string]bool
+	ResendInterval time.Duration
+}
+
+// ParseVerificationPolicy đọc danh sách tính năng, vd ["friend_invite", "upload"].
+// Giá trị "none" tắt toàn bộ giới hạn.
+func ParseVerificationPolicy(requiredFor []string, resendInterval time.Duration) *VerificationPolicy {
+	policy := &VerificationPolicy{
+		RequiredFor:    make(map[string]bool),
+		ResendInterval: resendInterval,
+	}
+	if policy.ResendInterval <= 0 {
+		policy.ResendInterval = time.Minute
+	}
+	for _, feature := range requiredFor {
+		feature = strings.TrimSpace(strings.ToLower(feature))
+		if feature == "" || feature == "none" {
+			continue
+		}
+		policy.RequiredFor[feature] = true
+	}
+	return policy
+}
+
+// Restricts trả về true nếu feature yêu cầu email đã xác thực
+func (p *VerificationPolicy) Restricts(feature string) bool {
+	return p != nil && p.RequiredFor[feature]
+}
+
+// verificationTokenTTL khớp với thời hạn của utils.GenerateEmailVerificationToken
+const verificationTokenTTL = 24 * time.Hour
+
+type VerificationService struct {
+	userRepo    repository.UserRepository
+	redisRepo   repository.RedisRepository
+	mailer      *mailer.TemplateMailer
+	policy      *VerificationPolicy
+	frontendURL string // URL frontend dùng cho các link gửi qua email
+}
+
+func NewVerificationService(userRepo repository.UserRepository, redisRepo repository.RedisRepository, mail *mailer.TemplateMailer, policy *VerificationPolicy, frontendURL string) *VerificationService {
+	return &VerificationService{
+		userRepo:    userRepo,
+		redisRepo:   redisRepo,
+		mailer:      mail,
+		policy:      policy,
+		frontendURL: frontendURL,
+	}
+}
+
+func (s *VerificationService) Policy() *VerificationPolicy {
+	return s.policy
+}
+
+// IsEmailVerified: email chỉ được coi là đã xác thực khi có email_verified_at, kể cả tài khoản
+// tạo bằng Google/OIDC (provider phải báo email đã xác thực lúc tạo tài khoản)
+func IsEmailVerified(user *models.User) bool {
+	return user.EmailVerifiedAt != nil
+}
+
+// SendVerificationEmail tạo token và gửi link xác thực tới email của user theo locale
+func (s *VerificationService) SendVerificationEmail(ctx context.Context, user *models.User, locale string) error {
+	token, err := utils.GenerateEmailVerificationToken(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create verification token: %w", err)
+	}
+	verifyLink := fmt.Sprintf("%s/verify-email?token=%s", s.frontendURL, token)
+	if err := s.mailer.SendTemplate(ctx, user.Email, locale, mailer.TemplateVerifyEmail, mailer.Data{
+		Name:      user.Name,
+		Link:      verifyLink,
+		ExpiresIn: verificationTokenTTL,
+	}); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "verification email sent", "user_id", user.ID)
+	return nil
+}
+
+// ResendVerificationEmail gửi lại email xác thực, giới hạn 1 lần mỗi ResendInterval.
+// Khi bị giới hạn trả về ErrVerificationThrottled kèm thời gian phải chờ.
+func (s *VerificationService) ResendVerificationEmail(ctx context.Context, userID uuid.UUID, locale string) (time.Duration, error) {
+	ctx, span := tracing.Start(ctx, "service", "VerificationService.ResendVerificationEmail")
+	defer span.End()
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get user: %w", err)
+	}
+	if IsEmailVerified(user) {
+		return 0, ErrEmailAlreadyVerified
+	}
+
+	ok, retryAfter, err := s.redisRepo.AcquireThrottle(ctx, "verify-email:"+user.ID.String(), s.policy.ResendInterval)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return retryAfter, ErrVerificationThrottled
+	}
+
+	return 0, s.SendVerificationEmail(ctx, user, locale)
+}
+
+// VerifyEmail kiểm tra token và đánh dấu email đã xác thực
+func (s *VerificationService) VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "service", "VerificationService.VerifyEmail")
+	defer span.End()
+	claims, err := utils.ValidateEmailVerificationToken(token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, claims.UserID)
+	if err != nil || user == nil {
+		return nil, errors.New("user not found")
+	}
+	if user.EmailVerifiedAt != nil {
+		return user, nil
+	}
+
+	now := time.Now()
+	if err := s.userRepo.MarkEmailVerified(ctx, user.ID, now); err != nil {
+		return nil, fmt.Errorf("failed to verify email: %w", err)
+	}
+	user.EmailVerifiedAt = &now
+	return user, nil
+}
+
+// IsVerified đọc trạng thái xác thực từ database (user trong cache Redis có thể đã cũ)
+func (s *VerificationService) IsVerified(ctx context.Context, userID uuid.UUID) (bool, error) {
+	ctx, span := tracing.Start(ctx, "service", "VerificationService.IsVerified")
+	defer span.End()
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if user == nil {
+		return false, errors.New("user not found")
+	}
+	return IsEmailVerified(user), nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"project/models"
+	"project/repository"
+	"project/utils"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVerificationPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      []string
+		restricted []string
+		allowed    []string
+	}{
+		{
+			name:       "Single feature",
+			value:      []string{"friend_invite"},
+			restricted: []string{FeatureFriendInvite},
+			allowed:    []string{FeatureSendMessage, FeatureUpload},
+		},
+		{
+			name:       "Multiple features with spaces",
+			value:      []string{" friend_invite ", "Upload"},
+			restricted: []string{FeatureFriendInvite, FeatureUpload},
+			allowed:    []string{FeatureSendMessage},
+		},
+		{
+			name:    "Disabled",
+			value:   []string{"none"},
+			allowed: []string{FeatureFriendInvite, FeatureSendMessage, FeatureUpload},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := ParseVerificationPolicy(tt.value, 0)
+			assert.Equal(t, time.Minute, policy.ResendInterval)
+			for _, feature := range tt.restricted {
+				assert.True(t, policy.Restricts(feature), feature)
+			}
+			for _, feature := range tt.allowed {
+				assert.False(t, policy.Restricts(feature), feature)
+			}
+		})
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	var markedID uuid.UUID
+
+	mockRepo := &repository.MockUserRepository{
+		MockGetUserByID: func(ctx context.Context, id uuid.UUID) (*models.User, error) {
+			return &models.User{ID: id, Provider: "local"}, nil
+		},
+		MockMarkEmailVerified: func(ctx context.Context, id uuid.UUID, verifiedAt time.Time) error {
+			markedID = id
+			return nil
+		},
+	}
+	service := NewVerificationService(mockRepo, nil, nil, ParseVerificationPolicy(nil, 0), "http://localhost:3000")
+
+	token, err := utils.GenerateEmailVerificationToken(userID)
+	assert.NoError(t, err)
+
+	user, err := service.VerifyEmail(ctx, token)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, markedID)
+	assert.NotNil(t, user.EmailVerifiedAt)
+
+	// Reset token không được dùng để xác thực email
+	resetToken, err := utils.GenerateResetToken(userID)
+	assert.NoError(t, err)
+	_, err = service.VerifyEmail(ctx, resetToken)
+	assert.Error(t, err)
+}
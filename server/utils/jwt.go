@@ -2,31 +2,25 @@ package utils
 
 import (
 	"errors"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
-// Lấy secret key từ biến môi trường. Rất quan trọng để giữ bí mật này an toàn!
-// Trong môi trường development, nó sẽ dùng một giá trị mặc định.
-var jwtSecretKey = []byte(getJWTSecret())
+// Secret key ký JWT, main gán từ config (auth.jwt_secret) lúc khởi động.
+// Rất quan trọng để giữ bí mật này an toàn! Giá trị mặc định chỉ dùng cho test.
+var jwtSecretKey = []byte("my-super-secret-key-for-dev")
 
-func getJWTSecret() string {
-	secret := os.Getenv("JWT_SECRET_KEY")
-	if secret == "" {
-		// Giá trị mặc định này KHÔNG an toàn cho production.
-		// Hãy chắc chắn rằng bạn đã đặt biến môi trường JWT_SECRET_KEY.
-		return "my-super-secret-key-for-dev"
-	}
-	return secret
+// SetJWTSecret đặt secret key ký JWT, phải gọi trước khi phục vụ request
+func SetJWTSecret(secret string) {
+	jwtSecretKey = []byte(secret)
 }
 
 // CustomClaims chứa dữ liệu của token (payload) và thời gian hết hạn.
 type CustomClaims struct {
 	UserID     uuid.UUID `json:"user_id"`
-	AuthMethod string    `json:"auth_method,omitempty"` // "local", "google", "reset"
+	AuthMethod string    `json:"auth_method,omitempty"` // "local", "google", "reset", "verify_email", "magic"
 	jwt.RegisteredClaims
 }
 
@@ -175,3 +169,87 @@ func ValidateResetToken(tokenString string) (*CustomClaims, error) {
 	}
 	return nil, errors.New("invalid reset token")
 }
+
+// GenerateEmailVerificationToken tạo token xác thực email (hết hạn sau 24 giờ)
+func GenerateEmailVerificationToken(userID uuid.UUID) (string, error) {
+	duration := 24 * time.Hour
+	claims := &CustomClaims{
+		UserID:     userID,
+		AuthMethod: "verify_email",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "web_chat_app_verify_email",
+			ID:        uuid.New().String(),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecretKey)
+}
+
+// ValidateEmailVerificationToken xác thực token xác thực email và trả về claims
+func ValidateEmailVerificationToken(tokenString string) (*CustomClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return jwtSecretKey, nil
+	})
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errors.New("verification token has expired")
+		}
+		return nil, errors.New("invalid verification token")
+	}
+	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
+		if claims.Issuer != "web_chat_app_verify_email" {
+			return nil, errors.New("invalid verification token issuer")
+		}
+		return claims, nil
+	}
+	return nil, errors.New("invalid verification token")
+}
+
+// GenerateMagicLinkToken tạo token đăng nhập không mật khẩu, trả về thêm jti để đánh dấu dùng 1 lần
+func GenerateMagicLinkToken(userID uuid.UUID, duration time.Duration) (string, string, error) {
+	jti := uuid.New().String()
+	claims := &CustomClaims{
+		UserID:     userID,
+		AuthMethod: "magic",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "web_chat_app_magic_link",
+			ID:        jti,
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(jwtSecretKey)
+	if err != nil {
+		return "", "", err
+	}
+	return signed, jti, nil
+}
+
+// ValidateMagicLinkToken xác thực magic link token và trả về claims
+func ValidateMagicLinkToken(tokenString string) (*CustomClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return jwtSecretKey, nil
+	})
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, errors.New("login link has expired")
+		}
+		return nil, errors.New("invalid login link")
+	}
+	if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
+		if claims.Issuer != "web_chat_app_magic_link" || claims.ID == "" {
+			return nil, errors.New("invalid login link issuer")
+		}
+		return claims, nil
+	}
+	return nil, errors.New("invalid login link")
+}
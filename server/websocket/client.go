@@ -1,13 +1,16 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
-	"log"
-	"project/repository"
+	"log/slog"
+	"project/pkg/tracing"
 
 	"time"
 
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -35,11 +38,20 @@ type Client struct {
 	Conn *websocket.Conn
 	Send chan []byte
 	Hub  *Hub
+	// Log mang request_id của request upgrade và user_id
+	Log *slog.Logger
 }
 
-func (c *Client) ReadPump() {
+func (c *Client) logger() *slog.Logger {
+	if c.Log == nil {
+		return slog.Default()
+	}
+	return c.Log
+}
+
+func (c *Client) ReadPump(ctx context.Context) {
 	defer func() {
-		c.Hub.unregister <- c
+		c.Hub.unregisterClient(c)
 		c.Conn.Close()
 	}()
 
@@ -51,7 +63,7 @@ func (c *Client) ReadPump() {
 		_, message, err := c.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("error: unexpected close: %v", err)
+				c.logger().Warn("websocket unexpected close", "err", err)
 			}
 			break
 		}
@@ -59,60 +71,82 @@ func (c *Client) ReadPump() {
 		// Basic validation: try to unmarshal into Message
 		var msg MessageWs
 		if err := json.Unmarshal(message, &msg); err != nil {
-			log.Println("invalid message format", err)
+			c.logger().Debug("invalid websocket message format", "err", err)
 			continue
 		}
-		//update online status
-		c.Hub.UpdateUserOnlineThrottled(c.ID, 10*time.Second)
-		switch msg.Type {
-		case "ping":
-			// Respond to ping
-			c.Conn.WriteMessage(websocket.PongMessage, nil)
-
-		case "chat":
-			if msg.To != "" {
-				c.Hub.SendToUser(msg.To, message)
-			}
-		case "notify_friend":
-			if msg.To != "" {
-				c.Hub.NotifyInviteFriend(msg.To, msg.Payload)
-			}
-		case "is_online":
-			// Handle is_online broadcast if needed
-			is_online, time_online, err := repository.NewRedisRepository().IsUserOnline(msg.To)
-			if err != nil {
-				log.Printf("failed to check online status for %s: %v", msg.To, err)
-				break
-			}
-			if is_online && time_online > 0 {
-				log.Printf("user %s is online, time online: %v", msg.To, time_online)
-			}
-			payload := map[string]interface{}{
-				"type":        "is_online_response",
-				"user_id":     msg.To,
-				"is_online":   is_online,
-				"time_online": time_online.Seconds(),
-			}
-			response, err := json.Marshal(payload)
-			if err != nil {
-				log.Printf("failed to marshal is_online response for %s: %v", msg.To, err)
-				break
-			}
-			log.Println("send to user ", msg.From)
-			go c.Hub.SendToUser(msg.From, response)
-		default:
-			b, _ := json.Marshal(MessageWs{
-				Type:    "error",
-				Payload: json.RawMessage(`"unknown message type"`),
-			})
-			c.Send <- b
-		}
+		c.handleMessage(ctx, &msg, message)
 		// If message has a "To" field, forward to specific user
 
 		// Otherwise broadcast to everyone (example)
 		// c.Hub.Broadcast <- message
 	}
 }
+
+// handleMessage xử lý 1 frame trong span riêng. Span là root mới (link tới span của request upgrade)
+// vì kết nối sống lâu, gom mọi frame vào 1 trace sẽ làm trace rất lớn.
+func (c *Client) handleMessage(ctx context.Context, msg *MessageWs, message []byte) {
+	// type do client gửi, chỉ dùng làm tên span khi là type đã biết
+	spanName := "ws.unknown"
+	switch msg.Type {
+	case "ping", "chat", "notify_friend", "is_online":
+		spanName = "ws." + msg.Type
+	}
+	ctx, span := tracing.Start(ctx, "websocket", spanName,
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(ctx)),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("ws.message.type", msg.Type), attribute.String("user.id", c.ID)),
+	)
+	defer span.End()
+
+	//update online status
+	c.Hub.UpdateUserOnlineThrottled(ctx, c.ID, 10*time.Second)
+	switch msg.Type {
+	case "ping":
+		// Respond to ping
+		c.Conn.WriteMessage(websocket.PongMessage, nil)
+
+	case "chat":
+		if msg.To != "" {
+			c.Hub.SendToUser(msg.To, message)
+		}
+	case "notify_friend":
+		if msg.To != "" {
+			c.Hub.NotifyInviteFriend(msg.To, msg.Payload)
+		}
+	case "is_online":
+		// Handle is_online broadcast if needed
+		is_online, time_online, err := c.Hub.presence.IsUserOnline(ctx, msg.To)
+		if err != nil {
+			c.logger().Warn("failed to check online status", "target_id", msg.To, "err", err)
+			tracing.RecordError(span, err)
+			return
+		}
+		if is_online && time_online > 0 {
+			c.logger().Debug("user is online", "target_id", msg.To, "time_online", time_online)
+		}
+		payload := map[string]interface{}{
+			"type":        "is_online_response",
+			"user_id":     msg.To,
+			"is_online":   is_online,
+			"time_online": time_online.Seconds(),
+		}
+		response, err := json.Marshal(payload)
+		if err != nil {
+			c.logger().Error("failed to marshal is_online response", "target_id", msg.To, "err", err)
+			return
+		}
+		go c.Hub.SendToUser(msg.From, response)
+	default:
+		b, _ := json.Marshal(MessageWs{
+			Type:    "error",
+			Payload: json.RawMessage(`"unknown message type"`),
+		})
+		// Qua hub để không gửi vào Send đã bị đóng khi server tắt
+		c.Hub.SendToUser(c.ID, b)
+	}
+}
+
 func (c *Client) WritePump() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
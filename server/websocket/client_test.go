@@ -0,0 +1,75 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"project/pkg/tracing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestReadPumpTracesFrames(t *testing.T) {
+	exporter, restore := tracing.UseInMemory()
+	t.Cleanup(restore)
+	presence := newFakePresence()
+	presence.err = errors.New("redis: connection refused")
+	hub := NewHub(presence)
+	go hub.Run()
+	t.Cleanup(hub.Shutdown)
+
+	// Span của request upgrade, frame span phải link tới span này
+	upgradeCtx, upgradeSpan := tracing.Start(context.Background(), "test", "GET /ws")
+	upgradeSpan.End()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := &Client{ID: "user-1", Hub: hub, Conn: conn, Send: make(chan []byte, 256)}
+		if !hub.registerClient(client) {
+			conn.Close()
+			return
+		}
+		go client.ReadPump(upgradeCtx)
+		client.WritePump()
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"is_online","to":"user-2"}`)))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"<script>"}`)))
+
+	var frames tracetest.SpanStubs
+	require.Eventually(t, func() bool {
+		frames = nil
+		for _, s := range exporter.GetSpans() {
+			if strings.HasPrefix(s.Name, "ws.") {
+				frames = append(frames, s)
+			}
+		}
+		return len(frames) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	isOnline := frames[0]
+	assert.Equal(t, "ws.is_online", isOnline.Name)
+	assert.False(t, isOnline.Parent.IsValid())
+	require.Len(t, isOnline.Links, 1)
+	assert.Equal(t, upgradeSpan.SpanContext().SpanID(), isOnline.Links[0].SpanContext.SpanID())
+	assert.NotEqual(t, upgradeSpan.SpanContext().TraceID(), isOnline.SpanContext.TraceID())
+	// Presence lỗi nên span frame bị đánh dấu lỗi
+	assert.Equal(t, "Error", isOnline.Status.Code.String())
+
+	assert.Equal(t, "ws.unknown", frames[1].Name)
+}
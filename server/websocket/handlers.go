@@ -1,8 +1,10 @@
 package websocket
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
+	"project/pkg/logging"
 	"project/service"
 
 	"github.com/gin-gonic/gin"
@@ -26,64 +28,68 @@ var upgrader = websocket.Upgrader{
 	WriteBufferSize: 1024,
 	CheckOrigin: func(r *http.Request) bool {
 		origin := r.Header.Get("Origin")
-		log.Printf("🔍 [WebSocket] Connection from origin: %s", origin)
+		slog.DebugContext(r.Context(), "websocket connection", "origin", origin)
 		return true
 	},
 }
 
 func (h *WsHandler) ServeWs() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		log.Println("🔌 [ServeWs] WebSocket connection attempt")
+		ctx := c.Request.Context()
+		// Logger của session mang request_id của request upgrade suốt vòng đời kết nối
+		logger := slog.With("request_id", logging.RequestID(c.Request.Context()))
+		logger.Debug("websocket connection attempt")
 
 		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
-			log.Printf("❌ [ServeWs] Upgrade error: %v", err)
+			logger.Warn("websocket upgrade failed", "err", err)
 			return
 		}
 
-		log.Println("✅ [ServeWs] WebSocket upgrade successful")
-
 		token := c.Query("token")
-		log.Printf("🔑 [ServeWs] Received token: %s", token)
-
 		if token == "" {
-			log.Println("❌ [ServeWs] Missing token in query parameters")
+			logger.Warn("websocket rejected: missing token")
 			conn.WriteMessage(websocket.CloseMessage,
 				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Missing token"))
 			conn.Close()
 			return
 		}
 
-		// log.Printf("🔐 [ServeWs] Verifying token for WebSocket connection: %s", token)
-		user, _, err := h.AuthService.VerifyAccessToken(token)
+		user, _, err := h.AuthService.VerifyAccessToken(ctx, token)
 		if err != nil {
-			log.Printf("❌ [ServeWs] Authentication failed: %v", err)
+			logger.Warn("websocket rejected: authentication failed", "err", err)
 			conn.WriteMessage(websocket.CloseMessage,
 				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Authentication failed"))
 			conn.Close()
 			return
 		}
-		log.Printf("✅ [ServeWs] User %s authenticated and connected", user.ID.String())
+		logger = logger.With("user_id", user.ID.String())
 
 		client := &Client{
 			ID:   user.ID.String(),
 			Hub:  h.Hub,
 			Conn: conn,
 			Send: make(chan []byte, 256),
+			Log:  logger,
 		}
 
-		log.Printf("📝 [ServeWs] Registering client %s with hub", user.ID.String())
-		client.Hub.register <- client
-
-		log.Printf("🚀 [ServeWs] Starting pumps for client %s", user.ID.String())
+		if !client.Hub.registerClient(client) {
+			logger.Warn("websocket rejected: hub stopped")
+			conn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseServiceRestart, shutdownCloseReason))
+			conn.Close()
+			return
+		}
+		logger.Info("websocket connected")
 
 		// FIX: Start ReadPump as goroutine, WritePump blocks
-		go client.ReadPump()
+		// ctx của request upgrade bị hủy khi handler trả về, giữ lại trace và request_id nhưng bỏ cancel
+		go client.ReadPump(context.WithoutCancel(ctx))
 
 		// WritePump MUST block to keep the HTTP handler alive
 		// When WritePump returns, the connection is closed
 		client.WritePump()
 
-		log.Printf("🔌 [ServeWs] Connection closed for user %s", user.ID.String())
+		logger.Info("websocket closed")
 	}
 }
@@ -5,14 +5,20 @@
 package websocket
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
-	"project/repository"
+	"log/slog"
+	"project/pkg/metrics"
 	"sync"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
+// Lý do gửi kèm close frame khi server tắt, client nên kết nối lại
+const shutdownCloseReason = "server restarting, reconnect"
+
 // Message represents a message sent to clients.
 
 // Hub maintains the set of active clients and broadcasts messages to the
@@ -32,36 +38,51 @@ type Hub struct {
 
 	lastUpdateMu sync.RWMutex
 	lastUpdate   map[string]time.Time // userID -> last update timestamp
+
+	// quit yêu cầu Run dừng, done đóng khi Run đã dừng và mọi client đã được đóng
+	quit     chan struct{}
+	done     chan struct{}
+	quitOnce sync.Once
+
+	// presence lưu trạng thái online (Redis ở production)
+	presence PresenceRepository
 }
 
-func NewHub() *Hub {
+func NewHub(presence PresenceRepository) *Hub {
 	return &Hub{
+		presence:   presence,
 		clients:    make(map[string]*Client),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		Broadcast:  make(chan []byte),
 		lastUpdate: make(map[string]time.Time),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
 	}
 }
 
 func (h *Hub) Run() {
 	cleanupTicker := time.NewTicker(5 * time.Minute)
 	defer cleanupTicker.Stop()
+	defer close(h.done)
 	for {
 		select {
+		case <-h.quit:
+			h.closeAll()
+			return
+
 		case client := <-h.register:
 			h.clientsMu.Lock()
 			h.clients[client.ID] = client
 			h.clientsMu.Unlock()
-			log.Printf("client registered: %s", client.ID)
+			client.logger().Debug("client registered")
 			// Update user online status asynchronously if repository exposes such method.
 			go func(user_id string) {
 				// If repository.RedisRepository provides UpdateUserOnlineStatus, uncomment and use it:
-				err := repository.NewRedisRepository().UpdateUserOnline(user_id)
+				err := h.presence.UpdateUserOnline(context.Background(), user_id)
 				if err != nil {
-					log.Printf("failed to update user online status for %s: %v", user_id, err)
+					slog.Error("update user online status failed", "user_id", user_id, "err", err)
 				}
-				log.Printf("updateUserOnlineStatus not called for: %s (method not found)", user_id)
 			}(client.ID)
 
 		case client := <-h.unregister:
@@ -71,7 +92,7 @@ func (h *Hub) Run() {
 				close(client.Send)
 			}
 			h.clientsMu.Unlock()
-			log.Printf("client unregistered: %s", client.ID)
+			client.logger().Debug("client unregistered")
 
 		case message := <-h.Broadcast:
 			// h.clientsMu.RLock()
@@ -85,7 +106,7 @@ func (h *Hub) Run() {
 			// 	}
 			// }
 			// h.clientsMu.RUnlock()
-			println(message)
+			slog.Debug("broadcast message ignored", "bytes", len(message))
 			continue
 		case <-cleanupTicker.C:
             h.cleanupLastUpdate()
@@ -93,6 +114,69 @@ func (h *Hub) Run() {
 		}
 	}
 }
+
+// Shutdown dừng Run, gửi close frame "server restarting" tới mọi client rồi đóng kết nối.
+// Gọi nhiều lần được, chặn tới khi Run kết thúc (Run phải đang chạy).
+func (h *Hub) Shutdown() {
+	h.quitOnce.Do(func() { close(h.quit) })
+	<-h.done
+}
+
+// closeAll lấy hết client ra khỏi map trước để SendToUser không gửi vào channel sắp đóng
+func (h *Hub) closeAll() {
+	h.clientsMu.Lock()
+	clients := make([]*Client, 0, len(h.clients))
+	for id, c := range h.clients {
+		clients = append(clients, c)
+		delete(h.clients, id)
+	}
+	h.clientsMu.Unlock()
+
+	msg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, shutdownCloseReason)
+	for _, c := range clients {
+		if err := c.Conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait)); err != nil {
+			c.logger().Warn("send close frame failed", "err", err)
+		}
+		// WritePump thấy channel đóng sẽ thoát và đóng kết nối
+		close(c.Send)
+	}
+	slog.Info("closed websocket clients for shutdown", "count", len(clients))
+}
+
+// registerClient trả về false nếu hub đã dừng
+func (h *Hub) registerClient(c *Client) bool {
+	select {
+	case h.register <- c:
+		return true
+	case <-h.done:
+		return false
+	}
+}
+
+// unregisterClient không chặn khi hub đã dừng (client đã được closeAll đóng)
+func (h *Hub) unregisterClient(c *Client) {
+	select {
+	case h.unregister <- c:
+	case <-h.done:
+	}
+}
+
+// Stats cho /metrics: số kết nối và độ đầy send channel của từng client
+func (h *Hub) Stats() metrics.HubStats {
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+	stats := metrics.HubStats{
+		Connections:     len(h.clients),
+		QueueSaturation: make([]float64, 0, len(h.clients)),
+	}
+	for _, c := range h.clients {
+		if cap(c.Send) > 0 {
+			stats.QueueSaturation = append(stats.QueueSaturation, float64(len(c.Send))/float64(cap(c.Send)))
+		}
+	}
+	return stats
+}
+
 func (h *Hub) cleanupLastUpdate() {
 
 	h.lastUpdateMu.Lock()
@@ -108,14 +192,14 @@ func (h *Hub) cleanupLastUpdate() {
 	h.clientsMu.RUnlock()
 	h.lastUpdateMu.Unlock()
 
-	log.Printf("🧹 [Hub] Cleaned up stale lastUpdate entries")
+	slog.Debug("cleaned up stale presence entries")
 }
 
 func (h *Hub) SendToUser(userID string, message []byte) error {
-	print("Sending message to user:", userID)
+	// Giữ RLock khi gửi để channel không bị đóng giữa chừng (unregister/closeAll cần Lock)
 	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
 	c, ok := h.clients[userID]
-	h.clientsMu.RUnlock()
 	if !ok {
 		return errors.New("user not connected")
 	}
@@ -124,23 +208,24 @@ func (h *Hub) SendToUser(userID string, message []byte) error {
 	case c.Send <- message:
 		return nil
 	default:
+		metrics.WSDroppedMessages.Inc()
 		return errors.New("user send channel full")
 	}
 }
 func (h *Hub) SendToUsers(users []string, message []byte) error {
 	var failed []string
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
 	for _, userID := range users {
-		h.clientsMu.RLock()
 		c, ok := h.clients[userID]
-		h.clientsMu.RUnlock()
 		if !ok {
 			failed = append(failed, userID)
 			continue
 		}
 		select {
 		case c.Send <- message:
-			println("send message success")
 		default:
+			metrics.WSDroppedMessages.Inc()
 			failed = append(failed, userID)
 		}
 	}
@@ -152,8 +237,8 @@ func (h *Hub) SendToUsers(users []string, message []byte) error {
 
 func (h *Hub) NotifyInviteFriend(userID string, message []byte) error {
 	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
 	c, ok := h.clients[userID]
-	h.clientsMu.RUnlock()
 	if !ok {
 		return errors.New("user not connected")
 	}
@@ -161,11 +246,12 @@ func (h *Hub) NotifyInviteFriend(userID string, message []byte) error {
 	case c.Send <- message:
 		return nil
 	default:
+		metrics.WSDroppedMessages.Inc()
 		return errors.New("user send channel full")
 	}
 }
 
-func (h *Hub) UpdateUserOnlineThrottled(userID string, threshold time.Duration) {
+func (h *Hub) UpdateUserOnlineThrottled(ctx context.Context, userID string, threshold time.Duration) {
 	h.lastUpdateMu.Lock()
 	lastTime, exists := h.lastUpdate[userID]
 	now := time.Now()
@@ -182,10 +268,10 @@ func (h *Hub) UpdateUserOnlineThrottled(userID string, threshold time.Duration)
 
 	// Update Redis (non-blocking)
 	go func(uid string) {
-		if err := repository.NewRedisRepository().UpdateUserOnline(uid); err != nil {
-			log.Printf("⚠️ [Hub] Failed to update online for %s: %v", uid, err)
+		if err := h.presence.UpdateUserOnline(ctx, uid); err != nil {
+			slog.ErrorContext(ctx, "update user online status failed", "user_id", uid, "err", err)
 		} else {
-			log.Printf("🔄 [Hub] Online status updated for %s", uid)
+			slog.DebugContext(ctx, "user online status updated", "user_id", uid)
 		}
 	}(userID)
 }
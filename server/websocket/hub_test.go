@@ -0,0 +1,129 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePresence thay Redis cho hub trong test, mỗi hub có presence riêng
+type fakePresence struct {
+	mu     sync.Mutex
+	online map[string]bool
+	err    error
+}
+
+func newFakePresence() *fakePresence {
+	return &fakePresence{online: make(map[string]bool)}
+}
+
+func (p *fakePresence) UpdateUserOnline(ctx context.Context, userID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.online[userID] = true
+	return nil
+}
+
+func (p *fakePresence) IsUserOnline(ctx context.Context, userID string) (bool, time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return false, 0, p.err
+	}
+	if !p.online[userID] {
+		return false, 0, nil
+	}
+	return true, time.Minute, nil
+}
+
+func (p *fakePresence) isOnline(userID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.online[userID]
+}
+
+// serveTestClient đăng ký client với hub giống ServeWs nhưng bỏ qua xác thực
+func serveTestClient(t *testing.T, hub *Hub) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := &Client{ID: r.URL.Query().Get("id"), Hub: hub, Conn: conn, Send: make(chan []byte, 256)}
+		if !hub.registerClient(client) {
+			conn.Close()
+			return
+		}
+		go client.ReadPump(context.Background())
+		client.WritePump()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHubShutdownSendsRestartClose(t *testing.T) {
+	presence := newFakePresence()
+	hub := NewHub(presence)
+	go hub.Run()
+	srv := serveTestClient(t, hub)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?id=user-1", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Eventually(t, func() bool {
+		return hub.SendToUser("user-1", []byte(`{"type":"hello"}`)) == nil
+	}, time.Second, 10*time.Millisecond)
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"hello"}`, string(msg))
+
+	hub.Shutdown()
+	hub.Shutdown() // gọi lại không bị chặn
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.CloseServiceRestart, closeErr.Code)
+	assert.Equal(t, shutdownCloseReason, closeErr.Text)
+	assert.Error(t, hub.SendToUser("user-1", []byte("late")))
+
+	// Client mới sau khi hub dừng bị từ chối thay vì treo
+	conn2, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?id=user-2", nil)
+	require.NoError(t, err)
+	defer conn2.Close()
+	conn2.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn2.ReadMessage()
+	assert.Error(t, err)
+}
+
+func TestHubsUseOwnPresence(t *testing.T) {
+	// 2 hub trong cùng process không chia sẻ trạng thái online
+	presenceA, presenceB := newFakePresence(), newFakePresence()
+	hubA, hubB := NewHub(presenceA), NewHub(presenceB)
+	go hubA.Run()
+	go hubB.Run()
+	t.Cleanup(hubA.Shutdown)
+	t.Cleanup(hubB.Shutdown)
+
+	srv := serveTestClient(t, hubA)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?id=user-1", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Eventually(t, func() bool { return presenceA.isOnline("user-1") }, time.Second, 10*time.Millisecond)
+	assert.False(t, presenceB.isOnline("user-1"))
+}
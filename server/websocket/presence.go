@@ -0,0 +1,13 @@
+package websocket
+
+import (
+	"context"
+	"time"
+)
+
+// PresenceRepository là phần của repository.RedisRepository mà hub cần để theo dõi trạng thái online.
+// Hub nhận dependency này qua NewHub nên test có thể chạy nhiều hub độc lập trong cùng 1 process.
+type PresenceRepository interface {
+	UpdateUserOnline(ctx context.Context, userID string) error
+	IsUserOnline(ctx context.Context, userID string) (bool, time.Duration, error)
+}